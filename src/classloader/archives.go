@@ -11,6 +11,8 @@ import (
 	"fmt"
 	"io"
 	"jacobin/log"
+	"net/url"
+	"path/filepath"
 	"strings"
 )
 
@@ -70,7 +72,7 @@ func (archive *Archive) scanArchive() error {
 	for _, file := range reader.File {
 		entry := archive.recordFile(file)
 		if entry.Type == Manifest {
-			if archive.parseManifest(file); err != nil {
+			if err = archive.parseManifest(file); err != nil {
 				return err
 			}
 		}
@@ -104,27 +106,49 @@ func (archive *Archive) recordFile(file *zip.File) ResourceEntry {
 
 func (archive *Archive) parseManifest(file *zip.File) error {
 	rc, err := file.Open()
-
-	defer rc.Close()
-
 	if err != nil {
 		return err
 	}
+	defer rc.Close()
 
 	data, err := io.ReadAll(rc)
+	if err != nil {
+		return err
+	}
 
-	contents := string(data)
-
-	lines := strings.Split(contents, "\r\n")
+	archive.parseManifestContents(string(data))
+	return nil
+}
 
-	for _, line := range lines {
-		parts := strings.Split(line, ":")
-		if len(parts) > 1 {
-			archive.manifest[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+// parseManifestContents records the attributes in the main section of a manifest,
+// which ends at the first blank line. Per the JAR specification, a line that begins
+// with a space continues the previous line (lines are limited to 72 bytes), and
+// lines can end with CR LF, LF, or CR.
+func (archive *Archive) parseManifestContents(contents string) {
+	contents = strings.ReplaceAll(contents, "\r\n", "\n")
+	contents = strings.ReplaceAll(contents, "\r", "\n")
+
+	var attributes []string
+	for _, line := range strings.Split(contents, "\n") {
+		if strings.HasPrefix(line, " ") && len(attributes) > 0 {
+			attributes[len(attributes)-1] += line[1:]
+			continue
 		}
+		if line == "" {
+			if len(attributes) > 0 {
+				break // the end of the main section
+			}
+			continue
+		}
+		attributes = append(attributes, line)
 	}
 
-	return nil
+	for _, attribute := range attributes {
+		name, value, found := strings.Cut(attribute, ":")
+		if found {
+			archive.manifest[strings.TrimSpace(name)] = strings.TrimSpace(value)
+		}
+	}
 }
 
 func (archive *Archive) hasResource(name string, resourceType ResourceType) bool {
@@ -174,6 +198,28 @@ func (archive *Archive) loadClass(className string) (*LoadResult, error) {
 	return &LoadResult{Data: &bytes, Success: true, ResourceEntry: item}, nil
 }
 
+// getClassPath returns the JARs and directories listed in the Class-Path attribute of
+// the manifest. The entries are URLs separated by spaces, which are relative to the
+// location of this JAR, unless they're absolute file: URLs. Entries with other schemes
+// and malformed entries are ignored, as they are by the JDK.
+func (archive *Archive) getClassPath() []string {
+	var entries []string
+	for _, entry := range strings.Fields(archive.manifest["Class-Path"]) {
+		u, err := url.Parse(entry)
+		if err != nil || (u.Scheme != "" && u.Scheme != "file") {
+			_ = log.Log("Ignoring invalid Class-Path entry "+entry+" in "+archive.Filename, log.WARNING)
+			continue
+		}
+
+		path := filepath.FromSlash(u.Path)
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(filepath.Dir(archive.Filename), path)
+		}
+		entries = append(entries, path)
+	}
+	return entries
+}
+
 func (archive *Archive) getMainClass() string {
 	mainClass, exists := archive.manifest["Main-Class"]
 
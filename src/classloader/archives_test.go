@@ -7,6 +7,7 @@
 package classloader
 
 import (
+	"archive/zip"
 	"os"
 	"path/filepath"
 	"testing"
@@ -102,3 +103,93 @@ func TestLoadClassDoesNotExist(t *testing.T) {
 		t.Error("Expected error loading class, but didn't get one.")
 	}
 }
+
+func TestManifestContinuationLines(t *testing.T) {
+	archive := Archive{Filename: "app.jar", manifest: make(map[string]string)}
+	archive.parseManifestContents("Manifest-Version: 1.0\r\n" +
+		"Main-Class: com.example.app.Applicati\r\n" +
+		" onMain\r\n" +
+		"Class-Path: lib/first.jar\r\n" +
+		"  lib/second.jar\n" +
+		"\r\n" +
+		"Name: com/example/app/\r\n" +
+		"Main-Class: com.example.app.Other\r\n")
+
+	if mainClass := archive.getMainClass(); mainClass != "com.example.app.ApplicationMain" {
+		t.Errorf("Expected Main-Class to be com.example.app.ApplicationMain, got: %s", mainClass)
+	}
+	if classPath := archive.manifest["Class-Path"]; classPath != "lib/first.jar lib/second.jar" {
+		t.Errorf("Expected Class-Path to be 'lib/first.jar lib/second.jar', got: '%s'", classPath)
+	}
+}
+
+func TestManifestClassPath(t *testing.T) {
+	dir := filepath.Join("opt", "app")
+	absolute, _ := filepath.Abs(filepath.Join("shared", "common.jar"))
+	archive := Archive{Filename: filepath.Join(dir, "app.jar"), manifest: make(map[string]string)}
+	archive.manifest["Class-Path"] = "lib/dep.jar  ../my%20libs/util.jar classes/ http://example.com/remote.jar " +
+		"file://" + filepath.ToSlash(absolute)
+
+	expected := []string{
+		filepath.Join(dir, "lib", "dep.jar"),
+		filepath.Join("opt", "my libs", "util.jar"),
+		filepath.Join(dir, "classes"),
+		absolute,
+	}
+	entries := archive.getClassPath()
+	if len(entries) != len(expected) {
+		t.Fatalf("Expected Class-Path entries %v, got: %v", expected, entries)
+	}
+	for i := range expected {
+		if entries[i] != expected[i] {
+			t.Errorf("Expected Class-Path entry %d to be %s, got: %s", i, expected[i], entries[i])
+		}
+	}
+}
+
+// the Class-Path entries are resolved against the location of the JAR
+func TestGetClasspathFromJar(t *testing.T) {
+	dir := t.TempDir()
+	jarFile := filepath.Join(dir, "app.jar")
+	out, err := os.Create(jarFile)
+	if err != nil {
+		t.Fatalf("Unable to create test JAR: %s", err.Error())
+	}
+	writer := zip.NewWriter(out)
+	manifest, _ := writer.Create("META-INF/MANIFEST.MF")
+	_, _ = manifest.Write([]byte("Manifest-Version: 1.0\r\nMain-Class: app.Main\r\nClass-Path: lib/dep.jar\r\n\r\n"))
+	_ = writer.Close()
+	_ = out.Close()
+
+	cl := Classloader{Name: "app", Archives: make(map[string]*Archive)}
+	entries, err := GetClasspathFromJar(cl, jarFile)
+	if err != nil {
+		t.Fatalf("Unexpected error reading the Class-Path of %s: %s", jarFile, err.Error())
+	}
+	if len(entries) != 1 || entries[0] != filepath.Join(dir, "lib", "dep.jar") {
+		t.Errorf("Expected the Class-Path to be %s, got: %v", filepath.Join(dir, "lib", "dep.jar"), entries)
+	}
+}
+
+// Goroutines that load classes at the same time share the classloader's archives
+func TestGetJarFileConcurrently(t *testing.T) {
+	jarFile, err := getJarFileName(GOOD_JAR_NAME)
+	if err != nil {
+		t.Fatalf("Unable to get jar file: %s", err.Error())
+	}
+	cl := Classloader{Name: "app", Archives: make(map[string]*Archive)}
+
+	jars := make(chan *Archive, 4)
+	for i := 0; i < 4; i++ {
+		go func() {
+			jar, _ := getJarFile(cl, jarFile)
+			jars <- jar
+		}()
+	}
+	first := <-jars
+	for i := 1; i < 4; i++ {
+		if jar := <-jars; jar == nil || jar != first {
+			t.Error("Expected every goroutine to get the same archive")
+		}
+	}
+}
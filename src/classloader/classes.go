@@ -11,12 +11,14 @@ import (
 	"fmt"
 	"jacobin/log"
 	"jacobin/shutdown"
+	"strings"
 )
 
 type Klass struct {
 	Status byte // I=Initializing,F=formatChecked,V=verified,L=linked,N=instantiated
 	Loader string
 	Data   *ClData
+	load   *classLoad // set only in the placeholder of a class that is being loaded
 }
 
 type ClData struct {
@@ -32,6 +34,8 @@ type ClData struct {
 	Bootstraps []BootstrapMethod
 	CP         CPool
 	Access     AccessFlags
+	VTable     *VTable // the virtual method table, built when first needed
+	Version    int     // the major version of the class file, e.g., 61 for Java 17
 }
 
 type CPool struct {
@@ -51,6 +55,7 @@ type CPool struct {
 	NameAndTypes   []NameAndTypeEntry
 	//	StringRefs     []uint16 // all StringRefs are converted into utf8Refs
 	Utf8Refs []string
+	Strings  []uint16 // the CP indexes of the String constants, which have become UTF8 entries
 }
 
 type AccessFlags struct {
@@ -94,8 +99,16 @@ type CodeAttrib struct {
 	MaxStack   int
 	MaxLocals  int
 	Code       []byte
-	Exceptions []CodeException // exception entries for this method
-	Attributes []Attr          // the code attributes has its own sub-attributes(!)
+	Exceptions []CodeException        // exception entries for this method
+	Attributes []Attr                 // the code attributes has its own sub-attributes(!)
+	LineTable  []BytecodeToSourceLine // from the LineNumberTable sub-attribute, if present
+}
+
+// BytecodeToSourceLine maps the first bytecode generated from a line of source
+// code to the number of that line. Used chiefly in stack traces.
+type BytecodeToSourceLine struct {
+	BytecodePos int // first instruction generated from the source line
+	SourceLine  int // the line number in the source file
 }
 
 // ParamAttrib is the MethodParameters method attribute
@@ -195,6 +208,7 @@ type InvokeDynamicEntry struct { // type 18 (invokedynamic data)
 // If it finds it there, then it loads that class into the MTable and returns that
 // entry as the Method it's returning.
 func FetchMethodAndCP(class, meth string, methType string) (MTentry, error) {
+	origFQN := class + "." + meth + methType // the method as requested, before any superclass search
 
 	for {
 	startSearch:
@@ -210,12 +224,17 @@ func FetchMethodAndCP(class, meth string, methType string) (MTentry, error) {
 		}
 
 		methFQN := class + "." + meth + methType // FQN = fully qualified name
-		methEntry := MTable[methFQN]
+		methEntry := MTableFetch(methFQN)
 
 		if methEntry.Meth != nil { // we found the entry in the MTable
 			if methEntry.MType == 'J' {
 				return MTentry{Meth: methEntry.Meth, MType: 'J'}, nil
 			} else if methEntry.MType == 'G' {
+				// Go methods are looked up by the name of the class they were invoked on,
+				// so an inherited Go method is also entered under that name
+				if methFQN != origFQN {
+					addEntry(&MTable, origFQN, methEntry)
+				}
 				return MTentry{Meth: methEntry.Meth, MType: 'G'}, nil
 			}
 		}
@@ -250,22 +269,11 @@ func FetchMethodAndCP(class, meth string, methType string) (MTentry, error) {
 		for i := 0; i < len(k.Data.Methods); i++ {
 			if k.Data.CP.Utf8Refs[k.Data.Methods[i].Name] == meth &&
 				k.Data.CP.Utf8Refs[k.Data.Methods[i].Desc] == methType {
-				m := k.Data.Methods[i]
-				jme := JmEntry{
-					accessFlags: m.AccessFlags,
-					MaxStack:    m.CodeAttr.MaxStack,
-					MaxLocals:   m.CodeAttr.MaxLocals,
-					Code:        m.CodeAttr.Code,
-					exceptions:  m.CodeAttr.Exceptions,
-					attribs:     m.CodeAttr.Attributes,
-					params:      m.Parameters,
-					deprecated:  m.Deprecated,
-					Cp:          &k.Data.CP,
-				}
-				MTable[methFQN] = MTentry{
+				jme := newJmEntry(&k.Data.Methods[i], k)
+				addEntry(&MTable, methFQN, MTentry{
 					Meth:  jme,
 					MType: 'J',
-				}
+				})
 				return MTentry{Meth: jme, MType: 'J'}, nil
 			}
 		}
@@ -292,6 +300,193 @@ func FetchMethodAndCP(class, meth string, methType string) (MTentry, error) {
 	return MTentry{}, errors.New("method not found") // dummy return needed for tests
 }
 
+// creates the MTable entry for a method of class k that's executed as bytecodes
+func newJmEntry(m *Method, k *Klass) JmEntry {
+	return JmEntry{
+		accessFlags: m.AccessFlags,
+		MaxStack:    m.CodeAttr.MaxStack,
+		MaxLocals:   m.CodeAttr.MaxLocals,
+		Code:        m.CodeAttr.Code,
+		Exceptions:  m.CodeAttr.Exceptions,
+		LineTable:   m.CodeAttr.LineTable,
+		attribs:     m.CodeAttr.Attributes,
+		params:      m.Parameters,
+		deprecated:  m.Deprecated,
+		Cp:          &k.Data.CP,
+	}
+}
+
+// FetchInterfaceMethod selects the method to run when an interface method is invoked
+// (by INVOKEINTERFACE) on an object of class class. Per the JVM spec's selection rules,
+// https://docs.oracle.com/javase/specs/jvms/se17/html/jvms-5.html#jvms-5.4.6
+// the method is searched for first in the class and then in its superclasses. If it's
+// not found there, the superinterfaces of all those classes are searched and the
+// default method of the most specific interface is chosen. It returns the method
+// and the name of the class or interface that declares it. If no non-abstract method
+// is found, the returned error is an *UnresolvedMethodError.
+func FetchInterfaceMethod(class, meth, methType string) (MTentry, string, error) {
+	var classes []*Klass // the class and its superclasses
+	for c := class; c != ""; {
+		k, err := fetchLoadedClass(c)
+		if err != nil {
+			return MTentry{}, "", err
+		}
+
+		mte, found := fetchImplementedMethod(c, k, meth, methType)
+		if found {
+			return mte, c, nil
+		}
+
+		classes = append(classes, k)
+		if c == "java/lang/Object" {
+			break
+		}
+		c = k.Data.Superclass
+	}
+
+	// gather all the superinterfaces that declare the method, whether as an
+	// abstract method or a default method
+	var declaring []string
+	visited := make(map[string]bool)
+	var toVisit []string
+	for _, k := range classes {
+		toVisit = append(toVisit, interfaceNames(k)...)
+	}
+	for len(toVisit) > 0 {
+		intf := toVisit[0]
+		toVisit = toVisit[1:]
+		if visited[intf] {
+			continue
+		}
+		visited[intf] = true
+
+		k, err := fetchLoadedClass(intf)
+		if err != nil {
+			return MTentry{}, "", err
+		}
+		if MTableFetch(intf+"."+meth+methType).Meth != nil || findMethod(k, meth, methType) != nil {
+			declaring = append(declaring, intf)
+		}
+		toVisit = append(toVisit, interfaceNames(k)...)
+	}
+
+	// the most specific declarations are those in interfaces that are not
+	// superinterfaces of another declaring interface. Among those, use the
+	// one that provides an implementation.
+	for _, intf := range declaring {
+		moreSpecificFound := false
+		for _, other := range declaring {
+			if other != intf && isSuperinterfaceOf(intf, other) {
+				moreSpecificFound = true
+				break
+			}
+		}
+		if moreSpecificFound {
+			continue
+		}
+
+		k := MethAreaFetch(intf)
+		mte, found := fetchImplementedMethod(intf, k, meth, methType)
+		if found {
+			return mte, intf, nil
+		}
+	}
+
+	return MTentry{}, "", &UnresolvedMethodError{
+		Class: class, Meth: meth, MethType: methType, IsDeclared: len(declaring) > 0}
+}
+
+// UnresolvedMethodError is returned by FetchInterfaceMethod() when no implementation
+// of the method is found. IsDeclared is true if the method is declared by an
+// interface the class implements, in which case the JVM throws AbstractMethodError,
+// rather than IncompatibleClassChangeError.
+type UnresolvedMethodError struct {
+	Class      string
+	Meth       string
+	MethType   string
+	IsDeclared bool
+}
+
+func (e *UnresolvedMethodError) Error() string {
+	return "Receiver class " + strings.ReplaceAll(e.Class, "/", ".") +
+		" does not define or inherit an implementation of the resolved method '" +
+		e.Meth + e.MethType + "'"
+}
+
+// fetchImplementedMethod returns the MTable entry for the method if class declares it
+// and it's not abstract (or static). If the entry is not yet in the MTable, it's added.
+func fetchImplementedMethod(class string, k *Klass, meth, methType string) (MTentry, bool) {
+	methFQN := class + "." + meth + methType
+	mte := MTableFetch(methFQN)
+	if mte.Meth != nil {
+		return mte, true
+	}
+
+	m := findMethod(k, meth, methType)
+	if m == nil || m.AccessFlags&(0x0400|0x0008) != 0 { // ACC_ABSTRACT or ACC_STATIC
+		return MTentry{}, false
+	}
+
+	mte = MTentry{Meth: newJmEntry(m, k), MType: 'J'}
+	addEntry(&MTable, methFQN, mte)
+	return mte, true
+}
+
+// findMethod returns the method of class k with the given name and type, or nil if
+// the class does not declare it
+func findMethod(k *Klass, meth, methType string) *Method {
+	for i := 0; i < len(k.Data.Methods); i++ {
+		if k.Data.CP.Utf8Refs[k.Data.Methods[i].Name] == meth &&
+			k.Data.CP.Utf8Refs[k.Data.Methods[i].Desc] == methType {
+			return &k.Data.Methods[i]
+		}
+	}
+	return nil
+}
+
+// interfaceNames returns the names of the interfaces that class k directly implements
+// (or, if k is an interface, that it directly extends)
+func interfaceNames(k *Klass) []string {
+	var names []string
+	for _, index := range k.Data.Interfaces {
+		names = append(names, k.Data.CP.Utf8Refs[index])
+	}
+	return names
+}
+
+// isSuperinterfaceOf returns true if super is among the interfaces that intf extends,
+// directly or indirectly. Both interfaces must already be loaded.
+func isSuperinterfaceOf(super, intf string) bool {
+	k := MethAreaFetch(intf)
+	if k == nil || k.Data == nil {
+		return false
+	}
+	for _, name := range interfaceNames(k) {
+		if name == super || isSuperinterfaceOf(super, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchLoadedClass returns the class from the method area, loading it first if necessary
+func fetchLoadedClass(class string) (*Klass, error) {
+	if MethAreaFetch(class) == nil {
+		if err := LoadClassFromNameOnly(class); err != nil {
+			return nil, err
+		}
+	}
+	if err := WaitForClassStatus(class); err != nil {
+		return nil, err
+	}
+
+	k := MethAreaFetch(class)
+	if k == nil || k.Data == nil {
+		return nil, errors.New("fetchLoadedClass: could not load class " + class)
+	}
+	return k, nil
+}
+
 // FetchUTF8stringFromCPEntryNumber fetches the UTF8 string using the CP entry number
 // for that string in the designated ClData.CP. Returns "" on error.
 func FetchUTF8stringFromCPEntryNumber(cp *CPool, entry uint16) string {
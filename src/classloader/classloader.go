@@ -34,7 +34,7 @@ type Classloader struct {
 	Name       string
 	Parent     string
 	ClassCount int
-	Archives   map[string]*Archive // TODO: I think this should be moved to classpath when we make it a thing
+	Archives   map[string]*Archive // the JAR files opened so far, by filename
 }
 
 // AppCL is the application classloader, which loads most of the app's classes
@@ -129,6 +129,13 @@ type codeAttrib struct {
 	code       []byte
 	exceptions []exception // exception entries for this method
 	attributes []attr      // the code attributes has its own sub-attributes(!)
+	lineTable  []lineNumber
+}
+
+// an entry in the LineNumberTable sub-attribute of the Code attribute
+type lineNumber struct {
+	startPc int // first instruction generated from the source line
+	line    int // the line number in the source file
 }
 
 // the MethodParameters method attribute
@@ -225,14 +232,13 @@ func walk(s string, d fs.DirEntry, err error) error {
 // Note that The class being loaded has records in the CP that indicate all the other classes it interacts with.
 // Thus, classes are preloaded prior to need.
 //
-// Note: per JACOBIN-327, this parallel processing has been temporarily
-// removed--it's not called by any function. It's likely to be reinstated later.
-func LoadReferencedClasses(clName string) {
+// Each class is reserved in the method area before it's sent to the channel, so
+// that code needing the class while it's being loaded waits for the load to finish.
+// An error is returned if the class named clName itself could not be loaded.
+func LoadReferencedClasses(clName string) error {
 	err := WaitForClassStatus(clName)
 	if err != nil {
-		msg := fmt.Sprintf("LoadReferencedClasses: %s", err.Error())
-		_ = log.Log(msg, log.SEVERE)
-		shutdown.Exit(shutdown.JVM_EXCEPTION)
+		return fmt.Errorf("LoadReferencedClasses: %s", err.Error())
 	}
 	currClass := MethAreaFetch(clName)
 	cpClassCP := currClass.Data.CP
@@ -242,7 +248,7 @@ func LoadReferencedClasses(clName string) {
 	for _, v := range classRefs {
 		refClassName := FetchUTF8stringFromCPEntryNumber(&cpClassCP, v)
 		name := normalizeClassReference(refClassName)
-		if name == "" {
+		if name == "" || !MethAreaReserve(name) { // skip classes that are already loaded
 			continue
 		}
 		loaderChannel <- name
@@ -250,50 +256,55 @@ func LoadReferencedClasses(clName string) {
 	globals.LoaderWg.Add(1)
 	go LoadFromLoaderChannel(loaderChannel)
 	close(loaderChannel)
+	return nil
 }
 
-// LoadFromLoaderChannel receives a name of a class to load in /java/lang/String format,
-// determines the classloader, checks if the class is already loaded, and loads it if not.
-//
-// Note: per JACOBIN-327, this parallel processing has been temporarily
-// removed--it's not called by any function. It's likely to be reinstated later.
+// LoadFromLoaderChannel receives a name of a class to load in /java/lang/String format
+// and loads the class, whose placeholder has been reserved in the method area. The
+// outcome of the load is passed to any goroutines waiting for the class. A failure is
+// not fatal here, as it's not clear yet that the class is needed.
 func LoadFromLoaderChannel(LoaderChannel <-chan string) {
 	for name := range LoaderChannel {
-		present := MethAreaFetch(name)
-		if present != nil { // if the class is already loaded, skip it
-			continue
-		}
-
-		// add entry to the method area, indicating initialization of the load of this class
-		eKI := Klass{
-			Status: 'I', // I = initializing the load
-			Loader: "",
-			Data:   nil,
-		}
-		MethAreaInsert(name, &eKI)
-		err := LoadClassFromNameOnly(util.ConvertToPlatformPathSeparators(name))
+		err := loadClassFromNameOnly(util.ConvertToPlatformPathSeparators(name), log.CLASS)
 		if err != nil {
-			shutdown.Exit(shutdown.JVM_EXCEPTION)
+			_ = log.Log("LoadFromLoaderChannel: could not preload "+name+": "+err.Error(), log.CLASS)
 		}
+		MethAreaLoadDone(name, err)
 	}
 	globals.LoaderWg.Done()
 }
 
+// LoadClassFromNameOnly loads the class from wherever it's found: a jmod, the
+// starting jar, or the filesystem. If the class is loaded, or being loaded by
+// another goroutine, it waits for that load instead. The class is reserved in
+// the method area before it's loaded, so no two goroutines load it at once.
 func LoadClassFromNameOnly(className string) error {
+	if !MethAreaReserve(className) {
+		return WaitForClassStatus(className)
+	}
+	err := loadClassFromNameOnly(className, log.SEVERE)
+	MethAreaLoadDone(className, err)
+	return err
+}
+
+// loadClassFromNameOnly loads the class, which the caller has reserved in the method
+// area. A failure is logged at failLevel: SEVERE if the class is needed, or CLASS if
+// it's only being preloaded.
+func loadClassFromNameOnly(className string, failLevel int) error {
 	var err error
 
 	jmodFileName := JmodMapFetch(className)
 
 	if className == "" {
 		msg := "LoadClassFromNameOnly: null class name is invalid"
-		_ = log.Log(msg, log.SEVERE)
+		_ = log.Log(msg, failLevel)
 		debug.PrintStack()
 		return errors.New(msg)
 	}
 
 	if strings.HasSuffix(className, ";") {
 		msg := fmt.Sprintf("LoadClassFromNameOnly: invalid class name: %s", className)
-		_ = log.Log(msg, log.SEVERE)
+		_ = log.Log(msg, failLevel)
 		debug.PrintStack()
 		return errors.New(msg)
 	}
@@ -302,33 +313,18 @@ func LoadClassFromNameOnly(className string) error {
 		_ = log.Log("LoadClassFromNameOnly: Load "+className+" from jmod "+jmodFileName, log.CLASS)
 		classBytes, err := GetClassBytes(jmodFileName, className)
 		if err != nil {
-			_ = log.Log("LoadClassFromNameOnly: GetClassBytes className="+className+" from jmodFileName="+jmodFileName+" failed", log.SEVERE)
-			_ = log.Log(err.Error(), log.SEVERE)
+			_ = log.Log("LoadClassFromNameOnly: GetClassBytes className="+className+" from jmodFileName="+jmodFileName+" failed", failLevel)
+			_ = log.Log(err.Error(), failLevel)
 		}
 		_, err = loadClassFromBytes(AppCL, className, classBytes)
 		return err
 	}
 
-	// Load class from a jar file?
-	if len(globals.GetGlobalRef().StartingJar) > 0 {
-		validName := util.ConvertToPlatformPathSeparators(className)
-		_ = log.Log("LoadClassFromNameOnly: LoadClassFromJar "+validName, log.CLASS)
-		_, err = LoadClassFromJar(AppCL, validName, globals.GetGlobalRef().StartingJar)
-		if err != nil {
-			_ = log.Log("LoadClassFromNameOnly: LoadClassFromJar "+validName+" failed", log.SEVERE)
-			_ = log.Log(err.Error(), log.SEVERE)
-		}
-		return err
-	}
-
-	// Loading from a local file system class
-	// TODO: classpath
-	validName := util.ConvertToPlatformPathSeparators(className)
-	_ = log.Log("LoadClassFromNameOnly: Load class from file "+validName, log.CLASS)
-	_, err = LoadClassFromFile(AppCL, validName)
+	// Load class from the classpath (which, when running a JAR file, is the JAR)
+	_, err = LoadClassFromClasspath(AppCL, className)
 	if err != nil {
-		_ = log.Log("LoadClassFromNameOnly: LoadClassFromFile "+validName+" failed", log.SEVERE)
-		_ = log.Log(err.Error(), log.SEVERE)
+		_ = log.Log("LoadClassFromNameOnly: LoadClassFromClasspath "+className+" failed", failLevel)
+		_ = log.Log(err.Error(), failLevel)
 	}
 	return err
 }
@@ -358,7 +354,14 @@ func LoadClassFromFile(cl Classloader, fname string) (string, error) {
 	return loadClassFromBytes(cl, filename, rawBytes)
 }
 
+// archivesMutex guards the classloaders' Archives, which the goroutines that load
+// classes share
+var archivesMutex sync.Mutex
+
 func getJarFile(cl Classloader, jarFileName string) (*Archive, error) {
+	archivesMutex.Lock()
+	defer archivesMutex.Unlock()
+
 	archive, exists := cl.Archives[jarFileName]
 
 	if exists {
@@ -386,6 +389,18 @@ func GetMainClassFromJar(cl Classloader, jarFileName string) (string, error) {
 	return jar.getMainClass(), nil
 }
 
+// GetClasspathFromJar returns the entries of the Class-Path attribute in the manifest
+// of the JAR file, as paths resolved against the JAR's location
+func GetClasspathFromJar(cl Classloader, jarFileName string) ([]string, error) {
+	jar, err := getJarFile(cl, jarFileName)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return jar.getClassPath(), nil
+}
+
 func LoadClassFromJar(cl Classloader, filename string, jarFileName string) (string, error) {
 	jar, err := getJarFile(cl, jarFileName)
 
@@ -445,6 +460,20 @@ func ParseAndPostClass(cl *Classloader, filename string, rawBytes []byte) (strin
 	return fullyParsedClass.className, nil
 }
 
+// ParseClassBytes parses and format-checks a class, presented as a slice of bytes,
+// without posting it to the method area. It's used to examine class files, as -javap does.
+func ParseClassBytes(rawBytes []byte) (*ClData, error) {
+	fullyParsedClass, err := parse(rawBytes)
+	if err != nil {
+		return nil, err
+	}
+	if err = formatCheckClass(&fullyParsedClass); err != nil {
+		return nil, err
+	}
+	classData := convertToPostableClass(&fullyParsedClass)
+	return &classData, nil
+}
+
 // load the parsed class into a form suitable for posting to the method area (which is
 // exec.MethArea. This mostly involves copying the data, converting most indexes to uint16
 // and removing some fields we needed in parsing, but which are no longer required.
@@ -455,6 +484,7 @@ func convertToPostableClass(fullyParsedClass *ParsedClass) ClData {
 	kd.Superclass = fullyParsedClass.superClass
 	kd.Module = fullyParsedClass.moduleName
 	kd.Pkg = fullyParsedClass.packageName
+	kd.Version = fullyParsedClass.javaVersion
 	for i := 0; i < len(fullyParsedClass.interfaces); i++ {
 		kd.Interfaces = append(kd.Interfaces, uint16(fullyParsedClass.interfaces[i]))
 	}
@@ -463,6 +493,7 @@ func convertToPostableClass(fullyParsedClass *ParsedClass) ClData {
 			kdf := Field{}
 			kdf.Name = uint16(fullyParsedClass.fields[i].name)
 			kdf.Desc = uint16(fullyParsedClass.fields[i].description)
+			kdf.AccessFlags = fullyParsedClass.fields[i].accessFlags
 			kdf.IsStatic = fullyParsedClass.fields[i].isStatic
 			if len(fullyParsedClass.fields[i].attributes) > 0 {
 				for j := 0; j < len(fullyParsedClass.fields[i].attributes); j++ {
@@ -505,6 +536,15 @@ func convertToPostableClass(fullyParsedClass *ParsedClass) ClData {
 					kdm.CodeAttr.Attributes = append(kdm.CodeAttr.Attributes, kdmca)
 				}
 			}
+			if len(fullyParsedClass.methods[i].codeAttr.lineTable) > 0 {
+				for k := 0; k < len(fullyParsedClass.methods[i].codeAttr.lineTable); k++ {
+					kdmln := BytecodeToSourceLine{
+						BytecodePos: fullyParsedClass.methods[i].codeAttr.lineTable[k].startPc,
+						SourceLine:  fullyParsedClass.methods[i].codeAttr.lineTable[k].line,
+					}
+					kdm.CodeAttr.LineTable = append(kdm.CodeAttr.LineTable, kdmln)
+				}
+			}
 			if len(fullyParsedClass.methods[i].attributes) > 0 {
 				for n := 0; n < len(fullyParsedClass.methods[i].attributes); n++ {
 					kdma := Attr{
@@ -581,6 +621,7 @@ func convertToPostableClass(fullyParsedClass *ParsedClass) ClData {
 				Slot: uint16(fullyParsedClass.cpIndex[cpIndexForUTF8.index].slot),
 			}
 			kd.CP.CpIndex = append(kd.CP.CpIndex, cpE)
+			kd.CP.Strings = append(kd.CP.Strings, uint16(i))
 		} else {
 			cpE := CpEntry{
 				Type: uint16(fullyParsedClass.cpIndex[i].entryType),
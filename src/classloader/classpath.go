@@ -0,0 +1,58 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"fmt"
+	"jacobin/globals"
+	"jacobin/log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// The classpath is the list of directories and JAR files in which the application
+// classloader looks for classes. It's set by the -cp, -classpath, and --class-path
+// options, by the CLASSPATH environment variable, or, when running a JAR file, to
+// that JAR. It defaults to the current directory. Wildcard entries have already been
+// expanded (see globals.ExpandClasspath()). The entries are searched in order and
+// the first one that contains the class is used.
+
+// LoadClassFromClasspath searches the classpath entries for the class, whose name is
+// in java/lang/String format, and loads it from the first entry that contains it
+func LoadClassFromClasspath(cl Classloader, className string) (string, error) {
+	className = filepath.ToSlash(className) // callers might have converted it to a platform path
+	classpath := globals.GetGlobalRef().Classpath
+	for _, entry := range classpath {
+		info, err := os.Stat(entry)
+		if err != nil { // as in the JDK, missing entries are skipped
+			continue
+		}
+
+		if info.IsDir() {
+			filename := filepath.Join(entry, filepath.FromSlash(className)+".class")
+			if _, err = os.Stat(filename); err == nil {
+				_ = log.Log("LoadClassFromClasspath: "+className+" found in "+entry, log.CLASS)
+				return LoadClassFromFile(cl, filename)
+			}
+			continue
+		}
+
+		jar, err := getJarFile(cl, entry)
+		if err != nil {
+			_ = log.Log("LoadClassFromClasspath: invalid JAR file "+entry+" on classpath", log.WARNING)
+			continue
+		}
+		resourceName := strings.ReplaceAll(className, "/", ".") // the form of class names in JARs
+		if jar.hasResource(resourceName, ClassFile) {
+			_ = log.Log("LoadClassFromClasspath: "+className+" found in "+entry, log.CLASS)
+			return LoadClassFromJar(cl, resourceName, entry)
+		}
+	}
+	return "", fmt.Errorf("class %s not found on classpath %s",
+		className, strings.Join(classpath, string(os.PathListSeparator)))
+}
@@ -0,0 +1,75 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"jacobin/globals"
+	"jacobin/log"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// sets up the application classloader with the given classpath
+func setupClasspath(classpath ...string) {
+	globals.InitGlobals("test")
+	log.Init()
+	AppCL.Name = "app"
+	AppCL.Archives = make(map[string]*Archive)
+	InitMethodArea()
+	globals.GetGlobalRef().Classpath = classpath
+}
+
+// the entries are searched in order; missing and invalid entries are skipped
+func TestLoadClassFromClasspath(t *testing.T) {
+	jarFile, err := getJarFileName(GOOD_JAR_NAME)
+	if err != nil {
+		t.Fatalf("Unable to get jar file: %s", err.Error())
+	}
+	emptyDir := t.TempDir()
+	notAJar := filepath.Join(emptyDir, "notes.txt")
+	_ = os.WriteFile(notAJar, []byte("not a jar"), 0644)
+
+	setupClasspath(filepath.Join(emptyDir, "missing"), emptyDir, notAJar, jarFile)
+
+	normalStderr := os.Stderr
+	_, w, _ := os.Pipe()
+	os.Stderr = w
+
+	className, err := LoadClassFromClasspath(AppCL, "jacobin/HelloWorld")
+
+	_ = w.Close()
+	os.Stderr = normalStderr
+
+	if err != nil {
+		t.Fatalf("Unexpected error loading class from classpath: %s", err.Error())
+	}
+	if className != "jacobin/HelloWorld" || MethAreaFetch("jacobin/HelloWorld") == nil {
+		t.Errorf("Expected jacobin/HelloWorld to be loaded, got: %s", className)
+	}
+	if _, ok := AppCL.Archives[jarFile]; !ok {
+		t.Errorf("Expected %s to be cached in the classloader's archives", jarFile)
+	}
+}
+
+// a directory entry is the root of the package hierarchy
+func TestLoadClassFromClasspathDirectory(t *testing.T) {
+	pwd, _ := os.Getwd()
+	testdata := filepath.Join(pwd, "..", "..", "testdata")
+	setupClasspath(t.TempDir(), testdata)
+
+	if _, err := LoadClassFromClasspath(AppCL, "Hello"); err != nil {
+		t.Fatalf("Unexpected error loading class from classpath: %s", err.Error())
+	}
+	if MethAreaFetch("Hello") == nil {
+		t.Errorf("Expected Hello to be loaded from %s", testdata)
+	}
+
+	if _, err := LoadClassFromClasspath(AppCL, "NoSuchClass"); err == nil {
+		t.Errorf("Expected an error loading a class that's not on the classpath")
+	}
+}
@@ -31,8 +31,9 @@ import (
 var MethodSignatures = make(map[string]GMeth)
 
 type GMeth struct {
-	ParamSlots int
-	GFunction  function
+	ParamSlots   int
+	GFunction    function
+	NeedsContext bool // if true, the thread's frame stack is passed after the parameters
 }
 
 type function func([]interface{}) interface{}
@@ -7,14 +7,10 @@
 package classloader
 
 import (
-	"fmt"
 	"jacobin/globals"
 	"jacobin/object"
 	"jacobin/shutdown"
-	"os"
-	"os/user"
 	"runtime"
-	"strconv"
 	"time"
 )
 
@@ -66,6 +62,12 @@ func Load_Lang_System() map[string]GMeth {
 			GFunction:  getProperty,
 		}
 
+	MethodSignatures["java/lang/System.getProperty(Ljava/lang/String;Ljava/lang/String;)Ljava/lang/String;"] =
+		GMeth{
+			ParamSlots: 2,
+			GFunction:  getPropertyWithDefault,
+		}
+
 	return MethodSignatures
 }
 
@@ -94,78 +96,32 @@ func forceGC([]interface{}) interface{} {
 	return nil
 }
 
-// Get a property
+// Get a property. If the property is not set, return null.
 func getProperty(params []interface{}) interface{} {
-	propObj := params[0].(*object.Object) // string
-	strPtr := propObj.Fields[0].Fvalue.(*[]byte)
-	str := *strPtr
-	prop := string(str)
-
-	var value string
-	g := globals.GetGlobalRef()
-	operSys := runtime.GOOS
-
-	switch prop {
-	case "file.separator":
-		value = string(os.PathSeparator)
-	case "java.class.path":
-		value = "." // OpenJDK JVM default value
-	case "java.compiler": // the name of the JIT compiler (we don't have a JIT)
-		value = "no JIT"
-	case "java.home":
-		value = g.JavaHome
-	case "java.library.path":
-		value = g.JavaHome
-	case "java.vendor":
-		value = "Jacobin"
-	case "java.vendor.url":
-		value = "http://jacobin.org"
-	case "java.vendor.version":
-		value = g.Version
-	case "java.version":
-		value = strconv.Itoa(g.MaxJavaVersion)
-	// case "java.version.date":
-	// 	value = // need to get this
-	case "java.vm.name":
-		value = fmt.Sprintf(
-			"Jacobin VM v. %s (Java %d) 64-bit VM", g.Version, g.MaxJavaVersion)
-	case "java.vm.specification.name":
-		value = "Java Virtual Machine Specification"
-	case "java.vm.specification.vendor":
-		value = "Oracle and Jacobin"
-	case "java.vm.specification.version":
-		value = strconv.Itoa(g.MaxJavaVersion)
-	case "java.vm.vendor":
-		value = "Jacobin"
-	case "java.vm.version":
-		value = strconv.Itoa(g.MaxJavaVersion)
-	case "line.separator":
-		if operSys == "windows" {
-			value = "\\r\\n"
-		} else {
-			value = "\\n"
-		}
-	case "native.encoding": // hard to find out what this is, so hard-coding to UTF8
-		value = "UTF8"
-	case "os.arch":
-		value = runtime.GOARCH
-	case "os.name":
-		value = operSys
-	case "os.version":
-		value = "not yet available"
-	case "path.separator":
-		value = string(os.PathSeparator)
-	case "user.dir": // present working directory
-		value, _ = os.Getwd()
-	case "user.home":
-		currentUser, _ := user.Current()
-		value = currentUser.HomeDir
-	case "user.name":
-		currentUser, _ := user.Current()
-		value = currentUser.Name
-	default:
-		value = "null" // TODO: make it that a string of nil prints out "null"
+	value, ok := globals.GetProperty(goStringParam(params[0]))
+	return PropertyValue(value, ok)
+}
+
+// Get a property. If the property is not set, return the default value passed in.
+func getPropertyWithDefault(params []interface{}) interface{} {
+	value, ok := globals.GetProperty(goStringParam(params[0]))
+	if !ok {
+		return params[1]
+	}
+	return PropertyValue(value, ok)
+}
+
+// converts a String passed to a Go method into a Go string
+func goStringParam(param interface{}) string {
+	str, _ := param.(*object.Object)
+	return object.GetGoStringFromJavaString(str)
+}
+
+// PropertyValue returns the value of a system property as a String, or null if the
+// property is not set
+func PropertyValue(value string, ok bool) interface{} {
+	if !ok {
+		return object.Null
 	}
-	obj := object.CreateCompactStringFromGoString(&value)
-	return obj
+	return object.CreateCompactStringFromGoString(&value)
 }
@@ -0,0 +1,55 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"jacobin/globals"
+	"jacobin/object"
+	"os"
+	"testing"
+)
+
+func javaString(s string) *object.Object {
+	return object.CreateCompactStringFromGoString(&s)
+}
+
+// the Go string for a String returned by a Go method, or "null"
+func goString(value interface{}) string {
+	obj := value.(*object.Object)
+	if obj == object.Null {
+		return "null"
+	}
+	return object.GetGoStringFromJavaString(obj)
+}
+
+func TestSystemProperties(t *testing.T) {
+	globals.InitGlobals("test")
+
+	if value := goString(getProperty([]interface{}{javaString("path.separator")})); value != string(os.PathListSeparator) {
+		t.Errorf("Expected path.separator to be %c, got: %s", os.PathListSeparator, value)
+	}
+
+	// an unknown property is null, not "null"
+	if value := getProperty([]interface{}{javaString("no.such.property")}); value.(*object.Object) != object.Null {
+		t.Errorf("Expected an unknown property to be null, got: %s", goString(value))
+	}
+	value := getPropertyWithDefault([]interface{}{javaString("no.such.property"), javaString("fallback")})
+	if goString(value) != "fallback" {
+		t.Errorf("Expected the default value for an unknown property, got: %s", goString(value))
+	}
+
+	globals.SetProperty("app.mode", "prod")
+	value = getPropertyWithDefault([]interface{}{javaString("app.mode"), javaString("fallback")})
+	if goString(value) != "prod" {
+		t.Errorf("Expected app.mode to be prod, got: %s", goString(value))
+	}
+
+	globals.ClearProperty("app.mode")
+	if value := getProperty([]interface{}{javaString("app.mode")}); value.(*object.Object) != object.Null {
+		t.Errorf("Expected a cleared property to be null, got: %s", goString(value))
+	}
+}
@@ -43,8 +43,9 @@ type mData interface{}
 // Fu is a go function. All go functions accept a possibly empty slice of interface{} and
 // return a possibly nil interface{}
 type GmEntry struct {
-	ParamSlots int
-	Fu         func([]interface{}) interface{}
+	ParamSlots   int
+	Fu           func([]interface{}) interface{}
+	NeedsContext bool // if true, the thread's frame stack is passed after the parameters
 }
 
 // JmEntry is the entry in the Mtable for Java methods.
@@ -53,21 +54,37 @@ type JmEntry struct {
 	MaxStack    int
 	MaxLocals   int
 	Code        []byte
-	exceptions  []CodeException
+	Exceptions  []CodeException
+	LineTable   []BytecodeToSourceLine
 	attribs     []Attr
 	params      []ParamAttrib
 	deprecated  bool
 	Cp          *CPool
 }
 
+// IsSynchronized returns true if the method is synchronized, so its monitor must be
+// held while it runs
+func (jme JmEntry) IsSynchronized() bool {
+	return jme.accessFlags&0x0020 != 0
+}
+
 // Function is the generic-style function used for Go entries: a function that accepts a
 // slice of empty interfaces and returns nothing (b/c all returns are pushed onto the
 // stack rather than actually returned to a caller).
 type Function func([]interface{}) interface{}
 
-// MTmutex is used for updates to the MTable because multiple threads could be
+// MTmutex is used for accesses to the MTable because multiple threads could be
 // updating it simultaneously.
-var MTmutex sync.Mutex
+var MTmutex sync.RWMutex
+
+// MTableFetch returns the MTable entry for the method, e.g., java/lang/Object.hashCode()I,
+// or the zero MTentry if there is none, using a mutex
+func MTableFetch(methFQN string) MTentry {
+	MTmutex.RLock()
+	mte := MTable[methFQN]
+	MTmutex.RUnlock()
+	return mte
+}
 
 // MTableLoadNatives loads the Go methods from files that contain them. It does this
 // by calling the Load_* function in each of those files to load whatever Go functions
@@ -78,11 +95,18 @@ func MTableLoadNatives() {
 	loadlib(&MTable, Load_Lang_Math())      // load the java.lang.system golang functions
 }
 
+// MTableLoadGoMethods loads Go methods that are defined outside this package. These
+// are chiefly methods in the jvm package that need access to the thread's frames.
+func MTableLoadGoMethods(libMeths map[string]GMeth) {
+	loadlib(&MTable, libMeths)
+}
+
 func loadlib(tbl *MT, libMeths map[string]GMeth) {
 	for key, val := range libMeths {
 		gme := GmEntry{}
 		gme.ParamSlots = val.ParamSlots
 		gme.Fu = val.GFunction
+		gme.NeedsContext = val.NeedsContext
 
 		tableEntry := MTentry{
 			MType: 'G',
@@ -12,7 +12,6 @@ import (
 	"jacobin/log"
 	"jacobin/types"
 	"sync"
-	"time"
 )
 
 // MethArea contains all the loaded classes. Key is the class name in java/lang/Object format.
@@ -38,13 +37,20 @@ func MethAreaFetch(key string) *Klass {
 
 // MethAreaInsert adds a class to the method area, using a pointer
 // to the parsed class.
+// If the class replaces a placeholder added by MethAreaReserve, the goroutines
+// waiting for the class to load are released.
 func MethAreaInsert(name string, klass *Klass) {
 	_ = log.Log("MethAreaInsert: key("+name+")", log.CLASS)
 	MethAreaMutex.Lock()
+	prev, _ := MethArea.Load(name)
 	MethArea.Store(name, klass)
 	methAreaSize++
 	MethAreaMutex.Unlock()
 
+	if placeholder, ok := prev.(*Klass); ok && placeholder != klass && placeholder.load != nil {
+		placeholder.load.finish(nil)
+	}
+
 	if klass.Status == 'F' || klass.Status == 'V' || klass.Status == 'L' {
 		_ = log.Log("Method area insert: "+klass.Data.Name+", loader: "+klass.Loader, log.CLASS)
 	}
@@ -61,24 +67,74 @@ func MethAreaSize() int {
 	return size
 }
 
-// Wait for klass.Status to no longer be "I"
-// TODO: must be a better way to do this!
+// classLoad tracks the load of a class whose placeholder (with status 'I') is in
+// the method area while a loader goroutine loads the class.
+type classLoad struct {
+	done chan struct{} // closed when the load has finished or failed
+	once sync.Once
+	err  error // why the load failed, if it did
+}
+
+// finish records the outcome of the load and releases the goroutines waiting for it.
+// Only the first outcome is recorded.
+func (cl *classLoad) finish(err error) {
+	cl.once.Do(func() {
+		cl.err = err
+		close(cl.done)
+	})
+}
+
+// MethAreaReserve adds a placeholder for a class that is about to be loaded, so that
+// other goroutines that need the class wait for the load to finish rather than load
+// it themselves. It returns false if the class (or its placeholder) is already in the
+// method area. The load must be concluded by a call to MethAreaLoadDone().
+func MethAreaReserve(name string) bool {
+	placeholder := Klass{
+		Status: 'I', // I = initializing the load
+		Loader: "",
+		Data:   nil,
+		load:   &classLoad{done: make(chan struct{})},
+	}
+	MethAreaMutex.Lock()
+	_, present := MethArea.LoadOrStore(name, &placeholder)
+	MethAreaMutex.Unlock()
+	return !present
+}
+
+// MethAreaLoadDone concludes the load of a class reserved by MethAreaReserve(). If the
+// load failed, or did not post a class by that name, the placeholder is removed (so a
+// later attempt can load the class) and the error is passed to the waiting goroutines.
+func MethAreaLoadDone(name string, err error) {
+	MethAreaMutex.Lock()
+	v, _ := MethArea.Load(name)
+	placeholder, ok := v.(*Klass)
+	if !ok || placeholder.load == nil { // the class has been posted
+		MethAreaMutex.Unlock()
+		return
+	}
+	if err == nil {
+		err = fmt.Errorf("class {%s} was not posted to the method area", name)
+	}
+	MethArea.Delete(name)
+	MethAreaMutex.Unlock()
+
+	placeholder.load.finish(err)
+}
+
+// WaitForClassStatus waits for a class that is being loaded (klass.Status is 'I')
+// to finish loading. It returns an error if the class is not in the method area or
+// if its load failed.
 func WaitForClassStatus(className string) error {
 	_ = log.Log("WaitForClassStatus: class name: "+className, log.CLASS)
 	klass := MethAreaFetch(className)
-	if klass == nil { // class not there yet
-		time.Sleep(100 * time.Millisecond) // sleep 100 milliseconds
-		klass = MethAreaFetch(className)
-		if klass == nil {
-			msg := fmt.Sprintf("WaitClassStatus: Timeout waiting for class {%s} to load", className)
-			return errors.New(msg)
-		}
+	if klass == nil {
+		msg := fmt.Sprintf("WaitClassStatus: class {%s} has not been loaded", className)
+		return errors.New(msg)
 	}
-	if klass.Status == 'I' { // class is being initialized by a loader, so wait
-		time.Sleep(100 * time.Millisecond) // sleep 100 milliseconds
-		klass = MethAreaFetch(className)
-		if klass.Status == 'I' {
-			msg := fmt.Sprintf("WaitClassStatus: Timeout waiting for class {%s} status", className)
+	if klass.load != nil { // the class is being loaded by another goroutine, so wait
+		<-klass.load.done
+		if klass.load.err != nil {
+			msg := fmt.Sprintf("WaitClassStatus: error loading class {%s}: %s", className, klass.load.err.Error())
 			return errors.New(msg)
 		}
 	}
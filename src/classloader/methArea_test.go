@@ -0,0 +1,106 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"errors"
+	"jacobin/globals"
+	"jacobin/log"
+	"strings"
+	"testing"
+	"time"
+)
+
+// starts goroutines that wait for the class and returns the channel on which
+// they report the outcome
+func startWaiters(className string, count int) chan error {
+	results := make(chan error, count)
+	for i := 0; i < count; i++ {
+		go func() { results <- WaitForClassStatus(className) }()
+	}
+	return results
+}
+
+func TestWaitForClassStatusUntilLoaded(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	InitMethodArea()
+
+	if !MethAreaReserve("test/Slow") {
+		t.Fatalf("Expected to reserve test/Slow")
+	}
+	if MethAreaReserve("test/Slow") {
+		t.Errorf("Expected a second reservation of test/Slow to fail")
+	}
+
+	results := startWaiters("test/Slow", 3)
+	select {
+	case err := <-results:
+		t.Fatalf("Expected the waiters to block until the class is loaded, got: %v", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	MethAreaInsert("test/Slow", &Klass{Status: 'F', Loader: "bootstrap", Data: &ClData{Name: "test/Slow"}})
+	MethAreaLoadDone("test/Slow", nil)
+	for i := 0; i < 3; i++ {
+		if err := <-results; err != nil {
+			t.Errorf("Unexpected error waiting for test/Slow: %s", err.Error())
+		}
+	}
+	if k := MethAreaFetch("test/Slow"); k == nil || k.Status != 'F' {
+		t.Errorf("Expected the loaded class in the method area, got: %v", k)
+	}
+}
+
+func TestWaitForClassStatusLoadFailure(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	InitMethodArea()
+
+	MethAreaReserve("test/Missing")
+	results := startWaiters("test/Missing", 2)
+	time.Sleep(10 * time.Millisecond) // let the waiters start waiting
+
+	MethAreaLoadDone("test/Missing", errors.New("class not found"))
+	for i := 0; i < 2; i++ {
+		err := <-results
+		if err == nil || !strings.Contains(err.Error(), "class not found") {
+			t.Errorf("Expected the load failure to be passed to the waiter, got: %v", err)
+		}
+	}
+
+	// the placeholder is removed, so a later attempt can load the class
+	if MethAreaFetch("test/Missing") != nil {
+		t.Errorf("Expected the placeholder of test/Missing to be removed")
+	}
+	if err := WaitForClassStatus("test/Missing"); err == nil {
+		t.Errorf("Expected an error waiting for a class that is not loaded")
+	}
+}
+
+// LoadClassFromNameOnly waits for a class that another goroutine is loading, rather
+// than loading it again
+func TestLoadClassFromNameOnlyWaitsForLoad(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	InitMethodArea()
+
+	MethAreaReserve("test/Slow")
+	result := make(chan error, 1)
+	go func() { result <- LoadClassFromNameOnly("test/Slow") }()
+	select {
+	case err := <-result:
+		t.Fatalf("Expected LoadClassFromNameOnly to wait for the load, got: %v", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	MethAreaInsert("test/Slow", &Klass{Status: 'F', Loader: "bootstrap", Data: &ClData{Name: "test/Slow"}})
+	MethAreaLoadDone("test/Slow", nil)
+	if err := <-result; err != nil {
+		t.Errorf("Unexpected error from LoadClassFromNameOnly: %s", err.Error())
+	}
+}
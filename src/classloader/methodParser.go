@@ -211,6 +211,15 @@ func parseCodeAttribute(att attr, meth *method, klass *ParsedClass) error {
 			pos = loc
 			log.Log("        "+klass.utf8Refs[cat.attrName].content, log.FINEST)
 			ca.attributes = append(ca.attributes, cat)
+
+			if klass.utf8Refs[cat.attrName].content == "LineNumberTable" {
+				lines, err3 := parseLineNumberTable(cat)
+				if err3 != nil {
+					return cfe("Error parsing LineNumberTable in Code attribute of " +
+						methodName + "() of " + klass.className)
+				}
+				ca.lineTable = append(ca.lineTable, lines...) // there can be more than one
+			}
 		}
 	}
 
@@ -222,6 +231,40 @@ func parseCodeAttribute(att attr, meth *method, klass *ParsedClass) error {
 	return nil
 }
 
+// parse the LineNumberTable sub-attribute of the Code attribute, which maps the
+// bytecodes to lines in the source file. Its layout is:
+//
+//	u2 line_number_table_length;
+//	{   u2 start_pc;
+//	    u2 line_number;
+//	} line_number_table[line_number_table_length];
+//
+// https://docs.oracle.com/javase/specs/jvms/se17/html/jvms-4.html#jvms-4.7.12
+func parseLineNumberTable(att attr) ([]lineNumber, error) {
+	pos := -1
+	count, err := intFrom2Bytes(att.attrContent, pos+1)
+	pos += 2
+	if err != nil {
+		return nil, err
+	}
+
+	lines := make([]lineNumber, 0, count)
+	for i := 0; i < count; i++ {
+		ln := lineNumber{}
+		ln.startPc, err = intFrom2Bytes(att.attrContent, pos+1)
+		if err != nil {
+			return nil, err
+		}
+		ln.line, err = intFrom2Bytes(att.attrContent, pos+3)
+		if err != nil {
+			return nil, err
+		}
+		pos += 4
+		lines = append(lines, ln)
+	}
+	return lines, nil
+}
+
 // The Exceptions attribute of a method indicates which checked exceptions a method
 // can throw. See: https://docs.oracle.com/javase/specs/jvms/se11/html/jvms-4.html#jvms-4.7.5
 // The structure of the Exceptions attribute of a method is: {
@@ -92,6 +92,77 @@ func TestValidCodeMethodAttribute(t *testing.T) {
 		t.Error("Expected 0 attributes of Code attribute. Got: " + strconv.Itoa(len(meth.codeAttr.attributes)))
 	}
 }
+
+// test a Code attribute that has a LineNumberTable sub-attribute
+func TestCodeAttributeWithLineNumberTable(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	// redirect stderr & stdout to capture results from stderr
+	normalStderr := os.Stderr
+	_, w, _ := os.Pipe()
+	os.Stderr = w
+
+	normalStdout := os.Stdout
+	_, wout, _ := os.Pipe()
+	os.Stdout = wout
+
+	klass := ParsedClass{}
+	klass.cpIndex = append(klass.cpIndex, cpEntry{})
+	klass.cpIndex = append(klass.cpIndex, cpEntry{UTF8, 0})
+	klass.cpIndex = append(klass.cpIndex, cpEntry{UTF8, 1})
+	klass.cpIndex = append(klass.cpIndex, cpEntry{UTF8, 2})
+
+	klass.utf8Refs = append(klass.utf8Refs, utf8Entry{"Code"})
+	klass.utf8Refs = append(klass.utf8Refs, utf8Entry{"testMethod"})
+	klass.utf8Refs = append(klass.utf8Refs, utf8Entry{"LineNumberTable"})
+
+	klass.cpCount = 4
+
+	meth := method{}
+	meth.name = 1 // points to UTF8 entry: "testMethod"
+
+	attrib := attr{}
+	attrib.attrName = 0
+	attrib.attrContent = []byte{
+		0, 2, // maxstack = 2
+		0, 1, // maxlocals = 1
+		0, 0, 0, 4, // code length = 4
+		0x04, 0x3C, 0x00, 0xB1, // iconst_1, istore_1, nop, return
+		0, 0, // number of exceptions = 0
+		0, 1, // attribute count of Code attribute = 1
+		0, 3, // name of the attribute: CP entry 3 -> "LineNumberTable"
+		0, 0, 0, 10, // length of the attribute
+		0, 2, // two entries in the table
+		0, 0, 0, 12, // bytecode 0 is on line 12
+		0, 3, 0, 14, // bytecode 3 is on line 14
+	}
+	attrib.attrSize = len(attrib.attrContent)
+
+	err := parseCodeAttribute(attrib, &meth, &klass)
+
+	// restore stderr and stdout to what they were before
+	_ = w.Close()
+	os.Stderr = normalStderr
+	_ = wout.Close()
+	os.Stdout = normalStdout
+
+	if err != nil {
+		t.Errorf("Unexpected error in processing Code attribute with a LineNumberTable: %s", err.Error())
+		return
+	}
+
+	lines := meth.codeAttr.lineTable
+	if len(lines) != 2 {
+		t.Errorf("Expected 2 entries in the line number table. Got: %d", len(lines))
+		return
+	}
+
+	if lines[0].startPc != 0 || lines[0].line != 12 || lines[1].startPc != 3 || lines[1].line != 14 {
+		t.Errorf("Expected line number entries {0, 12} and {3, 14}. Got: %v", lines)
+	}
+}
+
 func Test1ValidMethodExceptionsAttribute(t *testing.T) {
 	globals.InitGlobals("test")
 	log.Init()
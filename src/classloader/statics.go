@@ -55,12 +55,20 @@ func AddStatic(name string, s Static) error {
 	if name == "" {
 		return errors.New("AddStatic: Attempting to add invalid static entry")
 	}
-	staticsMutex.RLock()
+	staticsMutex.Lock()
 	Statics[name] = s
-	staticsMutex.RUnlock()
+	staticsMutex.Unlock()
 	return nil
 }
 
+// FetchStatic returns the static field in the Statics table, if present, using a mutex
+func FetchStatic(name string) (Static, bool) {
+	staticsMutex.RLock()
+	s, ok := Statics[name]
+	staticsMutex.RUnlock()
+	return s, ok
+}
+
 // StaticsPreload preloads static fields from java.lang.String and other
 // immediately necessary statics. It's called in jvmStart.go
 func StaticsPreload() {
@@ -0,0 +1,117 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"sync"
+)
+
+// VTable is the virtual method table of a class: the instance methods that can be
+// invoked on objects of the class, whether declared in the class or inherited.
+// A class's table begins with the entries of its superclass's table, in the same
+// order. A method that overrides a superclass method takes the slot of the method
+// it overrides, so a method has the same slot in a class and in all its subclasses.
+// This way, a call site can look up the slot once, using the class named in the
+// MethodRef, and then use it to find the method on objects of any subclass.
+type VTable struct {
+	Entries []VTableEntry
+	Slots   map[string]int // the slot of each method, keyed by method name + method type
+}
+
+// VTableEntry is the method in one slot of a VTable
+type VTableEntry struct {
+	ClassName string  // the class that declares the method, in java/lang/Object format
+	MethName  string  // the method name
+	MethType  string  // the method's signature, e.g. (I)V
+	Meth      MTentry // the method; Meth.Meth is nil if the method is abstract
+}
+
+// the vtables are built the first time they're needed, under this mutex
+var vtableMutex sync.RWMutex
+
+// FetchVTable returns the virtual method table of a class. The table is built
+// when the class is linked, which Jacobin does lazily: on the first virtual call
+// to a method of the class or its subclasses. The superclasses are linked first.
+func FetchVTable(class string) (*VTable, error) {
+	vtableMutex.RLock()
+	k := MethAreaFetch(class)
+	if k != nil && k.Data != nil && k.Data.VTable != nil {
+		vt := k.Data.VTable
+		vtableMutex.RUnlock()
+		return vt, nil
+	}
+	vtableMutex.RUnlock()
+
+	// the class and its superclasses are loaded before the lock is taken, so no
+	// thread waits for the lock while another reads class files
+	for c := class; c != ""; {
+		k, err := fetchLoadedClass(c)
+		if err != nil {
+			return nil, err
+		}
+		if c == "java/lang/Object" {
+			break
+		}
+		c = k.Data.Superclass
+	}
+
+	vtableMutex.Lock()
+	defer vtableMutex.Unlock()
+	return buildVTable(class)
+}
+
+// builds the vtable for the class (and its superclasses, if need be). Must be
+// called with vtableMutex locked, once the class and its superclasses are loaded.
+func buildVTable(class string) (*VTable, error) {
+	k, err := fetchLoadedClass(class)
+	if err != nil {
+		return nil, err
+	}
+	if k.Data.VTable != nil { // built while we waited for the lock
+		return k.Data.VTable, nil
+	}
+
+	vt := VTable{Slots: make(map[string]int)}
+	if class != "java/lang/Object" && k.Data.Superclass != "" {
+		superVT, err := buildVTable(k.Data.Superclass)
+		if err != nil {
+			return nil, err
+		}
+		vt.Entries = append(vt.Entries, superVT.Entries...)
+		for key, slot := range superVT.Slots {
+			vt.Slots[key] = slot
+		}
+	}
+
+	for i := 0; i < len(k.Data.Methods); i++ {
+		m := &k.Data.Methods[i]
+		name := k.Data.CP.Utf8Refs[m.Name]
+		desc := k.Data.CP.Utf8Refs[m.Desc]
+
+		// constructors, static methods, and private methods are not invoked virtually
+		if name == "<init>" || name == "<clinit>" || m.AccessFlags&(0x0008|0x0002) != 0 {
+			continue
+		}
+
+		entry := VTableEntry{ClassName: class, MethName: name, MethType: desc}
+		if gm := MTableFetch(class + "." + name + desc); gm.Meth != nil && gm.MType == 'G' {
+			entry.Meth = gm // methods implemented in Go replace those in the class file
+		} else if m.AccessFlags&0x0400 == 0 { // if not ACC_ABSTRACT
+			entry.Meth = MTentry{Meth: newJmEntry(m, k), MType: 'J'}
+		}
+
+		if slot, ok := vt.Slots[name+desc]; ok {
+			vt.Entries[slot] = entry // it overrides a superclass method
+		} else {
+			vt.Slots[name+desc] = len(vt.Entries)
+			vt.Entries = append(vt.Entries, entry)
+		}
+	}
+
+	k.Data.VTable = &vt
+	return &vt, nil
+}
@@ -0,0 +1,97 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"jacobin/globals"
+	"jacobin/log"
+	"testing"
+)
+
+// a method of a class created for the vtable tests
+type meth struct {
+	name, desc string
+	flags      int
+}
+
+// adds a class with the given methods to the method area
+func addVTableTestClass(name, superclass string, methods ...meth) {
+	data := ClData{Name: name, Superclass: superclass}
+	for _, m := range methods {
+		meth := Method{AccessFlags: m.flags}
+		meth.Name = uint16(len(data.CP.Utf8Refs))
+		data.CP.Utf8Refs = append(data.CP.Utf8Refs, m.name)
+		meth.Desc = uint16(len(data.CP.Utf8Refs))
+		data.CP.Utf8Refs = append(data.CP.Utf8Refs, m.desc)
+		data.Methods = append(data.Methods, meth)
+	}
+	MethAreaInsert(name, &Klass{Status: 'X', Loader: "bootstrap", Data: &data})
+}
+
+func TestFetchVTable(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	InitMethodArea()
+	MTable = make(map[string]MTentry)
+
+	addVTableTestClass("java/lang/Object", "",
+		meth{"<init>", "()V", 0x0001},
+		meth{"toString", "()Ljava/lang/String;", 0x0001})
+	addVTableTestClass("test/Base", "java/lang/Object",
+		meth{"run", "()V", 0x0401},    // abstract
+		meth{"helper", "()V", 0x0002}, // private, so not in the vtable
+		meth{"create", "()V", 0x0009}, // static, so not in the vtable
+		meth{"stop", "(I)V", 0x0001})
+	addVTableTestClass("test/Derived", "test/Base",
+		meth{"extra", "()V", 0x0001},
+		meth{"run", "()V", 0x0001},
+		meth{"toString", "()Ljava/lang/String;", 0x0001})
+	MTable["test/Derived.extra()V"] = MTentry{MType: 'G', Meth: GmEntry{ParamSlots: 1}}
+
+	baseVT, err := FetchVTable("test/Base")
+	if err != nil {
+		t.Fatalf("Unexpected error building vtable of test/Base: %s", err.Error())
+	}
+	if len(baseVT.Entries) != 3 {
+		t.Errorf("Expected 3 entries in vtable of test/Base, got: %d", len(baseVT.Entries))
+	}
+	if baseVT.Entries[baseVT.Slots["run()V"]].Meth.Meth != nil {
+		t.Errorf("Expected abstract method test/Base.run() to have no implementation")
+	}
+
+	derivedVT, err := FetchVTable("test/Derived")
+	if err != nil {
+		t.Fatalf("Unexpected error building vtable of test/Derived: %s", err.Error())
+	}
+	if len(derivedVT.Entries) != 4 {
+		t.Errorf("Expected 4 entries in vtable of test/Derived, got: %d", len(derivedVT.Entries))
+	}
+
+	// overriding methods keep the slots of the methods they override
+	for key, slot := range baseVT.Slots {
+		if derivedVT.Slots[key] != slot {
+			t.Errorf("Expected %s in slot %d of the subclass vtable, got: %d", key, slot, derivedVT.Slots[key])
+		}
+	}
+
+	expected := map[string]string{
+		"toString()Ljava/lang/String;": "test/Derived",
+		"run()V":                       "test/Derived",
+		"stop(I)V":                     "test/Base",
+		"extra()V":                     "test/Derived",
+	}
+	for key, className := range expected {
+		entry := derivedVT.Entries[derivedVT.Slots[key]]
+		if entry.ClassName != className || entry.Meth.Meth == nil {
+			t.Errorf("Expected %s to be implemented in %s, got: %s", key, className, entry.ClassName)
+		}
+	}
+
+	if derivedVT.Entries[derivedVT.Slots["extra()V"]].Meth.MType != 'G' {
+		t.Errorf("Expected the Go method for test/Derived.extra() in the vtable")
+	}
+}
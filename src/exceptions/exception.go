@@ -44,6 +44,7 @@ const (
 	IllegalMonitorStateException
 	IllegalPathStateException
 	IllegalStateException
+	IllegalThreadStateException
 	IllformedLocaleException
 	ImagingOpException
 	InaccessibleObjectException
@@ -193,6 +194,16 @@ const (
 	ThreadDeath
 	TransformerFactoryConfigurationError
 	VirtualMachineError
+
+	// subclasses of the errors above that are thrown by the JVM
+	AbstractMethodError
+	BootstrapMethodError
+	ExceptionInInitializerError
+	IncompatibleClassChangeError
+	NoClassDefFoundError
+	VerifyError
+	StackOverflowError
+	InternalError
 )
 
 // JacobinRuntimeErrLiterals are the displayed strings for the given exception.
@@ -209,6 +220,30 @@ var JDKRuntimeErrLiterals = []string{
 	"java.lang.ArithmeticException: / by zero",
 }
 
+// ExceptionClassNames maps the exceptions that are detected and thrown by the JVM
+// itself (rather than by the application) to the names of their classes.
+var ExceptionClassNames = map[int]string{
+	AbstractMethodError:            "java/lang/AbstractMethodError",
+	BootstrapMethodError:           "java/lang/BootstrapMethodError",
+	ExceptionInInitializerError:    "java/lang/ExceptionInInitializerError",
+	IncompatibleClassChangeError:   "java/lang/IncompatibleClassChangeError",
+	NoClassDefFoundError:           "java/lang/NoClassDefFoundError",
+	VerifyError:                    "java/lang/VerifyError",
+	StackOverflowError:             "java/lang/StackOverflowError",
+	InternalError:                  "java/lang/InternalError",
+	ArithmeticException:            "java/lang/ArithmeticException",
+	ArrayIndexOutOfBoundsException: "java/lang/ArrayIndexOutOfBoundsException",
+	ArrayStoreException:            "java/lang/ArrayStoreException",
+	ClassCastException:             "java/lang/ClassCastException",
+	IllegalArgumentException:       "java/lang/IllegalArgumentException",
+	IllegalMonitorStateException:   "java/lang/IllegalMonitorStateException",
+	IllegalThreadStateException:    "java/lang/IllegalThreadStateException",
+	IndexOutOfBoundsException:      "java/lang/IndexOutOfBoundsException",
+	InterruptedException:           "java/lang/InterruptedException",
+	NegativeArraySizeException:     "java/lang/NegativeArraySizeException",
+	NullPointerException:           "java/lang/NullPointerException",
+}
+
 // Throw duplicates the exception mechanism in Java. Right now, it displays the
 // exceptions message. Will add: catch logic, stack trace, and halt of execution
 // TODO: use ThreadNum to find the right thread
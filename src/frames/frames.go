@@ -11,6 +11,7 @@ import (
 	"fmt"
 	"jacobin/classloader"
 	"jacobin/log"
+	"jacobin/object"
 	"unsafe"
 )
 
@@ -29,16 +30,21 @@ type Number interface {
 // without manipulation at this width. (However, there will still be need for the dummy
 // second stack entry for these data items.
 type Frame struct {
-	Thread   int
-	MethName string             // method name
-	ClName   string             // class name
-	Meth     []byte             // bytecode of method
-	CP       *classloader.CPool // constant pool of class
-	Locals   []interface{}      // local variables
-	OpStack  []interface{}      // operand stack
-	TOS      int                // top of the operand stack
-	PC       int                // program counter (index into the bytecode of the method)
-	Ftype    byte               // type of method in frame: 'J' = java, 'G' = Golang, 'N' = native
+	Thread    int                                // the ID of the thread the frame runs on
+	Trace     bool                               // the thread traces the instructions it executes
+	MethName  string                             // method name
+	ClName    string                             // class name
+	Meth      []byte                             // bytecode of method
+	CP        *classloader.CPool                 // constant pool of class
+	ExcTable  []classloader.CodeException        // the method's exception table, if any
+	LineTable []classloader.BytecodeToSourceLine // maps the bytecodes to source lines, if available
+	Locals    []interface{}                      // local variables
+	OpStack   []interface{}                      // operand stack
+	TOS       int                                // top of the operand stack
+	PC        int                                // program counter (index into the bytecode of the method)
+	Ftype     byte                               // type of method in frame: 'J' = java, 'G' = Golang, 'N' = native
+	Monitor   *object.Object                     // for synchronized methods, the object whose monitor is held
+	Locks     []*object.Object                   // the objects whose monitors MONITORENTER has locked, in order
 }
 
 // CreateFrameStack creates a stack of frames. Implemented as a list in which
@@ -34,15 +34,27 @@ type Globals struct {
 	Args        []string
 	CommandLine string
 
-	StartingClass string
-	StartingJar   string
-	AppArgs       []string
-	Options       map[string]Option
+	StartingClass      string
+	StartingJar        string
+	MainClassName      string // the binary name of the class to run, e.g., com.example.Main
+	MainModule         string // the module of the main class, if given with -m module/mainclass
+	StartingSourceFile string // a .java file to compile and run (source-file mode)
+	SourceVersion      string // the Java version of the source file, set by --source
+	AppArgs            []string
+	Options            map[string]Option
+
+	// ---- -javap, which disassembles class files rather than running a program ----
+	JavapClasses []string // the class files to disassemble
+	JavapCode    bool     // -c: show the bytecode of the methods
+	JavapLines   bool     // -l: show the line number tables
+	JavapPrivate bool     // -p: show private members too
+	JavapVerbose bool     // -v: show the constant pool and all the details of the class
 
 	// ---- classloading items ----
-	MaxJavaVersion    int // the Java version as commonly known, i.e. Java 11
-	MaxJavaVersionRaw int // the Java version as it appears in bytecode i.e., 55 (= Java 11)
-	VerifyLevel       int
+	MaxJavaVersion    int      // the Java version as commonly known, i.e. Java 11
+	MaxJavaVersionRaw int      // the Java version as it appears in bytecode i.e., 55 (= Java 11)
+	VerifyLevel       int      // which classes are verified: VerifyNone, VerifyRemote, or VerifyAll
+	Classpath         []string // directories and jars searched by the app classloader, in order
 
 	// ---- Java Home and Version ----
 	JavaHome    string
@@ -52,7 +64,8 @@ type Globals struct {
 	JacobinHome string
 
 	// ---- thread management ----
-	Threads ThreadList // list of all app execution threads
+	Threads       ThreadList // list of all app execution threads
+	MaxFrameDepth int        // the most frames a thread's stack holds: deeper calls throw StackOverflowError
 
 	// ---- execution context ----
 	JacobinBuildData map[string]string
@@ -85,9 +98,12 @@ func InitGlobals(progName string) Globals {
 		Options:           make(map[string]Option),
 		StartingClass:     "",
 		StartingJar:       "",
-		MaxJavaVersion:    17, // this value and MaxJavaVersionRaw must *always* be in sync
-		MaxJavaVersionRaw: 61, // this value and MaxJavaVersion must *always* be in sync
-		Threads:           ThreadList{list.New(), sync.Mutex{}},
+		MaxJavaVersion:    21, // this value and MaxJavaVersionRaw must *always* be in sync
+		MaxJavaVersionRaw: 65, // this value and MaxJavaVersion must *always* be in sync
+		VerifyLevel:       VerifyRemote,
+		Classpath:         initClasspath(),
+		Threads:           ThreadList{ThreadsList: list.New()},
+		MaxFrameDepth:     DefaultThreadStackSize / FrameStackSize,
 		JacobinBuildData:  nil,
 		StrictJDK:         false,
 		ArrayAddressList:  InitArrayAddressList(),
@@ -103,13 +119,17 @@ func InitGlobals(progName string) Globals {
 		os.Exit(1)
 	}
 	InitArrayAddressList()
+	InitProperties()
 	return global
 }
 
 // ThreadList contains a list of all app execution threads and a mutex for adding new threads to the list.
+// A thread is removed from the list when it terminates.
 type ThreadList struct {
 	ThreadsList  *list.List
 	ThreadsMutex sync.Mutex
+	ThreadsByID  map[int]*list.Element // the elements of ThreadsList, by thread ID
+	NextID       int                   // the ID of the next thread added to the list
 }
 
 // GetGlobalRef returns a pointer to the singleton instance of Globals
@@ -117,6 +137,20 @@ func GetGlobalRef() *Globals {
 	return &global
 }
 
+// the levels of bytecode verification, which are set by -Xverify
+const (
+	VerifyNone   = iota // no classes are verified
+	VerifyRemote        // all classes except the JDK's are verified (the default)
+	VerifyAll           // all classes are verified
+)
+
+// -Xss sets the size of the threads' stacks, as in the JDK. Jacobin's frames are
+// not laid out on a stack of bytes, so the size is converted to a number of frames.
+const (
+	DefaultThreadStackSize = 1024 * 1024 // the default -Xss, in bytes, which is the JDK's
+	FrameStackSize         = 128         // the bytes of -Xss that each frame is counted as taking up
+)
+
 // Option is the value portion of the globals.options table. This table is described in
 // more detail in option_table_loader.go introductory comments
 type Option struct {
@@ -208,6 +242,51 @@ func cleanupPath(path string) string {
 	return path
 }
 
+// initClasspath gets the default classpath, which is the CLASSPATH environment
+// variable, or, if it's not set, the current directory
+func initClasspath() []string {
+	classpath := os.Getenv("CLASSPATH")
+	if classpath == "" {
+		classpath = "."
+	}
+	return ExpandClasspath(classpath)
+}
+
+// ExpandClasspath splits a classpath into its entries and expands the wildcard
+// entries. As in the JDK, an entry of * or one ending in /* stands for all the
+// jars in that directory (but not in its subdirectories), and an empty entry
+// is the current directory.
+func ExpandClasspath(classpath string) []string {
+	var entries []string
+	for _, entry := range filepath.SplitList(classpath) {
+		if entry == "" {
+			entry = "."
+		}
+		entry = cleanupPath(entry)
+
+		if entry != "*" && !strings.HasSuffix(entry, string(os.PathSeparator)+"*") {
+			entries = append(entries, entry)
+			continue
+		}
+
+		dir := strings.TrimSuffix(entry, "*")
+		if dir == "" {
+			dir = "."
+		}
+		files, err := os.ReadDir(dir) // the files are sorted by name
+		if err != nil {
+			continue // as in the JDK, a missing directory is ignored
+		}
+		for _, file := range files {
+			ext := filepath.Ext(file.Name())
+			if !file.IsDir() && (ext == ".jar" || ext == ".JAR") {
+				entries = append(entries, filepath.Join(dir, file.Name()))
+			}
+		}
+	}
+	return entries
+}
+
 // Array addresses must be kept in a list to avoid being GC'd.
 // This creates that list.
 func InitArrayAddressList() *list.List {
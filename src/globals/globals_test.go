@@ -9,6 +9,7 @@ package globals
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -135,3 +136,44 @@ func TestVariousInitialDefaultValues(t *testing.T) {
 		t.Errorf("Some global variables intialized to unexpected values.")
 	}
 }
+
+// the CLASSPATH environment variable sets the default classpath
+func TestClasspathFromEnvironment(t *testing.T) {
+	t.Setenv("CLASSPATH", "lib"+string(os.PathListSeparator)+"classes")
+	g := InitGlobals("test")
+	if len(g.Classpath) != 2 || g.Classpath[0] != "lib" || g.Classpath[1] != "classes" {
+		t.Errorf("Expected the classpath to be [lib classes], got: %v", g.Classpath)
+	}
+
+	t.Setenv("CLASSPATH", "")
+	g = InitGlobals("test")
+	if len(g.Classpath) != 1 || g.Classpath[0] != "." {
+		t.Errorf("Expected the default classpath to be the current directory, got: %v", g.Classpath)
+	}
+}
+
+// a wildcard entry expands to the jars in the directory, in order by name
+func TestExpandClasspathWildcard(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"b.jar", "a.JAR", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte{}, 0644); err != nil {
+			t.Fatalf("Unable to create test file %s: %s", name, err.Error())
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sub.jar"), 0755); err != nil {
+		t.Fatalf("Unable to create test directory: %s", err.Error())
+	}
+
+	sep := string(os.PathListSeparator)
+	entries := ExpandClasspath("classes" + sep + sep + filepath.Join(dir, "*") + sep +
+		filepath.Join(dir, "missing", "*"))
+	expected := []string{"classes", ".", filepath.Join(dir, "a.JAR"), filepath.Join(dir, "b.jar")}
+	if len(entries) != len(expected) {
+		t.Fatalf("Expected classpath %v, got: %v", expected, entries)
+	}
+	for i := range expected {
+		if entries[i] != expected[i] {
+			t.Errorf("Expected classpath entry %d to be %s, got: %s", i, expected[i], entries[i])
+		}
+	}
+}
@@ -0,0 +1,107 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package globals
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The system properties, which Java code reads and updates via System.getProperty(),
+// setProperty(), etc. They're set up with the defaults below when the globals are
+// initialized, and can then be overridden on the command line with -Dkey=value.
+// As in the JDK, they can be updated by any thread, so access is synchronized.
+var properties = make(map[string]string)
+var propertiesMutex sync.RWMutex
+
+// InitProperties sets the system properties to their default values
+func InitProperties() {
+	g := GetGlobalRef()
+	operSys := runtime.GOOS
+
+	defaults := map[string]string{
+		"file.separator":                string(os.PathSeparator),
+		"java.class.path":               strings.Join(g.Classpath, string(os.PathListSeparator)),
+		"java.compiler":                 "no JIT", // the name of the JIT compiler (we don't have a JIT)
+		"java.home":                     g.JavaHome,
+		"java.library.path":             g.JavaHome,
+		"java.vendor":                   "Jacobin",
+		"java.vendor.url":               "http://jacobin.org",
+		"java.vendor.version":           g.Version,
+		"java.version":                  strconv.Itoa(g.MaxJavaVersion),
+		"java.vm.name":                  fmt.Sprintf("Jacobin VM v. %s (Java %d) 64-bit VM", g.Version, g.MaxJavaVersion),
+		"java.vm.specification.name":    "Java Virtual Machine Specification",
+		"java.vm.specification.vendor":  "Oracle and Jacobin",
+		"java.vm.specification.version": strconv.Itoa(g.MaxJavaVersion),
+		"java.vm.vendor":                "Jacobin",
+		"java.vm.version":               strconv.Itoa(g.MaxJavaVersion),
+		"line.separator":                "\n",
+		"native.encoding":               "UTF8", // hard to find out what this is, so hard-coding to UTF8
+		"os.arch":                       runtime.GOARCH,
+		"os.name":                       operSys,
+		"os.version":                    "not yet available",
+		"path.separator":                string(os.PathListSeparator),
+	}
+	if operSys == "windows" {
+		defaults["line.separator"] = "\r\n"
+	}
+	if dir, err := os.Getwd(); err == nil { // present working directory
+		defaults["user.dir"] = dir
+	}
+	if currentUser, err := user.Current(); err == nil {
+		defaults["user.home"] = currentUser.HomeDir
+		defaults["user.name"] = currentUser.Username
+	}
+
+	propertiesMutex.Lock()
+	properties = defaults
+	propertiesMutex.Unlock()
+}
+
+// GetProperty returns the value of a system property and whether it's set
+func GetProperty(key string) (string, bool) {
+	propertiesMutex.RLock()
+	value, ok := properties[key]
+	propertiesMutex.RUnlock()
+	return value, ok
+}
+
+// SetProperty sets a system property and returns its previous value, if it was set
+func SetProperty(key, value string) (string, bool) {
+	propertiesMutex.Lock()
+	prev, ok := properties[key]
+	properties[key] = value
+	propertiesMutex.Unlock()
+	return prev, ok
+}
+
+// ClearProperty removes a system property and returns its value, if it was set
+func ClearProperty(key string) (string, bool) {
+	propertiesMutex.Lock()
+	prev, ok := properties[key]
+	delete(properties, key)
+	propertiesMutex.Unlock()
+	return prev, ok
+}
+
+// GetPropertyKeys returns the names of the system properties in sorted order
+func GetPropertyKeys() []string {
+	propertiesMutex.RLock()
+	keys := make([]string, 0, len(properties))
+	for key := range properties {
+		keys = append(keys, key)
+	}
+	propertiesMutex.RUnlock()
+	sort.Strings(keys)
+	return keys
+}
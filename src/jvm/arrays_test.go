@@ -109,7 +109,7 @@ func TestAaloadWithNil(t *testing.T) {
 	}
 
 	errMsg := err.Error()
-	if !strings.Contains(errMsg, "Invalid (null) reference") {
+	if !strings.Contains(errMsg, "java.lang.NullPointerException: Cannot") {
 		t.Errorf("AALOAD: Did not get expected error msg, got: %s", errMsg)
 	}
 }
@@ -180,19 +180,19 @@ func TestAastoreInvalid1(t *testing.T) {
 
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
-	_ = runFrame(fs)
+	err := runFrame(fs)
 
 	// restore stderr and stdout to what they were before
 	_ = w.Close()
-	out, _ := io.ReadAll(r)
+	_, _ = io.ReadAll(r)
 	os.Stderr = normalStderr
 
-	errMsg := string(out[:])
+	errMsg := err.Error()
 
 	_ = wout.Close()
 	os.Stdout = normalStdout
 
-	if !strings.Contains(errMsg, "Invalid (null) reference to an array") {
+	if !strings.Contains(errMsg, "java.lang.NullPointerException: Cannot") {
 		t.Errorf("AASTORE: Did not get expected error msg, got: %s", errMsg)
 	}
 }
@@ -218,19 +218,19 @@ func TestAastoreInvalid2(t *testing.T) {
 
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
-	_ = runFrame(fs)
+	err := runFrame(fs)
 
 	// restore stderr and stdout to what they were before
 	_ = w.Close()
-	out, _ := io.ReadAll(r)
+	_, _ = io.ReadAll(r)
 	os.Stderr = normalStderr
 
-	errMsg := string(out[:])
+	errMsg := err.Error()
 
 	_ = wout.Close()
 	os.Stdout = normalStdout
 
-	if !strings.Contains(errMsg, "Attempt to access array of incorrect type") {
+	if !strings.Contains(errMsg, "java.lang.ArrayStoreException: ") {
 		t.Errorf("AASTORE: Did not get expected error msg, got: %s", errMsg)
 	}
 }
@@ -255,19 +255,19 @@ func TestAastoreInvalid3(t *testing.T) {
 
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
-	_ = runFrame(fs)
+	err := runFrame(fs)
 
 	// restore stderr and stdout to what they were before
 	_ = w.Close()
-	out, _ := io.ReadAll(r)
+	_, _ = io.ReadAll(r)
 	os.Stderr = normalStderr
 
-	errMsg := string(out[:])
+	errMsg := err.Error()
 
 	_ = wout.Close()
 	os.Stdout = normalStdout
 
-	if !strings.Contains(errMsg, "AASTORE: Invalid array subscript") {
+	if !strings.Contains(errMsg, "java.lang.ArrayIndexOutOfBoundsException: Index 30 out of bounds for length 10") {
 		t.Errorf("AASTORE: Did not get expected error msg, got: %s", errMsg)
 	}
 }
@@ -347,7 +347,7 @@ func TestAnewrrayInvalidSize(t *testing.T) {
 	}
 
 	msg := err.Error()
-	if !(msg == "ANEWARRAY: Invalid size for array") {
+	if !(msg == "java.lang.NegativeArraySizeException: -1") {
 		t.Errorf("ANEWARRAY: Expecting different error msg, got %s", msg)
 	}
 }
@@ -590,7 +590,7 @@ func TestNilArrayLength(t *testing.T) {
 	}
 
 	errMsg := err.Error()
-	if errMsg != "ARRAYLENGTHY: invalid (null) reference to an array" {
+	if errMsg != "java.lang.NullPointerException: Cannot read the array length" {
 		t.Errorf("ARRAYLENGTH: Expecting different error msg, got: %s", errMsg)
 	}
 }
@@ -659,17 +659,17 @@ func TestBaloadNilArray(t *testing.T) {
 	push(&f, object.Null) // push the reference to the array, here nil
 	push(&f, int64(20))   // get contents in array[20]
 	fs := frames.CreateFrameStack()
-	fs.PushFront(&f) // push the new frame
-	_ = runFrame(fs) // execute the bytecode -- should generate exception
+	fs.PushFront(&f)    // push the new frame
+	err := runFrame(fs) // execute the bytecode -- should generate exception
 
 	// restore stderr to what they were before
 	_ = w.Close()
-	out, _ := io.ReadAll(r)
+	_, _ = io.ReadAll(r)
 	os.Stderr = normalStderr
 
-	errMsg := string(out[:])
+	errMsg := err.Error()
 
-	if !strings.Contains(errMsg, "Invalid (null) reference to an array") {
+	if !strings.Contains(errMsg, "java.lang.NullPointerException: Cannot") {
 		t.Errorf("BALOAD: Did not get expected err msg for nil array, got: %s",
 			errMsg)
 	}
@@ -701,17 +701,17 @@ func TestBaloadInvalidSubscript(t *testing.T) {
 	push(&f, ptr)        // push the reference to the array
 	push(&f, int64(200)) // get contents in array[200] which is invalid
 	fs = frames.CreateFrameStack()
-	fs.PushFront(&f) // push the new frame
-	_ = runFrame(fs) // execute the bytecode
+	fs.PushFront(&f)    // push the new frame
+	err := runFrame(fs) // execute the bytecode
 
 	// restore stderr to what they were before
 	_ = w.Close()
-	out, _ := io.ReadAll(r)
+	_, _ = io.ReadAll(r)
 	os.Stderr = normalStderr
 
-	errMsg := string(out[:])
+	errMsg := err.Error()
 
-	if !strings.Contains(errMsg, "Invalid array subscript") {
+	if !strings.Contains(errMsg, "java.lang.ArrayIndexOutOfBoundsException: Index") {
 		t.Errorf("BALOAD: Did not get expected err msg for invalid subscript, got: %s",
 			errMsg)
 	}
@@ -823,19 +823,19 @@ func TestBastoreInvalid1(t *testing.T) {
 
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
-	_ = runFrame(fs)
+	err := runFrame(fs)
 
 	// restore stderr and stdout to what they were before
 	_ = w.Close()
-	out, _ := io.ReadAll(r)
+	_, _ = io.ReadAll(r)
 	os.Stderr = normalStderr
 
-	errMsg := string(out[:])
+	errMsg := err.Error()
 
 	_ = wout.Close()
 	os.Stdout = normalStdout
 
-	if !strings.Contains(errMsg, "Invalid (null) reference to an array") {
+	if !strings.Contains(errMsg, "java.lang.NullPointerException: Cannot") {
 		t.Errorf("BASTORE: Did not get expected error msg, got: %s", errMsg)
 	}
 }
@@ -861,19 +861,19 @@ func TestBastoreInvalid2(t *testing.T) {
 
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
-	_ = runFrame(fs)
+	err := runFrame(fs)
 
 	// restore stderr and stdout to what they were before
 	_ = w.Close()
-	out, _ := io.ReadAll(r)
+	_, _ = io.ReadAll(r)
 	os.Stderr = normalStderr
 
-	errMsg := string(out[:])
+	errMsg := err.Error()
 
 	_ = wout.Close()
 	os.Stdout = normalStdout
 
-	if !strings.Contains(errMsg, "Attempt to access array of incorrect type") {
+	if !strings.Contains(errMsg, "java.lang.ArrayStoreException: ") {
 		t.Errorf("BASTORE: Did not get expected error msg, got: %s", errMsg)
 	}
 }
@@ -899,19 +899,19 @@ func TestBastoreInvalid3(t *testing.T) {
 
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
-	_ = runFrame(fs)
+	err := runFrame(fs)
 
 	// restore stderr and stdout to what they were before
 	_ = w.Close()
-	out, _ := io.ReadAll(r)
+	_, _ = io.ReadAll(r)
 	os.Stderr = normalStderr
 
-	errMsg := string(out[:])
+	errMsg := err.Error()
 
 	_ = wout.Close()
 	os.Stdout = normalStdout
 
-	if !strings.Contains(errMsg, "BASTORE: Invalid array subscript") {
+	if !strings.Contains(errMsg, "java.lang.ArrayIndexOutOfBoundsException: Index") {
 		t.Errorf("BASTORE: Did not get expected error msg, got: %s", errMsg)
 	}
 }
@@ -1030,17 +1030,17 @@ func TestDaloadNilArray(t *testing.T) {
 	push(&f, object.Null) // push the reference to the array, here nil
 	push(&f, int64(20))   // get contents in array[20]
 	fs := frames.CreateFrameStack()
-	fs.PushFront(&f) // push the new frame
-	_ = runFrame(fs) // execute the bytecode -- should generate exception
+	fs.PushFront(&f)    // push the new frame
+	err := runFrame(fs) // execute the bytecode -- should generate exception
 
 	// restore stderr to what they were before
 	_ = w.Close()
-	out, _ := io.ReadAll(r)
+	_, _ = io.ReadAll(r)
 	os.Stderr = normalStderr
 
-	errMsg := string(out[:])
+	errMsg := err.Error()
 
-	if !strings.Contains(errMsg, "Invalid (null) reference to an array") {
+	if !strings.Contains(errMsg, "java.lang.NullPointerException: Cannot") {
 		t.Errorf("DALOAD: Did not get expected err msg for nil array, got: %s",
 			errMsg)
 	}
@@ -1072,17 +1072,17 @@ func TestLaDoadInvalidSubscript(t *testing.T) {
 	push(&f, ptr)        // push the reference to the array
 	push(&f, int64(200)) // get contents in array[200] which is invalid
 	fs = frames.CreateFrameStack()
-	fs.PushFront(&f) // push the new frame
-	_ = runFrame(fs) // execute the bytecode
+	fs.PushFront(&f)    // push the new frame
+	err := runFrame(fs) // execute the bytecode
 
 	// restore stderr to what they were before
 	_ = w.Close()
-	out, _ := io.ReadAll(r)
+	_, _ = io.ReadAll(r)
 	os.Stderr = normalStderr
 
-	errMsg := string(out[:])
+	errMsg := err.Error()
 
-	if !strings.Contains(errMsg, "Invalid array subscript") {
+	if !strings.Contains(errMsg, "java.lang.ArrayIndexOutOfBoundsException: Index") {
 		t.Errorf("DALOAD: Did not get expected err msg for invalid subscript, got: %s",
 			errMsg)
 	}
@@ -1156,19 +1156,19 @@ func TestDastoreInvalid1(t *testing.T) {
 
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
-	_ = runFrame(fs)
+	err := runFrame(fs)
 
 	// restore stderr and stdout to what they were before
 	_ = w.Close()
-	out, _ := io.ReadAll(r)
+	_, _ = io.ReadAll(r)
 	os.Stderr = normalStderr
 
-	errMsg := string(out[:])
+	errMsg := err.Error()
 
 	_ = wout.Close()
 	os.Stdout = normalStdout
 
-	if !strings.Contains(errMsg, "Invalid (null) reference to an array") {
+	if !strings.Contains(errMsg, "java.lang.NullPointerException: Cannot") {
 		t.Errorf("DASTORE: Did not get expected error msg, got: %s", errMsg)
 	}
 }
@@ -1194,19 +1194,19 @@ func TestDastoreInvalid2(t *testing.T) {
 
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
-	_ = runFrame(fs)
+	err := runFrame(fs)
 
 	// restore stderr and stdout to what they were before
 	_ = w.Close()
-	out, _ := io.ReadAll(r)
+	_, _ = io.ReadAll(r)
 	os.Stderr = normalStderr
 
-	errMsg := string(out[:])
+	errMsg := err.Error()
 
 	_ = wout.Close()
 	os.Stdout = normalStdout
 
-	if !strings.Contains(errMsg, "Attempt to access array of incorrect type") {
+	if !strings.Contains(errMsg, "java.lang.ArrayStoreException: ") {
 		t.Errorf("DASTORE: Did not get expected error msg, got: %s", errMsg)
 	}
 }
@@ -1233,19 +1233,19 @@ func TestDastoreInvalid3(t *testing.T) {
 
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
-	_ = runFrame(fs)
+	err := runFrame(fs)
 
 	// restore stderr and stdout to what they were before
 	_ = w.Close()
-	out, _ := io.ReadAll(r)
+	_, _ = io.ReadAll(r)
 	os.Stderr = normalStderr
 
-	errMsg := string(out[:])
+	errMsg := err.Error()
 
 	_ = wout.Close()
 	os.Stdout = normalStdout
 
-	if !strings.Contains(errMsg, "Invalid array subscript") {
+	if !strings.Contains(errMsg, "java.lang.ArrayIndexOutOfBoundsException: Index") {
 		t.Errorf("DASTORE: Did not get expected error msg, got: %s", errMsg)
 	}
 }
@@ -1312,17 +1312,17 @@ func TestFaloadNilArray(t *testing.T) {
 	push(&f, object.Null) // push the reference to the array, here nil
 	push(&f, int64(20))   // get contents in array[20]
 	fs := frames.CreateFrameStack()
-	fs.PushFront(&f) // push the new frame
-	_ = runFrame(fs) // execute the bytecode -- should generate exception
+	fs.PushFront(&f)    // push the new frame
+	err := runFrame(fs) // execute the bytecode -- should generate exception
 
 	// restore stderr to what they were before
 	_ = w.Close()
-	out, _ := io.ReadAll(r)
+	_, _ = io.ReadAll(r)
 	os.Stderr = normalStderr
 
-	errMsg := string(out[:])
+	errMsg := err.Error()
 
-	if !strings.Contains(errMsg, "Invalid (null) reference to an array") {
+	if !strings.Contains(errMsg, "java.lang.NullPointerException: Cannot") {
 		t.Errorf("FALOAD: Did not get expected err msg for nil array, got: %s",
 			errMsg)
 	}
@@ -1354,17 +1354,17 @@ func TestFaloadInvalidSubscript(t *testing.T) {
 	push(&f, ptr)        // push the reference to the array
 	push(&f, int64(200)) // get contents in array[200] which is invalid
 	fs = frames.CreateFrameStack()
-	fs.PushFront(&f) // push the new frame
-	_ = runFrame(fs) // execute the bytecode
+	fs.PushFront(&f)    // push the new frame
+	err := runFrame(fs) // execute the bytecode
 
 	// restore stderr to what they were before
 	_ = w.Close()
-	out, _ := io.ReadAll(r)
+	_, _ = io.ReadAll(r)
 	os.Stderr = normalStderr
 
-	errMsg := string(out[:])
+	errMsg := err.Error()
 
-	if !strings.Contains(errMsg, "Invalid array subscript") {
+	if !strings.Contains(errMsg, "java.lang.ArrayIndexOutOfBoundsException: Index") {
 		t.Errorf("DALOAD: Did not get expected err msg for invalid subscript, got: %s",
 			errMsg)
 	}
@@ -1433,19 +1433,19 @@ func TestFastoreInvalid1(t *testing.T) {
 
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
-	_ = runFrame(fs)
+	err := runFrame(fs)
 
 	// restore stderr and stdout to what they were before
 	_ = w.Close()
-	out, _ := io.ReadAll(r)
+	_, _ = io.ReadAll(r)
 	os.Stderr = normalStderr
 
-	errMsg := string(out[:])
+	errMsg := err.Error()
 
 	_ = wout.Close()
 	os.Stdout = normalStdout
 
-	if !strings.Contains(errMsg, "Invalid (null) reference to an array") {
+	if !strings.Contains(errMsg, "java.lang.NullPointerException: Cannot") {
 		t.Errorf("FASTORE: Did not get expected error msg, got: %s", errMsg)
 	}
 }
@@ -1470,19 +1470,19 @@ func TestFastoreInvalid2(t *testing.T) {
 
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
-	_ = runFrame(fs)
+	err := runFrame(fs)
 
 	// restore stderr and stdout to what they were before
 	_ = w.Close()
-	out, _ := io.ReadAll(r)
+	_, _ = io.ReadAll(r)
 	os.Stderr = normalStderr
 
-	errMsg := string(out[:])
+	errMsg := err.Error()
 
 	_ = wout.Close()
 	os.Stdout = normalStdout
 
-	if !strings.Contains(errMsg, "Attempt to access array of incorrect type") {
+	if !strings.Contains(errMsg, "java.lang.ArrayStoreException: ") {
 		t.Errorf("FASTORE: Did not get expected error msg, got: %s", errMsg)
 	}
 }
@@ -1508,19 +1508,19 @@ func TestFastoreInvalid3(t *testing.T) {
 
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
-	_ = runFrame(fs)
+	err := runFrame(fs)
 
 	// restore stderr and stdout to what they were before
 	_ = w.Close()
-	out, _ := io.ReadAll(r)
+	_, _ = io.ReadAll(r)
 	os.Stderr = normalStderr
 
-	errMsg := string(out[:])
+	errMsg := err.Error()
 
 	_ = wout.Close()
 	os.Stdout = normalStdout
 
-	if !strings.Contains(errMsg, "Invalid array subscript") {
+	if !strings.Contains(errMsg, "java.lang.ArrayIndexOutOfBoundsException: Index") {
 		t.Errorf("FASTORE: Did not get expected error msg, got: %s", errMsg)
 	}
 }
@@ -1587,17 +1587,17 @@ func TestIaloadNilArray(t *testing.T) {
 	push(&f, object.Null) // push the reference to the array, here nil
 	push(&f, int64(20))   // get contents in array[20]
 	fs := frames.CreateFrameStack()
-	fs.PushFront(&f) // push the new frame
-	_ = runFrame(fs) // execute the bytecode -- should generate exception
+	fs.PushFront(&f)    // push the new frame
+	err := runFrame(fs) // execute the bytecode -- should generate exception
 
 	// restore stderr to what they were before
 	_ = w.Close()
-	out, _ := io.ReadAll(r)
+	_, _ = io.ReadAll(r)
 	os.Stderr = normalStderr
 
-	errMsg := string(out[:])
+	errMsg := err.Error()
 
-	if !strings.Contains(errMsg, "Invalid (null) reference to an array") {
+	if !strings.Contains(errMsg, "java.lang.NullPointerException: Cannot") {
 		t.Errorf("IALOAD: Did not get expected err msg for nil array, got: %s",
 			errMsg)
 	}
@@ -1644,17 +1644,17 @@ func TestIaloadInvalidSubscript(t *testing.T) {
 	push(&f, ptr)        // push the reference to the array
 	push(&f, int64(200)) // get contents in array[200] which is invalid
 	fs = frames.CreateFrameStack()
-	fs.PushFront(&f) // push the new frame
-	_ = runFrame(fs) // execute the bytecode
+	fs.PushFront(&f)    // push the new frame
+	err := runFrame(fs) // execute the bytecode
 
 	// restore stderr to what they were before
 	_ = w.Close()
-	out, _ := io.ReadAll(r)
+	_, _ = io.ReadAll(r)
 	os.Stderr = normalStderr
 
-	errMsg := string(out[:])
+	errMsg := err.Error()
 
-	if !strings.Contains(errMsg, "Invalid array subscript") {
+	if !strings.Contains(errMsg, "java.lang.ArrayIndexOutOfBoundsException: Index") {
 		t.Errorf("IALOAD: Did not get expected err msg for invalid subscript, got: %s",
 			errMsg)
 	}
@@ -1723,19 +1723,19 @@ func TestIastoreInvalid1(t *testing.T) {
 
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
-	_ = runFrame(fs)
+	err := runFrame(fs)
 
 	// restore stderr and stdout to what they were before
 	_ = w.Close()
-	out, _ := io.ReadAll(r)
+	_, _ = io.ReadAll(r)
 	os.Stderr = normalStderr
 
-	errMsg := string(out[:])
+	errMsg := err.Error()
 
 	_ = wout.Close()
 	os.Stdout = normalStdout
 
-	if !strings.Contains(errMsg, "Invalid (null) reference to an array") {
+	if !strings.Contains(errMsg, "java.lang.NullPointerException: Cannot") {
 		t.Errorf("IASTORE: Did not get expected error msg, got: %s", errMsg)
 	}
 }
@@ -1761,19 +1761,19 @@ func TestIastoreInvalid2(t *testing.T) {
 
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
-	_ = runFrame(fs)
+	err := runFrame(fs)
 
 	// restore stderr and stdout to what they were before
 	_ = w.Close()
-	out, _ := io.ReadAll(r)
+	_, _ = io.ReadAll(r)
 	os.Stderr = normalStderr
 
-	errMsg := string(out[:])
+	errMsg := err.Error()
 
 	_ = wout.Close()
 	os.Stdout = normalStdout
 
-	if !strings.Contains(errMsg, "Attempt to access array of incorrect type") {
+	if !strings.Contains(errMsg, "java.lang.ArrayStoreException: ") {
 		t.Errorf("IASTORE: Did not get expected error msg, got: %s", errMsg)
 	}
 }
@@ -1799,19 +1799,19 @@ func TestIastoreInvalid3(t *testing.T) {
 
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
-	_ = runFrame(fs)
+	err := runFrame(fs)
 
 	// restore stderr and stdout to what they were before
 	_ = w.Close()
-	out, _ := io.ReadAll(r)
+	_, _ = io.ReadAll(r)
 	os.Stderr = normalStderr
 
-	errMsg := string(out[:])
+	errMsg := err.Error()
 
 	_ = wout.Close()
 	os.Stdout = normalStdout
 
-	if !strings.Contains(errMsg, "IA/CA/SATORE: Invalid array subscript") {
+	if !strings.Contains(errMsg, "java.lang.ArrayIndexOutOfBoundsException: Index 30 out of bounds for length 10") {
 		t.Errorf("IASTORE: Did not get expected error msg, got: %s", errMsg)
 	}
 }
@@ -1880,17 +1880,17 @@ func TestLaloadNilArray(t *testing.T) {
 	push(&f, object.Null) // push the reference to the array, here nil
 	push(&f, int64(20))   // get contents in array[20]
 	fs := frames.CreateFrameStack()
-	fs.PushFront(&f) // push the new frame
-	_ = runFrame(fs) // execute the bytecode -- should generate exception
+	fs.PushFront(&f)    // push the new frame
+	err := runFrame(fs) // execute the bytecode -- should generate exception
 
 	// restore stderr to what they were before
 	_ = w.Close()
-	out, _ := io.ReadAll(r)
+	_, _ = io.ReadAll(r)
 	os.Stderr = normalStderr
 
-	errMsg := string(out[:])
+	errMsg := err.Error()
 
-	if !strings.Contains(errMsg, "Invalid (null) reference to an array") {
+	if !strings.Contains(errMsg, "java.lang.NullPointerException: Cannot") {
 		t.Errorf("LALOAD: Did not get expected err msg for nil array, got: %s",
 			errMsg)
 	}
@@ -1922,17 +1922,17 @@ func TestLaloadInvalidSubscript(t *testing.T) {
 	push(&f, ptr)        // push the reference to the array
 	push(&f, int64(200)) // get contents in array[200] which is invalid
 	fs = frames.CreateFrameStack()
-	fs.PushFront(&f) // push the new frame
-	_ = runFrame(fs) // execute the bytecode
+	fs.PushFront(&f)    // push the new frame
+	err := runFrame(fs) // execute the bytecode
 
 	// restore stderr to what they were before
 	_ = w.Close()
-	out, _ := io.ReadAll(r)
+	_, _ = io.ReadAll(r)
 	os.Stderr = normalStderr
 
-	errMsg := string(out[:])
+	errMsg := err.Error()
 
-	if !strings.Contains(errMsg, "Invalid array subscript") {
+	if !strings.Contains(errMsg, "java.lang.ArrayIndexOutOfBoundsException: Index") {
 		t.Errorf("LALOAD: Did not get expected err msg for invalid subscript, got: %s",
 			errMsg)
 	}
@@ -2005,19 +2005,19 @@ func TestLastoreInvalid1(t *testing.T) {
 
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
-	_ = runFrame(fs)
+	err := runFrame(fs)
 
 	// restore stderr and stdout to what they were before
 	_ = w.Close()
-	out, _ := io.ReadAll(r)
+	_, _ = io.ReadAll(r)
 	os.Stderr = normalStderr
 
-	errMsg := string(out[:])
+	errMsg := err.Error()
 
 	_ = wout.Close()
 	os.Stdout = normalStdout
 
-	if !strings.Contains(errMsg, "Invalid (null) reference to an array") {
+	if !strings.Contains(errMsg, "java.lang.NullPointerException: Cannot") {
 		t.Errorf("LASTORE: Did not get expected error msg, got: %s", errMsg)
 	}
 }
@@ -2044,19 +2044,19 @@ func TestLastoreInvalid2(t *testing.T) {
 
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
-	_ = runFrame(fs)
+	err := runFrame(fs)
 
 	// restore stderr and stdout to what they were before
 	_ = w.Close()
-	out, _ := io.ReadAll(r)
+	_, _ = io.ReadAll(r)
 	os.Stderr = normalStderr
 
-	errMsg := string(out[:])
+	errMsg := err.Error()
 
 	_ = wout.Close()
 	os.Stdout = normalStdout
 
-	if !strings.Contains(errMsg, "Attempt to access array of incorrect type") {
+	if !strings.Contains(errMsg, "java.lang.ArrayStoreException: ") {
 		t.Errorf("LASTORE: Did not get expected error msg, got: %s", errMsg)
 	}
 }
@@ -2083,19 +2083,19 @@ func TestLastoreInvalid3(t *testing.T) {
 
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
-	_ = runFrame(fs)
+	err := runFrame(fs)
 
 	// restore stderr and stdout to what they were before
 	_ = w.Close()
-	out, _ := io.ReadAll(r)
+	_, _ = io.ReadAll(r)
 	os.Stderr = normalStderr
 
-	errMsg := string(out[:])
+	errMsg := err.Error()
 
 	_ = wout.Close()
 	os.Stdout = normalStdout
 
-	if !strings.Contains(errMsg, "Invalid array subscript") {
+	if !strings.Contains(errMsg, "java.lang.ArrayIndexOutOfBoundsException: Index") {
 		t.Errorf("LASTORE: Did not get expected error msg, got: %s", errMsg)
 	}
 }
@@ -2342,7 +2342,7 @@ func TestNewrrayInvalidSize(t *testing.T) {
 	}
 
 	errMsg := err.Error()
-	if !strings.Contains(errMsg, "Invalid size for array") {
+	if !strings.Contains(errMsg, "java.lang.NegativeArraySizeException: -13") {
 		t.Errorf("NEWARRAY: Got unexpected error message: %s", errMsg)
 	}
 }
@@ -0,0 +1,263 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"container/list"
+	"fmt"
+	"jacobin/classloader"
+	"jacobin/exceptions"
+	"jacobin/frames"
+	"jacobin/globals"
+	"strings"
+	"sync"
+)
+
+// A class is initialized--its static fields are prepared and its static initializer,
+// <clinit>, is run--just before its first active use: the first NEW of an instance,
+// GETSTATIC or PUTSTATIC of one of its static fields, or INVOKESTATIC of one of its
+// methods. The procedure is the one in JVMS 5.5: the superclass is initialized first;
+// a thread that asks for the initialization of a class it's already initializing
+// (as happens when <clinit> refers to its own class) proceeds at once; other threads
+// wait for the initialization to finish; and a class whose initialization failed
+// can't be used thereafter.
+
+// the states of a class's initialization
+const (
+	classUninitialized = iota
+	classInitializing
+	classInitialized
+	classInitFailed
+)
+
+// classInit is the initialization state of a class
+type classInit struct {
+	mutex  sync.Mutex
+	done   *sync.Cond // signaled when the initialization finishes or fails
+	state  int
+	thread int // the thread that is running the initialization
+}
+
+var classInits sync.Map // *classloader.Klass -> *classInit
+
+// initializedClasses holds the names of the classes whose initialization has finished,
+// so the instructions that initialize a class on every execution return at once
+var initializedClasses sync.Map // string -> struct{}
+
+// the packages of the JDK's classes. Jacobin does not yet run their static
+// initializers: it sets up the statics of these classes itself (see StaticsPreload()
+// and nativeClassInits) and implements many of their methods natively.
+var jdkPackagePrefixes = []string{"java/", "javax/", "jdk/", "sun/", "com/sun/"}
+
+// the types of the statics that the JDK's static initializers set up for atomic
+// access. A JDK class that has such statics, but no native initializer, can't be
+// used: its methods would find the statics null.
+var atomicAccessTypes = []string{"Ljdk/internal/misc/Unsafe;", "Ljava/lang/invoke/VarHandle;"}
+
+// nativeClassInits are Go-based static initializers that run in place of the <clinit>
+// of the JDK classes whose methods need statics that Jacobin must set up itself, such
+// as the Unsafe offsets of the concurrency classes. These classes are otherwise
+// initialized as any other class is.
+var nativeClassInits = map[string]func(fs *list.List, className string) error{
+	"java/util/concurrent/atomic/AtomicInteger": initAtomicClass,
+	"java/util/concurrent/atomic/AtomicLong":    initAtomicClass,
+	"java/util/concurrent/ConcurrentHashMap":    initConcurrentHashMap,
+	"jdk/internal/misc/Unsafe":                  initUnsafe,
+}
+
+// isJDKClass reports whether the class is in one of the JDK's packages
+func isJDKClass(className string) bool {
+	for _, prefix := range jdkPackagePrefixes {
+		if strings.HasPrefix(className, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// initializeClass links (see linkClass()) and initializes the class if that hasn't been done yet. If the
+// initialization fails, the error is a Java exception that should be thrown
+// by the current instruction.
+func initializeClass(fs *list.List, className string) error {
+	if strings.HasPrefix(className, "[") {
+		return nil // array classes have no initializers
+	}
+	if _, done := initializedClasses.Load(className); done {
+		return nil
+	}
+	if isJDKClass(className) && nativeClassInits[className] == nil {
+		if err := initializeJDKClass(fs, className); err != nil {
+			return err
+		}
+		initializedClasses.Store(className, struct{}{})
+		return nil
+	}
+
+	if err := loadThisClass(className); err != nil {
+		return err
+	}
+	k := classloader.MethAreaFetch(className)
+	if k == nil || k.Data == nil {
+		return fmt.Errorf("initializeClass: class %s not found", className)
+	}
+	if err := linkClass(fs, k, className); err != nil {
+		return err
+	}
+
+	var ci *classInit
+	if v, ok := classInits.Load(k); ok {
+		ci = v.(*classInit)
+	} else {
+		ci = &classInit{}
+		ci.done = sync.NewCond(&ci.mutex)
+		if v, loaded := classInits.LoadOrStore(k, ci); loaded {
+			ci = v.(*classInit)
+		}
+	}
+	thread := fs.Front().Value.(*frames.Frame).Thread
+
+	ci.mutex.Lock()
+	for ci.state == classInitializing && ci.thread != thread {
+		ci.done.Wait() // another thread is initializing the class
+	}
+	switch ci.state {
+	case classInitialized, classInitializing: // done, or a recursive request
+		ci.mutex.Unlock()
+		return nil
+	case classInitFailed:
+		ci.mutex.Unlock()
+		return newVMThrowable(fs, exceptions.NoClassDefFoundError,
+			"Could not initialize class "+strings.ReplaceAll(className, "/", "."))
+	}
+	ci.state = classInitializing
+	ci.thread = thread
+	ci.mutex.Unlock()
+
+	err := runClassInitialization(fs, k, className)
+
+	ci.mutex.Lock()
+	if err != nil {
+		ci.state = classInitFailed
+	} else {
+		ci.state = classInitialized
+		initializedClasses.Store(className, struct{}{})
+	}
+	ci.done.Broadcast()
+	ci.mutex.Unlock()
+	return err
+}
+
+// initializeJDKClass links (see linkClass()) a class of the JDK whose static initializer
+// Jacobin does not run, after doing the same for its superclass. If the class has
+// statics for atomic access, which the initializer would have set up, the error is an
+// InternalError, so the class fails when it's first used rather than in some later call.
+func initializeJDKClass(fs *list.List, className string) error {
+	k := loadForVerification(className)
+	if k == nil {
+		return nil // the instruction that uses the class reports that it's missing
+	}
+	if k.Data.Superclass != "" {
+		if err := initializeClass(fs, k.Data.Superclass); err != nil {
+			return err
+		}
+	}
+	if globals.GetGlobalRef().VerifyLevel == globals.VerifyAll {
+		if err := linkClass(fs, k, className); err != nil {
+			return err
+		}
+	}
+
+	for _, field := range k.Data.Fields {
+		if !field.IsStatic {
+			continue
+		}
+		desc := k.Data.CP.Utf8Refs[field.Desc]
+		for _, atomicType := range atomicAccessTypes {
+			if desc == atomicType {
+				return newVMThrowable(fs, exceptions.InternalError, "static "+k.Data.CP.Utf8Refs[field.Name]+
+					" of class "+strings.ReplaceAll(className, "/", ".")+" is not set up by Jacobin")
+			}
+		}
+	}
+	return nil
+}
+
+// runClassInitialization initializes the superclass, prepares the static
+// fields, and runs <clinit>, if the class has one, or its native initializer
+// (see nativeClassInits). Exceptions thrown by <clinit>
+// that are not Errors are wrapped in an ExceptionInInitializerError.
+func runClassInitialization(fs *list.List, k *classloader.Klass, className string) error {
+	if k.Data.Superclass != "" {
+		if err := initializeClass(fs, k.Data.Superclass); err != nil {
+			return err
+		}
+	}
+
+	for _, field := range k.Data.Fields {
+		if field.IsStatic {
+			if _, err := createField(field, k, className); err != nil {
+				return err
+			}
+		}
+	}
+
+	if nativeInit, ok := nativeClassInits[className]; ok {
+		return nativeInit(fs, className)
+	}
+	if !declaresMethod(k, "<clinit>", "()V") {
+		return nil
+	}
+	mte, err := classloader.FetchMethodAndCP(className, "<clinit>", "()V")
+	if err != nil {
+		return err
+	}
+
+	_, err = runJavaMethod(fs, mte, className, "<clinit>", "()V", false, nil)
+	if jt, ok := err.(*javaThrowable); ok && !isClassOrSubclassOf(jt.className, "java/lang/Error") {
+		return newInitializerError(fs, jt)
+	}
+	return err
+}
+
+// declaresMethod reports whether the class itself (rather than a superclass)
+// declares the method
+func declaresMethod(k *classloader.Klass, methName, methType string) bool {
+	return findDeclaredMethod(k, methName, methType) != nil
+}
+
+// newVMThrowable creates an exception detected by the JVM, with the stack trace
+// of the current frames, to be thrown by the current instruction
+func newVMThrowable(fs *list.List, excType int, msg string) *javaThrowable {
+	return &javaThrowable{
+		className: exceptions.ExceptionClassNames[excType],
+		msg:       msg,
+		trace:     captureStackTrace(fs.Front()),
+	}
+}
+
+// newInitializerError creates the ExceptionInInitializerError for an exception
+// thrown by a static initializer. The exception becomes its cause.
+func newInitializerError(fs *list.List, cause *javaThrowable) *javaThrowable {
+	jt := newVMThrowable(fs, exceptions.ExceptionInInitializerError, "")
+	obj, err := newExceptionObject(jt.className, "")
+	if err != nil {
+		return jt
+	}
+	setObjectFieldByName(obj, "backtrace", jt.trace)
+	jt.obj = obj
+
+	if cause.obj == nil { // thrown by the JVM, so not yet instantiated
+		causeObj, err := newExceptionObject(cause.className, cause.msg)
+		if err != nil {
+			return jt
+		}
+		setObjectFieldByName(causeObj, "backtrace", cause.trace)
+		cause.obj = causeObj
+	}
+	setObjectFieldByName(obj, "cause", cause.obj)
+	return jt
+}
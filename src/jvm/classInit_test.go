@@ -0,0 +1,156 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"jacobin/classloader"
+	"jacobin/frames"
+	"jacobin/object"
+	"testing"
+)
+
+// adds the exception classes used when a static initializer fails
+func addInitExceptionClasses() {
+	addClass("java/lang/Throwable", "java/lang/Object", nil, newCPBuilder(), []testField{
+		{"detailMessage", "Ljava/lang/String;", false},
+		{"cause", "Ljava/lang/Throwable;", false},
+		{"backtrace", "Ljava/lang/Object;", false}})
+	addTestClass("java/lang/Exception", "java/lang/Throwable", nil)
+	addTestClass("java/lang/RuntimeException", "java/lang/Exception", nil)
+	addTestClass("java/lang/ArithmeticException", "java/lang/RuntimeException", nil)
+	addTestClass("java/lang/Error", "java/lang/Throwable", nil)
+	addTestClass("java/lang/LinkageError", "java/lang/Error", nil)
+	addTestClass("java/lang/ExceptionInInitializerError", "java/lang/LinkageError", nil)
+	addTestClass("java/lang/NoClassDefFoundError", "java/lang/LinkageError", nil)
+}
+
+// sets up these classes, whose static initializers are:
+//
+//	class Tally { static int count; static { count = count + 5; } }
+//	class Order { static int seq; }
+//	class Base { static { Order.seq = Order.seq * 10 + 1; } }
+//	class Derived extends Base { static { Order.seq = Order.seq * 10 + 2; } }
+//	class Broken { static { int x = 1 / 0; } static int run() { return 1; } }
+//
+// It returns the CP of the test code and the CP indexes of Tally.count,
+// Order.seq, the class Derived, and Broken.run()
+func setupClassInitClasses() (*classloader.CPool, []uint16) {
+	setupInterfaceClasses()
+	addInitExceptionClasses()
+	classloader.Statics = make(map[string]classloader.Static)
+
+	const static = 0x0008
+	b := newCPBuilder()
+	count := b.fieldRef("test/Tally", "count", "I")
+	addClass("test/Tally", "java/lang/Object", nil, b, []testField{{"count", "I", true}},
+		testMethod{"<clinit>", "()V", static, []byte{
+			GETSTATIC, 0x00, byte(count), ICONST_5, IADD, PUTSTATIC, 0x00, byte(count), RETURN}})
+
+	addClass("test/Order", "java/lang/Object", nil, newCPBuilder(), []testField{{"seq", "I", true}})
+	for _, class := range []struct {
+		name, superclass string
+		digit            byte
+	}{{"test/Base", "java/lang/Object", ICONST_1}, {"test/Derived", "test/Base", ICONST_2}} {
+		b = newCPBuilder()
+		seq := b.fieldRef("test/Order", "seq", "I")
+		addClass(class.name, class.superclass, nil, b, nil,
+			testMethod{"<clinit>", "()V", static, []byte{
+				GETSTATIC, 0x00, byte(seq), BIPUSH, 10, IMUL, class.digit, IADD,
+				PUTSTATIC, 0x00, byte(seq), RETURN}})
+	}
+
+	addClass("test/Broken", "java/lang/Object", nil, newCPBuilder(), nil,
+		testMethod{"<clinit>", "()V", static, []byte{ICONST_1, ICONST_0, IDIV, POP, RETURN}},
+		testMethod{"run", "()I", static, []byte{ICONST_1, IRETURN}})
+
+	b = newCPBuilder()
+	indexes := []uint16{
+		b.fieldRef("test/Tally", "count", "I"),
+		b.fieldRef("test/Order", "seq", "I"),
+		b.class("test/Derived"),
+		b.methodRef("test/Broken", "run", "()I"),
+	}
+	return &b.cp, indexes
+}
+
+// GETSTATIC: the static initializer runs once, on the first access, and can
+// itself access the class's statics
+func TestClassInitOnGetstatic(t *testing.T) {
+	CP, indexes := setupClassInitClasses()
+
+	f, err := runMainTestCode(CP,
+		GETSTATIC, 0x00, byte(indexes[0]),
+		GETSTATIC, 0x00, byte(indexes[0]),
+		IADD)
+	if err != nil {
+		t.Fatalf("GETSTATIC: Unexpected error: %s", err.Error())
+	}
+	if f.OpStack[0] != int64(10) {
+		t.Errorf("GETSTATIC: Expected Tally.count to be initialized to 5 once, got a sum of: %v", f.OpStack[0])
+	}
+}
+
+// NEW: the superclass is initialized before the class
+func TestClassInitSuperclassFirst(t *testing.T) {
+	CP, indexes := setupClassInitClasses()
+
+	f, err := runMainTestCode(CP,
+		NEW, 0x00, byte(indexes[2]),
+		GETSTATIC, 0x00, byte(indexes[1]))
+	if err != nil {
+		t.Fatalf("NEW: Unexpected error: %s", err.Error())
+	}
+	if f.OpStack[1] != int64(12) {
+		t.Errorf("NEW: Expected Base and then Derived to be initialized (12), got: %v", f.OpStack[1])
+	}
+}
+
+// Once a class is initialized, initializing it again returns at once, with no allocation
+func TestClassInitFastPath(t *testing.T) {
+	CP, indexes := setupClassInitClasses()
+	f, err := runMainTestCode(CP, GETSTATIC, 0x00, byte(indexes[0]))
+	if err != nil {
+		t.Fatalf("GETSTATIC: Unexpected error: %s", err.Error())
+	}
+
+	fs := frames.CreateFrameStack()
+	fs.PushFront(f)
+	allocs := testing.AllocsPerRun(100, func() {
+		if err := initializeClass(fs, "test/Tally"); err != nil {
+			t.Fatalf("Unexpected error: %s", err.Error())
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("Expected no allocations initializing an initialized class, got: %v", allocs)
+	}
+}
+
+// INVOKESTATIC: an exception in the static initializer is wrapped in an
+// ExceptionInInitializerError, and later uses of the class fail
+func TestClassInitFailure(t *testing.T) {
+	CP, indexes := setupClassInitClasses()
+
+	_, err := runMainTestCode(CP, INVOKESTATIC, 0x00, byte(indexes[3]))
+	jt, ok := err.(*javaThrowable)
+	if !ok || jt.className != "java/lang/ExceptionInInitializerError" {
+		t.Fatalf("INVOKESTATIC: Expected an ExceptionInInitializerError, got: %v", err)
+	}
+	value, _ := getObjectFieldByName(jt.obj, "cause")
+	cause, ok := value.(*object.Object)
+	if !ok || *cause.Klass != "java/lang/ArithmeticException" {
+		t.Errorf("INVOKESTATIC: Expected an ArithmeticException as the cause, got: %v", cause)
+	}
+
+	_, err = runMainTestCode(CP, INVOKESTATIC, 0x00, byte(indexes[3]))
+	jt, ok = err.(*javaThrowable)
+	if !ok || jt.className != "java/lang/NoClassDefFoundError" {
+		t.Fatalf("INVOKESTATIC: Expected a NoClassDefFoundError, got: %v", err)
+	}
+	if jt.msg != "Could not initialize class test.Broken" {
+		t.Errorf("INVOKESTATIC: Unexpected message: %s", jt.msg)
+	}
+}
@@ -41,6 +41,12 @@ func HandleCli(osArgs []string, Global *globals.Globals) (err error) {
 	for _, v := range osArgs[1:] {
 		args = append(args, v)
 	}
+
+	// replace any @argfiles with the args they contain
+	args, err = expandArgFiles(args, Global)
+	if err != nil {
+		return err
+	}
 	Global.Args = args
 	showCopyright(Global)
 
@@ -68,6 +74,27 @@ func HandleCli(osArgs []string, Global *globals.Globals) (err error) {
 			break
 		}
 
+		// likewise for a source file to compile and run. With --source, the file
+		// need not end in .java.
+		if strings.HasSuffix(option, ".java") ||
+			(Global.SourceVersion != "" && !strings.HasPrefix(option, "-")) {
+			Global.StartingSourceFile = option
+			for i = i + 1; i < len(args); i++ {
+				Global.AppArgs = append(Global.AppArgs, args[i])
+			}
+			break
+		}
+
+		// otherwise, the first arg that is not an option is the binary name of the
+		// main class, which is found on the classpath
+		if !strings.HasPrefix(option, "-") {
+			Global.MainClassName = option
+			for i = i + 1; i < len(args); i++ {
+				Global.AppArgs = append(Global.AppArgs, args[i])
+			}
+			break
+		}
+
 		opt, ok := Global.Options[option]
 		if ok {
 			i, _ = opt.Action(i, arg, Global)
@@ -84,6 +111,145 @@ func HandleCli(osArgs []string, Global *globals.Globals) (err error) {
 	return nil
 }
 
+// expandArgFiles replaces each @argfile in the args with the args in the file, as the
+// JDK does. Expansion stops at the main class or JAR (so app args are never expanded)
+// and at the --disable-@files option. An arg beginning with @@ is not an argfile: one
+// @ is removed and the rest is passed as is. Argfiles can't contain other argfiles.
+func expandArgFiles(args []string, Global *globals.Globals) ([]string, error) {
+	var expanded []string
+	expanding := true
+	optionValueNext := false // the next arg is the value of an option, e.g., of -cp
+	lastOption := false      // the option value is the JAR, after which come app args
+	for _, arg := range args {
+		if !expanding {
+			expanded = append(expanded, arg)
+			continue
+		}
+
+		argsToAdd := []string{arg}
+		if strings.HasPrefix(arg, "@@") {
+			argsToAdd = []string{arg[1:]}
+		} else if strings.HasPrefix(arg, "@") {
+			contents, err := os.ReadFile(arg[1:])
+			if err != nil {
+				_, _ = fmt.Fprintf(os.Stderr, "Error: could not open `%s'\n", arg[1:])
+				return nil, err
+			}
+			argsToAdd = parseArgFile(string(contents))
+			_ = log.Log(fmt.Sprintf("Expanded %s to: %v", arg, argsToAdd), log.FINE)
+		}
+
+		// the args that end the expansion can come from an argfile, so check each one
+		for _, a := range argsToAdd {
+			expanded = append(expanded, a)
+			switch {
+			case optionValueNext:
+				optionValueNext = false
+				expanding = !lastOption
+			case a == "--disable-@files":
+				expanding = false
+			case a == "-jar":
+				optionValueNext, lastOption = true, true
+			case strings.HasPrefix(a, "-"):
+				opt, ok := Global.Options[a]
+				optionValueNext = ok && opt.ArgStyle == 4 // the value is the next arg
+			default: // the main class, so the remaining args are app args
+				expanding = false
+			}
+		}
+	}
+	return expanded, nil
+}
+
+// parseArgFile splits the contents of an argfile into args, per the JDK's rules:
+// args are separated by whitespace; a # begins a comment that runs to the end of
+// the line; and whitespace can be included in an arg by enclosing it in single or
+// double quotes. Within quotes, a backslash escapes the next character (\n, \r, \t,
+// and \f are the usual control characters), and a backslash at the end of a line
+// continues the arg on the next line, whose leading whitespace is dropped. A quoted
+// arg ends at the end of the line if its closing quote is missing.
+func parseArgFile(contents string) []string {
+	var args []string
+	var arg strings.Builder
+	inArg := false   // an arg has been started, even if it's still empty (e.g., "")
+	var quote rune   // the open quote, or 0 if not in quotes
+	escaped := false // the previous character was a backslash within quotes
+	inComment := false
+	skipSpace := false // skipping the leading whitespace of a continued line
+
+	endArg := func() {
+		if inArg {
+			args = append(args, arg.String())
+		}
+		arg.Reset()
+		inArg = false
+	}
+
+	runes := []rune(contents)
+	for i := 0; i < len(runes); i++ {
+		ch := runes[i]
+		if inComment {
+			inComment = ch != '\n' && ch != '\r'
+			continue
+		}
+		if skipSpace {
+			if ch == ' ' || ch == '\t' || ch == '\f' {
+				continue
+			}
+			skipSpace = false
+		}
+
+		if escaped {
+			escaped = false
+			switch ch {
+			case 'n':
+				arg.WriteRune('\n')
+			case 'r':
+				arg.WriteRune('\r')
+			case 't':
+				arg.WriteRune('\t')
+			case 'f':
+				arg.WriteRune('\f')
+			case '\r', '\n': // a line continuation
+				if ch == '\r' && i+1 < len(runes) && runes[i+1] == '\n' {
+					i++
+				}
+				skipSpace = true
+			default:
+				arg.WriteRune(ch)
+			}
+			continue
+		}
+
+		switch {
+		case ch == '\n' || ch == '\r':
+			quote = 0 // an unterminated quote ends at the end of the line
+			endArg()
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			} else if ch == '\\' {
+				escaped = true
+			} else {
+				arg.WriteRune(ch)
+			}
+		case ch == ' ' || ch == '\t' || ch == '\f':
+			endArg()
+		case ch == '#':
+			endArg()
+			inComment = true
+		case ch == '"' || ch == '\'':
+			quote = ch
+			inArg = true
+		default:
+			arg.WriteRune(ch)
+			inArg = true
+		}
+	}
+	endArg()
+	return args
+}
+
 // pass in the option potentially with embedded arguments and get back
 // the option name and the embedded argument(s), if any
 func getOptionRootAndArgs(option string) (string, string, error) {
@@ -91,11 +257,18 @@ func getOptionRootAndArgs(option string) (string, string, error) {
 		return "", "", errors.New("empty option error")
 	}
 
-	// if the option has an embedded arg value, it'll come after a : or an =
-	argMarker := strings.Index(option, ":")
-	if argMarker == -1 {
-		argMarker = strings.Index(option, "=")
+	// a -D option embeds a property definition, e.g., -Dkey=value, which is its arg,
+	// and -Xss embeds a size, e.g., -Xss512k
+	if strings.HasPrefix(option, "-D") {
+		return "-D", option[2:], nil
 	}
+	if strings.HasPrefix(option, "-Xss") {
+		return "-Xss", option[4:], nil
+	}
+
+	// if the option has an embedded arg value, it'll come after the first : or =
+	// (the value itself can contain either character, e.g., --class-path=a.jar:b.jar)
+	argMarker := strings.IndexAny(option, ":=")
 
 	// if there's no embedded : or = then the option doesn't contain an arg value
 	if argMarker == -1 {
@@ -146,11 +319,28 @@ Usage: jacobin [options] <mainclass> [args...]
 	        (to execute a class)
    or jacobin [options] -jar <jarfile> [args...]
 	        (to execute a jar file)
+   or jacobin [options] -m <module>/<mainclass> [args...]
+       jacobin [options] --module <module>/<mainclass> [args...]
+	        (to execute the main class in a module)
+   or jacobin [options] <source-file> [args...]
+	        (to compile and execute a source-file program)
+   or jacobin -javap [-c] [-l] [-p] [-v] <classfile>...
+	        (to disassemble class files)
 Arguments following the main class, source file, -jar <jarfile>,
-are passed as the arguments to main class.
+-m or --module <module>/<mainclass> are passed as the arguments to
+main class.
 
 where options include:
+	-cp <class search path of directories and zip/jar files>
+	-classpath <class search path of directories and zip/jar files>
+	--class-path <class search path of directories and zip/jar files>
+	              A : separated list of directories, JAR archives,
+	              and ZIP archives to search for class files.
 	-client       to select the "client" VM
+	-D<name>=<value>
+	              set a system property
+	--source <version>
+	              set the version of the source in source-file mode
 	-verbose:[class|info|fine|finest]  enable verbose output
                   info, fine, finest are Jacobin-specific options providing
                     increasing amounts of detail. The finest level is used
@@ -162,10 +352,24 @@ where options include:
 	-showversion  print product version to the error stream and continue
 	--show-version
 				  print product version to the output stream and continue
+	@argument files
+	              one or more argument files containing options
+	--disable-@files
+	              prevent further argument file expansion
+	-Xss<size>    set the size of the threads' stacks, e.g., -Xss512k or -Xss2m.
+	              Calls nested more deeply than this allows throw
+	              java.lang.StackOverflowError
 
 Jacobin-specific options:
+	-javap [-c] [-l] [-p] [-v] <classfile>...
+	              disassemble the class files, as javap does: -c shows the
+	              bytecode, -l the line numbers, -p private members, and
+	              -v the constant pool and all other details
 	-strictJDK    make user messages conform closely to the JDK's format
-	-trace:inst   display instruction-level tracing data to the console`
+	-trace:inst   display instruction-level tracing data to the console
+	-Xverify:[none|remote|all]
+	              verify no classes, all classes but the JDK's (the default),
+	              or all classes before they are used`
 
 	_, _ = fmt.Fprintln(outStream, userMessage)
 }
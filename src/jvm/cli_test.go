@@ -11,6 +11,7 @@ import (
 	"jacobin/globals"
 	"jacobin/log"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -541,3 +542,344 @@ func TestEmptyOptionForEmbeddedArg(t *testing.T) {
 		t.Error("Empty option should fail test for embedded args, but did not.")
 	}
 }
+
+func TestClasspathOptions(t *testing.T) {
+	sep := string(os.PathListSeparator)
+	tests := []struct {
+		args     []string
+		expected []string
+	}{
+		{[]string{"jacobin", "-cp", "lib" + sep + "classes", "Hello.class"}, []string{"lib", "classes"}},
+		{[]string{"jacobin", "-classpath", "lib", "Hello.class"}, []string{"lib"}},
+		{[]string{"jacobin", "--class-path", "a.jar" + sep + "b.jar", "Hello.class"}, []string{"a.jar", "b.jar"}},
+		{[]string{"jacobin", "--class-path=a.jar" + sep + "b.jar", "Hello.class"}, []string{"a.jar", "b.jar"}},
+		{[]string{"jacobin", "-cp", "lib", "-jar", "app.jar"}, []string{"app.jar"}},
+	}
+
+	for _, test := range tests {
+		global := globals.InitGlobals("test")
+		LoadOptionsTable(global)
+		_ = HandleCli(test.args, &global)
+
+		if len(global.Classpath) != len(test.expected) {
+			t.Errorf("%v: expected classpath %v, got: %v", test.args, test.expected, global.Classpath)
+			continue
+		}
+		for i := range test.expected {
+			if global.Classpath[i] != test.expected[i] {
+				t.Errorf("%v: expected classpath %v, got: %v", test.args, test.expected, global.Classpath)
+				break
+			}
+		}
+	}
+}
+
+func TestMissingClasspath(t *testing.T) {
+	global := globals.InitGlobals("test")
+	LoadOptionsTable(global)
+	global.Args = []string{"jacobin", "-cp"}
+
+	normalStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+
+	_, err := getClasspath(1, "", &global)
+
+	_ = w.Close()
+	os.Stderr = normalStderr
+	out, _ := io.ReadAll(r)
+
+	if err != os.ErrInvalid {
+		t.Error("Missing classpath after -cp did not trigger the right error")
+	}
+	if !strings.Contains(string(out), "-cp requires class path specification") {
+		t.Errorf("Unexpected error message: %s", string(out))
+	}
+}
+
+func TestParseArgFile(t *testing.T) {
+	contents := "-verbose:class   # the comment is ignored\n" +
+		"\t-cp \"dir with spaces/lib.jar\":'other dir'\r\n" +
+		"#-showversion\n" +
+		"\"tab\\there\" \"\" 'it''s' C:\\path\\to\n" +
+		"\"first \\\n" +
+		"     second\" \"unterminated\n" +
+		"Main"
+	expected := []string{"-verbose:class", "-cp", "dir with spaces/lib.jar:other dir",
+		"tab\there", "", "its", "C:\\path\\to", "first second", "unterminated", "Main"}
+
+	args := parseArgFile(contents)
+	if len(args) != len(expected) {
+		t.Fatalf("Expected args %q, got: %q", expected, args)
+	}
+	for i := range expected {
+		if args[i] != expected[i] {
+			t.Errorf("Expected arg %d to be %q, got: %q", i, expected[i], args[i])
+		}
+	}
+}
+
+func TestExpandArgFiles(t *testing.T) {
+	global := globals.InitGlobals("test")
+	LoadOptionsTable(global)
+
+	dir := t.TempDir()
+	options := filepath.Join(dir, "options.txt")
+	_ = os.WriteFile(options, []byte("-verbose:fine\n-cp @notAFile\n"), 0644)
+	mainArgs := filepath.Join(dir, "main.txt")
+	_ = os.WriteFile(mainArgs, []byte("Hello.class @options.txt"), 0644)
+
+	tests := []struct {
+		args     []string
+		expected []string
+	}{
+		{[]string{"@" + options, "Hello.class", "@" + options},
+			[]string{"-verbose:fine", "-cp", "@notAFile", "Hello.class", "@" + options}},
+		{[]string{"-cp", "@@lib", "@" + mainArgs, "@" + options},
+			[]string{"-cp", "@lib", "Hello.class", "@options.txt", "@" + options}},
+		{[]string{"--disable-@files", "@" + options}, []string{"--disable-@files", "@" + options}},
+		{[]string{"-jar", "app.jar", "@" + options}, []string{"-jar", "app.jar", "@" + options}},
+	}
+	for _, test := range tests {
+		args, err := expandArgFiles(test.args, &global)
+		if err != nil {
+			t.Errorf("%v: unexpected error: %s", test.args, err.Error())
+			continue
+		}
+		if strings.Join(args, " ") != strings.Join(test.expected, " ") {
+			t.Errorf("%v: expected %q, got: %q", test.args, test.expected, args)
+		}
+	}
+}
+
+func TestMissingArgFile(t *testing.T) {
+	global := globals.InitGlobals("test")
+	LoadOptionsTable(global)
+
+	normalStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+
+	err := HandleCli([]string{"jacobin", "@no-such-file.txt", "Hello.class"}, &global)
+
+	_ = w.Close()
+	os.Stderr = normalStderr
+	out, _ := io.ReadAll(r)
+
+	if err == nil {
+		t.Errorf("Expected an error for a missing argfile")
+	}
+	if !strings.Contains(string(out), "Error: could not open `no-such-file.txt'") {
+		t.Errorf("Unexpected error message: %s", string(out))
+	}
+}
+
+func TestDefineProperties(t *testing.T) {
+	global := globals.InitGlobals("test")
+	LoadOptionsTable(global)
+
+	args := []string{"jacobin", "-Dapp.name=demo", "-Durl=http://example.com/a=b", "-Dflag",
+		"-cp", "lib", "Hello.class"}
+	_ = HandleCli(args, &global)
+
+	expected := map[string]string{
+		"app.name":        "demo",
+		"url":             "http://example.com/a=b",
+		"flag":            "",
+		"java.class.path": "lib",
+	}
+	for key, value := range expected {
+		if actual, ok := globals.GetProperty(key); !ok || actual != value {
+			t.Errorf("Expected property %s to be '%s', got: '%s'", key, value, actual)
+		}
+	}
+}
+
+func TestInvalidPropertyDefinition(t *testing.T) {
+	global := globals.InitGlobals("test")
+	LoadOptionsTable(global)
+	global.Args = []string{"jacobin", "-D=value"}
+
+	normalStderr := os.Stderr
+	_, w, _ := os.Pipe()
+	os.Stderr = w
+
+	_, err := defineProperty(1, "=value", &global)
+
+	_ = w.Close()
+	os.Stderr = normalStderr
+
+	if err != os.ErrInvalid {
+		t.Error("A -D option without a property name did not trigger the right error")
+	}
+}
+
+func TestSourceFileOnCommandLine(t *testing.T) {
+	tests := []struct {
+		args          []string
+		sourceFile    string
+		sourceVersion string
+		appArgs       []string
+	}{
+		{[]string{"jacobin", "-verbose:fine", "Hello.java", "a", "b"}, "Hello.java", "", []string{"a", "b"}},
+		{[]string{"jacobin", "--source", "17", "script", "-x"}, "script", "17", []string{"-x"}},
+		{[]string{"jacobin", "--source=11", "Tool.java"}, "Tool.java", "11", nil},
+	}
+	for _, test := range tests {
+		global := globals.InitGlobals("test")
+		LoadOptionsTable(global)
+		_ = HandleCli(test.args, &global)
+
+		if global.StartingSourceFile != test.sourceFile || global.SourceVersion != test.sourceVersion {
+			t.Errorf("%v: expected source file %s and version %s, got: %s and %s", test.args,
+				test.sourceFile, test.sourceVersion, global.StartingSourceFile, global.SourceVersion)
+		}
+		if strings.Join(global.AppArgs, " ") != strings.Join(test.appArgs, " ") {
+			t.Errorf("%v: expected app args %v, got: %v", test.args, test.appArgs, global.AppArgs)
+		}
+	}
+}
+
+func TestMainClassByBinaryName(t *testing.T) {
+	tests := []struct {
+		args      []string
+		mainClass string
+		module    string
+		appArgs   []string
+	}{
+		{[]string{"jacobin", "-cp", "classes", "com.example.Main", "a", "-b"}, "com.example.Main", "", []string{"a", "-b"}},
+		{[]string{"jacobin", "-m", "app/com.example.Main", "x"}, "com.example.Main", "app", []string{"x"}},
+		{[]string{"jacobin", "--module=app/Tool"}, "Tool", "app", nil},
+	}
+	for _, test := range tests {
+		global := globals.InitGlobals("test")
+		LoadOptionsTable(global)
+		_ = HandleCli(test.args, &global)
+
+		if global.MainClassName != test.mainClass || global.MainModule != test.module {
+			t.Errorf("%v: expected main class %s in module '%s', got: %s in '%s'", test.args,
+				test.mainClass, test.module, global.MainClassName, global.MainModule)
+		}
+		if strings.Join(global.AppArgs, " ") != strings.Join(test.appArgs, " ") {
+			t.Errorf("%v: expected app args %v, got: %v", test.args, test.appArgs, global.AppArgs)
+		}
+	}
+}
+
+func TestModuleWithoutMainClass(t *testing.T) {
+	global := globals.InitGlobals("test")
+	LoadOptionsTable(global)
+
+	normalStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+
+	global.Args = []string{"-m", "app"}
+	_, err := getMainModule(0, "", &global)
+
+	_ = w.Close()
+	os.Stderr = normalStderr
+	out, _ := io.ReadAll(r)
+
+	if err != os.ErrInvalid {
+		t.Errorf("Expected an error for a module without a main class")
+	}
+	if !strings.Contains(string(out), "Error: module app does not have a ModuleMainClass attribute") {
+		t.Errorf("Unexpected error message: %s", string(out))
+	}
+}
+
+func TestMainClassNotFoundStrictJDK(t *testing.T) {
+	global := globals.InitGlobals("test")
+	global.Classpath = []string{t.TempDir()}
+	globals.GetGlobalRef().Classpath = global.Classpath
+	global.StrictJDK = true
+	global.MainClassName = "com.example.Missing"
+
+	normalStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+
+	_, err := loadMainClass(&global)
+
+	_ = w.Close()
+	os.Stderr = normalStderr
+	out, _ := io.ReadAll(r)
+
+	if err == nil {
+		t.Errorf("Expected an error for a main class that is not on the classpath")
+	}
+	expected := "Error: Could not find or load main class com.example.Missing\n" +
+		"Caused by: java.lang.ClassNotFoundException: com.example.Missing\n"
+	if string(out) != expected {
+		t.Errorf("Unexpected error message: %s", string(out))
+	}
+}
+
+func TestVerifyOption(t *testing.T) {
+	tests := []struct {
+		arg   string
+		level int
+	}{
+		{"-Xverify:none", globals.VerifyNone},
+		{"-Xverify:remote", globals.VerifyRemote},
+		{"-Xverify:all", globals.VerifyAll},
+		{"-Xverify:some", globals.VerifyRemote}, // invalid, so the default is unchanged
+	}
+	for _, test := range tests {
+		global := globals.InitGlobals("test")
+		LoadOptionsTable(global)
+		_ = HandleCli([]string{"jacobin", test.arg, "Hello.class"}, &global)
+
+		if global.VerifyLevel != test.level {
+			t.Errorf("%s: expected verification level %d, got: %d", test.arg, test.level, global.VerifyLevel)
+		}
+	}
+}
+
+func TestJavapOption(t *testing.T) {
+	global := globals.InitGlobals("test")
+	LoadOptionsTable(global)
+	_ = HandleCli([]string{"jacobin", "-javap", "-c", "-v", "Hello.class", "World.class"}, &global)
+
+	if !global.JavapCode || !global.JavapVerbose || global.JavapLines || global.JavapPrivate {
+		t.Errorf("Expected -c and -v to be set, got: -c %t, -l %t, -p %t, -v %t",
+			global.JavapCode, global.JavapLines, global.JavapPrivate, global.JavapVerbose)
+	}
+	if strings.Join(global.JavapClasses, " ") != "Hello.class World.class" {
+		t.Errorf("Expected the class files Hello.class World.class, got: %v", global.JavapClasses)
+	}
+	if global.StartingClass != "" {
+		t.Errorf("Expected no class to be run, got: %s", global.StartingClass)
+	}
+}
+
+func TestThreadStackSizeOption(t *testing.T) {
+	normalStderr := os.Stderr
+	_, w, _ := os.Pipe()
+	os.Stderr = w
+
+	tests := []struct {
+		arg   string
+		depth int
+	}{
+		{"-Xss512k", 512 * 1024 / globals.FrameStackSize},
+		{"-Xss2M", 2 * 1024 * 1024 / globals.FrameStackSize},
+		{"-Xss100", 1},
+		{"-Xss4g", maxFrameDepth},
+		{"-Xss0", globals.DefaultThreadStackSize / globals.FrameStackSize}, // invalid, so unchanged
+		{"-Xssbig", globals.DefaultThreadStackSize / globals.FrameStackSize},
+	}
+	for _, test := range tests {
+		global := globals.InitGlobals("test")
+		LoadOptionsTable(global)
+		_ = HandleCli([]string{"jacobin", test.arg, "Hello.class"}, &global)
+
+		if global.MaxFrameDepth != test.depth {
+			t.Errorf("%s: expected a maximum frame depth of %d, got: %d", test.arg, test.depth, global.MaxFrameDepth)
+		}
+	}
+
+	_ = w.Close()
+	os.Stderr = normalStderr
+}
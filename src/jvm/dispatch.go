@@ -0,0 +1,127 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"jacobin/classloader"
+	"jacobin/object"
+	"jacobin/util"
+	"strings"
+	"sync"
+)
+
+// virtualCallSite holds what INVOKEVIRTUAL needs to know about the method named
+// in a MethodRef. It's looked up once per MethodRef and then cached.
+type virtualCallSite struct {
+	className  string // the class named in the MethodRef
+	methodName string
+	methodType string
+	argSlots   int // the operand stack slots taken by the arguments, excluding the object ref
+	vtableSlot int // the method's slot in the vtable of className; -1 if it has none
+}
+
+// the key of the call site cache: a MethodRef in a given CP
+type callSiteKey struct {
+	cp      *classloader.CPool
+	cpIndex int
+}
+
+var virtualCallSites sync.Map // callSiteKey -> *virtualCallSite
+
+// resolveVirtualCallSite returns the call site data for the MethodRef at cpIndex
+// in the CP, looking it up on first use. Methods that have no vtable slot (such
+// as private methods, which can be invoked by INVOKEVIRTUAL) or whose class can't
+// be linked get a slot of -1.
+func resolveVirtualCallSite(cp *classloader.CPool, cpIndex int) *virtualCallSite {
+	key := callSiteKey{cp: cp, cpIndex: cpIndex}
+	if site, ok := virtualCallSites.Load(key); ok {
+		return site.(*virtualCallSite)
+	}
+
+	className, methodName, methodType := getMethInfoFromCPmethref(cp, cpIndex)
+	site := virtualCallSite{
+		className:  className,
+		methodName: methodName,
+		methodType: methodType,
+		argSlots:   argSlotCount(methodType),
+		vtableSlot: -1,
+	}
+
+	if !strings.HasPrefix(className, "[") { // array classes have no vtable
+		if vt, err := classloader.FetchVTable(className); err == nil {
+			if slot, ok := vt.Slots[methodName+methodType]; ok {
+				site.vtableSlot = slot
+			}
+		}
+	}
+
+	virtualCallSites.Store(key, &site)
+	return &site
+}
+
+// argSlotCount returns the number of operand stack slots taken by the arguments
+// of a method with the given signature. Longs and doubles take two slots.
+func argSlotCount(methodType string) int {
+	count := 0
+	for _, param := range util.ParseIncomingParamsFromMethTypeString(methodType) {
+		if param == "J" || param == "D" {
+			count += 2
+		} else {
+			count += 1
+		}
+	}
+	return count
+}
+
+// selectVirtualMethod finds the method that INVOKEVIRTUAL runs for an object of
+// class receiverClass: the one in the vtable slot of the call site, which is the
+// method as overridden by the receiver's class or its closest superclass that does
+// so. Methods outside the vtable (such as default methods inherited from interfaces)
+// are looked up by the receiver's class. Returns the method and the name of the
+// class that declares it. If ok is false, the method could not be found this way
+// (for example, because the receiver's class could not be loaded), and the caller
+// should fall back to looking it up in the class named in the MethodRef. An
+// error is returned only when the selected method is abstract.
+func selectVirtualMethod(site *virtualCallSite, receiverClass string) (
+	mte classloader.MTentry, declaringClass string, ok bool, err error) {
+
+	if site.vtableSlot >= 0 {
+		vt, err := classloader.FetchVTable(receiverClass)
+		if err != nil {
+			return classloader.MTentry{}, "", false, nil
+		}
+
+		if site.vtableSlot < len(vt.Entries) {
+			entry := vt.Entries[site.vtableSlot]
+			if entry.MethName == site.methodName && entry.MethType == site.methodType {
+				if entry.Meth.Meth == nil { // the method is abstract
+					return classloader.MTentry{}, "", true, &classloader.UnresolvedMethodError{
+						Class: receiverClass, Meth: site.methodName, MethType: site.methodType,
+						IsDeclared: true}
+				}
+				return entry.Meth, entry.ClassName, true, nil
+			}
+		}
+	}
+
+	mte, declaringClass, err = classloader.FetchInterfaceMethod(
+		receiverClass, site.methodName, site.methodType)
+	if err != nil {
+		return classloader.MTentry{}, "", false, nil
+	}
+	return mte, declaringClass, true, nil
+}
+
+// returns the class of the object ref below the arguments on the operand stack,
+// or "" if the ref is null or not a Java object
+func receiverClassName(receiver interface{}) string {
+	obj, ok := receiver.(*object.Object)
+	if !ok || obj == nil || obj.Klass == nil {
+		return ""
+	}
+	return *obj.Klass
+}
@@ -21,10 +21,11 @@ import (
 // as an array of interface{}, which can be nil if there are no arguments.
 // Any return value from the method is returned to run() as an interface{}
 // (which is nil in the case of a void function), where it is placed
-// by run() on the operand stack of the calling function.
-func runGframe(fr *frames.Frame) (interface{}, int, error) {
+// by run() on the operand stack of the calling function. Methods that
+// need to examine the thread's frames are also passed the frame stack.
+func runGframe(fr *frames.Frame, fs *list.List) (interface{}, int, error) {
 	// get the go method from the MTable
-	me := classloader.MTable[fr.ClName+"."+fr.MethName]
+	me := classloader.MTableFetch(fr.ClName + "." + fr.MethName)
 	if me.Meth == nil {
 		return nil, 0, errors.New("runGframe: go method not found: " +
 			fr.ClName + "." + fr.MethName)
@@ -35,10 +36,18 @@ func runGframe(fr *frames.Frame) (interface{}, int, error) {
 	for _, v := range fr.OpStack {
 		*params = append(*params, v)
 	}
+	if me.Meth.(classloader.GmEntry).NeedsContext {
+		*params = append(*params, fs)
+	}
 
 	// call the function passing a pointer to the slice of arguments
 	ret := me.Meth.(classloader.GmEntry).Fu(*params)
 
+	// a Go method that fails or throws a Java exception returns the error
+	if err, ok := ret.(error); ok {
+		return nil, 0, err
+	}
+
 	// how many slots does the return value consume on the op stack?
 	// the last char in the method name indicates the data type of the return
 	// value. If it's 'J' (a long) or 'D' (a double), it will require two
@@ -68,6 +77,7 @@ func runGmethod(mt classloader.MTentry, fs *list.List, className, methodName, me
 	paramSlots := mt.Meth.(classloader.GmEntry).ParamSlots
 	gf := frames.CreateFrame(paramSlots)
 	gf.Thread = f.Thread
+	gf.Trace = f.Trace
 
 	gf.MethName = methodName + methodType
 	gf.ClName = className
@@ -97,7 +107,9 @@ func runGmethod(mt classloader.MTentry, fs *list.List, className, methodName, me
 	// then run the frame, which will call run(), which will eventually call runGFrame()
 	err := runFrame(fs)
 	if err != nil {
-		_ = log.Log("Error: "+err.Error(), log.SEVERE)
+		if _, thrown := err.(*javaThrowable); !thrown { // exceptions are reported by the caller
+			_ = log.Log("Error: "+err.Error(), log.SEVERE)
+		}
 		return nil, err
 	}
 
@@ -107,3 +119,58 @@ func runGmethod(mt classloader.MTentry, fs *list.List, className, methodName, me
 	f = fs.Front().Value.(*frames.Frame) // point f the head again
 	return f, nil
 }
+
+// runJavaMethod runs a method on behalf of the Go method whose frame is at the
+// head of the frame stack, such as the interface method of a lambda. The Go method's
+// frame serves as the calling frame: args, which holds the arguments as they are
+// pushed onto the operand stack (preceded by the object ref, if hasThis is true),
+// is pushed onto its operand stack, and the method's return value (nil for void
+// methods) is popped off it and returned. If the method throws an exception, the
+// method's frame is popped and the exception is returned as the error.
+func runJavaMethod(fs *list.List, mt classloader.MTentry, className, methodName, methodType string,
+	hasThis bool, args []interface{}) (interface{}, error) {
+
+	caller := fs.Front().Value.(*frames.Frame)
+	base := caller.TOS
+	for len(caller.OpStack) < base+1+len(args)+2 { // room for the args and the return value
+		caller.OpStack = append(caller.OpStack, nil)
+	}
+	for _, arg := range args {
+		push(caller, arg)
+	}
+
+	if mt.MType == 'G' {
+		if _, err := runGmethod(mt, fs, className, methodName, methodType); err != nil {
+			fs.Remove(fs.Front())
+			caller.TOS = base
+			return nil, err
+		}
+	} else {
+		m, ok := mt.Meth.(classloader.JmEntry)
+		if !ok {
+			caller.TOS = base
+			return nil, errors.New("runJavaMethod: method not found: " + className + "." + methodName)
+		}
+		fram, err := createAndInitNewFrame(className, methodName, methodType, &m, hasThis, caller)
+		if err != nil {
+			caller.TOS = base
+			return nil, err
+		}
+		fram.Thread = caller.Thread
+
+		fs.PushFront(fram)
+		err = runFrame(fs)
+		fs.Remove(fs.Front())
+		if err != nil {
+			caller.TOS = base
+			return nil, err
+		}
+	}
+
+	var ret interface{}
+	if !strings.HasSuffix(methodType, ")V") && caller.TOS > base {
+		ret = caller.OpStack[caller.TOS] // longs and doubles are in both slots
+	}
+	caller.TOS = base
+	return ret, nil
+}
@@ -194,7 +194,7 @@ func createField(f classloader.Field, k *classloader.Klass, classname string) (*
 					fieldToAdd.Fvalue = float64(k.Data.CP.Floats[valueSlot])
 				case classloader.DoubleConst:
 					fieldToAdd.Fvalue = k.Data.CP.Doubles[valueSlot]
-				case classloader.StringConst:
+				case classloader.UTF8: // string constants are UTF8 entries once the class is loaded
 					str := k.Data.CP.Utf8Refs[valueSlot]
 					fieldToAdd.Fvalue = object.NewStringFromGoString(str)
 				default:
@@ -216,7 +216,7 @@ func createField(f classloader.Field, k *classloader.Klass, classname string) (*
 		fieldName := k.Data.CP.Utf8Refs[f.Name]
 		fullFieldName := classname + "." + fieldName
 
-		_, alreadyPresent := classloader.Statics[fullFieldName]
+		_, alreadyPresent := classloader.FetchStatic(fullFieldName)
 		if !alreadyPresent { // add only if field has not been pre-loaded
 			_ = classloader.AddStatic(fullFieldName, s)
 		}
@@ -227,8 +227,8 @@ func createField(f classloader.Field, k *classloader.Klass, classname string) (*
 // Loads the class (if it's not already loaded) and makes sure it's accessible in the method area
 func loadThisClass(className string) error {
 	alreadyLoaded := classloader.MethAreaFetch(className)
-	if alreadyLoaded != nil { // if the class is already loaded, skip the rest of this
-		return nil
+	if alreadyLoaded != nil { // if the class is already loaded (or being loaded), just wait for it
+		return classloader.WaitForClassStatus(className)
 	}
 	// Try to load class by name
 	err := classloader.LoadClassFromNameOnly(className)
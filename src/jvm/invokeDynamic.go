@@ -0,0 +1,556 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"jacobin/classloader"
+	"jacobin/exceptions"
+	"jacobin/frames"
+	"jacobin/object"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// INVOKEDYNAMIC call sites are linked the first time they're executed by calling
+// the bootstrap method named in the InvokeDynamic CP entry. In the JDK, the
+// bootstrap methods are Java methods that generate classes and method handles.
+// Jacobin instead implements the bootstrap methods javac relies on in Go, and
+// the linked call site is cached, so later executions skip linking.
+
+// dynamicCallSite is a linked INVOKEDYNAMIC call site
+type dynamicCallSite interface {
+	// run pops the call site's arguments off the operand stack of the frame
+	// and pushes the result
+	run(fs *list.List, f *frames.Frame) error
+}
+
+var dynamicCallSites sync.Map // callSiteKey -> dynamicCallSite
+
+// bootstrapInfo holds what's needed to link an INVOKEDYNAMIC call site
+type bootstrapInfo struct {
+	className  string   // the class of the bootstrap method
+	methodName string   // the bootstrap method
+	name       string   // the name in the call site's InvokeDynamic entry
+	desc       string   // the method type in the call site's InvokeDynamic entry
+	args       []uint16 // the CP entries of the static arguments to the bootstrap method
+}
+
+// the kinds of method handles (JVMS 4.4.8)
+const (
+	refInvokeVirtual    = 5
+	refInvokeStatic     = 6
+	refInvokeSpecial    = 7
+	refNewInvokeSpecial = 8
+	refInvokeInterface  = 9
+)
+
+// linkCallSite returns the linked call site for the InvokeDynamic entry at cpIndex
+// in the CP of the frame's class, linking it if this is its first execution.
+func linkCallSite(f *frames.Frame, cpIndex int) (dynamicCallSite, error) {
+	key := callSiteKey{cp: f.CP, cpIndex: cpIndex}
+	if site, ok := dynamicCallSites.Load(key); ok {
+		return site.(dynamicCallSite), nil
+	}
+
+	bsi, err := fetchBootstrapInfo(f, cpIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	var site dynamicCallSite
+	switch bsi.className + "." + bsi.methodName {
+	case "java/lang/invoke/LambdaMetafactory.metafactory",
+		"java/lang/invoke/LambdaMetafactory.altMetafactory":
+		site, err = linkLambda(f, bsi)
+	case "java/lang/invoke/StringConcatFactory.makeConcatWithConstants",
+		"java/lang/invoke/StringConcatFactory.makeConcat":
+		site, err = linkStringConcat(f, bsi)
+	default:
+		err = fmt.Errorf("Unsupported bootstrap method %s.%s for call site %s%s",
+			strings.ReplaceAll(bsi.className, "/", "."), bsi.methodName, bsi.name, bsi.desc)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// if another thread linked the call site meanwhile, its linkage is the one used
+	linked, _ := dynamicCallSites.LoadOrStore(key, site)
+	return linked.(dynamicCallSite), nil
+}
+
+// fetchBootstrapInfo gets the bootstrap method and its static arguments for the
+// InvokeDynamic entry at cpIndex from the BootstrapMethods attribute of the class
+func fetchBootstrapInfo(f *frames.Frame, cpIndex int) (*bootstrapInfo, error) {
+	CP := f.CP
+	if cpIndex < 1 || cpIndex >= len(CP.CpIndex) || CP.CpIndex[cpIndex].Type != classloader.InvokeDynamic {
+		return nil, fmt.Errorf("Expected an invokedynamic entry at CP entry %d in class %s",
+			cpIndex, f.ClName)
+	}
+	indy := CP.InvokeDynamics[CP.CpIndex[cpIndex].Slot]
+
+	k := classloader.MethAreaFetch(f.ClName)
+	if k == nil || k.Data == nil || int(indy.BootstrapIndex) >= len(k.Data.Bootstraps) {
+		return nil, fmt.Errorf("Missing bootstrap method %d in class %s", indy.BootstrapIndex, f.ClName)
+	}
+	bsm := k.Data.Bootstraps[indy.BootstrapIndex]
+
+	_, className, methodName, _, err := methodHandleArg(CP, bsm.MethodRef)
+	if err != nil {
+		return nil, err
+	}
+
+	nAndT := CP.NameAndTypes[CP.CpIndex[indy.NameAndType].Slot]
+	return &bootstrapInfo{
+		className:  className,
+		methodName: methodName,
+		name:       classloader.FetchUTF8stringFromCPEntryNumber(CP, nAndT.NameIndex),
+		desc:       classloader.FetchUTF8stringFromCPEntryNumber(CP, nAndT.DescIndex),
+		args:       bsm.Args,
+	}, nil
+}
+
+// methodHandleArg returns the kind and the method of the MethodHandle CP entry at cpIndex
+func methodHandleArg(CP *classloader.CPool, cpIndex uint16) (
+	kind int, className, methodName, methodType string, err error) {
+
+	if int(cpIndex) >= len(CP.CpIndex) || CP.CpIndex[cpIndex].Type != classloader.MethodHandle {
+		return 0, "", "", "", fmt.Errorf("Expected a method handle at CP entry %d", cpIndex)
+	}
+	mh := CP.MethodHandles[CP.CpIndex[cpIndex].Slot]
+
+	ref := int(mh.RefIndex)
+	if ref < len(CP.CpIndex) && CP.CpIndex[ref].Type == classloader.Interface {
+		className, methodName, methodType = getMethInfoFromCPinterfaceRef(CP, ref)
+	} else {
+		className, methodName, methodType = getMethInfoFromCPmethref(CP, ref)
+	}
+	if className == "" {
+		return 0, "", "", "", fmt.Errorf("Expected a method reference at CP entry %d", ref)
+	}
+	return int(mh.RefKind), className, methodName, methodType, nil
+}
+
+// methodTypeArg returns the signature in the MethodType CP entry at cpIndex
+func methodTypeArg(CP *classloader.CPool, cpIndex uint16) (string, error) {
+	if int(cpIndex) >= len(CP.CpIndex) || CP.CpIndex[cpIndex].Type != classloader.MethodType {
+		return "", fmt.Errorf("Expected a method type at CP entry %d", cpIndex)
+	}
+	return classloader.FetchUTF8stringFromCPEntryNumber(CP, CP.MethodTypes[CP.CpIndex[cpIndex].Slot]), nil
+}
+
+// lambdaCallSite is a call site linked by LambdaMetafactory, which creates the
+// objects for lambda expressions and method references. The objects are instances
+// of a proxy class that implements the functional interface. The proxy's interface
+// method is a Go method that calls the implementation method (the method that
+// javac generates for a lambda's body, or the method referred to), passing it the
+// values the lambda captured followed by the interface method's arguments.
+type lambdaCallSite struct {
+	proxyClass    string         // the class of the lambda objects, e.g., test/Main$$Lambda$1
+	methodName    string         // the interface method
+	capturedTypes []string       // the types of the values captured by the lambda
+	implKind      int            // the kind of method handle of the implementation method
+	implClass     string         // the class of the implementation method
+	implName      string         // the implementation method
+	implType      string         // the signature of the implementation method
+	instance      *object.Object // the only lambda object, if the lambda captures no values
+}
+
+// the number used to name the next proxy class
+var lambdaCount atomic.Int64
+
+// the flags of LambdaMetafactory.altMetafactory()
+const (
+	lambdaFlagSerializable = 1
+	lambdaFlagMarkers      = 2
+	lambdaFlagBridges      = 4
+)
+
+// linkLambda links a call site whose bootstrap method is LambdaMetafactory.metafactory()
+// or altMetafactory(). Their static arguments are the erased signature of the
+// interface method, the implementation method, and the signature of the interface
+// method as instantiated at the call site. altMetafactory() also takes flags and,
+// depending on them, marker interfaces for the proxy class to implement and the
+// signatures of bridge methods, which must also be implemented.
+func linkLambda(f *frames.Frame, bsi *bootstrapInfo) (*lambdaCallSite, error) {
+	if len(bsi.args) < 3 {
+		return nil, fmt.Errorf("Expected 3 arguments to %s, got: %d", bsi.methodName, len(bsi.args))
+	}
+	CP := f.CP
+
+	methodType, err := methodTypeArg(CP, bsi.args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	site := lambdaCallSite{methodName: bsi.name}
+	site.implKind, site.implClass, site.implName, site.implType, err = methodHandleArg(CP, bsi.args[1])
+	if err != nil {
+		return nil, err
+	}
+	switch site.implKind {
+	case refInvokeVirtual, refInvokeStatic, refInvokeSpecial, refNewInvokeSpecial, refInvokeInterface:
+	default:
+		return nil, fmt.Errorf("Unsupported method handle kind %d for %s.%s",
+			site.implKind, site.implClass, site.implName)
+	}
+
+	// the call site's type takes the captured values and returns the interface
+	capturedTypes, iface := methodTypeParts(bsi.desc)
+	site.capturedTypes = capturedTypes
+	interfaces := []string{strings.TrimSuffix(strings.TrimPrefix(iface, "L"), ";")}
+	methodTypes := []string{methodType}
+
+	if bsi.methodName == "altMetafactory" && len(bsi.args) > 3 {
+		interfaces, methodTypes, err = altMetafactoryArgs(CP, bsi.args[3:], interfaces, methodTypes)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	site.proxyClass = fmt.Sprintf("%s$$Lambda$%d", f.ClName, lambdaCount.Add(1))
+	site.defineProxyClass(interfaces, methodTypes)
+
+	if len(site.capturedTypes) == 0 { // as in the JDK, all evaluations yield the same object
+		site.instance = site.newInstance(nil)
+	}
+	return &site, nil
+}
+
+// altMetafactoryArgs adds the marker interfaces and bridge method signatures in the
+// static arguments to altMetafactory() that follow the first three
+func altMetafactoryArgs(CP *classloader.CPool, args []uint16, interfaces, methodTypes []string) (
+	[]string, []string, error) {
+
+	intArg := func(i int) (int, error) {
+		if i >= len(args) || CP.CpIndex[args[i]].Type != classloader.IntConst {
+			return 0, errors.New("Invalid flags or count in arguments to altMetafactory")
+		}
+		return int(CP.IntConsts[CP.CpIndex[args[i]].Slot]), nil
+	}
+
+	flags, err := intArg(0)
+	if err != nil {
+		return nil, nil, err
+	}
+	i := 1
+
+	if flags&lambdaFlagSerializable != 0 {
+		interfaces = append(interfaces, "java/io/Serializable")
+	}
+
+	if flags&lambdaFlagMarkers != 0 {
+		count, err := intArg(i)
+		if err != nil {
+			return nil, nil, err
+		}
+		for i++; count > 0 && i < len(args); i, count = i+1, count-1 {
+			entry := CP.CpIndex[args[i]]
+			if entry.Type != classloader.ClassRef {
+				return nil, nil, errors.New("Expected a marker interface in arguments to altMetafactory")
+			}
+			interfaces = append(interfaces,
+				classloader.FetchUTF8stringFromCPEntryNumber(CP, CP.ClassRefs[entry.Slot]))
+		}
+	}
+
+	if flags&lambdaFlagBridges != 0 {
+		count, err := intArg(i)
+		if err != nil {
+			return nil, nil, err
+		}
+		for i++; count > 0 && i < len(args); i, count = i+1, count-1 {
+			bridgeType, err := methodTypeArg(CP, args[i])
+			if err != nil {
+				return nil, nil, err
+			}
+			methodTypes = append(methodTypes, bridgeType)
+		}
+	}
+	return interfaces, methodTypes, nil
+}
+
+// defineProxyClass adds the proxy class to the method area and its interface
+// method (and any bridge methods) to the method table as Go methods
+func (site *lambdaCallSite) defineProxyClass(interfaces, methodTypes []string) {
+	data := classloader.ClData{Name: site.proxyClass, Superclass: "java/lang/Object"}
+	for _, intf := range interfaces {
+		data.Interfaces = append(data.Interfaces, uint16(len(data.CP.Utf8Refs)))
+		data.CP.Utf8Refs = append(data.CP.Utf8Refs, intf)
+	}
+	classloader.MethAreaInsert(site.proxyClass,
+		&(classloader.Klass{Status: 'X', Loader: "bootstrap", Data: &data}))
+
+	methods := make(map[string]classloader.GMeth)
+	for _, methodType := range methodTypes {
+		methodType := methodType
+		methods[site.proxyClass+"."+site.methodName+methodType] = classloader.GMeth{
+			ParamSlots: 1 + argSlotCount(methodType), // the lambda object, then the arguments
+			GFunction: func(params []interface{}) interface{} {
+				return site.invoke(methodType, params)
+			},
+			NeedsContext: true,
+		}
+	}
+	classloader.MTableLoadGoMethods(methods)
+}
+
+// isLambdaProxy reports whether a class is the proxy class of a lambda. As in
+// the JDK, the frames of its methods are not shown in stack traces.
+func isLambdaProxy(className string) bool {
+	return strings.Contains(className, "$$Lambda$")
+}
+
+// newInstance creates a lambda object holding the captured values. As in the
+// JDK, they are in fields named arg$1, arg$2, etc.
+func (site *lambdaCallSite) newInstance(captured []interface{}) *object.Object {
+	obj := object.MakeEmptyObject()
+	obj.Klass = &site.proxyClass
+	obj.FieldTable = make(map[string]object.Field)
+	for i, value := range captured {
+		obj.FieldTable[fmt.Sprintf("arg$%d", i+1)] =
+			object.Field{Ftype: site.capturedTypes[i], Fvalue: value}
+	}
+	return obj
+}
+
+// run pops the captured values and pushes the lambda object
+func (site *lambdaCallSite) run(fs *list.List, f *frames.Frame) error {
+	if site.instance != nil {
+		push(f, site.instance)
+		return nil
+	}
+
+	captured := make([]interface{}, len(site.capturedTypes))
+	for i := len(captured) - 1; i >= 0; i-- {
+		if isWide(site.capturedTypes[i]) {
+			pop(f) // longs and doubles take two slots
+		}
+		captured[i] = pop(f)
+	}
+	push(f, site.newInstance(captured))
+	return nil
+}
+
+// invoke runs the implementation method for a call to the proxy's interface
+// method with the given signature. params holds the lambda object, the arguments,
+// and the frame stack. Arguments and return values are adapted (boxed, unboxed,
+// or widened) to the types the methods expect, as LambdaMetafactory does.
+func (site *lambdaCallSite) invoke(methodType string, params []interface{}) interface{} {
+	this := params[0].(*object.Object)
+	fs := params[len(params)-1].(*list.List)
+
+	paramTypes, retType := methodTypeParts(methodType)
+	argTypes := append(append([]string{}, site.capturedTypes...), paramTypes...)
+	args := make([]interface{}, 0, len(argTypes))
+	for i := range site.capturedTypes {
+		args = append(args, this.FieldTable[fmt.Sprintf("arg$%d", i+1)].Fvalue)
+	}
+	args = append(args, slotsToValues(params[1:len(params)-1], paramTypes)...)
+
+	implParams, implRet := methodTypeParts(site.implType)
+	hasThis := site.implKind != refInvokeStatic
+	if hasThis && site.implKind != refNewInvokeSpecial {
+		implParams = append([]string{"L" + site.implClass + ";"}, implParams...)
+	}
+	if len(args) != len(implParams) {
+		return fmt.Errorf("Lambda %s.%s%s: expected %d arguments for %s.%s%s, got: %d",
+			site.proxyClass, site.methodName, methodType,
+			len(implParams), site.implClass, site.implName, site.implType, len(args))
+	}
+
+	for i := range args {
+		var err error
+		if args[i], err = adaptValue(fs, args[i], argTypes[i], implParams[i]); err != nil {
+			return err
+		}
+	}
+
+	if site.implKind == refNewInvokeSpecial { // a constructor reference, e.g. ArrayList::new
+		obj, err := instantiateClass(site.implClass)
+		if err != nil {
+			return err
+		}
+		args = append([]interface{}{obj}, args...)
+		implParams = append([]string{"L" + site.implClass + ";"}, implParams...)
+		implRet = implParams[0]
+	}
+
+	mte, className, err := site.implMethod(fs, args)
+	if err != nil {
+		return err
+	}
+	ret, err := runJavaMethod(fs, mte, className, site.implName, site.implType, hasThis,
+		valuesToSlots(args, implParams))
+	if err != nil {
+		return err
+	}
+
+	if site.implKind == refNewInvokeSpecial {
+		ret = args[0]
+	}
+	if retType == "V" {
+		return nil
+	}
+	if ret, err = adaptValue(fs, ret, implRet, retType); err != nil {
+		return err
+	}
+	return ret
+}
+
+// implMethod finds the implementation method. If it's a virtual or interface
+// method, the method that's run is selected by the class of the receiver.
+func (site *lambdaCallSite) implMethod(fs *list.List, args []interface{}) (
+	classloader.MTentry, string, error) {
+
+	if site.implKind == refInvokeVirtual || site.implKind == refInvokeInterface {
+		receiverClass := receiverClassName(args[0])
+		if receiverClass == "" {
+			errMsg := fmt.Sprintf("Cannot invoke \"%s.%s()\"",
+				strings.ReplaceAll(site.implClass, "/", "."), site.implName)
+			return classloader.MTentry{}, "", throwVMException(fs, exceptions.NullPointerException, errMsg)
+		}
+		mte, className, err := classloader.FetchInterfaceMethod(receiverClass, site.implName, site.implType)
+		if err == nil {
+			return mte, className, nil
+		}
+	}
+
+	mte, err := classloader.FetchMethodAndCP(site.implClass, site.implName, site.implType)
+	if err != nil || mte.Meth == nil {
+		return classloader.MTentry{}, "", fmt.Errorf("Lambda implementation method not found: %s.%s%s",
+			site.implClass, site.implName, site.implType)
+	}
+	return mte, site.implClass, nil
+}
+
+// the wrapper classes of the primitive types, which are boxed with valueOf()
+var boxClasses = map[string]string{
+	"B": "java/lang/Byte", "C": "java/lang/Character", "D": "java/lang/Double",
+	"F": "java/lang/Float", "I": "java/lang/Integer", "J": "java/lang/Long",
+	"S": "java/lang/Short", "Z": "java/lang/Boolean",
+}
+
+// the methods that unbox a wrapper object into a value of each primitive type
+var unboxMethods = map[string]string{
+	"B": "byteValue", "C": "charValue", "D": "doubleValue", "F": "floatValue",
+	"I": "intValue", "J": "longValue", "S": "shortValue", "Z": "booleanValue",
+}
+
+// adaptValue converts a value of type from to type to, where each is a field
+// descriptor. Primitives are boxed to become references, references are unboxed
+// to become primitives, and primitives are widened as needed.
+func adaptValue(fs *list.List, value interface{}, from, to string) (interface{}, error) {
+	fromRef := isReferenceType(from)
+	toRef := isReferenceType(to)
+
+	switch {
+	case fromRef && toRef:
+		return value, nil
+	case !fromRef && toRef: // box the value
+		boxClass := boxClasses[from]
+		boxType := "(" + from + ")L" + boxClass + ";"
+		mte, err := classloader.FetchMethodAndCP(boxClass, "valueOf", boxType)
+		if err != nil {
+			return nil, err
+		}
+		return runJavaMethod(fs, mte, boxClass, "valueOf", boxType, false, valuesToSlots(
+			[]interface{}{value}, []string{from}))
+	case fromRef && !toRef: // unbox the value
+		unboxName, unboxType := unboxMethods[to], "()"+to
+		receiverClass := receiverClassName(value)
+		if receiverClass == "" {
+			errMsg := fmt.Sprintf("Cannot unbox null value to %s", to)
+			return nil, throwVMException(fs, exceptions.NullPointerException, errMsg)
+		}
+		mte, className, err := classloader.FetchInterfaceMethod(receiverClass, unboxName, unboxType)
+		if err != nil {
+			return nil, err
+		}
+		return runJavaMethod(fs, mte, className, unboxName, unboxType, true, []interface{}{value})
+	}
+
+	// widen the primitive: ints are int64s, and floats and doubles are float64s
+	if (to == "F" || to == "D") && from != "F" && from != "D" {
+		if i, ok := value.(int64); ok {
+			return float64(i), nil
+		}
+	}
+	return value, nil
+}
+
+// isReferenceType reports whether a field descriptor is that of a class or an array
+func isReferenceType(desc string) bool {
+	return strings.HasPrefix(desc, "L") || strings.HasPrefix(desc, "[")
+}
+
+// isWide reports whether a field descriptor is that of a long or a double,
+// which take two slots on the operand stack
+func isWide(desc string) bool {
+	return desc == "J" || desc == "D"
+}
+
+// slotsToValues converts arguments as found on the operand stack, where longs and
+// doubles take two slots, into a slice with one value per argument
+func slotsToValues(slots []interface{}, types []string) []interface{} {
+	values := make([]interface{}, 0, len(types))
+	i := 0
+	for _, t := range types {
+		if i >= len(slots) {
+			break
+		}
+		values = append(values, slots[i])
+		i++
+		if isWide(t) {
+			i++
+		}
+	}
+	return values
+}
+
+// valuesToSlots converts a slice with one value per argument into the arguments
+// as pushed onto the operand stack, where longs and doubles take two slots
+func valuesToSlots(values []interface{}, types []string) []interface{} {
+	slots := make([]interface{}, 0, len(values))
+	for i, value := range values {
+		slots = append(slots, value)
+		if isWide(types[i]) {
+			slots = append(slots, value)
+		}
+	}
+	return slots
+}
+
+// methodTypeParts splits a method signature, such as (ILjava/lang/String;[J)V,
+// into the field descriptors of its parameters and its return type
+func methodTypeParts(methodType string) ([]string, string) {
+	var params []string
+	end := strings.Index(methodType, ")")
+	if !strings.HasPrefix(methodType, "(") || end < 0 {
+		return params, ""
+	}
+
+	for i := 1; i < end; {
+		start := i
+		for i < end && methodType[i] == '[' {
+			i++
+		}
+		if i < end && methodType[i] == 'L' {
+			semicolon := strings.Index(methodType[i:], ";")
+			if semicolon < 0 {
+				return params, ""
+			}
+			i += semicolon
+		}
+		i++
+		params = append(params, methodType[start:i])
+	}
+	return params, methodType[end+1:]
+}
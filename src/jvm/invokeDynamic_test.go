@@ -0,0 +1,278 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"jacobin/classloader"
+	"jacobin/frames"
+	"jacobin/object"
+	"testing"
+)
+
+// cpBuilder builds the CP of a test class one entry at a time. Each method
+// adds an entry and returns its index in the CP.
+type cpBuilder struct {
+	cp classloader.CPool
+}
+
+func newCPBuilder() *cpBuilder {
+	b := cpBuilder{}
+	b.cp.CpIndex = []classloader.CpEntry{{Type: 0, Slot: 0}}
+	return &b
+}
+
+func (b *cpBuilder) add(entryType int, slot int) uint16 {
+	b.cp.CpIndex = append(b.cp.CpIndex, classloader.CpEntry{Type: uint16(entryType), Slot: uint16(slot)})
+	return uint16(len(b.cp.CpIndex) - 1)
+}
+
+func (b *cpBuilder) utf8(s string) uint16 {
+	b.cp.Utf8Refs = append(b.cp.Utf8Refs, s)
+	return b.add(classloader.UTF8, len(b.cp.Utf8Refs)-1)
+}
+
+func (b *cpBuilder) intConst(value int32) uint16 {
+	b.cp.IntConsts = append(b.cp.IntConsts, value)
+	return b.add(classloader.IntConst, len(b.cp.IntConsts)-1)
+}
+
+func (b *cpBuilder) class(name string) uint16 {
+	b.cp.ClassRefs = append(b.cp.ClassRefs, b.utf8(name))
+	return b.add(classloader.ClassRef, len(b.cp.ClassRefs)-1)
+}
+
+func (b *cpBuilder) nameAndType(name, desc string) uint16 {
+	nt := classloader.NameAndTypeEntry{NameIndex: b.utf8(name), DescIndex: b.utf8(desc)}
+	b.cp.NameAndTypes = append(b.cp.NameAndTypes, nt)
+	return b.add(classloader.NameAndType, len(b.cp.NameAndTypes)-1)
+}
+
+func (b *cpBuilder) fieldRef(class, name, desc string) uint16 {
+	fr := classloader.FieldRefEntry{ClassIndex: b.class(class), NameAndType: b.nameAndType(name, desc)}
+	b.cp.FieldRefs = append(b.cp.FieldRefs, fr)
+	return b.add(classloader.FieldRef, len(b.cp.FieldRefs)-1)
+}
+
+func (b *cpBuilder) methodRef(class, name, desc string) uint16 {
+	mr := classloader.MethodRefEntry{ClassIndex: b.class(class), NameAndType: b.nameAndType(name, desc)}
+	b.cp.MethodRefs = append(b.cp.MethodRefs, mr)
+	return b.add(classloader.MethodRef, len(b.cp.MethodRefs)-1)
+}
+
+func (b *cpBuilder) interfaceRef(class, name, desc string) uint16 {
+	ir := classloader.InterfaceRefEntry{ClassIndex: b.class(class), NameAndType: b.nameAndType(name, desc)}
+	b.cp.InterfaceRefs = append(b.cp.InterfaceRefs, ir)
+	return b.add(classloader.Interface, len(b.cp.InterfaceRefs)-1)
+}
+
+func (b *cpBuilder) methodHandle(kind int, ref uint16) uint16 {
+	b.cp.MethodHandles = append(b.cp.MethodHandles,
+		classloader.MethodHandleEntry{RefKind: uint16(kind), RefIndex: ref})
+	return b.add(classloader.MethodHandle, len(b.cp.MethodHandles)-1)
+}
+
+func (b *cpBuilder) methodType(desc string) uint16 {
+	b.cp.MethodTypes = append(b.cp.MethodTypes, b.utf8(desc))
+	return b.add(classloader.MethodType, len(b.cp.MethodTypes)-1)
+}
+
+func (b *cpBuilder) invokeDynamic(bootstrap int, name, desc string) uint16 {
+	b.cp.InvokeDynamics = append(b.cp.InvokeDynamics, classloader.InvokeDynamicEntry{
+		BootstrapIndex: uint16(bootstrap), NameAndType: b.nameAndType(name, desc)})
+	return b.add(classloader.InvokeDynamic, len(b.cp.InvokeDynamics)-1)
+}
+
+// sets up test/Main, whose CP holds three lambda call sites, and the classes they use:
+//
+//	interface IntOp { int apply(int x); }
+//	interface ToInt { int apply(Object o); }
+//	class Counter { int value() { return 4; } }
+//	class Main {
+//	    static int lambda$0(int captured, int x) { return captured + x; }
+//	    // indy #0: (I)Ltest/IntOp;  = x -> captured + x
+//	    // indy #1: ()Ltest/IntOp;   = Main::negate
+//	    // indy #2: ()Ltest/ToInt;   = Counter::value
+//	    static int negate(int x) { return -x; }
+//	}
+//
+// It returns the CP and the CP indexes of the three call sites and of the
+// interface methods IntOp.apply() and ToInt.apply()
+func setupLambdaClasses() (*classloader.CPool, []uint16) {
+	setupInterfaceClasses()
+
+	const public, static, abstract = 0x0001, 0x0008, 0x0400
+	addTestClass("test/IntOp", "java/lang/Object", nil,
+		testMethod{"apply", "(I)I", public | abstract, nil})
+	addTestClass("test/ToInt", "java/lang/Object", nil,
+		testMethod{"apply", "(Ljava/lang/Object;)I", public | abstract, nil})
+	addTestClass("test/Counter", "java/lang/Object", nil,
+		testMethod{"value", "()I", public, []byte{ICONST_4, IRETURN}})
+
+	addTestClass("test/Main", "java/lang/Object", nil,
+		testMethod{"lambda$0", "(II)I", static, []byte{ILOAD_0, ILOAD_1, IADD, IRETURN}},
+		testMethod{"negate", "(I)I", static, []byte{ILOAD_0, INEG, IRETURN}})
+
+	b := newCPBuilder()
+	b.cp.Utf8Refs = classloader.MethAreaFetch("test/Main").Data.CP.Utf8Refs // the names of its methods
+	metafactory := b.methodHandle(refInvokeStatic, b.methodRef("java/lang/invoke/LambdaMetafactory",
+		"metafactory", "(Ljava/lang/invoke/MethodHandles$Lookup;Ljava/lang/String;"+
+			"Ljava/lang/invoke/MethodType;Ljava/lang/invoke/MethodType;Ljava/lang/invoke/MethodHandle;"+
+			"Ljava/lang/invoke/MethodType;)Ljava/lang/invoke/CallSite;"))
+	bootstraps := []classloader.BootstrapMethod{
+		{MethodRef: metafactory, Args: []uint16{b.methodType("(I)I"),
+			b.methodHandle(refInvokeStatic, b.methodRef("test/Main", "lambda$0", "(II)I")),
+			b.methodType("(I)I")}},
+		{MethodRef: metafactory, Args: []uint16{b.methodType("(I)I"),
+			b.methodHandle(refInvokeStatic, b.methodRef("test/Main", "negate", "(I)I")),
+			b.methodType("(I)I")}},
+		{MethodRef: metafactory, Args: []uint16{b.methodType("(Ljava/lang/Object;)I"),
+			b.methodHandle(refInvokeVirtual, b.methodRef("test/Counter", "value", "()I")),
+			b.methodType("(Ltest/Counter;)I")}},
+	}
+	indexes := []uint16{
+		b.invokeDynamic(0, "apply", "(I)Ltest/IntOp;"),
+		b.invokeDynamic(1, "apply", "()Ltest/IntOp;"),
+		b.invokeDynamic(2, "apply", "()Ltest/ToInt;"),
+		b.interfaceRef("test/IntOp", "apply", "(I)I"),
+		b.interfaceRef("test/ToInt", "apply", "(Ljava/lang/Object;)I"),
+	}
+
+	k := classloader.MethAreaFetch("test/Main")
+	k.Data.Bootstraps = bootstraps
+	k.Data.CP = b.cp
+	return &k.Data.CP, indexes
+}
+
+// INVOKEDYNAMIC: a lambda capturing a value, whose interface method is then invoked:
+// IntOp op = x -> 5 + x; op.apply(2)
+func TestInvokedynamicLambda(t *testing.T) {
+	CP, indexes := setupLambdaClasses()
+
+	f, err := runMainTestCode(CP,
+		ICONST_5,
+		INVOKEDYNAMIC, 0x00, byte(indexes[0]), 0x00, 0x00,
+		ICONST_2,
+		INVOKEINTERFACE, 0x00, byte(indexes[3]), 0x02, 0x00)
+	if err != nil {
+		t.Fatalf("INVOKEDYNAMIC: Unexpected error: %s", err.Error())
+	}
+	if f.TOS != 0 || f.OpStack[0] != int64(7) {
+		t.Errorf("INVOKEDYNAMIC: Expected the lambda to return 7, got: %v (TOS: %d)", f.OpStack[0], f.TOS)
+	}
+
+	// the call site is linked once; later executions create objects of the same class
+	site, ok := dynamicCallSites.Load(callSiteKey{cp: CP, cpIndex: int(indexes[0])})
+	if !ok {
+		t.Fatalf("INVOKEDYNAMIC: Expected the call site to be cached")
+	}
+	f, _ = runMainTestCode(CP, ICONST_3, INVOKEDYNAMIC, 0x00, byte(indexes[0]), 0x00, 0x00)
+	lambda := f.OpStack[0].(*object.Object)
+	if *lambda.Klass != site.(*lambdaCallSite).proxyClass {
+		t.Errorf("INVOKEDYNAMIC: Expected a lambda of class %s, got: %s",
+			site.(*lambdaCallSite).proxyClass, *lambda.Klass)
+	}
+	if lambda.FieldTable["arg$1"].Fvalue != int64(3) {
+		t.Errorf("INVOKEDYNAMIC: Expected the lambda to capture 3, got: %v", lambda.FieldTable["arg$1"].Fvalue)
+	}
+}
+
+// INVOKEDYNAMIC: a non-capturing method reference yields the same object each time:
+// IntOp op = Main::negate; op.apply(6)
+func TestInvokedynamicMethodReference(t *testing.T) {
+	CP, indexes := setupLambdaClasses()
+
+	f, err := runMainTestCode(CP,
+		INVOKEDYNAMIC, 0x00, byte(indexes[1]), 0x00, 0x00,
+		INVOKEDYNAMIC, 0x00, byte(indexes[1]), 0x00, 0x00)
+	if err != nil {
+		t.Fatalf("INVOKEDYNAMIC: Unexpected error: %s", err.Error())
+	}
+	if f.TOS != 1 || f.OpStack[0] != f.OpStack[1] {
+		t.Errorf("INVOKEDYNAMIC: Expected a non-capturing lambda to yield the same object")
+	}
+
+	f, err = runMainTestCode(CP,
+		INVOKEDYNAMIC, 0x00, byte(indexes[1]), 0x00, 0x00,
+		BIPUSH, 6,
+		INVOKEINTERFACE, 0x00, byte(indexes[3]), 0x02, 0x00)
+	if err != nil {
+		t.Fatalf("INVOKEDYNAMIC: Unexpected error: %s", err.Error())
+	}
+	if f.OpStack[0] != int64(-6) {
+		t.Errorf("INVOKEDYNAMIC: Expected the method reference to return -6, got: %v", f.OpStack[0])
+	}
+}
+
+// INVOKEDYNAMIC: an unbound reference to an instance method runs the method on the
+// interface method's first argument: ToInt op = Counter::value; op.apply(counter)
+func TestInvokedynamicInstanceMethodReference(t *testing.T) {
+	CP, indexes := setupLambdaClasses()
+
+	counter := object.MakeEmptyObject()
+	className := "test/Counter"
+	counter.Klass = &className
+
+	f := newFrame(INVOKEDYNAMIC)
+	f.Meth = append(f.Meth, 0x00, byte(indexes[2]), 0x00, 0x00,
+		SWAP,
+		INVOKEINTERFACE, 0x00, byte(indexes[4]), 0x02, 0x00)
+	f.ClName = "test/Main"
+	f.CP = CP
+	push(&f, counter)
+	fs := frames.CreateFrameStack()
+	fs.PushFront(&f)
+
+	if err := runFrame(fs); err != nil {
+		t.Fatalf("INVOKEDYNAMIC: Unexpected error: %s", err.Error())
+	}
+	if f.TOS != 0 || f.OpStack[0] != int64(4) {
+		t.Errorf("INVOKEDYNAMIC: Expected the method reference to return 4, got: %v", f.OpStack[0])
+	}
+
+	// with a null argument, the method can't be invoked
+	f.PC = 0
+	f.TOS = -1
+	push(&f, object.Null)
+	err := runFrame(fs)
+	if jt, ok := err.(*javaThrowable); !ok || jt.className != "java/lang/NullPointerException" {
+		t.Errorf("INVOKEDYNAMIC: Expected a NullPointerException, got: %v", err)
+	}
+}
+
+// INVOKEDYNAMIC: call sites with unknown bootstrap methods can't be linked
+func TestInvokedynamicUnsupportedBootstrap(t *testing.T) {
+	CP, _ := setupLambdaClasses()
+	k := classloader.MethAreaFetch("test/Main")
+
+	b := cpBuilder{cp: *CP}
+	bootstrap := b.methodHandle(refInvokeStatic, b.methodRef("test/Main", "bootstrap", "()V"))
+	k.Data.Bootstraps = append(k.Data.Bootstraps, classloader.BootstrapMethod{MethodRef: bootstrap})
+	index := b.invokeDynamic(len(k.Data.Bootstraps)-1, "run", "()Ljava/lang/Runnable;")
+	k.Data.CP = b.cp
+
+	_, err := runMainTestCode(&k.Data.CP, INVOKEDYNAMIC, 0x00, byte(index), 0x00, 0x00)
+	jt, ok := err.(*javaThrowable)
+	if !ok || jt.className != "java/lang/BootstrapMethodError" {
+		t.Errorf("INVOKEDYNAMIC: Expected a BootstrapMethodError, got: %v", err)
+	}
+}
+
+func TestMethodTypeParts(t *testing.T) {
+	params, ret := methodTypeParts("(IJLjava/lang/String;[[D[Ljava/lang/Object;Z)Ljava/util/List;")
+	expected := []string{"I", "J", "Ljava/lang/String;", "[[D", "[Ljava/lang/Object;", "Z"}
+	if len(params) != len(expected) {
+		t.Fatalf("methodTypeParts: expected %v, got: %v", expected, params)
+	}
+	for i := range expected {
+		if params[i] != expected[i] {
+			t.Errorf("methodTypeParts: expected %s for parameter %d, got: %s", expected[i], i, params[i])
+		}
+	}
+	if ret != "Ljava/util/List;" {
+		t.Errorf("methodTypeParts: expected return type Ljava/util/List;, got: %s", ret)
+	}
+}
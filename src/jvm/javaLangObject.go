@@ -0,0 +1,138 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"container/list"
+	"jacobin/classloader"
+	"jacobin/exceptions"
+	"jacobin/frames"
+	"jacobin/object"
+	"jacobin/thread"
+	"time"
+)
+
+// Go-based implementations of the methods of java.lang.Object that use the object's
+// monitor (see monitors.go).
+
+func Load_Lang_Object() map[string]classloader.GMeth {
+	methods := make(map[string]classloader.GMeth)
+
+	methods["java/lang/Object.wait()V"] =
+		classloader.GMeth{
+			ParamSlots:   1, // [0] = the object
+			GFunction:    objectWait,
+			NeedsContext: true,
+		}
+
+	methods["java/lang/Object.wait(J)V"] =
+		classloader.GMeth{
+			ParamSlots:   3, // [0] = the object, [1] = the timeout in milliseconds (a long)
+			GFunction:    objectWaitMillis,
+			NeedsContext: true,
+		}
+
+	methods["java/lang/Object.wait(JI)V"] =
+		classloader.GMeth{
+			ParamSlots:   4, // [0] = the object, [1] = the timeout in milliseconds (a long), [3] = nanoseconds
+			GFunction:    objectWaitMillisNanos,
+			NeedsContext: true,
+		}
+
+	methods["java/lang/Object.notify()V"] =
+		classloader.GMeth{
+			ParamSlots:   1, // [0] = the object
+			GFunction:    objectNotify,
+			NeedsContext: true,
+		}
+
+	methods["java/lang/Object.notifyAll()V"] =
+		classloader.GMeth{
+			ParamSlots:   1, // [0] = the object
+			GFunction:    objectNotifyAll,
+			NeedsContext: true,
+		}
+
+	return methods
+}
+
+// java/lang/Object.wait() waits until another thread notifies the object
+func objectWait(params []interface{}) interface{} {
+	return waitOnObject(params[1].(*list.List), params[0].(*object.Object), 0)
+}
+
+// java/lang/Object.wait(long) waits until another thread notifies the object or the
+// given number of milliseconds elapses. A timeout of 0 means to wait as long as necessary.
+func objectWaitMillis(params []interface{}) interface{} {
+	fs := params[3].(*list.List)
+	millis := params[1].(int64)
+	if millis < 0 {
+		return newVMThrowable(fs, exceptions.IllegalArgumentException, "timeout value is negative")
+	}
+	return waitOnObject(fs, params[0].(*object.Object), millisToDuration(millis))
+}
+
+// java/lang/Object.wait(long, int) is wait(long), with the timeout in milliseconds
+// and nanoseconds. As in the JDK, any nanoseconds add a millisecond.
+func objectWaitMillisNanos(params []interface{}) interface{} {
+	fs := params[4].(*list.List)
+	millis, nanos := params[1].(int64), params[3].(int64)
+	if millis < 0 {
+		return newVMThrowable(fs, exceptions.IllegalArgumentException, "timeout value is negative")
+	}
+	if nanos < 0 || nanos > 999999 {
+		return newVMThrowable(fs, exceptions.IllegalArgumentException, "nanosecond timeout value out of range")
+	}
+	if nanos > 0 && millis < 1<<63-1 {
+		millis++
+	}
+	return waitOnObject(fs, params[0].(*object.Object), millisToDuration(millis))
+}
+
+// waitOnObject releases the object's monitor, which the thread must hold, and waits
+// until it's notified, the timeout (if not 0) elapses, or the thread is interrupted,
+// which throws an InterruptedException. As JLS 17.2.4 requires, a thread that's both
+// notified and interrupted returns normally, with its interrupt status still set, so
+// that the notification isn't lost.
+func waitOnObject(fs *list.List, obj *object.Object, timeout time.Duration) interface{} {
+	threadID := fs.Front().Value.(*frames.Frame).Thread
+	exec := currentExecThread(fs)
+
+	if !obj.HoldsMonitor(threadID) {
+		return newVMThrowable(fs, exceptions.IllegalMonitorStateException, notOwnerMsg)
+	}
+	if exec.ClearInterrupt() {
+		return newVMThrowable(fs, exceptions.InterruptedException, "")
+	}
+	setWaitState(fs, exec, waitingState(timeout), obj)
+	notified, _ := obj.MonitorWait(threadID, timeout, exec.Interrupts())
+	exec.SetWaitState(thread.Runnable, nil)
+	if !notified && exec.ClearInterrupt() {
+		return newVMThrowable(fs, exceptions.InterruptedException, "")
+	}
+	return nil
+}
+
+// java/lang/Object.notify() wakes one of the threads waiting on the object
+func objectNotify(params []interface{}) interface{} {
+	return notifyObject(params[1].(*list.List), params[0].(*object.Object), false)
+}
+
+// java/lang/Object.notifyAll() wakes all the threads waiting on the object
+func objectNotifyAll(params []interface{}) interface{} {
+	return notifyObject(params[1].(*list.List), params[0].(*object.Object), true)
+}
+
+// notifyObject wakes one or all of the threads waiting on the object. The thread
+// must hold the object's monitor.
+func notifyObject(fs *list.List, obj *object.Object, all bool) interface{} {
+	threadID := fs.Front().Value.(*frames.Frame).Thread
+	if !obj.MonitorNotify(threadID, all) {
+		return newVMThrowable(fs, exceptions.IllegalMonitorStateException, notOwnerMsg)
+	}
+	return nil
+}
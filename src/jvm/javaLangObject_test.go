@@ -0,0 +1,195 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"container/list"
+	"jacobin/frames"
+	"jacobin/globals"
+	"jacobin/log"
+	"jacobin/object"
+	"jacobin/thread"
+	"math"
+	"testing"
+	"time"
+)
+
+// returns a new thread, which is in the thread table, and a frame stack holding a frame of it
+func newWaitTestThread() (*thread.ExecThread, *list.List) {
+	exec := thread.CreateThread()
+	thread.AddThreadToTable(&exec, &globals.GetGlobalRef().Threads)
+	f := newFrame(RETURN)
+	f.Thread = exec.ID
+	fs := frames.CreateFrameStack()
+	fs.PushFront(&f)
+	return &exec, fs
+}
+
+// starts a goroutine for a new thread that locks the object's monitor and then waits
+// on it. The returned channel receives what wait() returns. Once this function
+// returns, the thread is in the monitor's wait set.
+func startWaiter(t *testing.T, obj *object.Object, mainID int) (*thread.ExecThread, chan interface{}) {
+	exec, fs := newWaitTestThread()
+	entered := make(chan struct{})
+	result := make(chan interface{}, 1)
+	go func() {
+		obj.MonitorEnter(exec.ID)
+		close(entered)
+		ret := objectWait([]interface{}{obj, fs})
+		if !obj.MonitorExit(exec.ID) {
+			t.Error("Expected the waiting thread to hold the monitor again after wait()")
+		}
+		result <- ret
+	}()
+
+	// once this thread can lock the monitor, the waiter has released it by waiting
+	<-entered
+	obj.MonitorEnter(mainID)
+	obj.MonitorExit(mainID)
+	return exec, result
+}
+
+// receives what wait() returned, failing if it doesn't return in time
+func awaitWaiter(t *testing.T, result chan interface{}) interface{} {
+	select {
+	case ret := <-result:
+		return ret
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for wait() to return")
+		return nil
+	}
+}
+
+func setupWaitTest() (*thread.ExecThread, *list.List) {
+	globals.InitGlobals("test")
+	log.Init()
+	return newWaitTestThread()
+}
+
+// wait() and notify() can be called only by the thread that holds the object's monitor
+func TestWaitAndNotifyWithoutMonitor(t *testing.T) {
+	_, fs := setupWaitTest()
+	obj := object.MakeEmptyObject()
+
+	for name, ret := range map[string]interface{}{
+		"wait()":      objectWait([]interface{}{obj, fs}),
+		"notify()":    objectNotify([]interface{}{obj, fs}),
+		"notifyAll()": objectNotifyAll([]interface{}{obj, fs}),
+	} {
+		jt, ok := ret.(*javaThrowable)
+		if !ok || jt.className != "java/lang/IllegalMonitorStateException" {
+			t.Errorf("Expected an IllegalMonitorStateException from %s, got: %v", name, ret)
+		}
+	}
+}
+
+// A timed wait returns when the time elapses, holding the monitor as many times as before
+func TestTimedWait(t *testing.T) {
+	exec, fs := setupWaitTest()
+	obj := object.MakeEmptyObject()
+	obj.MonitorEnter(exec.ID)
+	obj.MonitorEnter(exec.ID)
+
+	start := time.Now()
+	if ret := objectWaitMillis([]interface{}{obj, int64(20), int64(20), fs}); ret != nil {
+		t.Fatalf("Unexpected result from wait(20): %v", ret)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("Expected wait(20) to take at least 20ms, took: %s", elapsed)
+	}
+	if !obj.MonitorExit(exec.ID) || !obj.MonitorExit(exec.ID) || obj.MonitorExit(exec.ID) {
+		t.Error("Expected the monitor to be held twice after wait()")
+	}
+
+	ret := objectWaitMillis([]interface{}{obj, int64(-1), int64(-1), fs})
+	if jt, ok := ret.(*javaThrowable); !ok || jt.className != "java/lang/IllegalArgumentException" {
+		t.Errorf("Expected an IllegalArgumentException from wait(-1), got: %v", ret)
+	}
+}
+
+// Timeouts too long for a time.Duration wait as long as necessary, rather than for
+// a timeout that has overflowed
+func TestLongestTimedWaits(t *testing.T) {
+	exec, fs := setupWaitTest()
+	obj := object.MakeEmptyObject()
+
+	for name, call := range map[string]func() interface{}{
+		"wait(2^64 / 1000000 + 1)": func() interface{} { // as nanoseconds, this wraps to under 1ms
+			millis := int64(math.MaxUint64/1000000 + 1)
+			return objectWaitMillis([]interface{}{obj, millis, millis, fs})
+		},
+		"wait(Long.MAX_VALUE, 1)": func() interface{} {
+			return objectWaitMillisNanos([]interface{}{obj, int64(math.MaxInt64), int64(math.MaxInt64), int64(1), fs})
+		},
+	} {
+		result := make(chan interface{}, 1)
+		go func() {
+			obj.MonitorEnter(exec.ID)
+			defer obj.MonitorExit(exec.ID)
+			result <- call()
+		}()
+		select {
+		case ret := <-result:
+			t.Errorf("Expected %s to wait, but it returned: %v", name, ret)
+			continue
+		case <-time.After(50 * time.Millisecond):
+		}
+		exec.Interrupt()
+		if ret := awaitWaiter(t, result); ret == nil {
+			t.Errorf("Expected an InterruptedException from %s", name)
+		}
+	}
+}
+
+// notify() wakes one waiting thread, and notifyAll() wakes the rest
+func TestNotifyAndNotifyAll(t *testing.T) {
+	main, fs := setupWaitTest()
+	obj := object.MakeEmptyObject()
+	_, first := startWaiter(t, obj, main.ID)
+	_, second := startWaiter(t, obj, main.ID)
+	_, third := startWaiter(t, obj, main.ID)
+
+	obj.MonitorEnter(main.ID)
+	if ret := objectNotify([]interface{}{obj, fs}); ret != nil {
+		t.Fatalf("Unexpected result from notify(): %v", ret)
+	}
+	obj.MonitorExit(main.ID)
+	if ret := awaitWaiter(t, first); ret != nil {
+		t.Errorf("Expected the first waiting thread to return normally, got: %v", ret)
+	}
+	select {
+	case <-second:
+		t.Error("Expected notify() to wake only one thread")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	obj.MonitorEnter(main.ID)
+	objectNotifyAll([]interface{}{obj, fs})
+	obj.MonitorExit(main.ID)
+	for _, result := range []chan interface{}{second, third} {
+		if ret := awaitWaiter(t, result); ret != nil {
+			t.Errorf("Expected the waiting threads to return normally, got: %v", ret)
+		}
+	}
+}
+
+// Interrupting a waiting thread makes wait() throw an InterruptedException, which
+// clears the thread's interrupt status
+func TestInterruptWait(t *testing.T) {
+	main, _ := setupWaitTest()
+	obj := object.MakeEmptyObject()
+	waiter, result := startWaiter(t, obj, main.ID)
+
+	waiter.Interrupt()
+	ret := awaitWaiter(t, result)
+	if jt, ok := ret.(*javaThrowable); !ok || jt.className != "java/lang/InterruptedException" {
+		t.Errorf("Expected an InterruptedException from wait(), got: %v", ret)
+	}
+	if waiter.IsInterrupted() {
+		t.Error("Expected the InterruptedException to clear the interrupt status")
+	}
+}
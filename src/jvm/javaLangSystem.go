@@ -0,0 +1,237 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"container/list"
+	"jacobin/classloader"
+	"jacobin/globals"
+	"jacobin/object"
+	"sync"
+	"sync/atomic"
+)
+
+// Go-based implementations of the methods of java.lang.System that run Java code,
+// and of the methods of the Properties that System.getProperties() returns, which
+// keep it in step with the system properties.
+
+func Load_Lang_System() map[string]classloader.GMeth {
+	methods := make(map[string]classloader.GMeth)
+
+	methods["java/lang/System.getProperties()Ljava/util/Properties;"] =
+		classloader.GMeth{
+			ParamSlots:   0,
+			GFunction:    getProperties,
+			NeedsContext: true,
+		}
+
+	methods["java/lang/System.setProperty(Ljava/lang/String;Ljava/lang/String;)Ljava/lang/String;"] =
+		classloader.GMeth{
+			ParamSlots:   2,
+			GFunction:    systemSetProperty,
+			NeedsContext: true,
+		}
+
+	methods["java/lang/System.clearProperty(Ljava/lang/String;)Ljava/lang/String;"] =
+		classloader.GMeth{
+			ParamSlots:   1,
+			GFunction:    systemClearProperty,
+			NeedsContext: true,
+		}
+
+	methods[systemPropsClass+".put"+propsPutType] =
+		classloader.GMeth{
+			ParamSlots:   3, // [0] = the Properties, [1] = the key, [2] = the value
+			GFunction:    propertiesPut,
+			NeedsContext: true,
+		}
+
+	methods[systemPropsClass+".remove"+propsRemoveType] =
+		classloader.GMeth{
+			ParamSlots:   2, // [0] = the Properties, [1] = the key
+			GFunction:    propertiesRemove,
+			NeedsContext: true,
+		}
+
+	return methods
+}
+
+const (
+	propsClass      = "java/util/Properties"
+	propsPutType    = "(Ljava/lang/Object;Ljava/lang/Object;)Ljava/lang/Object;"
+	propsRemoveType = "(Ljava/lang/Object;)Ljava/lang/Object;"
+)
+
+// The java.util.Properties that System.getProperties() returns. It's created from the
+// system properties in globals the first time it's asked for, and from then on, it's
+// kept in step with them. It's an instance of systemPropsClass, a synthetic subclass
+// of Properties whose put() and remove(), which the other methods of Properties, such
+// as setProperty() and load(), call, run those of Properties and then change the system
+// properties too. Other Properties objects are not affected. System.setProperty() and
+// clearProperty() change it as well as the system properties. (Changes made to it by
+// Hashtable methods that Properties overrides without calling put() or remove(), such
+// as clear(), are not made to the system properties.) systemPropsMutex serializes its
+// creation and the changes made by System.
+var systemProps atomic.Value // the *object.Object, once it's created
+var systemPropsMutex sync.Mutex
+
+const systemPropsClass = "jacobin/SystemProperties"
+
+// returns the Properties that System.getProperties() returns, or nil if it has not
+// been created yet
+func systemPropsObject() *object.Object {
+	props, _ := systemProps.Load().(*object.Object)
+	return props
+}
+
+// adds systemPropsClass to the method area, if it's not there yet. Its put() and
+// remove() are native, as the Go methods above implement them.
+func addSystemPropsClass() {
+	if classloader.MethAreaFetch(systemPropsClass) != nil {
+		return
+	}
+	data := classloader.ClData{Name: systemPropsClass, Superclass: propsClass}
+	data.CP.Utf8Refs = []string{"put", propsPutType, "remove", propsRemoveType}
+	for i := 0; i < len(data.CP.Utf8Refs); i += 2 {
+		data.Methods = append(data.Methods, classloader.Method{
+			AccessFlags: 0x0001 | 0x0100, // ACC_PUBLIC, ACC_NATIVE
+			Name:        uint16(i),
+			Desc:        uint16(i + 1),
+		})
+	}
+	classloader.MethAreaInsert(systemPropsClass, &classloader.Klass{
+		Status: 'N', // N = instantiated
+		Loader: "bootstrap",
+		Data:   &data,
+	})
+}
+
+// java/lang/System.getProperties() returns the java.util.Properties that holds
+// the system properties
+func getProperties(params []interface{}) interface{} {
+	fs := params[0].(*list.List)
+
+	systemPropsMutex.Lock()
+	defer systemPropsMutex.Unlock()
+	if props := systemPropsObject(); props != nil {
+		return props
+	}
+
+	addSystemPropsClass()
+	props, err := instantiateClass(systemPropsClass)
+	if err != nil {
+		return err
+	}
+	mte, err := classloader.FetchMethodAndCP(propsClass, "<init>", "()V")
+	if err != nil {
+		return err
+	}
+	if _, err = runJavaMethod(fs, mte, propsClass, "<init>", "()V", true, []interface{}{props}); err != nil {
+		return err
+	}
+
+	for _, key := range globals.GetPropertyKeys() {
+		value, ok := globals.GetProperty(key)
+		if !ok { // cleared since the keys were fetched
+			continue
+		}
+		args := []interface{}{props,
+			object.CreateCompactStringFromGoString(&key), object.CreateCompactStringFromGoString(&value)}
+		if _, err = runPropertiesMethod(fs, "put", propsPutType, args); err != nil {
+			return err
+		}
+	}
+	systemProps.Store(props)
+	return props
+}
+
+// java/lang/System.setProperty() sets a system property and returns its previous
+// value, or null if it was not set
+func systemSetProperty(params []interface{}) interface{} {
+	fs := params[2].(*list.List)
+	key, _ := params[0].(*object.Object)
+	value, _ := params[1].(*object.Object)
+
+	systemPropsMutex.Lock()
+	defer systemPropsMutex.Unlock()
+	prev, ok := globals.SetProperty(
+		object.GetGoStringFromJavaString(key), object.GetGoStringFromJavaString(value))
+	if props := systemPropsObject(); props != nil {
+		if _, err := runPropertiesMethod(fs, "put", propsPutType, []interface{}{props, key, value}); err != nil {
+			return err
+		}
+	}
+	return classloader.PropertyValue(prev, ok)
+}
+
+// java/lang/System.clearProperty() removes a system property and returns its value,
+// or null if it was not set
+func systemClearProperty(params []interface{}) interface{} {
+	fs := params[1].(*list.List)
+	key, _ := params[0].(*object.Object)
+
+	systemPropsMutex.Lock()
+	defer systemPropsMutex.Unlock()
+	prev, ok := globals.ClearProperty(object.GetGoStringFromJavaString(key))
+	if props := systemPropsObject(); props != nil {
+		if _, err := runPropertiesMethod(fs, "remove", propsRemoveType, []interface{}{props, key}); err != nil {
+			return err
+		}
+	}
+	return classloader.PropertyValue(prev, ok)
+}
+
+// put() of the system Properties runs that of java.util.Properties and sets the system
+// property too. As System.getProperty() returns null for a property whose value is
+// not a String, such a value clears it.
+func propertiesPut(params []interface{}) interface{} {
+	fs := params[3].(*list.List)
+	prev, err := runPropertiesMethod(fs, "put", propsPutType, params[:3])
+	if err != nil {
+		return err
+	}
+	if key, ok := javaString(params[1]); ok {
+		if value, ok := javaString(params[2]); ok {
+			globals.SetProperty(key, value)
+		} else {
+			globals.ClearProperty(key)
+		}
+	}
+	return prev
+}
+
+// remove() of the system Properties runs that of java.util.Properties and removes the
+// system property too
+func propertiesRemove(params []interface{}) interface{} {
+	fs := params[2].(*list.List)
+	prev, err := runPropertiesMethod(fs, "remove", propsRemoveType, params[:2])
+	if err != nil {
+		return err
+	}
+	if key, ok := javaString(params[1]); ok {
+		globals.ClearProperty(key)
+	}
+	return prev
+}
+
+// runs the method of java.util.Properties on the Properties in args[0]
+func runPropertiesMethod(fs *list.List, methName, methType string, args []interface{}) (interface{}, error) {
+	mte, err := classloader.FetchMethodAndCP(propsClass, methName, methType)
+	if err != nil {
+		return nil, err
+	}
+	return runJavaMethod(fs, mte, propsClass, methName, methType, true, args)
+}
+
+// returns the Go string that a java.lang.String holds, and false if ref is not a String
+func javaString(ref interface{}) (string, bool) {
+	obj, ok := ref.(*object.Object)
+	if !ok || obj == nil || obj.Klass == nil || *obj.Klass != "java/lang/String" {
+		return "", false
+	}
+	return object.GetGoStringFromJavaString(obj), true
+}
@@ -0,0 +1,180 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"jacobin/classloader"
+	"jacobin/frames"
+	"jacobin/globals"
+	"jacobin/object"
+	"testing"
+)
+
+// sets up a java.util.Properties whose methods work as they do in the JDK, where
+// they keep the properties in the ConcurrentHashMap in its map field. Here, that map
+// is the Go map in store, which the static Go methods of test/Map update:
+//
+//	public Object setProperty(String key, String value) { return put(key, value); }
+//	public String getProperty(String key) { return (String) Map.get(this, key); }
+//	public Object put(Object key, Object value) { return Map.put(this, key, value); }
+//	public Object remove(Object key) { return Map.remove(this, key); }
+func setupProperties() {
+	setupInterfaceClasses()
+	classloader.MTableLoadGoMethods(Load_Lang_System())
+	systemProps.Store((*object.Object)(nil))
+
+	addTestClass("test/Map", "java/lang/Object", nil)
+	store := make(map[*object.Object]map[string]*object.Object)
+	entries := func(params []interface{}) (map[string]*object.Object, string) {
+		props := params[0].(*object.Object)
+		if store[props] == nil {
+			store[props] = make(map[string]*object.Object)
+		}
+		return store[props], object.GetGoStringFromJavaString(params[1].(*object.Object))
+	}
+	value := func(v *object.Object, ok bool) interface{} {
+		if !ok {
+			return object.Null
+		}
+		return v
+	}
+	addGoMethod := func(name, desc string, paramSlots int, fu func([]interface{}) interface{}) {
+		classloader.MTable["test/Map."+name+desc] = classloader.MTentry{MType: 'G',
+			Meth: classloader.GmEntry{ParamSlots: paramSlots, Fu: fu}}
+	}
+	addGoMethod("get", "(Ljava/lang/Object;Ljava/lang/Object;)Ljava/lang/Object;", 2,
+		func(params []interface{}) interface{} {
+			m, key := entries(params)
+			prev, ok := m[key]
+			return value(prev, ok)
+		})
+	addGoMethod("put", "(Ljava/lang/Object;Ljava/lang/Object;Ljava/lang/Object;)Ljava/lang/Object;", 3,
+		func(params []interface{}) interface{} {
+			m, key := entries(params)
+			prev, ok := m[key]
+			m[key] = params[2].(*object.Object)
+			return value(prev, ok)
+		})
+	addGoMethod("remove", "(Ljava/lang/Object;Ljava/lang/Object;)Ljava/lang/Object;", 2,
+		func(params []interface{}) interface{} {
+			m, key := entries(params)
+			prev, ok := m[key]
+			delete(m, key)
+			return value(prev, ok)
+		})
+
+	b := newCPBuilder()
+	put := b.methodRef(propsClass, "put", propsPutType)
+	mapGet := b.methodRef("test/Map", "get", "(Ljava/lang/Object;Ljava/lang/Object;)Ljava/lang/Object;")
+	mapPut := b.methodRef("test/Map", "put", "(Ljava/lang/Object;Ljava/lang/Object;Ljava/lang/Object;)Ljava/lang/Object;")
+	mapRemove := b.methodRef("test/Map", "remove", "(Ljava/lang/Object;Ljava/lang/Object;)Ljava/lang/Object;")
+
+	const public = 0x0001
+	k := addClass(propsClass, "java/lang/Object", nil, b, nil,
+		testMethod{"<init>", "()V", public, []byte{RETURN}},
+		testMethod{"setProperty", "(Ljava/lang/String;Ljava/lang/String;)Ljava/lang/Object;", public,
+			[]byte{ALOAD_0, ALOAD_1, ALOAD_2, INVOKEVIRTUAL, byte(put >> 8), byte(put), ARETURN}},
+		testMethod{"getProperty", "(Ljava/lang/String;)Ljava/lang/String;", public,
+			[]byte{ALOAD_0, ALOAD_1, INVOKESTATIC, byte(mapGet >> 8), byte(mapGet), ARETURN}},
+		testMethod{"put", propsPutType, public,
+			[]byte{ALOAD_0, ALOAD_1, ALOAD_2, INVOKESTATIC, byte(mapPut >> 8), byte(mapPut), ARETURN}},
+		testMethod{"remove", propsRemoveType, public,
+			[]byte{ALOAD_0, ALOAD_1, INVOKESTATIC, byte(mapRemove >> 8), byte(mapRemove), ARETURN}})
+	for i := range k.Data.Methods {
+		k.Data.Methods[i].CodeAttr.MaxStack = 3
+		k.Data.Methods[i].CodeAttr.MaxLocals = 3
+	}
+}
+
+// System.getProperties() returns a Properties that holds the system properties, and
+// changes made to it are made to the system properties, and vice versa
+func TestGetProperties(t *testing.T) {
+	setupProperties()
+	globals.SetProperty("app.name", "demo")
+
+	f := newFrame(RETURN)
+	f.OpStack = make([]interface{}, 10)
+	fs := frames.CreateFrameStack()
+	fs.PushFront(&f)
+
+	// invokes a method of Properties on props, which is selected by the class of props,
+	// as INVOKEVIRTUAL does
+	invoke := func(props *object.Object, meth, desc string, args ...string) string {
+		mte, err := classloader.FetchMethodAndCP(*props.Klass, meth, desc)
+		if err != nil {
+			t.Fatalf("Could not fetch Properties.%s(): %v", meth, err)
+		}
+		params := []interface{}{props}
+		for i := range args {
+			params = append(params, object.CreateCompactStringFromGoString(&args[i]))
+		}
+		ret, err := runJavaMethod(fs, mte, *props.Klass, meth, desc, true, params)
+		if err != nil {
+			t.Fatalf("Properties.%s() failed: %v", meth, err)
+		}
+		if ret == object.Null {
+			return "null"
+		}
+		return object.GetGoStringFromJavaString(ret.(*object.Object))
+	}
+	const getType = "(Ljava/lang/String;)Ljava/lang/String;"
+	const setType = "(Ljava/lang/String;Ljava/lang/String;)Ljava/lang/Object;"
+	str := func(s string) *object.Object { return object.CreateCompactStringFromGoString(&s) }
+
+	result := getProperties([]interface{}{fs})
+	props, ok := result.(*object.Object)
+	if !ok || !isClassOrSubclassOf(*props.Klass, propsClass) {
+		t.Fatalf("Expected a java/util/Properties, got: %v", result)
+	}
+	if again := getProperties([]interface{}{fs}); again != props {
+		t.Errorf("Expected System.getProperties() to return the same Properties each time")
+	}
+	if value := invoke(props, "getProperty", getType, "app.name"); value != "demo" {
+		t.Errorf("Expected app.name to be demo in the properties, got: %s", value)
+	}
+
+	// setProperty() calls put(), which sets the system property too
+	if prev := invoke(props, "setProperty", setType, "app.mode", "test"); prev != "null" {
+		t.Errorf("Expected setProperty() to return null for a new property, got: %s", prev)
+	}
+	if value, _ := globals.GetProperty("app.mode"); value != "test" {
+		t.Errorf("Expected setProperty() to set the system property app.mode to test, got: %s", value)
+	}
+	if prev := invoke(props, "remove", propsRemoveType, "app.mode"); prev != "test" {
+		t.Errorf("Expected remove() to return test, got: %s", prev)
+	}
+	if _, ok := globals.GetProperty("app.mode"); ok {
+		t.Errorf("Expected remove() to clear the system property app.mode")
+	}
+
+	// System.setProperty() and clearProperty() change the Properties too
+	prev := systemSetProperty([]interface{}{str("app.name"), str("prod"), fs})
+	if object.GetGoStringFromJavaString(prev.(*object.Object)) != "demo" {
+		t.Errorf("Expected System.setProperty() to return demo, got: %v", prev)
+	}
+	if value := invoke(props, "getProperty", getType, "app.name"); value != "prod" {
+		t.Errorf("Expected app.name to be prod in the properties, got: %s", value)
+	}
+	systemClearProperty([]interface{}{str("app.name"), fs})
+	if value := invoke(props, "getProperty", getType, "app.name"); value != "null" {
+		t.Errorf("Expected app.name to be cleared from the properties, got: %s", value)
+	}
+
+	// other Properties are not the system properties
+	other, err := instantiateClass(propsClass)
+	if err != nil {
+		t.Fatalf("Could not instantiate Properties: %v", err)
+	}
+	invoke(other, "setProperty", setType, "app.other", "x")
+	if _, ok := globals.GetProperty("app.other"); ok {
+		t.Errorf("Expected a Properties other than System.getProperties() not to set system properties")
+	}
+
+	if f.TOS != -1 {
+		t.Errorf("Expected the caller's operand stack to be unchanged, got TOS: %d", f.TOS)
+	}
+}
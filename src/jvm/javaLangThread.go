@@ -0,0 +1,529 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"container/list"
+	"errors"
+	"jacobin/classloader"
+	"jacobin/exceptions"
+	"jacobin/frames"
+	"jacobin/globals"
+	"jacobin/object"
+	"jacobin/thread"
+	"jacobin/types"
+	"math"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Go-based implementations of the methods of java.lang.Thread. Each started Thread
+// runs its own frame stack on a goroutine (see thread.ExecThread).
+
+// javaThread is what Jacobin knows about a java.lang.Thread object
+type javaThread struct {
+	exec   *thread.ExecThread
+	target *object.Object // the Runnable passed to the constructor, if any
+}
+
+// A Thread object's javaThread is kept in its eetop field, where the JDK's VM keeps
+// its own thread, so it's freed with the object. javaThreads holds the javaThreads
+// of the Thread objects whose class has no such field.
+var javaThreads sync.Map // *object.Object -> *javaThread
+
+// threadMutex guards the names and the daemon status of the threads, which can be
+// changed by one thread while another reads them
+var threadMutex sync.Mutex
+
+// threadNumber is the number of the last thread given a default name, Thread-N
+var threadNumber int64 = -1
+
+func Load_Lang_Thread() map[string]classloader.GMeth {
+	methods := make(map[string]classloader.GMeth)
+
+	methods["java/lang/Thread.<init>()V"] =
+		classloader.GMeth{
+			ParamSlots:   1, // [0] = the Thread
+			GFunction:    threadInit,
+			NeedsContext: true,
+		}
+
+	methods["java/lang/Thread.<init>(Ljava/lang/Runnable;)V"] =
+		classloader.GMeth{
+			ParamSlots:   2, // [0] = the Thread, [1] = the Runnable
+			GFunction:    threadInitWithTarget,
+			NeedsContext: true,
+		}
+
+	methods["java/lang/Thread.<init>(Ljava/lang/String;)V"] =
+		classloader.GMeth{
+			ParamSlots:   2, // [0] = the Thread, [1] = the name
+			GFunction:    threadInitWithName,
+			NeedsContext: true,
+		}
+
+	methods["java/lang/Thread.<init>(Ljava/lang/Runnable;Ljava/lang/String;)V"] =
+		classloader.GMeth{
+			ParamSlots:   3, // [0] = the Thread, [1] = the Runnable, [2] = the name
+			GFunction:    threadInitWithTargetAndName,
+			NeedsContext: true,
+		}
+
+	methods["java/lang/Thread.start()V"] =
+		classloader.GMeth{
+			ParamSlots:   1, // [0] = the Thread
+			GFunction:    threadStart,
+			NeedsContext: true,
+		}
+
+	methods["java/lang/Thread.run()V"] =
+		classloader.GMeth{
+			ParamSlots:   1, // [0] = the Thread
+			GFunction:    threadRun,
+			NeedsContext: true,
+		}
+
+	methods["java/lang/Thread.join()V"] =
+		classloader.GMeth{
+			ParamSlots:   1, // [0] = the Thread
+			GFunction:    threadJoin,
+			NeedsContext: true,
+		}
+
+	methods["java/lang/Thread.join(J)V"] =
+		classloader.GMeth{
+			ParamSlots:   3, // [0] = the Thread, [1] = the timeout in milliseconds (a long)
+			GFunction:    threadJoinMillis,
+			NeedsContext: true,
+		}
+
+	methods["java/lang/Thread.sleep(J)V"] =
+		classloader.GMeth{
+			ParamSlots:   2, // [0] = the time in milliseconds (a long)
+			GFunction:    threadSleep,
+			NeedsContext: true,
+		}
+
+	methods["java/lang/Thread.interrupt()V"] =
+		classloader.GMeth{
+			ParamSlots: 1, // [0] = the Thread
+			GFunction:  threadInterrupt,
+		}
+
+	methods["java/lang/Thread.isInterrupted()Z"] =
+		classloader.GMeth{
+			ParamSlots: 1, // [0] = the Thread
+			GFunction:  threadIsInterrupted,
+		}
+
+	methods["java/lang/Thread.interrupted()Z"] =
+		classloader.GMeth{
+			ParamSlots:   0,
+			GFunction:    threadInterrupted,
+			NeedsContext: true,
+		}
+
+	methods["java/lang/Thread.currentThread()Ljava/lang/Thread;"] =
+		classloader.GMeth{
+			ParamSlots:   0,
+			GFunction:    currentThread,
+			NeedsContext: true,
+		}
+
+	methods["java/lang/Thread.isAlive()Z"] =
+		classloader.GMeth{
+			ParamSlots: 1, // [0] = the Thread
+			GFunction:  threadIsAlive,
+		}
+
+	// in recent JDKs, isAlive() calls alive(), which reads eetop
+	methods["java/lang/Thread.alive()Z"] =
+		classloader.GMeth{
+			ParamSlots: 1, // [0] = the Thread
+			GFunction:  threadIsAlive,
+		}
+
+	methods["java/lang/Thread.setDaemon(Z)V"] =
+		classloader.GMeth{
+			ParamSlots:   2, // [0] = the Thread, [1] = the daemon status
+			GFunction:    threadSetDaemon,
+			NeedsContext: true,
+		}
+
+	methods["java/lang/Thread.isDaemon()Z"] =
+		classloader.GMeth{
+			ParamSlots: 1, // [0] = the Thread
+			GFunction:  threadIsDaemon,
+		}
+
+	methods["java/lang/Thread.getName()Ljava/lang/String;"] =
+		classloader.GMeth{
+			ParamSlots: 1, // [0] = the Thread
+			GFunction:  threadGetName,
+		}
+
+	methods["java/lang/Thread.setName(Ljava/lang/String;)V"] =
+		classloader.GMeth{
+			ParamSlots:   2, // [0] = the Thread, [1] = the name
+			GFunction:    threadSetName,
+			NeedsContext: true,
+		}
+
+	return methods
+}
+
+// java/lang/Thread.<init>() creates a thread named Thread-N, whose run() does nothing
+// unless it's overridden
+func threadInit(params []interface{}) interface{} {
+	newJavaThread(params[1].(*list.List), params[0].(*object.Object), nil, "")
+	return nil
+}
+
+// java/lang/Thread.<init>(Runnable) creates a thread named Thread-N, whose run()
+// runs the Runnable's run()
+func threadInitWithTarget(params []interface{}) interface{} {
+	target, _ := params[1].(*object.Object)
+	newJavaThread(params[2].(*list.List), params[0].(*object.Object), target, "")
+	return nil
+}
+
+// java/lang/Thread.<init>(String) creates a thread with the given name
+func threadInitWithName(params []interface{}) interface{} {
+	fs := params[2].(*list.List)
+	name, ok := params[1].(*object.Object)
+	if !ok || name == nil {
+		return newVMThrowable(fs, exceptions.NullPointerException, "'name' is null")
+	}
+	newJavaThread(fs, params[0].(*object.Object), nil, object.GetGoStringFromJavaString(name))
+	return nil
+}
+
+// java/lang/Thread.<init>(Runnable, String) creates a thread with the given name,
+// whose run() runs the Runnable's run()
+func threadInitWithTargetAndName(params []interface{}) interface{} {
+	fs := params[3].(*list.List)
+	name, ok := params[2].(*object.Object)
+	if !ok || name == nil {
+		return newVMThrowable(fs, exceptions.NullPointerException, "'name' is null")
+	}
+	target, _ := params[1].(*object.Object)
+	newJavaThread(fs, params[0].(*object.Object), target, object.GetGoStringFromJavaString(name))
+	return nil
+}
+
+// newJavaThread records a new Thread object
+func newJavaThread(fs *list.List, obj *object.Object, target *object.Object, name string) {
+	storeJavaThread(obj, makeJavaThread(fs, obj, target, name))
+}
+
+// storeJavaThread records what's known about a Thread object
+func storeJavaThread(obj *object.Object, jt *javaThread) {
+	lock := obj.FieldLock()
+	lock.Lock()
+	defer lock.Unlock()
+	if !setObjectFieldByName(obj, "eetop", jt) {
+		javaThreads.Store(obj, jt)
+	}
+}
+
+// makeJavaThread creates what's known about a new Thread object. As in the JDK, a
+// thread is a daemon thread if the thread that creates it is one. It traces the
+// instructions it runs if the thread that creates it does. If name is empty, the
+// thread is named Thread-N.
+func makeJavaThread(fs *list.List, obj *object.Object, target *object.Object, name string) *javaThread {
+	if name == "" {
+		name = "Thread-" + strconv.FormatInt(atomic.AddInt64(&threadNumber, 1), 10)
+	}
+	exec := thread.CreateThread()
+	exec.Name = name
+	exec.Object = obj
+	if fs != nil && fs.Len() > 0 {
+		threadMutex.Lock()
+		exec.Daemon = currentExecThread(fs).Daemon
+		threadMutex.Unlock()
+		exec.Trace = fs.Front().Value.(*frames.Frame).Trace
+	}
+
+	return &javaThread{exec: &exec, target: target}
+}
+
+// getJavaThread returns what's known about a Thread object. A Thread whose
+// constructor was not run by Jacobin (which should not happen) gets the defaults.
+func getJavaThread(obj *object.Object) *javaThread {
+	lock := obj.FieldLock()
+	lock.Lock()
+	defer lock.Unlock()
+	if eetop, ok := getObjectFieldByName(obj, "eetop"); ok {
+		jt, ok := eetop.(*javaThread)
+		if !ok {
+			jt = makeJavaThread(nil, obj, nil, "")
+			setObjectFieldByName(obj, "eetop", jt)
+		}
+		return jt
+	}
+
+	if jt, ok := javaThreads.Load(obj); ok {
+		return jt.(*javaThread)
+	}
+	jt := makeJavaThread(nil, obj, nil, "")
+	javaThreads.Store(obj, jt) // under the lock, so no other thread stores one
+	return jt
+}
+
+// currentExecThread returns the thread that's running the frames in the frame stack
+func currentExecThread(fs *list.List) *thread.ExecThread {
+	id := fs.Front().Value.(*frames.Frame).Thread
+	if t := thread.FindThread(id, &globals.GetGlobalRef().Threads); t != nil {
+		return t
+	}
+	return &MainThread
+}
+
+// threadName returns the name of the thread
+func threadName(t *thread.ExecThread) string {
+	threadMutex.Lock()
+	defer threadMutex.Unlock()
+	return t.Name
+}
+
+// java/lang/Thread.start() runs the thread's run() method on a new goroutine. A
+// thread can be started only once.
+func threadStart(params []interface{}) interface{} {
+	obj := params[0].(*object.Object)
+	fs := params[1].(*list.List)
+	exec := getJavaThread(obj).exec
+
+	threadMutex.Lock() // so a concurrent setDaemon() sees the thread as started
+	started := exec.Start(func() { runJavaThread(exec) })
+	threadMutex.Unlock()
+	if !started {
+		return newVMThrowable(fs, exceptions.IllegalThreadStateException, "")
+	}
+	return nil
+}
+
+// runJavaThread is the body of a started thread: it runs the run() method of the
+// Thread object on the thread's own frame stack. An exception that run() doesn't
+// catch ends the thread, but not the JVM.
+func runJavaThread(exec *thread.ExecThread) {
+	exec.Stack = frames.CreateFrameStack()
+	thread.AddThreadToTable(exec, &globals.GetGlobalRef().Threads)
+
+	f, err := newRunFrame(exec)
+	if err != nil {
+		if jt, ok := err.(*javaThrowable); ok {
+			reportUncaughtException(jt, threadName(exec))
+		}
+		return
+	}
+	_ = frames.PushFrame(exec.Stack, f)
+	_ = runThread(exec)
+}
+
+// newRunFrame creates the first frame of a started thread, which runs the run()
+// method of the Thread object: the thread's own run() if it's overridden, or else
+// Thread.run(), which runs the Runnable passed to the constructor.
+func newRunFrame(exec *thread.ExecThread) (*frames.Frame, error) {
+	mte, className, err := classloader.FetchInterfaceMethod(*exec.Object.Klass, "run", "()V")
+	if err != nil {
+		return nil, err
+	}
+
+	if mte.MType == 'J' {
+		m := mte.Meth.(classloader.JmEntry)
+		caller := frames.CreateFrame(1) // holds the object ref that the new frame takes
+		caller.Thread = exec.ID
+		caller.Trace = exec.Trace
+		push(caller, exec.Object)
+		return createAndInitNewFrame(className, "run", "()V", &m, true, caller)
+	}
+
+	gf := frames.CreateFrame(1)
+	gf.Thread = exec.ID
+	gf.Trace = exec.Trace
+	gf.ClName = "java/lang/Thread"
+	gf.MethName = "run()V"
+	gf.Ftype = 'G'
+	push(gf, exec.Object)
+	return gf, nil
+}
+
+// java/lang/Thread.run() runs the run() method of the Runnable passed to the
+// constructor. If there's none, it does nothing.
+func threadRun(params []interface{}) interface{} {
+	obj := params[0].(*object.Object)
+	fs := params[1].(*list.List)
+
+	target := getJavaThread(obj).target
+	if target == nil || target.Klass == nil {
+		return nil
+	}
+	mte, className, err := classloader.FetchInterfaceMethod(*target.Klass, "run", "()V")
+	if err != nil {
+		return errors.New("Thread.run: run() not found in class " + *target.Klass)
+	}
+	if _, err = runJavaMethod(fs, mte, className, "run", "()V", true, []interface{}{target}); err != nil {
+		return err
+	}
+	return nil
+}
+
+// java/lang/Thread.join() waits for the thread to terminate
+func threadJoin(params []interface{}) interface{} {
+	return joinThread(params[1].(*list.List), params[0].(*object.Object), 0)
+}
+
+// java/lang/Thread.join(long) waits at most the given number of milliseconds for the
+// thread to terminate. A timeout of 0 means to wait as long as necessary.
+func threadJoinMillis(params []interface{}) interface{} {
+	fs := params[3].(*list.List)
+	millis := params[1].(int64)
+	if millis < 0 {
+		return newVMThrowable(fs, exceptions.IllegalArgumentException, "timeout value is negative")
+	}
+	return joinThread(fs, params[0].(*object.Object), millisToDuration(millis))
+}
+
+// joinThread waits for the thread to terminate, unless it hasn't been started. If the
+// waiting thread is interrupted, it throws an InterruptedException.
+func joinThread(fs *list.List, obj *object.Object, timeout time.Duration) interface{} {
+	exec := getJavaThread(obj).exec
+	if exec.State() == thread.New {
+		return nil
+	}
+	current := currentExecThread(fs)
+	setWaitState(fs, current, waitingState(timeout), obj)
+	defer current.SetWaitState(thread.Runnable, nil)
+	if !current.Await(exec.Done(), timeout) {
+		return newVMThrowable(fs, exceptions.InterruptedException, "")
+	}
+	return nil
+}
+
+// waitingState returns the state of a thread that waits for no longer than timeout:
+// TimedWaiting, or Waiting if timeout is 0, which means to wait as long as necessary
+func waitingState(timeout time.Duration) int32 {
+	if timeout > 0 {
+		return thread.TimedWaiting
+	}
+	return thread.Waiting
+}
+
+// millisToDuration converts a timeout in milliseconds, which must not be negative, to
+// a time.Duration. Timeouts too long for a Duration, such as the Long.MAX_VALUE of
+// Thread.sleep(Long.MAX_VALUE), are the longest Duration, about 292 years.
+func millisToDuration(millis int64) time.Duration {
+	if millis > math.MaxInt64/int64(time.Millisecond) {
+		return math.MaxInt64
+	}
+	return time.Duration(millis) * time.Millisecond
+}
+
+// java/lang/Thread.sleep(long) suspends the current thread for the given number of
+// milliseconds. If the thread is interrupted, it throws an InterruptedException.
+func threadSleep(params []interface{}) interface{} {
+	fs := params[2].(*list.List)
+	millis := params[0].(int64)
+	if millis < 0 {
+		return newVMThrowable(fs, exceptions.IllegalArgumentException, "timeout value is negative")
+	}
+	exec := currentExecThread(fs)
+	setWaitState(fs, exec, thread.TimedWaiting, nil)
+	defer exec.SetWaitState(thread.Runnable, nil)
+	if !exec.Sleep(millisToDuration(millis)) {
+		return newVMThrowable(fs, exceptions.InterruptedException, "sleep interrupted")
+	}
+	return nil
+}
+
+// java/lang/Thread.interrupt() sets the thread's interrupt status. If the thread is
+// sleeping, waiting on an object, or joining another thread, it throws an
+// InterruptedException, which clears the status.
+func threadInterrupt(params []interface{}) interface{} {
+	getJavaThread(params[0].(*object.Object)).exec.Interrupt()
+	return nil
+}
+
+// java/lang/Thread.isInterrupted() returns the thread's interrupt status
+func threadIsInterrupted(params []interface{}) interface{} {
+	interrupted := getJavaThread(params[0].(*object.Object)).exec.IsInterrupted()
+	return types.ConvertGoBoolToJavaBool(interrupted)
+}
+
+// java/lang/Thread.interrupted() returns the current thread's interrupt status and
+// clears it
+func threadInterrupted(params []interface{}) interface{} {
+	return types.ConvertGoBoolToJavaBool(currentExecThread(params[0].(*list.List)).ClearInterrupt())
+}
+
+// java/lang/Thread.currentThread() returns the Thread object of the thread that's
+// running. The main thread's is created the first time it's asked for.
+func currentThread(params []interface{}) interface{} {
+	fs := params[0].(*list.List)
+	exec := currentExecThread(fs)
+	if exec.Object != nil {
+		return exec.Object
+	}
+
+	obj, err := instantiateClass("java/lang/Thread")
+	if err != nil {
+		return err
+	}
+	exec.Object = obj
+	storeJavaThread(obj, &javaThread{exec: exec})
+	return obj
+}
+
+// java/lang/Thread.isAlive() returns true if the thread has been started and has
+// not yet terminated
+func threadIsAlive(params []interface{}) interface{} {
+	alive := getJavaThread(params[0].(*object.Object)).exec.IsAlive()
+	return types.ConvertGoBoolToJavaBool(alive)
+}
+
+// java/lang/Thread.setDaemon(boolean) marks the thread as a daemon thread or not.
+// The JVM doesn't wait for daemon threads to finish. It can't be changed while
+// the thread is running.
+func threadSetDaemon(params []interface{}) interface{} {
+	exec := getJavaThread(params[0].(*object.Object)).exec
+	threadMutex.Lock()
+	defer threadMutex.Unlock()
+	if exec.IsAlive() {
+		return newVMThrowable(params[2].(*list.List), exceptions.IllegalThreadStateException, "")
+	}
+	exec.Daemon = params[1].(int64) != types.JavaBoolFalse
+	return nil
+}
+
+// java/lang/Thread.isDaemon() returns true if the thread is a daemon thread
+func threadIsDaemon(params []interface{}) interface{} {
+	exec := getJavaThread(params[0].(*object.Object)).exec
+	threadMutex.Lock()
+	defer threadMutex.Unlock()
+	return types.ConvertGoBoolToJavaBool(exec.Daemon)
+}
+
+// java/lang/Thread.getName() returns the thread's name
+func threadGetName(params []interface{}) interface{} {
+	name := threadName(getJavaThread(params[0].(*object.Object)).exec)
+	return object.CreateCompactStringFromGoString(&name)
+}
+
+// java/lang/Thread.setName(String) changes the thread's name
+func threadSetName(params []interface{}) interface{} {
+	name, ok := params[1].(*object.Object)
+	if !ok || name == nil {
+		return newVMThrowable(params[2].(*list.List), exceptions.NullPointerException, "'name' is null")
+	}
+	exec := getJavaThread(params[0].(*object.Object)).exec
+	threadMutex.Lock()
+	exec.Name = object.GetGoStringFromJavaString(name)
+	threadMutex.Unlock()
+	return nil
+}
@@ -0,0 +1,321 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"container/list"
+	"jacobin/classloader"
+	"jacobin/frames"
+	"jacobin/globals"
+	"jacobin/object"
+	"jacobin/thread"
+	"jacobin/types"
+	"math"
+	"testing"
+	"time"
+)
+
+// the Thread objects returned by Thread.currentThread() in test/Probe.hit()
+var probeThreads chan *object.Object
+
+// if not nil, test/Probe.hit() waits until it's closed
+var probeRelease chan struct{}
+
+// sets up java/lang/Thread, whose methods are the Go methods, and these classes:
+//
+//	class Probe { static native void hit(); } // sends Thread.currentThread() to probeThreads
+//	class Worker extends Thread { public void run() { Probe.hit(); } }
+//	class Task implements Runnable { public void run() { Probe.hit(); } }
+func setupThreadClasses() {
+	setupInterfaceClasses()
+	classloader.MTableLoadGoMethods(Load_Lang_Thread())
+	MainThread = thread.CreateThread()
+	MainThread.Name = "main"
+	MainThread.ID = thread.AddThreadToTable(&MainThread, &globals.GetGlobalRef().Threads)
+
+	probeThreads = make(chan *object.Object, 10)
+	probeRelease = nil
+	const public, static = 0x0001, 0x0008
+	addClass("java/lang/Thread", "java/lang/Object", nil, newCPBuilder(),
+		[]testField{{"eetop", "J", false}}, testMethod{"run", "()V", public, []byte{RETURN}})
+	addTestClass("test/Probe", "java/lang/Object", nil)
+	classloader.MTable["test/Probe.hit()V"] =
+		classloader.MTentry{MType: 'G', Meth: classloader.GmEntry{
+			ParamSlots:   0,
+			NeedsContext: true,
+			Fu: func(params []interface{}) interface{} {
+				probeThreads <- currentThread(params).(*object.Object)
+				if probeRelease != nil {
+					<-probeRelease
+				}
+				return nil
+			}}}
+
+	for _, class := range []struct{ name, super string }{
+		{"test/Worker", "java/lang/Thread"}, {"test/Task", "java/lang/Object"}} {
+		b := newCPBuilder()
+		hit := b.methodRef("test/Probe", "hit", "()V")
+		addClass(class.name, class.super, nil, b, nil,
+			testMethod{"run", "()V", public, []byte{INVOKESTATIC, byte(hit >> 8), byte(hit), RETURN}})
+	}
+}
+
+// returns a frame stack holding a frame of the main thread
+func newThreadTestFrameStack() *list.List {
+	f := newFrame(RETURN)
+	f.Thread = MainThread.ID
+	fs := frames.CreateFrameStack()
+	fs.PushFront(&f)
+	return fs
+}
+
+// returns the name of a java.lang.Thread
+func testThreadName(obj *object.Object) string {
+	return object.GetGoStringFromJavaString(threadGetName([]interface{}{obj}).(*object.Object))
+}
+
+// receives the Thread object sent by test/Probe.hit(), failing if it's not sent in time
+func awaitProbe(t *testing.T) *object.Object {
+	select {
+	case obj := <-probeThreads:
+		return obj
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the thread to run")
+		return nil
+	}
+}
+
+// A subclass of Thread runs its own run() on a new thread, in which currentThread()
+// returns the Thread object. join() waits for it to finish.
+func TestThreadStartAndJoin(t *testing.T) {
+	setupThreadClasses()
+	fs := newThreadTestFrameStack()
+
+	worker, err := instantiateClass("test/Worker")
+	if err != nil {
+		t.Fatalf("Unexpected error instantiating test/Worker: %s", err.Error())
+	}
+	threadInit([]interface{}{worker, fs})
+	if threadIsAlive([]interface{}{worker}) != types.JavaBoolFalse {
+		t.Error("Expected the thread not to be alive before start()")
+	}
+	if name := testThreadName(worker); len(name) < len("Thread-0") || name[:7] != "Thread-" {
+		t.Errorf("Expected the thread's default name to be Thread-N, got: %s", name)
+	}
+
+	if ret := threadStart([]interface{}{worker, fs}); ret != nil {
+		t.Fatalf("Unexpected error starting the thread: %v", ret)
+	}
+	if current := awaitProbe(t); current != worker {
+		t.Errorf("Expected currentThread() in run() to be the Worker, got: %v", current)
+	}
+	threadJoin([]interface{}{worker, fs})
+	if threadIsAlive([]interface{}{worker}) != types.JavaBoolFalse {
+		t.Error("Expected the thread not to be alive after join()")
+	}
+
+	ret := threadStart([]interface{}{worker, fs})
+	jt, ok := ret.(*javaThrowable)
+	if !ok || jt.className != "java/lang/IllegalThreadStateException" {
+		t.Errorf("Expected an IllegalThreadStateException starting the thread twice, got: %v", ret)
+	}
+}
+
+// A Thread created with a Runnable runs the Runnable's run()
+func TestThreadRunsRunnable(t *testing.T) {
+	setupThreadClasses()
+	fs := newThreadTestFrameStack()
+
+	task, _ := instantiateClass("test/Task")
+	th, _ := instantiateClass("java/lang/Thread")
+	name := "worker"
+	threadInitWithTargetAndName([]interface{}{th, task, object.CreateCompactStringFromGoString(&name), fs})
+	if testThreadName(th) != "worker" {
+		t.Errorf("Expected the thread's name to be worker, got: %s", testThreadName(th))
+	}
+
+	threadStart([]interface{}{th, fs})
+	if current := awaitProbe(t); current != th {
+		t.Errorf("Expected currentThread() in the Runnable to be the Thread, got: %v", current)
+	}
+	threadJoinMillis([]interface{}{th, int64(5000), int64(5000), fs})
+	if threadIsAlive([]interface{}{th}) != types.JavaBoolFalse {
+		t.Error("Expected the thread not to be alive after join()")
+	}
+}
+
+// The main thread's Thread object is named main
+func TestCurrentThreadOfMainThread(t *testing.T) {
+	setupThreadClasses()
+	fs := newThreadTestFrameStack()
+
+	main, ok := currentThread([]interface{}{fs}).(*object.Object)
+	if !ok {
+		t.Fatal("Expected currentThread() to return a Thread object")
+	}
+	if testThreadName(main) != "main" {
+		t.Errorf("Expected the main thread's name to be main, got: %s", testThreadName(main))
+	}
+	if currentThread([]interface{}{fs}) != main {
+		t.Error("Expected currentThread() to return the same object each time")
+	}
+}
+
+// setDaemon() can't be called on a running thread
+func TestThreadSetDaemon(t *testing.T) {
+	setupThreadClasses()
+	fs := newThreadTestFrameStack()
+
+	worker, _ := instantiateClass("test/Worker")
+	threadInit([]interface{}{worker, fs})
+	if ret := threadSetDaemon([]interface{}{worker, types.JavaBoolTrue, fs}); ret != nil {
+		t.Fatalf("Unexpected error from setDaemon(): %v", ret)
+	}
+	if threadIsDaemon([]interface{}{worker}) != types.JavaBoolTrue {
+		t.Error("Expected the thread to be a daemon thread")
+	}
+
+	probeRelease = make(chan struct{})
+	threadStart([]interface{}{worker, fs})
+	awaitProbe(t)
+	ret := threadSetDaemon([]interface{}{worker, types.JavaBoolFalse, fs})
+	close(probeRelease)
+	threadJoin([]interface{}{worker, fs})
+
+	jt, ok := ret.(*javaThrowable)
+	if !ok || jt.className != "java/lang/IllegalThreadStateException" {
+		t.Errorf("Expected an IllegalThreadStateException from setDaemon(), got: %v", ret)
+	}
+}
+
+// sleep() and join() reject negative timeouts
+func TestThreadNegativeTimeouts(t *testing.T) {
+	setupThreadClasses()
+	fs := newThreadTestFrameStack()
+
+	ret := threadSleep([]interface{}{int64(-1), int64(-1), fs})
+	if jt, ok := ret.(*javaThrowable); !ok || jt.className != "java/lang/IllegalArgumentException" {
+		t.Errorf("Expected an IllegalArgumentException from sleep(-1), got: %v", ret)
+	}
+
+	worker, _ := instantiateClass("test/Worker")
+	threadInit([]interface{}{worker, fs})
+	ret = threadJoinMillis([]interface{}{worker, int64(-1), int64(-1), fs})
+	if jt, ok := ret.(*javaThrowable); !ok || jt.msg != "timeout value is negative" {
+		t.Errorf("Expected an IllegalArgumentException from join(-1), got: %v", ret)
+	}
+
+	start := time.Now()
+	threadSleep([]interface{}{int64(20), int64(20), fs})
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("Expected sleep(20) to take at least 20ms, took: %s", elapsed)
+	}
+}
+
+// sleep(Long.MAX_VALUE) and join(Long.MAX_VALUE) wait as long as necessary, rather
+// than for a timeout that has overflowed
+func TestThreadLongestTimeouts(t *testing.T) {
+	setupThreadClasses()
+	fs := newThreadTestFrameStack()
+	main := currentThread([]interface{}{fs}).(*object.Object)
+
+	probeRelease = make(chan struct{})
+	worker, _ := instantiateClass("test/Worker")
+	threadInit([]interface{}{worker, fs})
+	threadStart([]interface{}{worker, fs})
+	awaitProbe(t)
+
+	for name, call := range map[string]func() interface{}{
+		"sleep(Long.MAX_VALUE)": func() interface{} {
+			return threadSleep([]interface{}{int64(math.MaxInt64), int64(math.MaxInt64), fs})
+		},
+		"join(Long.MAX_VALUE)": func() interface{} {
+			return threadJoinMillis([]interface{}{worker, int64(math.MaxInt64), int64(math.MaxInt64), fs})
+		},
+	} {
+		done := make(chan interface{}, 1)
+		go func() { done <- call() }()
+		select {
+		case ret := <-done:
+			t.Errorf("Expected %s to wait, but it returned: %v", name, ret)
+			continue
+		case <-time.After(50 * time.Millisecond):
+		}
+		threadInterrupt([]interface{}{main})
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for the interrupt to end %s", name)
+		}
+	}
+
+	close(probeRelease)
+	threadJoin([]interface{}{worker, fs})
+}
+
+// interrupt() sets the thread's interrupt status, which isInterrupted() reports and
+// interrupted() clears. A thread whose status is set throws an InterruptedException
+// as soon as it sleeps.
+func TestThreadInterruptStatus(t *testing.T) {
+	setupThreadClasses()
+	fs := newThreadTestFrameStack()
+	main := currentThread([]interface{}{fs}).(*object.Object)
+
+	threadInterrupt([]interface{}{main})
+	if threadIsInterrupted([]interface{}{main}) != types.JavaBoolTrue {
+		t.Error("Expected isInterrupted() to be true after interrupt()")
+	}
+	if threadInterrupted([]interface{}{fs}) != types.JavaBoolTrue ||
+		threadInterrupted([]interface{}{fs}) != types.JavaBoolFalse {
+		t.Error("Expected interrupted() to return true and then, as it clears the status, false")
+	}
+
+	threadInterrupt([]interface{}{main})
+	ret := threadSleep([]interface{}{int64(5000), int64(5000), fs})
+	if jt, ok := ret.(*javaThrowable); !ok || jt.className != "java/lang/InterruptedException" ||
+		jt.msg != "sleep interrupted" {
+		t.Errorf("Expected an InterruptedException from sleep(), got: %v", ret)
+	}
+	if MainThread.IsInterrupted() {
+		t.Error("Expected the InterruptedException to clear the interrupt status")
+	}
+}
+
+// Interrupting a thread that's sleeping or joining another thread wakes it with an
+// InterruptedException
+func TestInterruptSleepAndJoin(t *testing.T) {
+	setupThreadClasses()
+	fs := newThreadTestFrameStack()
+	main := currentThread([]interface{}{fs}).(*object.Object)
+
+	probeRelease = make(chan struct{})
+	worker, _ := instantiateClass("test/Worker")
+	threadInit([]interface{}{worker, fs})
+	threadStart([]interface{}{worker, fs})
+	awaitProbe(t)
+
+	for name, call := range map[string]func() interface{}{
+		"sleep()": func() interface{} { return threadSleep([]interface{}{int64(5000), int64(5000), fs}) },
+		"join()":  func() interface{} { return threadJoin([]interface{}{worker, fs}) },
+	} {
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			threadInterrupt([]interface{}{main})
+		}()
+		start := time.Now()
+		ret := call()
+		if jt, ok := ret.(*javaThrowable); !ok || jt.className != "java/lang/InterruptedException" {
+			t.Errorf("Expected an InterruptedException from %s, got: %v", name, ret)
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("Expected the interrupt to end %s early, took: %s", name, elapsed)
+		}
+	}
+
+	close(probeRelease)
+	threadJoin([]interface{}{worker, fs})
+}
@@ -0,0 +1,113 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"container/list"
+	"fmt"
+	"jacobin/classloader"
+	"jacobin/frames"
+	"jacobin/object"
+	"os"
+	"strings"
+)
+
+// Go-based implementations of the methods of java.lang.Throwable that rely on
+// native code in the JDK. Because they need access to the thread's frames, they
+// are here rather than in the classloader package with the other Go methods.
+
+func Load_Lang_Throwable() map[string]classloader.GMeth {
+	methods := make(map[string]classloader.GMeth)
+
+	// called by every Throwable constructor
+	methods["java/lang/Throwable.fillInStackTrace()Ljava/lang/Throwable;"] =
+		classloader.GMeth{
+			ParamSlots:   1, // [0] = the Throwable
+			GFunction:    fillInStackTrace,
+			NeedsContext: true,
+		}
+
+	methods["java/lang/Throwable.getStackTrace()[Ljava/lang/StackTraceElement;"] =
+		classloader.GMeth{
+			ParamSlots: 1, // [0] = the Throwable
+			GFunction:  getStackTrace,
+		}
+
+	methods["java/lang/Throwable.printStackTrace()V"] =
+		classloader.GMeth{
+			ParamSlots: 1, // [0] = the Throwable
+			GFunction:  printStackTrace,
+		}
+
+	return methods
+}
+
+// java/lang/Throwable.fillInStackTrace() records the thread's frames in the
+// exception's backtrace field. As in the JDK, the frames of this method and of
+// the exception's constructors are not part of the stack trace, so that the
+// trace starts at the method that created the exception.
+func fillInStackTrace(params []interface{}) interface{} {
+	this := params[0].(*object.Object)
+	fs := params[1].(*list.List)
+
+	elem := fs.Front().Next() // skip the frame of this method
+	for elem != nil {
+		f := elem.Value.(*frames.Frame)
+		if f.MethName != "<init>" || !isClassOrSubclassOf(*this.Klass, f.ClName) {
+			break
+		}
+		elem = elem.Next()
+	}
+
+	setObjectFieldByName(this, "backtrace", captureStackTrace(elem))
+	return this
+}
+
+// java/lang/Throwable.getStackTrace() returns the stack trace recorded by
+// fillInStackTrace() as an array of java/lang/StackTraceElement objects.
+func getStackTrace(params []interface{}) interface{} {
+	jt := newThrowableFromObject(params[0].(*object.Object))
+
+	arrObj := object.Make1DimArray(object.REF, int64(len(jt.trace)))
+	arr := arrObj.Fields[0].Fvalue.(*[]*object.Object)
+	for i, ste := range jt.trace {
+		steObj, err := newStackTraceElementObject(ste)
+		if err != nil {
+			return object.Null
+		}
+		(*arr)[i] = steObj
+	}
+	return arrObj
+}
+
+// newStackTraceElementObject creates the java/lang/StackTraceElement object for
+// an element of a stack trace. The fields are the ones set by its constructor.
+func newStackTraceElementObject(ste stackTraceElement) (*object.Object, error) {
+	obj, err := instantiateClass("java/lang/StackTraceElement")
+	if err != nil {
+		return nil, err
+	}
+
+	declaringClass := strings.ReplaceAll(ste.className, "/", ".")
+	setObjectFieldByName(obj, "declaringClass", object.CreateCompactStringFromGoString(&declaringClass))
+	setObjectFieldByName(obj, "methodName", object.CreateCompactStringFromGoString(&ste.methodName))
+	if ste.sourceFile != "" {
+		setObjectFieldByName(obj, "fileName", object.CreateCompactStringFromGoString(&ste.sourceFile))
+	} else {
+		setObjectFieldByName(obj, "fileName", object.Null)
+	}
+	setObjectFieldByName(obj, "lineNumber", int64(ste.line))
+	return obj, nil
+}
+
+// java/lang/Throwable.printStackTrace() prints the exception, its stack trace,
+// and its causes to stderr
+func printStackTrace(params []interface{}) interface{} {
+	jt := newThrowableFromObject(params[0].(*object.Object))
+	_, _ = fmt.Fprint(os.Stderr, stackTraceString(jt))
+	return nil
+}
@@ -0,0 +1,881 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"jacobin/classloader"
+	"jacobin/globals"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// The -javap option disassembles class files, much as the JDK's javap tool does, so
+// that they can be examined without a JDK. Each class file is parsed and format-checked
+// (but not loaded into the method area) and the declarations of the class and of its
+// members are shown. With -c, the bytecode of the methods is shown too, with the CP
+// entries that the instructions refer to resolved; -l adds the line number tables; and
+// -v shows the constant pool, the flags, and the other details of the class file.
+
+// javap disassembles the class files in gl.JavapClasses to w. It returns an error
+// if any of them can't be read or parsed, in which case it goes on to the next one.
+func javap(w io.Writer, gl *globals.Globals) error {
+	var failure error
+	for _, fileName := range gl.JavapClasses {
+		if err := javapClass(w, fileName, gl); err != nil {
+			failure = err
+		}
+	}
+	return failure
+}
+
+// disassembles one class file. The parser and the disassembler index into the class
+// file's bytes as its entries direct, so a truncated or corrupt class file can make
+// them panic, which is reported as an error, as the other failures are.
+func javapClass(w io.Writer, fileName string, gl *globals.Globals) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: error while reading %s: %v\n", fileName, r)
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	rawBytes, err := os.ReadFile(fileName)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: class not found: %s\n", fileName)
+		return err
+	}
+	data, err := classloader.ParseClassBytes(rawBytes)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: error while reading constant pool for %s: %s\n",
+			fileName, err.Error())
+		return err
+	}
+
+	d := disassembler{w: w, k: data, cp: &data.CP, gl: gl}
+	if gl.JavapVerbose {
+		d.classFileHeader(fileName, rawBytes)
+	}
+	d.class()
+	return nil
+}
+
+// disassembler writes the disassembly of a class
+type disassembler struct {
+	w  io.Writer
+	k  *classloader.ClData
+	cp *classloader.CPool
+	gl *globals.Globals
+}
+
+func (d *disassembler) printf(format string, args ...interface{}) {
+	_, _ = fmt.Fprintf(d.w, format, args...)
+}
+
+// classFileHeader shows the file's details, as javap -v does
+func (d *disassembler) classFileHeader(fileName string, rawBytes []byte) {
+	if path, err := filepath.Abs(fileName); err == nil {
+		fileName = path
+	}
+	d.printf("Classfile %s\n", fileName)
+	if info, err := os.Stat(fileName); err == nil {
+		d.printf("  Last modified %s; size %d bytes\n", info.ModTime().Format("Jan 2, 2006"), len(rawBytes))
+	}
+	d.printf("  SHA-256 checksum %x\n", sha256.Sum256(rawBytes))
+	if d.k.SourceFile != "" {
+		d.printf("  Compiled from \"%s\"\n", d.k.SourceFile)
+	}
+}
+
+// class shows the class's declaration and its members
+func (d *disassembler) class() {
+	if !d.gl.JavapVerbose && d.k.SourceFile != "" {
+		d.printf("Compiled from \"%s\"\n", d.k.SourceFile)
+	}
+	d.printf("%s", d.classDeclaration())
+
+	if d.gl.JavapVerbose {
+		d.printf("\n")
+		d.printf("  major version: %d\n", d.k.Version)
+		flags := d.classFlags()
+		d.printf("  %s\n", flagsLine(flags, classFlagNames))
+		d.printf("  %-38s// %s\n", fmt.Sprintf("this_class: #%d", d.classIndex(d.k.Name)), d.k.Name)
+		if d.k.Superclass != "" {
+			d.printf("  %-38s// %s\n", fmt.Sprintf("super_class: #%d", d.classIndex(d.k.Superclass)),
+				d.k.Superclass)
+		}
+		d.printf("  interfaces: %d, fields: %d, methods: %d, attributes: %d\n",
+			len(d.k.Interfaces), len(d.k.Fields), len(d.k.Methods), len(d.k.Attributes))
+		d.constantPool()
+		d.printf("{\n")
+	} else {
+		d.printf(" {\n")
+	}
+
+	first := true
+	for i := range d.k.Fields {
+		f := &d.k.Fields[i]
+		if f.AccessFlags&0x0002 != 0 && !d.gl.JavapPrivate && !d.gl.JavapVerbose { // ACC_PRIVATE
+			continue
+		}
+		if !first && (d.gl.JavapVerbose || d.gl.JavapCode || d.gl.JavapLines) {
+			d.printf("\n")
+		}
+		first = false
+		d.field(f)
+	}
+	for i := range d.k.Methods {
+		m := &d.k.Methods[i]
+		if m.AccessFlags&0x0002 != 0 && !d.gl.JavapPrivate && !d.gl.JavapVerbose {
+			continue
+		}
+		if !first && (d.gl.JavapVerbose || d.gl.JavapCode || d.gl.JavapLines) {
+			d.printf("\n")
+		}
+		first = false
+		d.method(m)
+	}
+	d.printf("}\n")
+
+	if d.gl.JavapVerbose {
+		d.classAttributes()
+	}
+}
+
+// classDeclaration returns the declaration of the class, e.g., public class Foo extends Bar
+func (d *disassembler) classDeclaration() string {
+	access := d.k.Access
+	var decl []string
+	if access.ClassIsPublic {
+		decl = append(decl, "public")
+	}
+	if access.ClassIsAbstract && !access.ClassIsInterface {
+		decl = append(decl, "abstract")
+	}
+	if access.ClassIsFinal {
+		decl = append(decl, "final")
+	}
+	if access.ClassIsInterface {
+		decl = append(decl, "interface")
+	} else {
+		decl = append(decl, "class")
+	}
+	decl = append(decl, javaName(d.k.Name))
+
+	var interfaces []string
+	for _, i := range d.k.Interfaces {
+		interfaces = append(interfaces, javaName(d.k.CP.Utf8Refs[i]))
+	}
+	if access.ClassIsInterface {
+		if len(interfaces) > 0 {
+			decl = append(decl, "extends", strings.Join(interfaces, ","))
+		}
+	} else {
+		if d.k.Superclass != "" && d.k.Superclass != "java/lang/Object" {
+			decl = append(decl, "extends", javaName(d.k.Superclass))
+		}
+		if len(interfaces) > 0 {
+			decl = append(decl, "implements", strings.Join(interfaces, ","))
+		}
+	}
+	return strings.Join(decl, " ")
+}
+
+// classFlags returns the class's access flags, as they are in the class file
+func (d *disassembler) classFlags() int {
+	access := d.k.Access
+	flags := 0
+	for _, flag := range []struct {
+		set   bool
+		value int
+	}{
+		{access.ClassIsPublic, 0x0001}, {access.ClassIsFinal, 0x0010}, {access.ClassIsSuper, 0x0020},
+		{access.ClassIsInterface, 0x0200}, {access.ClassIsAbstract, 0x0400},
+		{access.ClassIsSynthetic, 0x1000}, {access.ClassIsAnnotation, 0x2000},
+		{access.ClassIsEnum, 0x4000}, {access.ClassIsModule, 0x8000},
+	} {
+		if flag.set {
+			flags |= flag.value
+		}
+	}
+	return flags
+}
+
+// the names of the access flags, in the order javap shows them
+type flagName struct {
+	value int
+	name  string
+}
+
+var classFlagNames = []flagName{
+	{0x0001, "ACC_PUBLIC"}, {0x0010, "ACC_FINAL"}, {0x0020, "ACC_SUPER"}, {0x0200, "ACC_INTERFACE"},
+	{0x0400, "ACC_ABSTRACT"}, {0x1000, "ACC_SYNTHETIC"}, {0x2000, "ACC_ANNOTATION"}, {0x4000, "ACC_ENUM"},
+	{0x8000, "ACC_MODULE"},
+}
+
+var fieldFlagNames = []flagName{
+	{0x0001, "ACC_PUBLIC"}, {0x0002, "ACC_PRIVATE"}, {0x0004, "ACC_PROTECTED"}, {0x0008, "ACC_STATIC"},
+	{0x0010, "ACC_FINAL"}, {0x0040, "ACC_VOLATILE"}, {0x0080, "ACC_TRANSIENT"}, {0x1000, "ACC_SYNTHETIC"},
+	{0x4000, "ACC_ENUM"},
+}
+
+var methodFlagNames = []flagName{
+	{0x0001, "ACC_PUBLIC"}, {0x0002, "ACC_PRIVATE"}, {0x0004, "ACC_PROTECTED"}, {0x0008, "ACC_STATIC"},
+	{0x0010, "ACC_FINAL"}, {0x0020, "ACC_SYNCHRONIZED"}, {0x0040, "ACC_BRIDGE"}, {0x0080, "ACC_VARARGS"},
+	{0x0100, "ACC_NATIVE"}, {0x0400, "ACC_ABSTRACT"}, {0x0800, "ACC_STRICT"}, {0x1000, "ACC_SYNTHETIC"},
+}
+
+// flagsLine returns the access flags and their names, e.g., flags: (0x0009) ACC_PUBLIC, ACC_STATIC
+func flagsLine(flags int, names []flagName) string {
+	line := fmt.Sprintf("flags: (0x%04x)", flags)
+	var set []string
+	for _, flag := range names {
+		if flags&flag.value != 0 {
+			set = append(set, flag.name)
+		}
+	}
+	if len(set) > 0 {
+		line += " " + strings.Join(set, ", ")
+	}
+	return line
+}
+
+// modifiers returns the Java modifiers of a field or method, e.g., public static final
+func modifiers(flags int, isMethod bool) []string {
+	var mods []string
+	for _, mod := range []struct {
+		value    int
+		name     string
+		isMethod bool // is it a modifier of methods (or of fields)?
+	}{
+		{0x0001, "public", false}, {0x0004, "protected", false}, {0x0002, "private", false},
+		{0x0400, "abstract", true}, {0x0008, "static", false}, {0x0010, "final", false},
+		{0x0080, "transient", false}, {0x0040, "volatile", false}, // in methods, these are ACC_VARARGS and ACC_BRIDGE
+		{0x0020, "synchronized", true}, {0x0100, "native", true}, {0x0800, "strictfp", true},
+	} {
+		if flags&mod.value != 0 && (mod.isMethod == isMethod || (!mod.isMethod && mod.value&0x00C0 == 0)) {
+			mods = append(mods, mod.name)
+		}
+	}
+	return mods
+}
+
+// constantPool shows the entries of the CP, as javap -v does
+func (d *disassembler) constantPool() {
+	d.printf("Constant pool:\n")
+	width := len(strconv.Itoa(len(d.cp.CpIndex)-1)) + 4
+	for i := 1; i < len(d.cp.CpIndex); i++ {
+		kind, args := d.cpEntry(i)
+		if kind == "" { // the unused entry after a long or double
+			continue
+		}
+		line := fmt.Sprintf("%*s = %-18s %s", width, "#"+strconv.Itoa(i), kind, args)
+		if comment := d.cpComment(i); comment != "" && (d.cp.CpIndex[i].Type != classloader.UTF8 || d.isString(i)) {
+			line = fmt.Sprintf("%*s = %-18s %-14s // %s", width, "#"+strconv.Itoa(i), kind, args, comment)
+		}
+		d.printf("%s\n", strings.TrimRight(line, " "))
+	}
+}
+
+// isString reports whether the UTF8 entry at index was a String constant in the class file
+func (d *disassembler) isString(index int) bool {
+	for _, s := range d.cp.Strings {
+		if int(s) == index {
+			return true
+		}
+	}
+	return false
+}
+
+// utf8Index returns the index of the UTF8 entry that holds the string of a String constant
+func (d *disassembler) utf8Index(index int) int {
+	for i, entry := range d.cp.CpIndex {
+		if entry.Type == classloader.UTF8 && entry.Slot == d.cp.CpIndex[index].Slot && !d.isString(i) {
+			return i
+		}
+	}
+	return 0
+}
+
+// classIndex returns the index of the ClassRef entry for the class, or 0 if there is none
+func (d *disassembler) classIndex(className string) int {
+	for i, entry := range d.cp.CpIndex {
+		if entry.Type == classloader.ClassRef {
+			if name, _ := cpClassName(d.cp, i); name == className {
+				return i
+			}
+		}
+	}
+	return 0
+}
+
+// cpEntry returns the kind of the CP entry at index and its contents, as javap shows
+// them: the indexes of the entries it refers to or its value
+func (d *disassembler) cpEntry(index int) (string, string) {
+	cp := d.cp
+	entry := cp.CpIndex[index]
+	slot := int(entry.Slot)
+	switch entry.Type {
+	case classloader.UTF8:
+		if d.isString(index) {
+			return "String", "#" + strconv.Itoa(d.utf8Index(index))
+		}
+		return "Utf8", cp.Utf8Refs[slot]
+	case classloader.IntConst:
+		return "Integer", strconv.Itoa(int(cp.IntConsts[slot]))
+	case classloader.FloatConst:
+		return "Float", formatFloat(float64(cp.Floats[slot]), 32) + "f"
+	case classloader.LongConst:
+		return "Long", strconv.FormatInt(cp.LongConsts[slot], 10) + "l"
+	case classloader.DoubleConst:
+		return "Double", formatFloat(cp.Doubles[slot], 64) + "d"
+	case classloader.ClassRef:
+		return "Class", "#" + strconv.Itoa(int(cp.ClassRefs[slot]))
+	case classloader.FieldRef:
+		return "Fieldref", fmt.Sprintf("#%d.#%d", cp.FieldRefs[slot].ClassIndex, cp.FieldRefs[slot].NameAndType)
+	case classloader.MethodRef:
+		return "Methodref", fmt.Sprintf("#%d.#%d", cp.MethodRefs[slot].ClassIndex, cp.MethodRefs[slot].NameAndType)
+	case classloader.Interface:
+		return "InterfaceMethodref", fmt.Sprintf("#%d.#%d",
+			cp.InterfaceRefs[slot].ClassIndex, cp.InterfaceRefs[slot].NameAndType)
+	case classloader.NameAndType:
+		return "NameAndType", fmt.Sprintf("#%d:#%d", cp.NameAndTypes[slot].NameIndex, cp.NameAndTypes[slot].DescIndex)
+	case classloader.MethodHandle:
+		return "MethodHandle", fmt.Sprintf("%d:#%d", cp.MethodHandles[slot].RefKind, cp.MethodHandles[slot].RefIndex)
+	case classloader.MethodType:
+		return "MethodType", "#" + strconv.Itoa(int(cp.MethodTypes[slot]))
+	case classloader.Dynamic:
+		return "Dynamic", fmt.Sprintf("#%d:#%d", cp.Dynamics[slot].BootstrapIndex, cp.Dynamics[slot].NameAndType)
+	case classloader.InvokeDynamic:
+		return "InvokeDynamic", fmt.Sprintf("#%d:#%d",
+			cp.InvokeDynamics[slot].BootstrapIndex, cp.InvokeDynamics[slot].NameAndType)
+	case classloader.Module:
+		return "Module", d.k.Module
+	case classloader.Package:
+		return "Package", d.k.Pkg
+	}
+	return "", ""
+}
+
+// cpComment returns what the CP entry at index refers to, resolved to names and
+// descriptors, as javap shows it in comments
+func (d *disassembler) cpComment(index int) string {
+	if index < 1 || index >= len(d.cp.CpIndex) {
+		return "invalid constant pool index " + strconv.Itoa(index)
+	}
+	cp := d.cp
+	entry := cp.CpIndex[index]
+	slot := int(entry.Slot)
+	switch entry.Type {
+	case classloader.UTF8:
+		return cp.Utf8Refs[slot]
+	case classloader.ClassRef:
+		name, _ := cpClassName(cp, index)
+		if strings.HasPrefix(name, "[") {
+			return "\"" + name + "\""
+		}
+		return name
+	case classloader.FieldRef, classloader.MethodRef, classloader.Interface:
+		class, name, desc := d.memberRef(index)
+		return class + "." + quoteSpecialName(name) + ":" + desc
+	case classloader.NameAndType:
+		nat := cp.NameAndTypes[slot]
+		return quoteSpecialName(d.utf8(nat.NameIndex)) + ":" + d.utf8(nat.DescIndex)
+	case classloader.MethodHandle:
+		mh := cp.MethodHandles[slot]
+		return methodHandleKind(int(mh.RefKind)) + " " + d.cpComment(int(mh.RefIndex))
+	case classloader.MethodType:
+		return d.utf8(cp.MethodTypes[slot])
+	case classloader.Dynamic:
+		return fmt.Sprintf("#%d:%s", cp.Dynamics[slot].BootstrapIndex, d.cpComment(int(cp.Dynamics[slot].NameAndType)))
+	case classloader.InvokeDynamic:
+		return fmt.Sprintf("#%d:%s", cp.InvokeDynamics[slot].BootstrapIndex,
+			d.cpComment(int(cp.InvokeDynamics[slot].NameAndType)))
+	case classloader.Module:
+		return d.k.Module
+	case classloader.Package:
+		return d.k.Pkg
+	}
+	return "" // numeric constants are shown as they are
+}
+
+// constantComment returns the comment javap shows for a constant that an instruction
+// (LDC, e.g.) or a ConstantValue attribute refers to, e.g., String Hello or int 5
+func (d *disassembler) constantComment(index int) string {
+	if index < 1 || index >= len(d.cp.CpIndex) {
+		return "invalid constant pool index " + strconv.Itoa(index)
+	}
+	kind, value := d.cpEntry(index)
+	switch d.cp.CpIndex[index].Type {
+	case classloader.UTF8:
+		return "String " + d.cpComment(index)
+	case classloader.IntConst:
+		return "int " + value
+	case classloader.FloatConst, classloader.LongConst, classloader.DoubleConst:
+		return strings.ToLower(kind) + " " + value
+	case classloader.Dummy:
+		return "invalid constant pool index " + strconv.Itoa(index)
+	case classloader.ClassRef:
+		return "class " + d.cpComment(index)
+	}
+	return kind + " " + d.cpComment(index)
+}
+
+// memberRef returns the class, name, and descriptor of a field or method ref
+func (d *disassembler) memberRef(index int) (string, string, string) {
+	cp := d.cp
+	slot := cp.CpIndex[index].Slot
+	var classIndex, natIndex uint16
+	switch cp.CpIndex[index].Type {
+	case classloader.FieldRef:
+		classIndex, natIndex = cp.FieldRefs[slot].ClassIndex, cp.FieldRefs[slot].NameAndType
+	case classloader.MethodRef:
+		classIndex, natIndex = cp.MethodRefs[slot].ClassIndex, cp.MethodRefs[slot].NameAndType
+	default:
+		classIndex, natIndex = cp.InterfaceRefs[slot].ClassIndex, cp.InterfaceRefs[slot].NameAndType
+	}
+	class := d.cpComment(int(classIndex))
+	nat := cp.NameAndTypes[cp.CpIndex[natIndex].Slot]
+	return class, d.utf8(nat.NameIndex), d.utf8(nat.DescIndex)
+}
+
+func (d *disassembler) utf8(index uint16) string {
+	s, _ := cpUTF8(d.cp, int(index))
+	return s
+}
+
+// quoteSpecialName puts the names of constructors and static initializers in quotes
+func quoteSpecialName(name string) string {
+	if strings.HasPrefix(name, "<") {
+		return "\"" + name + "\""
+	}
+	return name
+}
+
+func methodHandleKind(kind int) string {
+	kinds := []string{"", "REF_getField", "REF_getStatic", "REF_putField", "REF_putStatic", "REF_invokeVirtual",
+		"REF_invokeStatic", "REF_invokeSpecial", "REF_newInvokeSpecial", "REF_invokeInterface"}
+	if kind < 1 || kind >= len(kinds) {
+		return "REF_unknown"
+	}
+	return kinds[kind]
+}
+
+// formatFloat formats a float constant as Java does, e.g., 1.0 rather than Go's 1 and
+// 1.0E8 rather than 1e+08
+func formatFloat(f float64, bitSize int) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	}
+	if abs := math.Abs(f); abs == 0 || (abs >= 1e-3 && abs < 1e7) {
+		s := strconv.FormatFloat(f, 'f', -1, bitSize)
+		if !strings.Contains(s, ".") {
+			s += ".0"
+		}
+		return s
+	}
+	s := strconv.FormatFloat(f, 'E', -1, bitSize)
+	mantissa, exponent, _ := strings.Cut(s, "E")
+	if !strings.Contains(mantissa, ".") {
+		mantissa += ".0"
+	}
+	exp, _ := strconv.Atoi(exponent)
+	return mantissa + "E" + strconv.Itoa(exp)
+}
+
+// javaName converts a class name to the form used in Java source, e.g., java.lang.String
+func javaName(className string) string {
+	return strings.ReplaceAll(className, "/", ".")
+}
+
+// javaType converts the field descriptor at the start of desc to the type as it's
+// written in Java source, e.g., [Ljava/lang/String; is java.lang.String[]. It also
+// returns the length of the descriptor.
+func javaType(desc string) (string, int) {
+	dimensions := 0
+	for dimensions < len(desc) && desc[dimensions] == '[' {
+		dimensions++
+	}
+	if dimensions == len(desc) {
+		return desc, len(desc)
+	}
+
+	length := dimensions + 1
+	var name string
+	switch desc[dimensions] {
+	case 'B':
+		name = "byte"
+	case 'C':
+		name = "char"
+	case 'D':
+		name = "double"
+	case 'F':
+		name = "float"
+	case 'I':
+		name = "int"
+	case 'J':
+		name = "long"
+	case 'S':
+		name = "short"
+	case 'Z':
+		name = "boolean"
+	case 'V':
+		name = "void"
+	case 'L':
+		end := strings.IndexByte(desc[dimensions:], ';')
+		if end < 0 {
+			return desc, len(desc)
+		}
+		name = javaName(desc[dimensions+1 : dimensions+end])
+		length = dimensions + end + 1
+	default:
+		return desc, len(desc)
+	}
+	return name + strings.Repeat("[]", dimensions), length
+}
+
+// field shows the declaration of a field and, with -v, its details
+func (d *disassembler) field(f *classloader.Field) {
+	typeName, _ := javaType(d.k.CP.Utf8Refs[f.Desc])
+	decl := append(modifiers(f.AccessFlags, false), typeName, d.k.CP.Utf8Refs[f.Name])
+	d.printf("  %s;\n", strings.Join(decl, " "))
+	if !d.gl.JavapVerbose {
+		return
+	}
+
+	d.printf("    descriptor: %s\n", d.k.CP.Utf8Refs[f.Desc])
+	d.printf("    %s\n", flagsLine(f.AccessFlags, fieldFlagNames))
+	for _, attr := range f.Attributes {
+		name := d.k.CP.Utf8Refs[attr.AttrName]
+		if name == "ConstantValue" && len(attr.AttrContent) == 2 {
+			d.printf("    ConstantValue: %s\n", d.constantComment(int(binary.BigEndian.Uint16(attr.AttrContent))))
+		} else {
+			d.printf("    %s: length = 0x%X\n", name, len(attr.AttrContent))
+		}
+	}
+}
+
+// methodDeclaration returns the declaration of a method, e.g., public static void main(java.lang.String[])
+func (d *disassembler) methodDeclaration(m *classloader.Method) string {
+	name := d.k.CP.Utf8Refs[m.Name]
+	desc := d.k.CP.Utf8Refs[m.Desc]
+	if name == "<clinit>" {
+		return "static {}"
+	}
+
+	var params []string
+	i := 1
+	for i < len(desc) && desc[i] != ')' {
+		param, length := javaType(desc[i:])
+		params = append(params, param)
+		i += length
+	}
+	if m.AccessFlags&0x0080 != 0 && len(params) > 0 { // ACC_VARARGS
+		last := params[len(params)-1]
+		params[len(params)-1] = strings.TrimSuffix(last, "[]") + "..."
+	}
+
+	decl := modifiers(m.AccessFlags, true)
+	if d.k.Access.ClassIsInterface && m.AccessFlags&(0x0400|0x0008|0x0002) == 0 {
+		decl = append(decl, "default") // neither abstract, static, nor private
+	}
+	if name == "<init>" {
+		decl = append(decl, javaName(d.k.Name))
+	} else {
+		returnType := ""
+		if i < len(desc) {
+			returnType, _ = javaType(desc[i+1:])
+		}
+		decl = append(decl, returnType, name)
+	}
+	s := strings.Join(decl, " ") + "(" + strings.Join(params, ", ") + ")"
+
+	if len(m.Exceptions) > 0 {
+		var thrown []string
+		for _, e := range m.Exceptions {
+			thrown = append(thrown, javaName(d.k.CP.Utf8Refs[e]))
+		}
+		s += " throws " + strings.Join(thrown, ", ")
+	}
+	return s
+}
+
+// method shows the declaration of a method and, with -c, -l, or -v, its code
+func (d *disassembler) method(m *classloader.Method) {
+	d.printf("  %s;\n", d.methodDeclaration(m))
+	if d.gl.JavapVerbose {
+		d.printf("    descriptor: %s\n", d.k.CP.Utf8Refs[m.Desc])
+		d.printf("    %s\n", flagsLine(m.AccessFlags, methodFlagNames))
+	}
+
+	indent := "    "
+	hasCode := m.AccessFlags&(0x0100|0x0400) == 0 // not ACC_NATIVE or ACC_ABSTRACT
+	if hasCode && (d.gl.JavapCode || d.gl.JavapVerbose) {
+		d.printf("    Code:\n")
+		if d.gl.JavapVerbose {
+			indent = "      "
+			params, _, _ := methodDescriptorTypes(d.k.CP.Utf8Refs[m.Desc])
+			argsSize := len(expandTypes(params))
+			if m.AccessFlags&0x0008 == 0 { // not ACC_STATIC, so this is an argument too
+				argsSize++
+			}
+			d.printf("%sstack=%d, locals=%d, args_size=%d\n", indent, m.CodeAttr.MaxStack,
+				m.CodeAttr.MaxLocals, argsSize)
+		}
+		d.code(m.CodeAttr.Code, indent)
+		d.exceptionTable(m.CodeAttr.Exceptions, indent)
+	}
+	if hasCode && (d.gl.JavapLines || d.gl.JavapVerbose) && len(m.CodeAttr.LineTable) > 0 {
+		d.printf("%sLineNumberTable:\n", indent)
+		for _, line := range m.CodeAttr.LineTable {
+			d.printf("%s  line %d: %d\n", indent, line.SourceLine, line.BytecodePos)
+		}
+	}
+	if !d.gl.JavapVerbose {
+		return
+	}
+
+	for _, attr := range m.CodeAttr.Attributes {
+		name := d.k.CP.Utf8Refs[attr.AttrName]
+		switch name {
+		case "LineNumberTable":
+		case "StackMapTable":
+			d.stackMapTable(m, attr.AttrContent, indent)
+		default:
+			d.printf("%s%s: length = 0x%X\n", indent, name, len(attr.AttrContent))
+		}
+	}
+	for _, attr := range m.Attributes {
+		name := d.k.CP.Utf8Refs[attr.AttrName]
+		switch name {
+		case "Code":
+		case "Exceptions":
+			d.printf("    Exceptions:\n")
+			for _, e := range m.Exceptions {
+				d.printf("      throws %s\n", javaName(d.k.CP.Utf8Refs[e]))
+			}
+		default:
+			d.printf("    %s: length = 0x%X\n", name, len(attr.AttrContent))
+		}
+	}
+}
+
+// code shows the instructions of a method, whose operands are resolved
+func (d *disassembler) code(code []byte, indent string) {
+	for pc := 0; pc < len(code); {
+		length := instructionLength(code, pc)
+		if length == 0 {
+			d.printf("%s%4d: invalid opcode 0x%02X\n", indent, pc, code[pc])
+			return
+		}
+		d.printf("%s%4d: %s\n", indent, pc, d.instruction(code, pc, indent))
+		pc += length
+	}
+}
+
+// instruction returns the mnemonic and operands of the instruction at code[pc]
+func (d *disassembler) instruction(code []byte, pc int, indent string) string {
+	opcode := code[pc]
+	mnemonic := strings.ToLower(BytecodeNames[opcode])
+	u1 := func(offset int) int { return int(code[pc+offset]) }
+	u2 := func(offset int) int { return int(binary.BigEndian.Uint16(code[pc+offset:])) }
+	s4 := func(offset int) int { return int(int32(binary.BigEndian.Uint32(code[pc+offset:]))) }
+	withOperands := func(operands string) string {
+		return fmt.Sprintf("%-13s %s", mnemonic, operands)
+	}
+	withComment := func(operands, comment string) string {
+		return fmt.Sprintf("%-13s %-18s // %s", mnemonic, operands, comment)
+	}
+
+	switch {
+	case opcode == BIPUSH:
+		return withOperands(strconv.Itoa(int(int8(code[pc+1]))))
+	case opcode == SIPUSH:
+		return withOperands(strconv.Itoa(int(int16(u2(1)))))
+	case opcode == LDC:
+		return withComment("#"+strconv.Itoa(u1(1)), d.constantComment(u1(1)))
+	case opcode == LDC_W || opcode == LDC2_W:
+		return withComment("#"+strconv.Itoa(u2(1)), d.constantComment(u2(1)))
+	case opcode >= ILOAD && opcode <= ALOAD, opcode >= ISTORE && opcode <= ASTORE, opcode == RET:
+		return withOperands(strconv.Itoa(u1(1)))
+	case opcode == IINC:
+		return withOperands(fmt.Sprintf("%d, %d", u1(1), int8(code[pc+2])))
+	case opcode >= IFEQ && opcode <= JSR, opcode == IFNULL || opcode == IFNONNULL:
+		return withOperands(strconv.Itoa(pc + int(int16(u2(1)))))
+	case opcode == GOTO_W || opcode == JSR_W:
+		return withOperands(strconv.Itoa(pc + s4(1)))
+	case opcode == TABLESWITCH || opcode == LOOKUPSWITCH:
+		return d.switchInstruction(code, pc, indent)
+	case opcode >= GETSTATIC && opcode <= PUTFIELD:
+		return withComment("#"+strconv.Itoa(u2(1)), "Field "+d.memberComment(u2(1)))
+	case opcode >= INVOKEVIRTUAL && opcode <= INVOKESTATIC:
+		kind := "Method "
+		if d.cp.CpIndex[u2(1)].Type == classloader.Interface {
+			kind = "InterfaceMethod "
+		}
+		return withComment("#"+strconv.Itoa(u2(1)), kind+d.memberComment(u2(1)))
+	case opcode == INVOKEINTERFACE:
+		return withComment(fmt.Sprintf("#%d,  %d", u2(1), u1(3)), "InterfaceMethod "+d.memberComment(u2(1)))
+	case opcode == INVOKEDYNAMIC:
+		return withComment(fmt.Sprintf("#%d,  0", u2(1)), "InvokeDynamic "+d.cpComment(u2(1)))
+	case opcode == NEW || opcode == ANEWARRAY || opcode == CHECKCAST || opcode == INSTANCEOF:
+		return withComment("#"+strconv.Itoa(u2(1)), "class "+d.cpComment(u2(1)))
+	case opcode == NEWARRAY:
+		types := []string{"boolean", "char", "float", "double", "byte", "short", "int", "long"}
+		if u1(1) >= 4 && u1(1) <= 11 {
+			return withOperands(types[u1(1)-4])
+		}
+		return withOperands("invalid type " + strconv.Itoa(u1(1)))
+	case opcode == MULTIANEWARRAY:
+		return withComment(fmt.Sprintf("#%d,  %d", u2(1), u1(3)), "class "+d.cpComment(u2(1)))
+	case opcode == WIDE: // shown as the modified instruction, e.g., iload_w
+		mnemonic = strings.ToLower(BytecodeNames[code[pc+1]]) + "_w"
+		if code[pc+1] == IINC {
+			return withOperands(fmt.Sprintf("%d, %d", u2(2), int16(u2(4))))
+		}
+		return withOperands(strconv.Itoa(u2(2)))
+	}
+	return mnemonic
+}
+
+// memberComment returns the class, name, and descriptor of a field or method, as
+// javap shows them: the class is omitted if it's the one being disassembled
+func (d *disassembler) memberComment(index int) string {
+	if index < 1 || index >= len(d.cp.CpIndex) {
+		return "invalid constant pool index " + strconv.Itoa(index)
+	}
+	switch d.cp.CpIndex[index].Type {
+	case classloader.FieldRef, classloader.MethodRef, classloader.Interface:
+	default:
+		return d.cpComment(index)
+	}
+	class, name, desc := d.memberRef(index)
+	if class == d.k.Name {
+		return quoteSpecialName(name) + ":" + desc
+	}
+	return class + "." + quoteSpecialName(name) + ":" + desc
+}
+
+// switchInstruction returns TABLESWITCH or LOOKUPSWITCH with its targets, one per line
+func (d *disassembler) switchInstruction(code []byte, pc int, indent string) string {
+	s4 := func(offset int) int { return int(int32(binary.BigEndian.Uint32(code[offset:]))) }
+	base := pc + 1 + (4-(pc+1)%4)%4
+	var sb strings.Builder
+	if code[pc] == TABLESWITCH {
+		low, high := s4(base+4), s4(base+8)
+		sb.WriteString(fmt.Sprintf("%-13s { // %d to %d\n", "tableswitch", low, high))
+		for i := 0; i <= high-low; i++ {
+			sb.WriteString(fmt.Sprintf("%s%18d: %d\n", indent, low+i, pc+s4(base+12+4*i)))
+		}
+	} else {
+		pairs := s4(base + 4)
+		sb.WriteString(fmt.Sprintf("%-13s { // %d\n", "lookupswitch", pairs))
+		for i := 0; i < pairs; i++ {
+			sb.WriteString(fmt.Sprintf("%s%18d: %d\n", indent, s4(base+8+8*i), pc+s4(base+12+8*i)))
+		}
+	}
+	sb.WriteString(fmt.Sprintf("%s%18s: %d\n", indent, "default", pc+s4(base)))
+	sb.WriteString(indent + "   }")
+	return sb.String()
+}
+
+// exceptionTable shows the exception handlers of a method
+func (d *disassembler) exceptionTable(handlers []classloader.CodeException, indent string) {
+	if len(handlers) == 0 {
+		return
+	}
+	d.printf("%sException table:\n", indent)
+	d.printf("%s   from    to  target type\n", indent)
+	for _, h := range handlers {
+		catchType := "any"
+		if h.CatchType != 0 {
+			catchType = "Class " + d.cpComment(int(h.CatchType))
+		}
+		d.printf("%s%7d %5d %5d   %s\n", indent, h.StartPc, h.EndPc, h.HandlerPc, catchType)
+	}
+}
+
+// stackMapTable shows the frames of a method's StackMapTable, with the types of
+// the locals and the operand stack at each of them
+func (d *disassembler) stackMapTable(m *classloader.Method, content []byte, indent string) {
+	var locals []vType
+	if m.AccessFlags&0x0008 == 0 { // not ACC_STATIC
+		this := refType(d.k.Name)
+		if d.k.CP.Utf8Refs[m.Name] == "<init>" && d.k.Name != "java/lang/Object" {
+			this = vType{tag: vtUninitThis}
+		}
+		locals = append(locals, this)
+	}
+	params, _, _ := methodDescriptorTypes(d.k.CP.Utf8Refs[m.Desc])
+	locals = append(locals, params...)
+
+	frames, err := parseStackMapTable(content, d.cp, locals)
+	if err != nil {
+		d.printf("%sStackMapTable: invalid: %s\n", indent, err.Error())
+		return
+	}
+	d.printf("%sStackMapTable: number_of_entries = %d\n", indent, len(frames))
+	for _, frame := range frames {
+		d.printf("%s  offset = %d\n", indent, frame.offset)
+		d.printf("%s    locals = [%s]\n", indent, typeList(frame.locals))
+		d.printf("%s    stack = [%s]\n", indent, typeList(frame.stack))
+	}
+}
+
+// typeList returns the verification types of a frame as javap shows them, e.g., [ int, class java/lang/String ]
+func typeList(types []vType) string {
+	if len(types) == 0 {
+		return ""
+	}
+	var names []string
+	for _, t := range types {
+		switch {
+		case t.tag == vtRef && strings.HasPrefix(t.class, "["):
+			names = append(names, "class \""+t.class+"\"")
+		case t.tag == vtRef:
+			names = append(names, "class "+t.class)
+		case t.tag == vtInt:
+			names = append(names, "int")
+		default:
+			names = append(names, t.String())
+		}
+	}
+	return " " + strings.Join(names, ", ") + " "
+}
+
+// classAttributes shows the attributes of the class, as javap -v does
+func (d *disassembler) classAttributes() {
+	for _, attr := range d.k.Attributes {
+		name := d.k.CP.Utf8Refs[attr.AttrName]
+		switch name {
+		case "SourceFile":
+			d.printf("SourceFile: \"%s\"\n", d.k.SourceFile)
+		case "BootstrapMethods":
+			d.printf("BootstrapMethods:\n")
+			for i, bsm := range d.k.Bootstraps {
+				d.printf("  %d: #%d %s\n", i, bsm.MethodRef, d.cpComment(int(bsm.MethodRef)))
+				d.printf("    Method arguments:\n")
+				for _, arg := range bsm.Args {
+					d.printf("      #%d %s\n", arg, d.cpComment(int(arg)))
+				}
+			}
+		default:
+			d.printf("%s: length = 0x%X\n", name, len(attr.AttrContent))
+		}
+	}
+}
@@ -0,0 +1,168 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"bytes"
+	"jacobin/classloader"
+	"jacobin/globals"
+	"jacobin/log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runs javap on a class file in testdata with the given options
+func javapTestOutput(t *testing.T, classFile string, code, verbose bool) string {
+	gl := globals.InitGlobals("test")
+	log.Init()
+	gl.JavapClasses = []string{"../../testdata/" + classFile}
+	gl.JavapCode = code
+	gl.JavapVerbose = verbose
+
+	var out bytes.Buffer
+	if err := javap(&out, &gl); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	return out.String()
+}
+
+func expectLines(t *testing.T, output string, lines ...string) {
+	for _, line := range lines {
+		if !strings.Contains(output, line+"\n") {
+			t.Errorf("Expected the line %q in:\n%s", line, output)
+		}
+	}
+}
+
+func TestJavapDeclarations(t *testing.T) {
+	output := javapTestOutput(t, "Hello2.class", false, false)
+	expectLines(t, output,
+		`Compiled from "Hello2.java"`,
+		"class Hello2 {",
+		"  Hello2();",
+		"  public static void main(java.lang.String[]);",
+		"  static int addTwo(int, int);",
+		"}")
+	if strings.Contains(output, "Code:") {
+		t.Errorf("Expected no code without -c, got:\n%s", output)
+	}
+}
+
+func TestJavapCode(t *testing.T) {
+	output := javapTestOutput(t, "Hello2.class", true, false)
+	expectLines(t, output,
+		`       1: invokespecial #8                 // Method java/lang/Object."<init>":()V`,
+		"       2: goto          23",
+		"       9: invokestatic  #16                // Method addTwo:(II)I",
+		"      13: getstatic     #20                // Field java/lang/System.out:Ljava/io/PrintStream;",
+		"      20: iinc          2, 1",
+		"      24: bipush        10",
+		"      26: if_icmplt     5")
+}
+
+func TestJavapVerbose(t *testing.T) {
+	output := javapTestOutput(t, "ListTest.class", false, true)
+	expectLines(t, output,
+		"  major version: 55",
+		"  flags: (0x0020) ACC_SUPER",
+		"  this_class: #14                       // ListTest",
+		"Constant pool:",
+		`    #1 = Methodref          #15.#27        // java/lang/Object."<init>":()V`,
+		"    #4 = String             #29            // Hello!",
+		"   #29 = Utf8               Hello!",
+		"    flags: (0x0009) ACC_PUBLIC, ACC_STATIC",
+		"      stack=2, locals=4, args_size=1",
+		"         9: ldc           #4                 // String Hello!",
+		"        11: invokeinterface #5,  2             // InterfaceMethod java/util/List.add:(Ljava/lang/Object;)Z",
+		"      LineNumberTable:",
+		"        line 7: 0",
+		"      StackMapTable: number_of_entries = 2",
+		`          locals = [ class "[Ljava/lang/String;", class java/util/List ]`,
+		`SourceFile: "ListTest.java"`)
+}
+
+// switches and exception handlers, which javac's output in testdata doesn't have
+func TestJavapSwitchesAndHandlers(t *testing.T) {
+	b := newCPBuilder()
+	exc := b.class("java/lang/ArithmeticException")
+	k := classloader.ClData{Name: "test/Switch", Superclass: "java/lang/Object", CP: b.cp}
+	gl := globals.InitGlobals("test")
+	d := disassembler{k: &k, cp: &k.CP, gl: &gl}
+	var out bytes.Buffer
+	d.w = &out
+
+	d.code([]byte{
+		ILOAD_0,
+		TABLESWITCH, 0, 0, // padding to a multiple of 4
+		0x00, 0x00, 0x00, 0x17, // default
+		0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x02, // 1 to 2
+		0x00, 0x00, 0x00, 0x17, 0x00, 0x00, 0x00, 0x18,
+		ICONST_0, IRETURN,
+		WIDE, IINC, 0x01, 0x00, 0xFF, 0xFE}, "    ")
+	d.exceptionTable([]classloader.CodeException{
+		{StartPc: 0, EndPc: 24, HandlerPc: 24, CatchType: exc},
+		{StartPc: 0, EndPc: 24, HandlerPc: 26, CatchType: 0}}, "    ")
+
+	expectLines(t, out.String(),
+		"       1: tableswitch   { // 1 to 2",
+		"                     1: 24",
+		"                     2: 25",
+		"               default: 24",
+		"       }",
+		"      26: iinc_w        256, -2",
+		"    Exception table:",
+		"       from    to  target type",
+		"          0    24    24   Class java/lang/ArithmeticException",
+		"          0    24    26   any")
+}
+
+func TestJavapFloatFormat(t *testing.T) {
+	tests := []struct {
+		value    float64
+		expected string
+	}{
+		{1, "1.0"}, {0.5, "0.5"}, {100000000, "1.0E8"}, {1.5e-5, "1.5E-5"}, {-2.25e20, "-2.25E20"},
+	}
+	for _, test := range tests {
+		if s := formatFloat(test.value, 64); s != test.expected {
+			t.Errorf("%g: expected %s, got: %s", test.value, test.expected, s)
+		}
+	}
+}
+
+func TestJavapMissingClassFile(t *testing.T) {
+	gl := globals.InitGlobals("test")
+	log.Init()
+	gl.JavapClasses = []string{"../../testdata/NoSuchClass.class"}
+	if err := javap(&bytes.Buffer{}, &gl); err == nil {
+		t.Error("Expected an error for a missing class file")
+	}
+}
+
+// a truncated class file is reported as an error, as a missing one is, rather than
+// crashing the parser
+func TestJavapTruncatedClassFile(t *testing.T) {
+	gl := globals.InitGlobals("test")
+	log.Init()
+	rawBytes, err := os.ReadFile("../../testdata/Hello2.class")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	fileName := filepath.Join(t.TempDir(), "Hello2.class")
+	if err = os.WriteFile(fileName, rawBytes[:40], 0644); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	gl.JavapClasses = []string{fileName, "../../testdata/Hello2.class"}
+	var out bytes.Buffer
+	if err := javap(&out, &gl); err == nil {
+		t.Error("Expected an error for a truncated class file")
+	}
+	expectLines(t, out.String(), "class Hello2 {")
+}
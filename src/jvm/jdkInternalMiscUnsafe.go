@@ -0,0 +1,678 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"container/list"
+	"fmt"
+	"jacobin/classloader"
+	"jacobin/exceptions"
+	"jacobin/object"
+	"jacobin/types"
+	"math"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// Go-based implementations of the methods of jdk.internal.misc.Unsafe with which the
+// JDK's concurrency classes (AtomicInteger, ConcurrentHashMap, and others) read and
+// update fields and array elements atomically: compare-and-set, compare-and-exchange,
+// get-and-add, get-and-set, and volatile gets and puts, on ints, longs, and references.
+//
+// Unsafe addresses a field by the offset objectFieldOffset() returns for it, which
+// in Jacobin depends on how instantiateClass() lays out the class's objects:
+//   - if the fields are in Object.Fields, the offset is the index of the field there
+//   - if they're in Object.FieldTable, it's tableFieldOffset plus a number for the
+//     field's name (see tableFieldNames)
+//
+// An array element's offset is its index: arrayBaseOffset() is 0 and arrayIndexScale() is 1.
+//
+// Every access holds the object's field lock (see object/fieldLock.go), which GETFIELD
+// and PUTFIELD also hold. So each operation is atomic, and all of them are ordered as
+// volatile accesses are. The weaker orderings (plain, opaque, acquire, and release)
+// get this stronger guarantee as well. The floats and doubles that the JDK passes to
+// these methods as their raw bits are converted to and from the float64 that Jacobin
+// stores.
+
+// the offset of the first field that's accessed by name in an object's FieldTable.
+// It's beyond any index into Object.Fields.
+const tableFieldOffset = int64(1) << 32
+
+// tableFieldNames numbers the names of the fields accessed in a FieldTable. The
+// offset of a field is tableFieldOffset plus the index of its name in names.
+var tableFieldNames struct {
+	sync.Mutex
+	names   []string
+	offsets map[string]int64
+}
+
+// the kinds of values Unsafe reads and writes
+const (
+	unsafeInt  = iota // an int, or the raw bits of a float
+	unsafeLong        // a long, or the raw bits of a double
+	unsafeRef         // an object reference
+)
+
+var unsafeClassName = "jdk/internal/misc/Unsafe"
+
+// theUnsafe is the instance of Unsafe that Unsafe.getUnsafe() returns
+var theUnsafe struct {
+	sync.Once
+	obj *object.Object
+}
+
+func Load_Jdk_Internal_Misc_Unsafe() map[string]classloader.GMeth {
+	methods := make(map[string]classloader.GMeth)
+	const class = "jdk/internal/misc/Unsafe."
+
+	methods[class+"registerNatives()V"] =
+		classloader.GMeth{
+			ParamSlots: 0,
+			GFunction:  unsafeRegisterNatives,
+		}
+
+	methods[class+"getUnsafe()Ljdk/internal/misc/Unsafe;"] =
+		classloader.GMeth{
+			ParamSlots: 0,
+			GFunction:  unsafeGetUnsafe,
+		}
+
+	// the parameters of the instance methods below start with [0] = the Unsafe,
+	// [1] = the object, and [2] = the offset (a long)
+	for _, name := range []string{"objectFieldOffset", "objectFieldOffset1"} {
+		methods[class+name+"(Ljava/lang/Class;Ljava/lang/String;)J"] =
+			classloader.GMeth{
+				ParamSlots:   3, // [1] = the class, [2] = the field name
+				GFunction:    unsafeObjectFieldOffset,
+				NeedsContext: true,
+			}
+	}
+	for _, name := range []string{"arrayBaseOffset", "arrayBaseOffset0"} {
+		methods[class+name+"(Ljava/lang/Class;)I"] =
+			classloader.GMeth{ParamSlots: 2, GFunction: unsafeArrayBaseOffset}
+	}
+	for _, name := range []string{"arrayIndexScale", "arrayIndexScale0"} {
+		methods[class+name+"(Ljava/lang/Class;)I"] =
+			classloader.GMeth{ParamSlots: 2, GFunction: unsafeArrayIndexScale}
+	}
+
+	// the weak compare-and-sets, which may fail spuriously, never do here
+	for _, name := range []string{"compareAndSet", "weakCompareAndSet", "weakCompareAndSetPlain",
+		"weakCompareAndSetAcquire", "weakCompareAndSetRelease"} {
+		methods[class+name+"Int(Ljava/lang/Object;JII)Z"] =
+			classloader.GMeth{ParamSlots: 6, GFunction: unsafeCompareAndSetInt, NeedsContext: true}
+		methods[class+name+"Long(Ljava/lang/Object;JJJ)Z"] =
+			classloader.GMeth{ParamSlots: 8, GFunction: unsafeCompareAndSetLong, NeedsContext: true}
+		methods[class+name+"Reference(Ljava/lang/Object;JLjava/lang/Object;Ljava/lang/Object;)Z"] =
+			classloader.GMeth{ParamSlots: 6, GFunction: unsafeCompareAndSetReference, NeedsContext: true}
+	}
+
+	for _, order := range []string{"", "Acquire", "Release"} {
+		methods[class+"compareAndExchangeInt"+order+"(Ljava/lang/Object;JII)I"] =
+			classloader.GMeth{ParamSlots: 6, GFunction: unsafeCompareAndExchangeInt, NeedsContext: true}
+		methods[class+"compareAndExchangeLong"+order+"(Ljava/lang/Object;JJJ)J"] =
+			classloader.GMeth{ParamSlots: 8, GFunction: unsafeCompareAndExchangeLong, NeedsContext: true}
+		methods[class+"compareAndExchangeReference"+order+
+			"(Ljava/lang/Object;JLjava/lang/Object;Ljava/lang/Object;)Ljava/lang/Object;"] =
+			classloader.GMeth{ParamSlots: 6, GFunction: unsafeCompareAndExchangeReference, NeedsContext: true}
+
+		methods[class+"getAndAddInt"+order+"(Ljava/lang/Object;JI)I"] =
+			classloader.GMeth{ParamSlots: 5, GFunction: unsafeGetAndAddInt, NeedsContext: true}
+		methods[class+"getAndAddLong"+order+"(Ljava/lang/Object;JJ)J"] =
+			classloader.GMeth{ParamSlots: 6, GFunction: unsafeGetAndAddLong, NeedsContext: true}
+
+		methods[class+"getAndSetInt"+order+"(Ljava/lang/Object;JI)I"] =
+			classloader.GMeth{ParamSlots: 5, GFunction: unsafeGetAndSetInt, NeedsContext: true}
+		methods[class+"getAndSetLong"+order+"(Ljava/lang/Object;JJ)J"] =
+			classloader.GMeth{ParamSlots: 6, GFunction: unsafeGetAndSetLong, NeedsContext: true}
+		methods[class+"getAndSetReference"+order+"(Ljava/lang/Object;JLjava/lang/Object;)Ljava/lang/Object;"] =
+			classloader.GMeth{ParamSlots: 5, GFunction: unsafeGetAndSetReference, NeedsContext: true}
+	}
+
+	for _, order := range []string{"", "Volatile", "Acquire", "Opaque"} {
+		methods[class+"getInt"+order+"(Ljava/lang/Object;J)I"] =
+			classloader.GMeth{ParamSlots: 4, GFunction: unsafeGetInt, NeedsContext: true}
+		methods[class+"getLong"+order+"(Ljava/lang/Object;J)J"] =
+			classloader.GMeth{ParamSlots: 4, GFunction: unsafeGetLong, NeedsContext: true}
+		methods[class+"getReference"+order+"(Ljava/lang/Object;J)Ljava/lang/Object;"] =
+			classloader.GMeth{ParamSlots: 4, GFunction: unsafeGetReference, NeedsContext: true}
+	}
+
+	for _, order := range []string{"", "Volatile", "Release", "Opaque"} {
+		methods[class+"putInt"+order+"(Ljava/lang/Object;JI)V"] =
+			classloader.GMeth{ParamSlots: 5, GFunction: unsafePutInt, NeedsContext: true}
+		methods[class+"putLong"+order+"(Ljava/lang/Object;JJ)V"] =
+			classloader.GMeth{ParamSlots: 6, GFunction: unsafePutLong, NeedsContext: true}
+		methods[class+"putReference"+order+"(Ljava/lang/Object;JLjava/lang/Object;)V"] =
+			classloader.GMeth{ParamSlots: 5, GFunction: unsafePutReference, NeedsContext: true}
+	}
+
+	return methods
+}
+
+// jdk/internal/misc/Unsafe.registerNatives() has nothing to do, since the methods are
+// in the MTable
+func unsafeRegisterNatives([]interface{}) interface{} {
+	return nil
+}
+
+// jdk/internal/misc/Unsafe.getUnsafe() returns the one instance of Unsafe
+func unsafeGetUnsafe([]interface{}) interface{} {
+	theUnsafe.Do(func() {
+		theUnsafe.obj = object.MakeEmptyObject()
+		theUnsafe.obj.Klass = &unsafeClassName
+	})
+	return theUnsafe.obj
+}
+
+// jdk/internal/misc/Unsafe.objectFieldOffset(Class, String) returns the offset of the
+// named field in the objects of the class. As in Jacobin classes are represented by
+// their names (as LDC pushes them), the class is a string.
+func unsafeObjectFieldOffset(params []interface{}) interface{} {
+	fs := params[3].(*list.List)
+	class, name := refParam(params[1]), refParam(params[2])
+	if class == nil || name == nil {
+		return newVMThrowable(fs, exceptions.NullPointerException, "")
+	}
+
+	className := strings.ReplaceAll(object.GetGoStringFromJavaString(class), ".", "/")
+	fieldName := object.GetGoStringFromJavaString(name)
+	offset, ok := fieldOffset(className, fieldName)
+	if !ok {
+		return newVMThrowable(fs, exceptions.InternalError, fieldName)
+	}
+	return offset
+}
+
+// fieldOffset returns the offset by which Unsafe accesses the named instance field
+// in the objects of the class, which instantiateClass() lays out: in Object.Fields,
+// in the order the fields are declared, if the class's superclass is Object, and
+// otherwise in Object.FieldTable, with the fields of all its superclasses.
+func fieldOffset(className, fieldName string) (int64, bool) {
+	if loadThisClass(className) != nil {
+		return 0, false
+	}
+	k := classloader.MethAreaFetch(className)
+	if k == nil || k.Data == nil {
+		return 0, false
+	}
+
+	if k.Data.Superclass == "java/lang/Object" {
+		for i, f := range k.Data.Fields {
+			if !f.IsStatic && k.Data.CP.Utf8Refs[f.Name] == fieldName {
+				return int64(i), true
+			}
+		}
+		return 0, false
+	}
+
+	for c := k; c != nil && c.Data != nil; {
+		for _, f := range c.Data.Fields {
+			if !f.IsStatic && c.Data.CP.Utf8Refs[f.Name] == fieldName {
+				return tableFieldOffsetOf(fieldName), true
+			}
+		}
+		super := c.Data.Superclass
+		if super == "" || super == "java/lang/Object" || loadThisClass(super) != nil {
+			break
+		}
+		c = classloader.MethAreaFetch(super)
+	}
+	return 0, false
+}
+
+// tableFieldOffsetOf returns the offset of the field with the given name in objects
+// whose fields are in their FieldTable
+func tableFieldOffsetOf(name string) int64 {
+	tableFieldNames.Lock()
+	defer tableFieldNames.Unlock()
+	if offset, ok := tableFieldNames.offsets[name]; ok {
+		return offset
+	}
+	if tableFieldNames.offsets == nil {
+		tableFieldNames.offsets = make(map[string]int64)
+	}
+	offset := tableFieldOffset + int64(len(tableFieldNames.names))
+	tableFieldNames.names = append(tableFieldNames.names, name)
+	tableFieldNames.offsets[name] = offset
+	return offset
+}
+
+// tableFieldName returns the name of the field whose offset tableFieldOffsetOf() returned
+func tableFieldName(offset int64) (string, bool) {
+	tableFieldNames.Lock()
+	defer tableFieldNames.Unlock()
+	i := offset - tableFieldOffset
+	if i < 0 || i >= int64(len(tableFieldNames.names)) {
+		return "", false
+	}
+	return tableFieldNames.names[i], true
+}
+
+// The concurrency classes get the Unsafe and the offsets they use in their static
+// initializers, which Jacobin doesn't run (see isJDKClass()). So it sets up their
+// statics itself, with these native initializers (see nativeClassInits).
+
+// initAtomicClass sets up the statics of AtomicInteger and AtomicLong:
+//
+//	private static final Unsafe U = Unsafe.getUnsafe();
+//	private static final long VALUE = U.objectFieldOffset(AtomicInteger.class, "value");
+//	static final boolean VM_SUPPORTS_LONG_CAS = VMSupportsCS8(); // in AtomicLong only
+func initAtomicClass(fs *list.List, className string) error {
+	if err := setOffsetStatic(fs, className, "VALUE", className, "value"); err != nil {
+		return err
+	}
+	if err := setStaticValue(fs, className, "U", unsafeGetUnsafe(nil)); err != nil {
+		return err
+	}
+	if className == "java/util/concurrent/atomic/AtomicLong" {
+		return setStaticValue(fs, className, "VM_SUPPORTS_LONG_CAS", types.JavaBoolTrue)
+	}
+	return nil
+}
+
+// initConcurrentHashMap sets up the statics of ConcurrentHashMap that its static
+// initializer computes, other than serialPersistentFields, which only serialization uses
+func initConcurrentHashMap(fs *list.List, className string) error {
+	for _, offset := range []struct{ static, class, field string }{
+		{"SIZECTL", className, "sizeCtl"},
+		{"TRANSFERINDEX", className, "transferIndex"},
+		{"BASECOUNT", className, "baseCount"},
+		{"CELLSBUSY", className, "cellsBusy"},
+		{"CELLVALUE", className + "$CounterCell", "value"},
+	} {
+		if err := setOffsetStatic(fs, className, offset.static, offset.class, offset.field); err != nil {
+			return err
+		}
+	}
+	for _, static := range []struct {
+		name  string
+		value interface{}
+	}{
+		{"NCPU", int64(runtime.NumCPU())},
+		{"U", unsafeGetUnsafe(nil)},
+		{"ABASE", unsafeArrayBaseOffset(nil)},
+		{"ASHIFT", int64(0)}, // log2 of arrayIndexScale()
+	} {
+		if err := setStaticValue(fs, className, static.name, static.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// initUnsafe sets up the static that holds the Unsafe, which getUnsafe() returns:
+//
+//	private static final Unsafe theUnsafe = new Unsafe();
+func initUnsafe(fs *list.List, className string) error {
+	return setStaticValue(fs, className, "theUnsafe", unsafeGetUnsafe(nil))
+}
+
+// setOffsetStatic sets a static of the class to the offset of the field, as
+// objectFieldOffset() would. A missing field is an InternalError, as it is there.
+func setOffsetStatic(fs *list.List, className, static, fieldClass, fieldName string) error {
+	offset, ok := fieldOffset(fieldClass, fieldName)
+	if !ok {
+		return newVMThrowable(fs, exceptions.InternalError, fieldName)
+	}
+	return setStaticValue(fs, className, static, offset)
+}
+
+// setStaticValue sets the value of a static field that the class's initialization
+// has prepared. A missing static is an InternalError: the class no longer has the
+// statics that its native initializer sets up.
+func setStaticValue(fs *list.List, className, fieldName string, value interface{}) error {
+	s, ok := classloader.FetchStatic(className + "." + fieldName)
+	if !ok {
+		return newVMThrowable(fs, exceptions.InternalError,
+			"native initializer: no static "+fieldName+" in class "+strings.ReplaceAll(className, "/", "."))
+	}
+	s.Value = value
+	return classloader.AddStatic(className+"."+fieldName, s)
+}
+
+// jdk/internal/misc/Unsafe.arrayBaseOffset(Class) returns the offset of the first
+// element of arrays, which is 0, as the offset of an element is its index
+func unsafeArrayBaseOffset([]interface{}) interface{} {
+	return int64(0)
+}
+
+// jdk/internal/misc/Unsafe.arrayIndexScale(Class) returns the difference between the
+// offsets of adjacent array elements
+func unsafeArrayIndexScale([]interface{}) interface{} {
+	return int64(1)
+}
+
+// jdk/internal/misc/Unsafe.compareAndSetInt(Object, long, int, int) sets the int to
+// the last value if it's the expected value, and returns whether it did
+func unsafeCompareAndSetInt(params []interface{}) interface{} {
+	return compareAndSet(params[6].(*list.List), params, unsafeInt, params[4], params[5])
+}
+
+// jdk/internal/misc/Unsafe.compareAndSetLong(Object, long, long, long)
+func unsafeCompareAndSetLong(params []interface{}) interface{} {
+	return compareAndSet(params[8].(*list.List), params, unsafeLong, params[4], params[6])
+}
+
+// jdk/internal/misc/Unsafe.compareAndSetReference(Object, long, Object, Object)
+func unsafeCompareAndSetReference(params []interface{}) interface{} {
+	return compareAndSet(params[6].(*list.List), params, unsafeRef, refParam(params[4]), refParam(params[5]))
+}
+
+// compareAndSet sets the value at the offset to x if it's the expected value, and
+// returns whether it did
+func compareAndSet(fs *list.List, params []interface{}, kind int, expected, x interface{}) interface{} {
+	swapped := false
+	_, err := unsafeUpdate(fs, params, kind, func(old interface{}) (interface{}, bool) {
+		swapped = unsafeEqual(old, expected)
+		return x, swapped
+	})
+	if err != nil {
+		return err
+	}
+	return types.ConvertGoBoolToJavaBool(swapped)
+}
+
+// jdk/internal/misc/Unsafe.compareAndExchangeInt(Object, long, int, int) sets the int
+// to the last value if it's the expected value. It returns the value it found.
+func unsafeCompareAndExchangeInt(params []interface{}) interface{} {
+	return compareAndExchange(params[6].(*list.List), params, unsafeInt, params[4], params[5])
+}
+
+// jdk/internal/misc/Unsafe.compareAndExchangeLong(Object, long, long, long)
+func unsafeCompareAndExchangeLong(params []interface{}) interface{} {
+	return compareAndExchange(params[8].(*list.List), params, unsafeLong, params[4], params[6])
+}
+
+// jdk/internal/misc/Unsafe.compareAndExchangeReference(Object, long, Object, Object)
+func unsafeCompareAndExchangeReference(params []interface{}) interface{} {
+	return compareAndExchange(params[6].(*list.List), params, unsafeRef, refParam(params[4]), refParam(params[5]))
+}
+
+// compareAndExchange sets the value at the offset to x if it's the expected value,
+// and returns the value it found
+func compareAndExchange(fs *list.List, params []interface{}, kind int, expected, x interface{}) interface{} {
+	return unsafeResult(unsafeUpdate(fs, params, kind, func(old interface{}) (interface{}, bool) {
+		return x, unsafeEqual(old, expected)
+	}))
+}
+
+// jdk/internal/misc/Unsafe.getAndAddInt(Object, long, int) adds to the int and returns
+// its previous value
+func unsafeGetAndAddInt(params []interface{}) interface{} {
+	delta := params[4].(int64)
+	return unsafeResult(unsafeUpdate(params[5].(*list.List), params, unsafeInt, func(old interface{}) (interface{}, bool) {
+		return int64(int32(old.(int64) + delta)), true // wrapping around as Java ints do
+	}))
+}
+
+// jdk/internal/misc/Unsafe.getAndAddLong(Object, long, long)
+func unsafeGetAndAddLong(params []interface{}) interface{} {
+	delta := params[4].(int64)
+	return unsafeResult(unsafeUpdate(params[6].(*list.List), params, unsafeLong, func(old interface{}) (interface{}, bool) {
+		return old.(int64) + delta, true
+	}))
+}
+
+// jdk/internal/misc/Unsafe.getAndSetInt(Object, long, int) sets the int and returns
+// its previous value
+func unsafeGetAndSetInt(params []interface{}) interface{} {
+	return getAndSet(params[5].(*list.List), params, unsafeInt, params[4])
+}
+
+// jdk/internal/misc/Unsafe.getAndSetLong(Object, long, long)
+func unsafeGetAndSetLong(params []interface{}) interface{} {
+	return getAndSet(params[6].(*list.List), params, unsafeLong, params[4])
+}
+
+// jdk/internal/misc/Unsafe.getAndSetReference(Object, long, Object)
+func unsafeGetAndSetReference(params []interface{}) interface{} {
+	return getAndSet(params[5].(*list.List), params, unsafeRef, refParam(params[4]))
+}
+
+func getAndSet(fs *list.List, params []interface{}, kind int, x interface{}) interface{} {
+	return unsafeResult(unsafeUpdate(fs, params, kind, func(interface{}) (interface{}, bool) {
+		return x, true
+	}))
+}
+
+// jdk/internal/misc/Unsafe.getIntVolatile(Object, long) returns the int at the offset
+// in the object. getInt() and the others with weaker orderings are the same.
+func unsafeGetInt(params []interface{}) interface{} {
+	return unsafeGet(params[4].(*list.List), params, unsafeInt)
+}
+
+// jdk/internal/misc/Unsafe.getLongVolatile(Object, long)
+func unsafeGetLong(params []interface{}) interface{} {
+	return unsafeGet(params[4].(*list.List), params, unsafeLong)
+}
+
+// jdk/internal/misc/Unsafe.getReferenceVolatile(Object, long)
+func unsafeGetReference(params []interface{}) interface{} {
+	return unsafeGet(params[4].(*list.List), params, unsafeRef)
+}
+
+func unsafeGet(fs *list.List, params []interface{}, kind int) interface{} {
+	return unsafeResult(unsafeUpdate(fs, params, kind, func(interface{}) (interface{}, bool) {
+		return nil, false
+	}))
+}
+
+// jdk/internal/misc/Unsafe.putIntVolatile(Object, long, int) sets the int at the
+// offset in the object. putInt() and the others with weaker orderings are the same.
+func unsafePutInt(params []interface{}) interface{} {
+	return unsafePut(params[5].(*list.List), params, unsafeInt, params[4])
+}
+
+// jdk/internal/misc/Unsafe.putLongVolatile(Object, long, long)
+func unsafePutLong(params []interface{}) interface{} {
+	return unsafePut(params[6].(*list.List), params, unsafeLong, params[4])
+}
+
+// jdk/internal/misc/Unsafe.putReferenceVolatile(Object, long, Object)
+func unsafePutReference(params []interface{}) interface{} {
+	return unsafePut(params[5].(*list.List), params, unsafeRef, refParam(params[4]))
+}
+
+func unsafePut(fs *list.List, params []interface{}, kind int, x interface{}) interface{} {
+	_, err := unsafeUpdate(fs, params, kind, func(interface{}) (interface{}, bool) {
+		return x, true
+	})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// unsafeResult returns the value an Unsafe method read, or the exception it throws
+func unsafeResult(old interface{}, err error) interface{} {
+	if err != nil {
+		return err
+	}
+	return old
+}
+
+// unsafeUpdate reads the value at the offset in the object (params[1] and params[2]
+// of the Unsafe methods) and, if update returns true, replaces it with the value
+// update returns. It does both with the object's field lock held. It returns the
+// value it read, as an Unsafe method of the kind sees it, or the exception to throw.
+func unsafeUpdate(fs *list.List, params []interface{}, kind int,
+	update func(old interface{}) (interface{}, bool)) (interface{}, error) {
+	obj, offset := refParam(params[1]), params[2].(int64)
+	if obj == nil {
+		return nil, newVMThrowable(fs, exceptions.NullPointerException, "")
+	}
+
+	// the exception is created once the lock is released, as that can run Java code
+	var exception int
+	var msg string
+	old := func() interface{} {
+		lock := obj.FieldLock()
+		lock.Lock()
+		defer lock.Unlock()
+
+		value, ok := unsafeLoad(obj, offset)
+		if !ok {
+			exception, msg = unsafeOffsetError(obj, offset)
+			return nil
+		}
+		old := toUnsafeValue(value, kind)
+		if x, store := update(old); store {
+			unsafeStore(obj, offset, fromUnsafeValue(x, kind, value))
+		}
+		return old
+	}()
+	if msg != "" {
+		return nil, newVMThrowable(fs, exception, msg)
+	}
+	return old, nil
+}
+
+// unsafeLoad returns the value of the field or array element at the offset in the
+// object, or false if there's none
+func unsafeLoad(obj *object.Object, offset int64) (interface{}, bool) {
+	if isUnsafeArray(obj) {
+		switch arr := obj.Fields[0].Fvalue.(type) {
+		case *[]int64:
+			if offset >= 0 && offset < int64(len(*arr)) {
+				return (*arr)[offset], true
+			}
+		case *[]float64:
+			if offset >= 0 && offset < int64(len(*arr)) {
+				return (*arr)[offset], true
+			}
+		case *[]byte:
+			if offset >= 0 && offset < int64(len(*arr)) {
+				return int64(int8((*arr)[offset])), true
+			}
+		case *[]*object.Object:
+			if offset >= 0 && offset < int64(len(*arr)) {
+				return (*arr)[offset], true
+			}
+		}
+		return nil, false
+	}
+
+	if offset >= tableFieldOffset {
+		name, ok := tableFieldName(offset)
+		if !ok || obj.FieldTable == nil {
+			return nil, false
+		}
+		field, ok := obj.FieldTable[name]
+		return field.Fvalue, ok
+	}
+	if offset < 0 || offset >= int64(len(obj.Fields)) ||
+		strings.HasPrefix(obj.Fields[offset].Ftype, types.Static) {
+		return nil, false
+	}
+	return obj.Fields[offset].Fvalue, true
+}
+
+// unsafeStore sets the field or array element at the offset in the object, which
+// unsafeLoad() has found
+func unsafeStore(obj *object.Object, offset int64, value interface{}) {
+	if isUnsafeArray(obj) {
+		switch arr := obj.Fields[0].Fvalue.(type) {
+		case *[]int64:
+			(*arr)[offset] = value.(int64)
+		case *[]float64:
+			(*arr)[offset] = value.(float64)
+		case *[]byte:
+			(*arr)[offset] = byte(value.(int64))
+		case *[]*object.Object:
+			(*arr)[offset], _ = value.(*object.Object)
+		}
+		return
+	}
+
+	if offset >= tableFieldOffset {
+		name, _ := tableFieldName(offset)
+		field := obj.FieldTable[name]
+		field.Fvalue = value
+		obj.FieldTable[name] = field
+		return
+	}
+	obj.Fields[offset].Fvalue = value
+}
+
+// unsafeOffsetError returns the exception to throw for an offset that's not that of
+// a field or array element of the object
+func unsafeOffsetError(obj *object.Object, offset int64) (int, string) {
+	if isUnsafeArray(obj) {
+		length := int64(0)
+		switch arr := obj.Fields[0].Fvalue.(type) {
+		case *[]int64:
+			length = int64(len(*arr))
+		case *[]float64:
+			length = int64(len(*arr))
+		case *[]byte:
+			length = int64(len(*arr))
+		case *[]*object.Object:
+			length = int64(len(*arr))
+		}
+		return exceptions.ArrayIndexOutOfBoundsException,
+			fmt.Sprintf("Index %d out of bounds for length %d", offset, length)
+	}
+	return exceptions.InternalError, fmt.Sprintf("invalid field offset: %d", offset)
+}
+
+// isUnsafeArray returns true if the object is an array, whose elements Unsafe
+// accesses by their index
+func isUnsafeArray(obj *object.Object) bool {
+	return len(obj.Fields) == 1 && (obj.Klass == nil || strings.HasPrefix(*obj.Klass, types.Array))
+}
+
+// toUnsafeValue converts a stored value to what an Unsafe method of the kind sees:
+// a float or double as its raw bits, and a null reference as object.Null
+func toUnsafeValue(value interface{}, kind int) interface{} {
+	switch v := value.(type) {
+	case float64:
+		if kind == unsafeInt {
+			return int64(int32(math.Float32bits(float32(v))))
+		}
+		return int64(math.Float64bits(v))
+	case nil:
+		if kind == unsafeRef {
+			return object.Null
+		}
+	}
+	return value
+}
+
+// fromUnsafeValue converts a value that an Unsafe method of the kind stores to what
+// Jacobin stores in place of the old value
+func fromUnsafeValue(value interface{}, kind int, old interface{}) interface{} {
+	if _, isFloat := old.(float64); isFloat && kind != unsafeRef {
+		bits := value.(int64)
+		if kind == unsafeInt {
+			return float64(math.Float32frombits(uint32(bits)))
+		}
+		return math.Float64frombits(uint64(bits))
+	}
+	return value
+}
+
+// unsafeEqual returns true if the value an Unsafe method found is the expected one.
+// References are the same if they point to the same object. A field that PUTFIELD
+// set to an array holds the array's elements, which are the same as the array.
+func unsafeEqual(found, expected interface{}) bool {
+	exp, isRef := expected.(*object.Object)
+	if !isRef {
+		return found == expected
+	}
+	if ref, ok := found.(*object.Object); ok {
+		return ref == exp
+	}
+	return exp != nil && isUnsafeArray(exp) && exp.Fields[0].Fvalue == found
+}
+
+// refParam returns the reference passed to a Go method, which is nil for null
+func refParam(param interface{}) *object.Object {
+	obj, _ := param.(*object.Object)
+	return obj
+}
@@ -0,0 +1,400 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"container/list"
+	"jacobin/classloader"
+	"jacobin/frames"
+	"jacobin/object"
+	"jacobin/types"
+	"math"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// sets up these classes, whose objects keep their fields in Object.Fields and in
+// Object.FieldTable, respectively:
+//
+//	class Counter { String name; int count; long total; double ratio; }
+//	class SubCounter extends Counter { int extra; }
+func setupUnsafeClasses() *list.List {
+	setupInterfaceClasses()
+	classloader.MTableLoadGoMethods(Load_Jdk_Internal_Misc_Unsafe())
+
+	addClass("test/Counter", "java/lang/Object", nil, newCPBuilder(), []testField{
+		{"name", "Ljava/lang/String;", false}, {"count", "I", false}, {"total", "J", false}, {"ratio", "D", false}})
+	addClass("test/SubCounter", "test/Counter", nil, newCPBuilder(), []testField{{"extra", "I", false}})
+
+	f := newFrame(RETURN)
+	fs := frames.CreateFrameStack()
+	fs.PushFront(&f)
+	return fs
+}
+
+// returns the offset of the field, failing if Unsafe.objectFieldOffset() doesn't
+func testFieldOffset(t *testing.T, fs *list.List, class, field string) int64 {
+	ret := unsafeObjectFieldOffset([]interface{}{unsafeGetUnsafe(nil),
+		object.CreateCompactStringFromGoString(&class), object.CreateCompactStringFromGoString(&field), fs})
+	offset, ok := ret.(int64)
+	if !ok {
+		t.Fatalf("Unexpected result from objectFieldOffset(%s, %s): %v", class, field, ret)
+	}
+	return offset
+}
+
+// objectFieldOffset() returns the index of a field in Object.Fields or, for objects
+// whose fields are in a FieldTable, an offset for the field's name
+func TestUnsafeObjectFieldOffset(t *testing.T) {
+	fs := setupUnsafeClasses()
+
+	if offset := testFieldOffset(t, fs, "test.Counter", "total"); offset != 2 {
+		t.Errorf("Expected the offset of Counter.total to be 2, got: %d", offset)
+	}
+	extra := testFieldOffset(t, fs, "test/SubCounter", "extra")
+	count := testFieldOffset(t, fs, "test/SubCounter", "count")
+	if extra < tableFieldOffset || count < tableFieldOffset || extra == count {
+		t.Errorf("Expected distinct field table offsets for SubCounter's fields, got: %d, %d", extra, count)
+	}
+
+	missing := "missing"
+	ret := unsafeObjectFieldOffset([]interface{}{unsafeGetUnsafe(nil),
+		object.CreateCompactStringFromGoString(&missing), object.CreateCompactStringFromGoString(&missing), fs})
+	if jt, ok := ret.(*javaThrowable); !ok || jt.className != "java/lang/InternalError" {
+		t.Errorf("Expected an InternalError for a missing field, got: %v", ret)
+	}
+	ret = unsafeObjectFieldOffset([]interface{}{unsafeGetUnsafe(nil), object.Null, object.Null, fs})
+	if jt, ok := ret.(*javaThrowable); !ok || jt.className != "java/lang/NullPointerException" {
+		t.Errorf("Expected a NullPointerException for a null class, got: %v", ret)
+	}
+}
+
+// compare-and-set, compare-and-exchange, get-and-add, get-and-set, get, and put, on
+// fields in Object.Fields and in Object.FieldTable
+func TestUnsafeFieldOperations(t *testing.T) {
+	fs := setupUnsafeClasses()
+	u := unsafeGetUnsafe(nil)
+
+	for _, class := range []string{"test/Counter", "test/SubCounter"} {
+		obj, err := instantiateClass(class)
+		if err != nil {
+			t.Fatalf("Unexpected error instantiating %s: %s", class, err.Error())
+		}
+		count := testFieldOffset(t, fs, class, "count")
+		total := testFieldOffset(t, fs, class, "total")
+
+		if unsafeCompareAndSetInt([]interface{}{u, obj, count, count, int64(0), int64(5), fs}) != types.JavaBoolTrue ||
+			unsafeCompareAndSetInt([]interface{}{u, obj, count, count, int64(0), int64(6), fs}) != types.JavaBoolFalse {
+			t.Errorf("%s: Expected compareAndSetInt() to succeed only when the int is the expected value", class)
+		}
+		if ret := unsafeCompareAndExchangeInt([]interface{}{u, obj, count, count, int64(7), int64(8), fs}); ret != int64(5) {
+			t.Errorf("%s: Expected compareAndExchangeInt() to return 5, got: %v", class, ret)
+		}
+		unsafePutInt([]interface{}{u, obj, count, count, int64(math.MaxInt32), fs})
+		if ret := unsafeGetAndAddInt([]interface{}{u, obj, count, count, int64(1), fs}); ret != int64(math.MaxInt32) {
+			t.Errorf("%s: Expected getAndAddInt() to return the previous value, got: %v", class, ret)
+		}
+		if ret := unsafeGetInt([]interface{}{u, obj, count, count, fs}); ret != int64(math.MinInt32) {
+			t.Errorf("%s: Expected getAndAddInt() to wrap around to MinInt32, got: %v", class, ret)
+		}
+
+		unsafeGetAndAddLong([]interface{}{u, obj, total, total, int64(1 << 40), int64(1 << 40), fs})
+		if ret := unsafeGetAndSetLong([]interface{}{u, obj, total, total, int64(3), int64(3), fs}); ret != int64(1<<40) {
+			t.Errorf("%s: Expected getAndSetLong() to return 1<<40, got: %v", class, ret)
+		}
+		if ret := unsafeGetLong([]interface{}{u, obj, total, total, fs}); ret != int64(3) {
+			t.Errorf("%s: Expected getLong() to return 3, got: %v", class, ret)
+		}
+
+		ret := unsafePutInt([]interface{}{u, obj, tableFieldOffset + 99, tableFieldOffset + 99, int64(1), fs})
+		if jt, ok := ret.(*javaThrowable); !ok || jt.className != "java/lang/InternalError" {
+			t.Errorf("%s: Expected an InternalError for an invalid offset, got: %v", class, ret)
+		}
+		ret = unsafeGetInt([]interface{}{u, object.Null, count, count, fs})
+		if jt, ok := ret.(*javaThrowable); !ok || jt.className != "java/lang/NullPointerException" {
+			t.Errorf("%s: Expected a NullPointerException for a null object, got: %v", class, ret)
+		}
+	}
+}
+
+// References are compared by identity. Doubles are compared and set by their raw bits.
+func TestUnsafeReferencesAndDoubles(t *testing.T) {
+	fs := setupUnsafeClasses()
+	u := unsafeGetUnsafe(nil)
+	obj, _ := instantiateClass("test/Counter")
+	name := testFieldOffset(t, fs, "test/Counter", "name")
+	ratio := testFieldOffset(t, fs, "test/Counter", "ratio")
+
+	first, second := "first", "second"
+	a, b := object.CreateCompactStringFromGoString(&first), object.CreateCompactStringFromGoString(&second)
+	if unsafeCompareAndSetReference([]interface{}{u, obj, name, name, object.Null, a, fs}) != types.JavaBoolTrue ||
+		unsafeCompareAndSetReference([]interface{}{u, obj, name, name, b, b, fs}) != types.JavaBoolFalse {
+		t.Error("Expected compareAndSetReference() to succeed only when the field holds the expected object")
+	}
+	if ret := unsafeGetAndSetReference([]interface{}{u, obj, name, name, b, fs}); ret != a {
+		t.Errorf("Expected getAndSetReference() to return the previous object, got: %v", ret)
+	}
+	if ret := unsafeGetReference([]interface{}{u, obj, name, name, fs}); ret != b {
+		t.Errorf("Expected getReference() to return the object set, got: %v", ret)
+	}
+
+	zero, half := int64(math.Float64bits(0)), int64(math.Float64bits(0.5))
+	if unsafeCompareAndSetLong([]interface{}{u, obj, ratio, ratio, zero, zero, half, half, fs}) != types.JavaBoolTrue {
+		t.Error("Expected compareAndSetLong() to compare a double field by its raw bits")
+	}
+	if obj.Fields[ratio].Fvalue != 0.5 {
+		t.Errorf("Expected the double field to be 0.5, got: %v", obj.Fields[ratio].Fvalue)
+	}
+}
+
+// The offset of an array element is its index
+func TestUnsafeArrays(t *testing.T) {
+	fs := setupUnsafeClasses()
+	u := unsafeGetUnsafe(nil)
+	if unsafeArrayBaseOffset(nil) != int64(0) || unsafeArrayIndexScale(nil) != int64(1) {
+		t.Error("Expected the array base offset to be 0 and the index scale 1")
+	}
+
+	ints := object.Make1DimArray(object.INT, 4)
+	unsafeGetAndAddInt([]interface{}{u, ints, int64(3), int64(3), int64(9), fs})
+	if (*ints.Fields[0].Fvalue.(*[]int64))[3] != 9 {
+		t.Errorf("Expected element 3 to be 9, got: %v", *ints.Fields[0].Fvalue.(*[]int64))
+	}
+
+	refs := object.Make1DimArray(object.REF, 2)
+	elem := object.MakeEmptyObject()
+	if unsafeCompareAndSetReference([]interface{}{u, refs, int64(1), int64(1), object.Null, elem, fs}) != types.JavaBoolTrue ||
+		(*refs.Fields[0].Fvalue.(*[]*object.Object))[1] != elem {
+		t.Error("Expected compareAndSetReference() to set the array element")
+	}
+
+	ret := unsafeGetInt([]interface{}{u, ints, int64(4), int64(4), fs})
+	if jt, ok := ret.(*javaThrowable); !ok || jt.className != "java/lang/ArrayIndexOutOfBoundsException" {
+		t.Errorf("Expected an ArrayIndexOutOfBoundsException, got: %v", ret)
+	}
+}
+
+// Concurrent getAndAdd() and compare-and-set loops lose no updates
+func TestUnsafeConcurrentUpdates(t *testing.T) {
+	fs := setupUnsafeClasses()
+	u := unsafeGetUnsafe(nil)
+	obj, _ := instantiateClass("test/SubCounter")
+	count := testFieldOffset(t, fs, "test/SubCounter", "count")
+	extra := testFieldOffset(t, fs, "test/SubCounter", "extra")
+	longs := object.Make1DimArray(object.INT, 1)
+
+	const goroutines, updates = 8, 500
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < updates; i++ {
+				unsafeGetAndAddInt([]interface{}{u, obj, count, count, int64(1), fs})
+				unsafeGetAndAddLong([]interface{}{u, longs, int64(0), int64(0), int64(2), int64(2), fs})
+				for { // as AtomicInteger.incrementAndGet() did before getAndAdd()
+					v := unsafeGetInt([]interface{}{u, obj, extra, extra, fs}).(int64)
+					if unsafeCompareAndSetInt([]interface{}{u, obj, extra, extra, v, v + 1, fs}) == types.JavaBoolTrue {
+						break
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if ret := unsafeGetInt([]interface{}{u, obj, count, count, fs}); ret != int64(goroutines*updates) {
+		t.Errorf("Expected getAndAddInt() to count %d, got: %v", goroutines*updates, ret)
+	}
+	if ret := unsafeGetInt([]interface{}{u, obj, extra, extra, fs}); ret != int64(goroutines*updates) {
+		t.Errorf("Expected the compare-and-set loop to count %d, got: %v", goroutines*updates, ret)
+	}
+	if ret := unsafeGetLong([]interface{}{u, longs, int64(0), int64(0), fs}); ret != int64(2*goroutines*updates) {
+		t.Errorf("Expected getAndAddLong() to count %d, got: %v", 2*goroutines*updates, ret)
+	}
+}
+
+// sets up java.util.concurrent.atomic.AtomicInteger, with the members incrementAndGet()
+// uses, which are those of the JDK's:
+//
+//	public class AtomicInteger extends Number {
+//	    private static final Unsafe U = Unsafe.getUnsafe();
+//	    private static final long VALUE = U.objectFieldOffset(AtomicInteger.class, "value");
+//	    private volatile int value;
+//	    public AtomicInteger(int initialValue) { value = initialValue; }
+//	    public final int incrementAndGet() { return U.getAndAddInt(this, VALUE, 1) + 1; }
+//	}
+//
+// Its static initializer is left out, as Jacobin sets up its statics natively. It
+// returns the CP of the test code and the CP indexes of the class, its constructor,
+// and incrementAndGet().
+func setupAtomicInteger() (*classloader.CPool, []uint16) {
+	setupUnsafeClasses()
+	classloader.Statics = make(map[string]classloader.Static)
+	addTestClass("java/lang/Number", "java/lang/Object", nil)
+	addClass("jdk/internal/misc/Unsafe", "java/lang/Object", nil, newCPBuilder(),
+		[]testField{{"theUnsafe", "Ljdk/internal/misc/Unsafe;", true}})
+
+	const atomicInteger = "java/util/concurrent/atomic/AtomicInteger"
+	const public = 0x0001
+	b := newCPBuilder()
+	unsafe := b.fieldRef(atomicInteger, "U", "Ljdk/internal/misc/Unsafe;")
+	offset := b.fieldRef(atomicInteger, "VALUE", "J")
+	value := b.fieldRef(atomicInteger, "value", "I")
+	getAndAdd := b.methodRef("jdk/internal/misc/Unsafe", "getAndAddInt", "(Ljava/lang/Object;JI)I")
+	k := addClass(atomicInteger, "java/lang/Number", nil, b,
+		[]testField{{"U", "Ljdk/internal/misc/Unsafe;", true}, {"VALUE", "J", true}, {"value", "I", false}},
+		testMethod{"<init>", "(I)V", public, []byte{
+			ALOAD_0, ILOAD_1, PUTFIELD, 0x00, byte(value), RETURN}},
+		testMethod{"incrementAndGet", "()I", public, []byte{
+			GETSTATIC, 0x00, byte(unsafe), ALOAD_0, GETSTATIC, 0x00, byte(offset), ICONST_1,
+			INVOKEVIRTUAL, 0x00, byte(getAndAdd), ICONST_1, IADD, IRETURN}})
+	k.Data.Methods[0].CodeAttr.MaxLocals = 2
+	k.Data.Methods[1].CodeAttr.MaxStack = 5
+
+	b = newCPBuilder()
+	indexes := []uint16{
+		b.class(atomicInteger),
+		b.methodRef(atomicInteger, "<init>", "(I)V"),
+		b.methodRef(atomicInteger, "incrementAndGet", "()I"),
+	}
+	return &b.cp, indexes
+}
+
+// AtomicInteger.incrementAndGet() runs on Unsafe, with the statics that Jacobin sets
+// up when the class is initialized:
+//
+//	AtomicInteger count = new AtomicInteger(5);
+//	count.incrementAndGet(); count.incrementAndGet();
+func TestAtomicIntegerIncrementAndGet(t *testing.T) {
+	CP, indexes := setupAtomicInteger()
+
+	f, err := runMainTestCode(CP,
+		NEW, 0x00, byte(indexes[0]), DUP, DUP, ICONST_5,
+		INVOKESPECIAL, 0x00, byte(indexes[1]),
+		INVOKEVIRTUAL, 0x00, byte(indexes[2]),
+		SWAP,
+		INVOKEVIRTUAL, 0x00, byte(indexes[2]))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if f.TOS != 1 || f.OpStack[0] != int64(6) || f.OpStack[1] != int64(7) {
+		t.Errorf("Expected incrementAndGet() to return 6 and then 7, got: %v", f.OpStack[:f.TOS+1])
+	}
+}
+
+// Threads that call incrementAndGet() on the same AtomicInteger lose no increments
+func TestAtomicIntegerConcurrentIncrements(t *testing.T) {
+	CP, indexes := setupAtomicInteger()
+	f, err := runMainTestCode(CP, NEW, 0x00, byte(indexes[0]), DUP, ICONST_0,
+		INVOKESPECIAL, 0x00, byte(indexes[1]))
+	if err != nil {
+		t.Fatalf("Unexpected error creating the AtomicInteger: %s", err.Error())
+	}
+	count := f.OpStack[0]
+	fs := frames.CreateFrameStack()
+	fs.PushFront(f)
+	offset := testFieldOffset(t, fs, "java/util/concurrent/atomic/AtomicInteger", "value")
+
+	const goroutines, increments = 8, 200
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < increments; i++ {
+				f := newFrame(ALOAD_0)
+				f.Meth = append(f.Meth, INVOKEVIRTUAL, 0x00, byte(indexes[2]), POP, RETURN)
+				f.CP = CP
+				f.Locals = []interface{}{count}
+				stack := frames.CreateFrameStack()
+				stack.PushFront(&f)
+				if err := runFrame(stack); err != nil {
+					t.Errorf("Unexpected error: %s", err.Error())
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if ret := unsafeGetInt([]interface{}{unsafeGetUnsafe(nil), count, offset, offset, fs}); ret != int64(goroutines*increments) {
+		t.Errorf("Expected %d increments, got: %v", goroutines*increments, ret)
+	}
+}
+
+// ConcurrentHashMap's statics are set up when the class is initialized: the offsets
+// of its fields and of CounterCell.value, and those of the elements of its table
+func TestConcurrentHashMapStatics(t *testing.T) {
+	fs := setupUnsafeClasses()
+	classloader.Statics = make(map[string]classloader.Static)
+	const chm = "java/util/concurrent/ConcurrentHashMap"
+	addTestClass("java/util/AbstractMap", "java/lang/Object", nil)
+	statics := []testField{}
+	for _, static := range []string{"SIZECTL", "TRANSFERINDEX", "BASECOUNT", "CELLSBUSY", "CELLVALUE"} {
+		statics = append(statics, testField{static, "J", true})
+	}
+	statics = append(statics, testField{"NCPU", "I", true}, testField{"ABASE", "I", true},
+		testField{"ASHIFT", "I", true}, testField{"U", "Ljdk/internal/misc/Unsafe;", true})
+	addClass(chm, "java/util/AbstractMap", nil, newCPBuilder(), append(statics,
+		testField{"table", "[Ljava/util/concurrent/ConcurrentHashMap$Node;", false},
+		testField{"baseCount", "J", false}, testField{"sizeCtl", "I", false},
+		testField{"transferIndex", "I", false}, testField{"cellsBusy", "I", false}))
+	addClass(chm+"$CounterCell", "java/lang/Object", nil, newCPBuilder(), []testField{{"value", "J", false}})
+
+	if err := initializeClass(fs, chm); err != nil {
+		t.Fatalf("Unexpected error initializing ConcurrentHashMap: %s", err.Error())
+	}
+	for static, expected := range map[string]interface{}{
+		"SIZECTL":   testFieldOffset(t, fs, chm, "sizeCtl"),
+		"BASECOUNT": testFieldOffset(t, fs, chm, "baseCount"),
+		"CELLVALUE": int64(0),
+		"ABASE":     int64(0),
+		"ASHIFT":    int64(0),
+		"U":         unsafeGetUnsafe(nil),
+	} {
+		if s, _ := classloader.FetchStatic(chm + "." + static); s.Value != expected {
+			t.Errorf("Expected ConcurrentHashMap.%s to be %v, got: %v", static, expected, s.Value)
+		}
+	}
+}
+
+// A JDK class whose native initializer finds a static missing, as after the static is
+// renamed in the JDK, fails to initialize with an InternalError that names the static
+func TestNativeInitMissingStatic(t *testing.T) {
+	fs := setupUnsafeClasses()
+	classloader.Statics = make(map[string]classloader.Static)
+	const atomicLong = "java/util/concurrent/atomic/AtomicLong"
+	addTestClass("java/lang/Number", "java/lang/Object", nil)
+	addClass(atomicLong, "java/lang/Number", nil, newCPBuilder(), []testField{
+		{"U", "Ljdk/internal/misc/Unsafe;", true}, {"VALUE", "J", true}, {"value", "J", false}})
+
+	err := initializeClass(fs, atomicLong)
+	if jt, ok := err.(*javaThrowable); !ok || jt.className != "java/lang/InternalError" ||
+		!strings.Contains(jt.msg, "VM_SUPPORTS_LONG_CAS") {
+		t.Errorf("Expected an InternalError naming VM_SUPPORTS_LONG_CAS, got: %v", err)
+	}
+	if jt, ok := initializeClass(fs, atomicLong).(*javaThrowable); !ok || jt.className != "java/lang/NoClassDefFoundError" {
+		t.Errorf("Expected the class to remain unusable, got: %v", jt)
+	}
+}
+
+// A JDK class that has statics for atomic access, but no native initializer to set
+// them up, fails when it's first used rather than when a method finds the statics null
+func TestJDKClassWithAtomicStatics(t *testing.T) {
+	fs := setupUnsafeClasses()
+	const atomicBoolean = "java/util/concurrent/atomic/AtomicBoolean"
+	addClass(atomicBoolean, "java/lang/Object", nil, newCPBuilder(), []testField{
+		{"VALUE", "Ljava/lang/invoke/VarHandle;", true}, {"value", "I", false}})
+
+	err := initializeClass(fs, atomicBoolean)
+	if jt, ok := err.(*javaThrowable); !ok || jt.className != "java/lang/InternalError" ||
+		!strings.Contains(jt.msg, "VALUE") {
+		t.Errorf("Expected an InternalError naming VALUE, got: %v", err)
+	}
+}
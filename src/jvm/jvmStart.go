@@ -13,6 +13,7 @@ import (
 	"jacobin/log"
 	"jacobin/shutdown"
 	"os"
+	"strings"
 )
 
 var Global globals.Globals
@@ -35,7 +36,8 @@ func JVMrun() int {
 
 	// handle the command-line interface (cli) -- i.e., process the args
 	LoadOptionsTable(Global)
-	err := HandleCli(os.Args, &Global)
+	err := HandleCli(os.Args, globals.GetGlobalRef()) // the classloader reads the classpath, etc., from there
+	Global = *globals.GetGlobalRef()
 	if err != nil {
 		return shutdown.Exit(shutdown.JVM_EXCEPTION)
 	}
@@ -43,6 +45,13 @@ func JVMrun() int {
 	if Global.ExitNow == true {
 		return shutdown.Exit(shutdown.OK)
 	}
+	// -javap disassembles class files, which doesn't need the JDK's classes to be loaded
+	if len(Global.JavapClasses) > 0 {
+		if javap(os.Stdout, &Global) != nil {
+			return shutdown.Exit(shutdown.APP_EXCEPTION)
+		}
+		return shutdown.Exit(shutdown.OK)
+	}
 
 	// Init classloader and load base classes
 	err = classloader.Init() // must precede classloader.LoadBaseClasses
@@ -66,6 +75,14 @@ func JVMrun() int {
 			_ = log.Log(fmt.Sprintf("no main manifest attribute, in %s", Global.StartingJar), log.INFO)
 			return shutdown.Exit(shutdown.APP_EXCEPTION)
 		}
+		// the JARs listed in the manifest's Class-Path follow the JAR on the classpath
+		manifestClasspath, err := classloader.GetClasspathFromJar(classloader.BootstrapCL, Global.StartingJar)
+		if err != nil {
+			_ = log.Log(err.Error(), log.INFO)
+			return shutdown.Exit(shutdown.JVM_EXCEPTION)
+		}
+		globals.GetGlobalRef().Classpath = append(Global.Classpath, manifestClasspath...)
+
 		mainClass, err = classloader.LoadClassFromJar(classloader.BootstrapCL, manifestClass, Global.StartingJar)
 		if err != nil { // the exceptions message will already have been shown to user
 			return shutdown.Exit(shutdown.JVM_EXCEPTION)
@@ -75,18 +92,37 @@ func JVMrun() int {
 		if err != nil { // the exceptions message will already have been shown to user
 			return shutdown.Exit(shutdown.JVM_EXCEPTION)
 		}
+	} else if Global.StartingSourceFile != "" {
+		classFile, sourceOutDir, err := compileSourceFile(globals.GetGlobalRef())
+		if err != nil { // javac's messages will already have been shown to user
+			return shutdown.Exit(shutdown.APP_EXCEPTION)
+		}
+		// the compiled classes are removed when the JVM exits, however it exits
+		shutdown.AddHook(func() { _ = os.RemoveAll(sourceOutDir) })
+		mainClass, err = classloader.LoadClassFromFile(classloader.BootstrapCL, classFile)
+		if err != nil {
+			return shutdown.Exit(shutdown.JVM_EXCEPTION)
+		}
+	} else if Global.MainClassName != "" {
+		mainClass, err = loadMainClass(globals.GetGlobalRef())
+		if err != nil { // the error will already have been shown to user
+			return shutdown.Exit(shutdown.JVM_EXCEPTION)
+		}
 	} else {
 		_ = log.Log("Error: No executable program specified. Exiting.", log.INFO)
 		ShowUsage(os.Stdout)
 		return shutdown.Exit(shutdown.APP_EXCEPTION)
 	}
 
-	// the following was commented out per JACOBIN-327.
-	// Likely to be reinstated at some later point
-	// classloader.LoadReferencedClasses(mainClass)
+	// preload the classes referenced by the main class in parallel with its execution
+	if err = classloader.LoadReferencedClasses(mainClass); err != nil {
+		_ = log.Log(err.Error(), log.SEVERE)
+		return shutdown.Exit(shutdown.JVM_EXCEPTION)
+	}
 
-	// begin execution
+	// begin execution. From here on, SIGQUIT prints a dump of the threads.
 	_ = log.Log("Starting execution with: "+mainClass, log.INFO)
+	handleThreadDumpSignal()
 	status := StartExec(mainClass, &Global)
 
 	if status != nil {
@@ -94,3 +130,30 @@ func JVMrun() int {
 	}
 	return shutdown.Exit(shutdown.OK)
 }
+
+// loadMainClass loads the main class given by its binary name (e.g., com.example.Main)
+// from the classpath. If it can't be loaded, the error is shown to the user, in the
+// JDK's words if -strictJDK is on.
+func loadMainClass(gl *globals.Globals) (string, error) {
+	binaryName := strings.ReplaceAll(gl.MainClassName, "/", ".") // as the JDK, accept either form
+	className := strings.ReplaceAll(binaryName, ".", "/")
+
+	mainClass, err := classloader.LoadClassFromClasspath(classloader.BootstrapCL, className)
+	if err == nil && mainClass != className { // the file's class is in another package
+		err = fmt.Errorf("%s (wrong name: %s)", className, mainClass)
+		cause := "java.lang.NoClassDefFoundError: " + err.Error()
+		_, _ = fmt.Fprintf(os.Stderr, "Error: Could not find or load main class %s\nCaused by: %s\n",
+			binaryName, cause)
+		return "", err
+	}
+	if err != nil {
+		cause := err.Error()
+		if gl.StrictJDK {
+			cause = "java.lang.ClassNotFoundException: " + binaryName
+		}
+		_, _ = fmt.Fprintf(os.Stderr, "Error: Could not find or load main class %s\nCaused by: %s\n",
+			binaryName, cause)
+		return "", err
+	}
+	return mainClass, nil
+}
@@ -0,0 +1,136 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"container/list"
+	"fmt"
+	"jacobin/classloader"
+	"jacobin/object"
+	"strings"
+)
+
+// The launch protocol of Java 21 (JEP 445) lets main() be an instance method, omit
+// its String[] parameter, and have any access other than private. The first of these
+// methods found in the main class is run:
+//
+//  1. static void main(String[] args)
+//  2. static void main()
+//  3. void main(String[] args), declared in the class or inherited from a superclass
+//  4. void main(), declared in the class or inherited from a superclass
+//
+// Before an instance main() is run, an instance of the main class is created with its
+// zero-argument constructor, which must not be private. An unnamed class (one whose
+// source has methods outside of any class) is launched the same way: javac names it
+// after its source file.
+
+const mainArgsType = "([Ljava/lang/String;)V"
+
+// mainMethod is the main() that the launch protocol chose
+type mainMethod struct {
+	className string // the class that declares the method
+	methType  string // mainArgsType or ()V
+	isStatic  bool
+}
+
+// selectMainMethod finds the main() to run in the main class, className
+func selectMainMethod(className string) (mainMethod, error) {
+	candidates := []mainMethod{
+		{methType: mainArgsType, isStatic: true},
+		{methType: "()V", isStatic: true},
+		{methType: mainArgsType},
+		{methType: "()V"},
+	}
+	for _, candidate := range candidates {
+		declaringClass, err := findMainMethod(className, candidate.methType, candidate.isStatic)
+		if err != nil {
+			return mainMethod{}, err
+		}
+		if declaringClass != "" {
+			candidate.className = declaringClass
+			return candidate, nil
+		}
+	}
+	return mainMethod{}, fmt.Errorf("Main method not found in class %s, please define the main method as:\n"+
+		"   public static void main(String[] args)", strings.ReplaceAll(className, "/", "."))
+}
+
+// findMainMethod returns the name of the class that declares a non-private main()
+// of the given type: the main class itself or, for inherited methods, one of its
+// superclasses. It returns "" if there is no such method. Static main() methods
+// that are inherited must be public, as was the case before Java 21.
+func findMainMethod(className, methType string, isStatic bool) (string, error) {
+	for class := className; class != "" && class != "java/lang/Object"; {
+		if err := loadThisClass(class); err != nil {
+			return "", err
+		}
+		k := classloader.MethAreaFetch(class)
+		if k == nil || k.Data == nil {
+			return "", fmt.Errorf("selectMainMethod: class %s not found", class)
+		}
+
+		m := findDeclaredMethod(k, "main", methType)
+		if m != nil && m.AccessFlags&0x0002 == 0 && // not ACC_PRIVATE
+			m.AccessFlags&0x0400 == 0 && // nor ACC_ABSTRACT
+			(m.AccessFlags&0x0008 != 0) == isStatic { // ACC_STATIC
+			if !isStatic || class == className || m.AccessFlags&0x0001 != 0 { // ACC_PUBLIC
+				return class, nil
+			}
+		}
+		class = k.Data.Superclass
+	}
+	return "", nil
+}
+
+// findDeclaredMethod returns the method declared by the class itself (rather than
+// a superclass), or nil if it does not declare it
+func findDeclaredMethod(k *classloader.Klass, methName, methType string) *classloader.Method {
+	for i, m := range k.Data.Methods {
+		if k.Data.CP.Utf8Refs[m.Name] == methName && k.Data.CP.Utf8Refs[m.Desc] == methType {
+			return &k.Data.Methods[i]
+		}
+	}
+	return nil
+}
+
+// newMainInstance creates the instance of the main class on which an instance main()
+// is invoked, by running the class's zero-argument constructor, which must not be
+// private. The top frame of fs, that of main(), serves as the constructor's caller.
+func newMainInstance(fs *list.List, className string) (*object.Object, error) {
+	k := classloader.MethAreaFetch(className)
+	if k == nil || k.Data == nil {
+		return nil, fmt.Errorf("newMainInstance: class %s not found", className)
+	}
+	ctor := findDeclaredMethod(k, "<init>", "()V")
+	if ctor == nil || ctor.AccessFlags&0x0002 != 0 { // ACC_PRIVATE
+		return nil, fmt.Errorf("non-private zero argument constructor not found in class %s",
+			strings.ReplaceAll(className, "/", "."))
+	}
+
+	obj, err := instantiateClass(className)
+	if err != nil {
+		return nil, err
+	}
+	mte, err := classloader.FetchMethodAndCP(className, "<init>", "()V")
+	if err != nil {
+		return nil, err
+	}
+	if _, err = runJavaMethod(fs, mte, className, "<init>", "()V", true, []interface{}{obj}); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// makeMainArgs creates the String[] passed to main() from the app args
+func makeMainArgs(appArgs []string) *object.Object {
+	args := object.Make1DimArray(object.REF, int64(len(appArgs)))
+	argsArray := args.Fields[0].Fvalue.(*[]*object.Object)
+	for i, arg := range appArgs {
+		(*argsArray)[i] = object.NewStringFromGoString(arg)
+	}
+	return args
+}
@@ -0,0 +1,104 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"jacobin/classloader"
+	"jacobin/globals"
+	"strings"
+	"testing"
+)
+
+const (
+	accPublic  = 0x0001
+	accPrivate = 0x0002
+	accStatic  = 0x0008
+)
+
+func TestSelectMainMethod(t *testing.T) {
+	setupInterfaceClasses()
+	ret := []byte{RETURN}
+	addTestClass("test/Classic", "java/lang/Object", nil,
+		testMethod{"main", "()V", accStatic, ret},
+		testMethod{"main", mainArgsType, accPublic | accStatic, ret})
+	addTestClass("test/NoArgs", "java/lang/Object", nil,
+		testMethod{"main", mainArgsType, 0, ret},
+		testMethod{"main", "()V", accStatic, ret})
+	addTestClass("test/Instance", "java/lang/Object", nil,
+		testMethod{"main", "()V", 0, ret},
+		testMethod{"main", mainArgsType, 0, ret})
+	addTestClass("test/Script", "java/lang/Object", nil,
+		testMethod{"main", "()V", 0, ret})
+	addTestClass("test/Base", "java/lang/Object", nil,
+		testMethod{"main", mainArgsType, accStatic, ret}, // not public, so not inherited
+		testMethod{"main", "()V", 0, ret})
+	addTestClass("test/Derived", "test/Base", nil)
+
+	tests := []struct {
+		class    string
+		expected mainMethod
+	}{
+		{"test/Classic", mainMethod{"test/Classic", mainArgsType, true}},
+		{"test/NoArgs", mainMethod{"test/NoArgs", "()V", true}},
+		{"test/Instance", mainMethod{"test/Instance", mainArgsType, false}},
+		{"test/Script", mainMethod{"test/Script", "()V", false}},
+		{"test/Derived", mainMethod{"test/Base", "()V", false}},
+	}
+	for _, test := range tests {
+		mainMeth, err := selectMainMethod(test.class)
+		if err != nil {
+			t.Errorf("%s: Unexpected error: %s", test.class, err.Error())
+		} else if mainMeth != test.expected {
+			t.Errorf("%s: Expected %v, got: %v", test.class, test.expected, mainMeth)
+		}
+	}
+}
+
+func TestSelectMainMethodNotFound(t *testing.T) {
+	setupInterfaceClasses()
+	addTestClass("test/Hidden", "java/lang/Object", nil,
+		testMethod{"main", mainArgsType, accPrivate | accStatic, []byte{RETURN}},
+		testMethod{"main", "(I)V", accPublic | accStatic, []byte{RETURN}})
+
+	_, err := selectMainMethod("test/Hidden")
+	if err == nil || !strings.HasPrefix(err.Error(), "Main method not found in class test.Hidden") {
+		t.Errorf("Expected a main method not found error, got: %v", err)
+	}
+}
+
+// an instance main() is invoked on an instance of the main class
+func TestStartExecInstanceMain(t *testing.T) {
+	setupInterfaceClasses()
+	classloader.Statics = make(map[string]classloader.Static)
+	b := newCPBuilder()
+	ran := b.fieldRef("test/Script", "ran", "I")
+	addClass("test/Script", "java/lang/Object", nil, b, []testField{{"ran", "I", true}},
+		testMethod{"<init>", "()V", 0, []byte{RETURN}},
+		testMethod{"main", "()V", 0, []byte{
+			ALOAD_0, IFNULL, 0x00, 0x07, // skip the PUTSTATIC if there is no this
+			ICONST_1, PUTSTATIC, 0x00, byte(ran),
+			RETURN}})
+
+	if err := StartExec("test/Script", globals.GetGlobalRef()); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if s, ok := classloader.Statics["test/Script.ran"]; !ok || s.Value != int64(1) {
+		t.Errorf("Expected main() to run on an instance of test/Script, got: %v", s.Value)
+	}
+}
+
+func TestInstanceMainWithPrivateConstructor(t *testing.T) {
+	setupInterfaceClasses()
+	addTestClass("test/Singleton", "java/lang/Object", nil,
+		testMethod{"<init>", "()V", accPrivate, []byte{RETURN}},
+		testMethod{"main", "()V", 0, []byte{RETURN}})
+
+	err := StartExec("test/Singleton", globals.GetGlobalRef())
+	if err == nil || !strings.Contains(err.Error(), "non-private zero argument constructor not found") {
+		t.Errorf("Expected an error for a private constructor, got: %v", err)
+	}
+}
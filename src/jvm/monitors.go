@@ -0,0 +1,86 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"container/list"
+	"jacobin/classloader"
+	"jacobin/exceptions"
+	"jacobin/frames"
+	"jacobin/object"
+	"jacobin/thread"
+	"sync"
+)
+
+// Object monitors are locked by MONITORENTER and MONITOREXIT, which are generated
+// for synchronized blocks, and by synchronized methods, whose frames hold the monitor
+// while they run. The monitors themselves are in the object package (see monitor.go).
+
+// the message of the IllegalMonitorStateException thrown when a thread releases a
+// monitor it doesn't hold, as in the JDK
+const notOwnerMsg = "current thread is not owner"
+
+var classMonitorName = "java/lang/Class"
+
+// classMonitors holds the objects whose monitors the static synchronized methods of
+// each class lock. Since Jacobin does not yet have an instance of java.lang.Class for
+// each class, each class gets an object for this. The classes are identified by their
+// CPs, so a static method that's invoked through a subclass locks the class that
+// declares it.
+var classMonitors sync.Map // *classloader.CPool -> *object.Object
+
+// methodMonitor returns the object whose monitor a synchronized method holds: the
+// object the method is invoked on or, for static methods, the method's class
+func methodMonitor(m *classloader.JmEntry, hasThis bool, locals []interface{}) *object.Object {
+	if hasThis {
+		if obj, ok := locals[0].(*object.Object); ok && obj != nil {
+			return obj
+		}
+	}
+	if mon, ok := classMonitors.Load(m.Cp); ok {
+		return mon.(*object.Object)
+	}
+	obj := object.MakeEmptyObject()
+	obj.Klass = &classMonitorName
+	mon, _ := classMonitors.LoadOrStore(m.Cp, obj)
+	return mon.(*object.Object)
+}
+
+// enterMonitor locks the object's monitor for the thread of the frame stack. If
+// another thread holds it, the thread is shown as blocked on the monitor (e.g., in
+// thread dumps) while it waits for it.
+func enterMonitor(fs *list.List, obj *object.Object) {
+	threadID := fs.Front().Value.(*frames.Frame).Thread
+	if obj.MonitorTryEnter(threadID) {
+		return
+	}
+	exec := currentExecThread(fs)
+	setWaitState(fs, exec, thread.Blocked, obj)
+	obj.MonitorEnter(threadID)
+	exec.SetWaitState(thread.Runnable, nil)
+}
+
+// removeLock removes the last entry for obj from the objects whose monitors the
+// frame has locked with MONITORENTER
+func removeLock(f *frames.Frame, obj *object.Object) {
+	for i := len(f.Locks) - 1; i >= 0; i-- {
+		if f.Locks[i] == obj {
+			f.Locks = append(f.Locks[:i:i], f.Locks[i+1:]...)
+			return
+		}
+	}
+}
+
+// exitMethodMonitor is deferred by runFrame() for synchronized methods. It releases
+// the monitor when the method returns or throws an exception. If the method no longer
+// holds the monitor, which it must have released with MONITOREXIT, the method throws
+// an IllegalMonitorStateException, unless it's already throwing an exception.
+func exitMethodMonitor(fs *list.List, f *frames.Frame, err *error) {
+	if !f.Monitor.MonitorExit(f.Thread) && *err == nil {
+		*err = newVMThrowable(fs, exceptions.IllegalMonitorStateException, notOwnerMsg)
+	}
+}
@@ -0,0 +1,155 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"jacobin/classloader"
+	"jacobin/frames"
+	"jacobin/globals"
+	"jacobin/log"
+	"jacobin/object"
+	"sync"
+	"testing"
+)
+
+// runs the code in a frame whose local 0 holds the object. If synchronized is true,
+// the frame is that of a synchronized method of the object.
+func runMonitorTestCode(obj *object.Object, synchronized bool, code ...byte) error {
+	globals.InitGlobals("test")
+	log.Init()
+
+	f := frames.CreateFrame(2)
+	f.ClName = "test/Monitors"
+	f.MethName = "run"
+	f.Meth = code
+	f.Locals = []interface{}{obj}
+	if synchronized {
+		f.Monitor = obj
+	}
+	fs := frames.CreateFrameStack()
+	fs.PushFront(f)
+	return runFrame(fs)
+}
+
+// MONITORENTER and MONITOREXIT: the monitor is reentrant
+func TestMonitorEnterAndExit(t *testing.T) {
+	obj := object.MakeEmptyObject()
+	err := runMonitorTestCode(obj, false,
+		ALOAD_0, MONITORENTER, ALOAD_0, MONITORENTER, ALOAD_0, MONITOREXIT, ALOAD_0, MONITOREXIT, RETURN)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if obj.HoldsMonitor(0) {
+		t.Error("Expected the monitor to be released after two entries and two exits")
+	}
+
+	err = runMonitorTestCode(obj, false, ALOAD_0, MONITORENTER, RETURN)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !obj.HoldsMonitor(0) || obj.HoldsMonitor(1) {
+		t.Error("Expected the monitor to be held by thread 0 after MONITORENTER")
+	}
+}
+
+// MONITOREXIT: a thread that doesn't hold the monitor can't release it
+func TestMonitorExitNotOwner(t *testing.T) {
+	err := runMonitorTestCode(object.MakeEmptyObject(), false, ALOAD_0, MONITOREXIT, RETURN)
+	jt, ok := err.(*javaThrowable)
+	if !ok || jt.className != "java/lang/IllegalMonitorStateException" || jt.msg != notOwnerMsg {
+		t.Errorf("Expected an IllegalMonitorStateException, got: %v", err)
+	}
+}
+
+// MONITORENTER: null has no monitor
+func TestMonitorEnterNull(t *testing.T) {
+	err := runMonitorTestCode(nil, false, ALOAD_0, MONITORENTER, RETURN)
+	jt, ok := err.(*javaThrowable)
+	if !ok || jt.className != "java/lang/NullPointerException" {
+		t.Errorf("Expected a NullPointerException, got: %v", err)
+	}
+}
+
+// A synchronized method holds the monitor while it runs, and releases it when it
+// returns. If the method releases the monitor itself, that's an IllegalMonitorStateException.
+func TestSynchronizedMethod(t *testing.T) {
+	obj := object.MakeEmptyObject()
+	if err := runMonitorTestCode(obj, true, RETURN); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if obj.HoldsMonitor(0) {
+		t.Error("Expected the monitor to be released when the synchronized method returns")
+	}
+
+	err := runMonitorTestCode(obj, true, ALOAD_0, MONITOREXIT, RETURN)
+	jt, ok := err.(*javaThrowable)
+	if !ok || jt.className != "java/lang/IllegalMonitorStateException" {
+		t.Errorf("Expected an IllegalMonitorStateException, got: %v", err)
+	}
+
+	err = runMonitorTestCode(obj, true, ACONST_NULL, ATHROW)
+	if _, ok := err.(*javaThrowable); !ok {
+		t.Errorf("Expected an exception, got: %v", err)
+	}
+	if obj.HoldsMonitor(0) {
+		t.Error("Expected the monitor to be released when the synchronized method throws an exception")
+	}
+}
+
+// Static synchronized methods lock an object for the class that declares them
+func TestMethodMonitor(t *testing.T) {
+	obj := object.MakeEmptyObject()
+	m := classloader.JmEntry{Cp: &classloader.CPool{}}
+	if methodMonitor(&m, true, []interface{}{obj}) != obj {
+		t.Error("Expected an instance method to lock the object it's invoked on")
+	}
+
+	classMon := methodMonitor(&m, false, []interface{}{int64(0)})
+	if classMon == nil || methodMonitor(&m, false, nil) != classMon {
+		t.Error("Expected the static methods of a class to lock the same object")
+	}
+	other := classloader.JmEntry{Cp: &classloader.CPool{}}
+	if methodMonitor(&other, false, nil) == classMon {
+		t.Error("Expected the static methods of another class to lock another object")
+	}
+}
+
+// The monitor excludes other threads, including when the lock is inflated and when
+// the holder enters it more times than a thin lock can count
+func TestMonitorExcludesOtherThreads(t *testing.T) {
+	obj := object.MakeEmptyObject()
+	counter := 0
+
+	var wg sync.WaitGroup
+	for id := 1; id <= 4; id++ {
+		wg.Add(1)
+		go func(threadID int) {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				depth := 1 + i%300
+				for d := 0; d < depth; d++ {
+					obj.MonitorEnter(threadID)
+				}
+				counter++
+				for d := 0; d < depth; d++ {
+					if !obj.MonitorExit(threadID) {
+						t.Errorf("Thread %d could not exit the monitor", threadID)
+						return
+					}
+				}
+			}
+		}(id)
+	}
+	wg.Wait()
+
+	if counter != 2000 {
+		t.Errorf("Expected the counter to be 2000, got: %d", counter)
+	}
+	if obj.MonitorExit(1) {
+		t.Error("Expected MonitorExit() to fail once the monitor is released")
+	}
+}
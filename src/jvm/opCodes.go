@@ -6,6 +6,8 @@
 
 package jvm
 
+import "encoding/binary"
+
 const AALOAD = 0x32
 const AASTORE = 0x53
 const ACONST_NULL = 0x01
@@ -417,3 +419,63 @@ var BytecodeNames = []string{
 	"JSR_W",           // 0xC9
 	"BREAKPOINT",      // 0xCA
 }
+
+// instructionLength returns the length in bytes of the instruction at code[pc],
+// including its operands. It returns 0 if the opcode is not a valid one or the
+// instruction runs past the end of the code.
+func instructionLength(code []byte, pc int) int {
+	if pc < 0 || pc >= len(code) {
+		return 0
+	}
+
+	length := 1
+	switch opcode := code[pc]; {
+	case opcode == BIPUSH || opcode == LDC || opcode == RET || opcode == NEWARRAY,
+		opcode >= ILOAD && opcode <= ALOAD, opcode >= ISTORE && opcode <= ASTORE:
+		length = 2
+	case opcode == SIPUSH || opcode == LDC_W || opcode == LDC2_W || opcode == IINC,
+		opcode >= IFEQ && opcode <= JSR, opcode >= GETSTATIC && opcode <= INVOKESTATIC,
+		opcode == NEW || opcode == ANEWARRAY || opcode == CHECKCAST || opcode == INSTANCEOF,
+		opcode == IFNULL || opcode == IFNONNULL:
+		length = 3
+	case opcode == MULTIANEWARRAY:
+		length = 4
+	case opcode == INVOKEINTERFACE || opcode == INVOKEDYNAMIC || opcode == GOTO_W || opcode == JSR_W:
+		length = 5
+	case opcode == WIDE:
+		length = 4
+		if pc+1 < len(code) && code[pc+1] == IINC {
+			length = 6
+		}
+	case opcode == TABLESWITCH || opcode == LOOKUPSWITCH:
+		pad := (4 - (pc+1)%4) % 4 // the operands start on a 4-byte boundary
+		base := pc + 1 + pad
+		if base+8 > len(code) {
+			return 0
+		}
+		if opcode == TABLESWITCH {
+			if base+12 > len(code) {
+				return 0
+			}
+			low := int32(binary.BigEndian.Uint32(code[base+4:]))
+			high := int32(binary.BigEndian.Uint32(code[base+8:]))
+			if high < low {
+				return 0
+			}
+			length = 1 + pad + 12 + 4*(int(high)-int(low)+1)
+		} else {
+			pairs := int32(binary.BigEndian.Uint32(code[base+4:]))
+			if pairs < 0 {
+				return 0
+			}
+			length = 1 + pad + 8 + 8*int(pairs)
+		}
+	case opcode > JSR_W:
+		return 0
+	}
+
+	if pc+length > len(code) {
+		return 0
+	}
+	return length
+}
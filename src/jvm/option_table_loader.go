@@ -12,7 +12,11 @@ import (
 	"jacobin/execdata"
 	"jacobin/globals"
 	"jacobin/log"
+	"jacobin/shutdown"
+	"math"
 	"os"
+	"strconv"
+	"strings"
 )
 
 // This set of routines loads the Global.Options table with the various
@@ -66,6 +70,17 @@ func LoadOptionsTable(Global globals.Globals) {
 	Global.Options["-client"] = client
 	client.Set = true
 
+	classpath := globals.Option{true, false, 4, getClasspath}
+	Global.Options["-cp"] = classpath
+	Global.Options["-classpath"] = classpath
+	Global.Options["--class-path"] = classpath
+
+	disableArgFiles := globals.Option{true, false, 0, disableArgFiles}
+	Global.Options["--disable-@files"] = disableArgFiles
+
+	defineProperty := globals.Option{true, false, 0, defineProperty}
+	Global.Options["-D"] = defineProperty
+
 	dryRun := globals.Option{false, false, 0, notSupported}
 	Global.Options["--dry-run"] = dryRun
 	dryRun.Set = true
@@ -81,16 +96,26 @@ func LoadOptionsTable(Global globals.Globals) {
 	helpp := globals.Option{true, false, 0, showHelpStdoutAndExit}
 	Global.Options["--help"] = helpp
 
+	javap := globals.Option{true, false, 0, javapMode}
+	Global.Options["-javap"] = javap
+
 	jarFile := globals.Option{true, false, 4, getJarFilename}
 	Global.Options["-jar"] = jarFile
 	jarFile.Set = true
 
+	module := globals.Option{true, false, 4, getMainModule}
+	Global.Options["-m"] = module
+	Global.Options["--module"] = module
+
 	showversion := globals.Option{true, false, 0, showVersionStderr}
 	Global.Options["-showversion"] = showversion
 
 	show_Version := globals.Option{true, false, 0, showVersionStdout}
 	Global.Options["--show-version"] = show_Version
 
+	source := globals.Option{true, false, 4, getSourceVersion}
+	Global.Options["--source"] = source
+
 	strictJdk := globals.Option{true, false, 0, strictJDK}
 	Global.Options["-strictJDK"] = strictJdk
 
@@ -100,6 +125,12 @@ func LoadOptionsTable(Global globals.Globals) {
 	verboseClass := globals.Option{true, false, 1, verbosityLevel}
 	Global.Options["-verbose"] = verboseClass
 
+	verify := globals.Option{true, false, 1, verifyLevel}
+	Global.Options["-Xverify"] = verify
+
+	stackSize := globals.Option{true, false, 0, threadStackSize}
+	Global.Options["-Xss"] = stackSize
+
 	version := globals.Option{true, false, 1, versionStderrThenExit}
 	Global.Options["-version"] = version
 
@@ -117,12 +148,34 @@ func clientVM(pos int, name string, gl *globals.Globals) (int, error) {
 	return pos, nil
 }
 
-// for -jar option. Get the next arg, which must be the JAR filename, and then all remaining args
+// for the -cp, -classpath, and --class-path options. Get the next arg (or, for
+// --class-path=, the embedded value), which is the classpath. It replaces the
+// default classpath taken from the CLASSPATH environment variable.
+func getClasspath(pos int, argValue string, gl *globals.Globals) (int, error) {
+	name := gl.Args[pos]
+	if argValue == "" {
+		if len(gl.Args) <= pos+1 {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: %s requires class path specification\n", name)
+			return pos, os.ErrInvalid
+		}
+		pos++
+		argValue = gl.Args[pos]
+	}
+	gl.Classpath = globals.ExpandClasspath(argValue)
+	globals.SetProperty("java.class.path", strings.Join(gl.Classpath, string(os.PathListSeparator)))
+	log.Log("Classpath set to: "+strings.Join(gl.Classpath, string(os.PathListSeparator)), log.FINE)
+	setOptionToSeen("-cp", gl)
+	return pos, nil
+}
+
+// for -jar option. The JAR file is the classpath. Get the next arg, which must be the JAR filename, and then all remaining args
 // are app args, which are duly added to Global.appArgs
 func getJarFilename(pos int, name string, gl *globals.Globals) (int, error) {
 	setOptionToSeen("-jar", gl)
 	if len(gl.Args) > pos+1 {
 		gl.StartingJar = gl.Args[pos+1]
+		gl.Classpath = []string{gl.StartingJar} // as in the JDK, -jar overrides any classpath
+		globals.SetProperty("java.class.path", gl.StartingJar)
 		log.Log("Starting with JAR file: "+gl.StartingJar, log.FINE)
 		for i := pos + 2; i < len(gl.Args); i++ {
 			gl.AppArgs = append(gl.AppArgs, gl.Args[i])
@@ -133,6 +186,89 @@ func getJarFilename(pos int, name string, gl *globals.Globals) (int, error) {
 	}
 }
 
+// for -javap, which disassembles class files rather than running a program. Its
+// own options (-c, -l, -p, and -v, as in the JDK's javap) come next, and then
+// the class files.
+func javapMode(pos int, argValue string, gl *globals.Globals) (int, error) {
+	pos++
+	for ; pos < len(gl.Args) && strings.HasPrefix(gl.Args[pos], "-"); pos++ {
+		switch gl.Args[pos] {
+		case "-c":
+			gl.JavapCode = true
+		case "-l":
+			gl.JavapLines = true
+		case "-p", "-private":
+			gl.JavapPrivate = true
+		case "-v", "-verbose":
+			gl.JavapVerbose = true
+		default:
+			_, _ = fmt.Fprintf(os.Stderr, "Error: %s is not a valid -javap option\n", gl.Args[pos])
+			shutdown.Exit(shutdown.JVM_EXCEPTION)
+			return len(gl.Args), os.ErrInvalid // the remaining args are not to be run
+		}
+	}
+
+	gl.JavapClasses = append(gl.JavapClasses, gl.Args[pos:]...)
+	if len(gl.JavapClasses) == 0 {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: -javap requires one or more class files\n")
+		shutdown.Exit(shutdown.JVM_EXCEPTION)
+		return pos, os.ErrInvalid
+	}
+	setOptionToSeen("-javap", gl)
+	return len(gl.Args), nil
+}
+
+// for -Dkey=value, which sets the system property key to value. Without =value,
+// the property is set to an empty string.
+func defineProperty(pos int, argValue string, gl *globals.Globals) (int, error) {
+	key, value, _ := strings.Cut(argValue, "=")
+	if key == "" {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %s is not a valid property definition\n", gl.Args[pos])
+		return pos, os.ErrInvalid
+	}
+	globals.SetProperty(key, value)
+	setOptionToSeen("-D", gl)
+	return pos, nil
+}
+
+// --disable-@files stops the expansion of argfiles, which is done before the
+// options are processed (see expandArgFiles()), so here it's simply noted
+func disableArgFiles(pos int, name string, gl *globals.Globals) (int, error) {
+	setOptionToSeen("--disable-@files", gl)
+	return pos, nil
+}
+
+// for the -m and --module options. Get the next arg (or, for --module=, the embedded
+// value), which must be <module>/<mainclass>, and then all remaining args are app
+// args. Modules are not yet supported, so the main class is found on the classpath.
+func getMainModule(pos int, argValue string, gl *globals.Globals) (int, error) {
+	name := gl.Args[pos]
+	if argValue == "" {
+		if len(gl.Args) <= pos+1 {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: %s requires module name\n", name)
+			return pos, os.ErrInvalid
+		}
+		pos++
+		argValue = gl.Args[pos]
+	}
+
+	module, mainClass, found := strings.Cut(argValue, "/")
+	if !found || mainClass == "" {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: module %s does not have a ModuleMainClass attribute, "+
+			"use -m <module>/<main-class>\n", module)
+		return pos, os.ErrInvalid
+	}
+	gl.MainModule = module
+	gl.MainClassName = mainClass
+	log.Log("Starting with main class "+mainClass+" in module "+module, log.FINE)
+	setOptionToSeen("-m", gl)
+
+	for i := pos + 1; i < len(gl.Args); i++ {
+		gl.AppArgs = append(gl.AppArgs, gl.Args[i])
+	}
+	return len(gl.Args), nil
+}
+
 // generic notification function that an option is not supported
 func notSupported(pos int, arg string, gl *globals.Globals) (int, error) {
 	name := gl.Args[pos]
@@ -164,6 +300,22 @@ func showVersionStdout(pos int, name string, gl *globals.Globals) (int, error) {
 	return pos, nil
 }
 
+// for --source, which gives the Java version of the source file to run in source-file
+// mode. Get the next arg (or, for --source=, the embedded value), which is the version.
+func getSourceVersion(pos int, argValue string, gl *globals.Globals) (int, error) {
+	if argValue == "" {
+		if len(gl.Args) <= pos+1 {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: --source requires source version\n")
+			return pos, os.ErrInvalid
+		}
+		pos++
+		argValue = gl.Args[pos]
+	}
+	gl.SourceVersion = argValue
+	setOptionToSeen("--source", gl)
+	return pos, nil
+}
+
 func strictJDK(pos int, name string, gl *globals.Globals) (int, error) {
 	gl.StrictJDK = true
 	setOptionToSeen("-strictJDK", gl)
@@ -224,6 +376,61 @@ func verbosityLevel(pos int, argValue string, gl *globals.Globals) (int, error)
 	return pos, nil
 }
 
+// for -Xverify:none, -Xverify:remote, and -Xverify:all, which set which classes are
+// verified before they're used. The JDK's own classes are verified only with all.
+func verifyLevel(pos int, argValue string, gl *globals.Globals) (int, error) {
+	switch argValue {
+	case "none":
+		gl.VerifyLevel = globals.VerifyNone
+	case "remote":
+		gl.VerifyLevel = globals.VerifyRemote
+	case "all":
+		gl.VerifyLevel = globals.VerifyAll
+	default:
+		log.Log("Error: "+argValue+" is not a valid -Xverify option. Ignored.", log.WARNING)
+		return pos, errors.New("Invalid verification level specified: " + argValue)
+	}
+	setOptionToSeen("-Xverify", gl)
+	return pos, nil
+}
+
+// for -Xss<size>, which sets the size of the threads' stacks. As in the JDK, the size
+// is in bytes, or in kilobytes, megabytes, or gigabytes with a k, m, or g suffix. The
+// size limits the number of frames on a thread's stack (see globals.FrameStackSize).
+func threadStackSize(pos int, argValue string, gl *globals.Globals) (int, error) {
+	multiplier := int64(1)
+	digits := argValue
+	if len(argValue) > 0 {
+		switch argValue[len(argValue)-1] {
+		case 'k', 'K':
+			multiplier = 1024
+		case 'm', 'M':
+			multiplier = 1024 * 1024
+		case 'g', 'G':
+			multiplier = 1024 * 1024 * 1024
+		}
+		if multiplier > 1 {
+			digits = argValue[:len(argValue)-1]
+		}
+	}
+
+	size, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil || size <= 0 || size > math.MaxInt64/multiplier {
+		_, _ = fmt.Fprintf(os.Stderr, "Invalid thread stack size: -Xss%s\n", argValue)
+		shutdown.Exit(shutdown.JVM_EXCEPTION)
+		return pos, os.ErrInvalid
+	}
+	depth := size * multiplier / globals.FrameStackSize
+	if depth > maxFrameDepth {
+		depth = maxFrameDepth
+	} else if depth < 1 {
+		depth = 1
+	}
+	gl.MaxFrameDepth = int(depth)
+	setOptionToSeen("-Xss", gl)
+	return pos, nil
+}
+
 // Marks the given option as having been 'set' that is, specified on the command line
 func setOptionToSeen(optionKey string, gl *globals.Globals) {
 	o := gl.Options[optionKey]
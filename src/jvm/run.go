@@ -21,8 +21,10 @@ import (
 	"jacobin/types"
 	"jacobin/util"
 	"math"
+	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"unsafe"
 )
 
@@ -30,15 +32,25 @@ var MainThread thread.ExecThread
 
 // StartExec is where execution begins. It initializes various structures, such as
 // the MTable, then using the passed-in name of the starting class, finds its main() method
-// in the method area (it's guaranteed to already be loaded), grabs the executable
+// (see selectMainMethod() for the rules) in the method area (it's guaranteed to already be loaded), grabs the executable
 // bytes, creates a thread of execution, pushes the main() frame onto the JVM stack
 // and begins execution.
-func StartExec(className string, globals *globals.Globals) error {
+func StartExec(className string, gl *globals.Globals) error {
 	// initialize the MTable
 	classloader.MTable = make(map[string]classloader.MTentry)
 	classloader.MTableLoadNatives()
+	classloader.MTableLoadGoMethods(Load_Lang_Throwable())
+	classloader.MTableLoadGoMethods(Load_Lang_System())
+	classloader.MTableLoadGoMethods(Load_Lang_Thread())
+	classloader.MTableLoadGoMethods(Load_Lang_Object())
+	classloader.MTableLoadGoMethods(Load_Jdk_Internal_Misc_Unsafe())
 
-	me, err := classloader.FetchMethodAndCP(className, "main", "([Ljava/lang/String;)V")
+	mainMeth, err := selectMainMethod(className)
+	if err != nil {
+		_ = log.Log("Error: "+err.Error(), log.SEVERE)
+		return err
+	}
+	me, err := classloader.FetchMethodAndCP(mainMeth.className, "main", mainMeth.methType)
 	if err != nil {
 		return errors.New("Class not found: " + className + ".main()")
 	}
@@ -46,29 +58,37 @@ func StartExec(className string, globals *globals.Globals) error {
 	m := me.Meth.(classloader.JmEntry)
 	f := frames.CreateFrame(m.MaxStack) // create a new frame
 	f.MethName = "main"
-	f.ClName = className
+	f.ClName = mainMeth.className
 	f.CP = m.Cp                        // add its pointer to the class CP
+	f.ExcTable = m.Exceptions          // and its exception table
+	f.LineTable = m.LineTable          // and its source line numbers
 	for i := 0; i < len(m.Code); i++ { // copy the bytecodes over
 		f.Meth = append(f.Meth, m.Code[i])
 	}
 
-	// allocate the local variables
+	// allocate the local variables. The args of main(String[]) follow the
+	// instance of the main class, if main() is an instance method.
 	for k := 0; k < m.MaxLocals; k++ {
 		f.Locals = append(f.Locals, 0)
 	}
+	argsLocal := 0
+	if !mainMeth.isStatic {
+		argsLocal = 1
+	}
+	if mainMeth.methType == mainArgsType && argsLocal < len(f.Locals) {
+		f.Locals[argsLocal] = makeMainArgs(gl.AppArgs)
+	}
 
 	// create the first thread and place its first frame on it
 	MainThread = thread.CreateThread()
+	MainThread.Name = "main"
 	MainThread.Stack = frames.CreateFrameStack()
-	MainThread.ID = thread.AddThreadToTable(&MainThread, &globals.Threads)
+	MainThread.ID = thread.AddThreadToTable(&MainThread, &gl.Threads)
 
-	tracing := false
-	trace, exists := globals.Options["-trace"]
-	if exists {
-		tracing = trace.Set
-	}
-	MainThread.Trace = tracing
+	trace, exists := gl.Options["-trace"]
+	MainThread.Trace = exists && trace.Set
 	f.Thread = MainThread.ID
+	f.Trace = MainThread.Trace
 
 	if frames.PushFrame(MainThread.Stack, f) != nil {
 		_ = log.Log("Memory exceptions allocating frame on thread: "+strconv.Itoa(MainThread.ID),
@@ -76,17 +96,46 @@ func StartExec(className string, globals *globals.Globals) error {
 		return errors.New("outOfMemory Exception")
 	}
 
-	if MainThread.Trace {
+	if f.Trace {
 		traceInfo := fmt.Sprintf("StartExec: f.MethName=%s, m.MaxStack=%d, m.MaxLocals=%d, len(m.Code)=%d",
 			f.MethName, m.MaxStack, m.MaxLocals, len(m.Code))
 		_ = log.Log(traceInfo, log.TRACE_INST)
 	}
 
-	err = runThread(&MainThread)
-	if err != nil {
+	// as in the JDK, the main class is initialized before main() starts. So main()
+	// is not in the stack trace if the initialization fails.
+	if err = initializeClass(MainThread.Stack, className); err != nil {
+		if jt, ok := err.(*javaThrowable); ok {
+			jt.trace = []stackTraceElement{}
+			reportUncaughtException(jt, "main")
+		}
 		return err
 	}
-	return nil
+
+	if !mainMeth.isStatic {
+		obj, err := newMainInstance(MainThread.Stack, className)
+		if err != nil {
+			if jt, ok := err.(*javaThrowable); ok {
+				if len(jt.trace) > 0 {
+					jt.trace = jt.trace[:len(jt.trace)-1] // main() has not started yet
+				}
+				reportUncaughtException(jt, "main")
+			} else {
+				_ = log.Log("Error: "+err.Error(), log.SEVERE)
+			}
+			return err
+		}
+		f.Locals[0] = obj
+	}
+	if m.IsSynchronized() {
+		f.Monitor = methodMonitor(&m, !mainMeth.isStatic, f.Locals)
+	}
+
+	// as in the JDK, the JVM keeps running after main() returns (or throws an
+	// exception) until all the threads that are not daemon threads have finished
+	MainThread.Run(func() { err = runThread(&MainThread) })
+	thread.WaitForNonDaemonThreads()
+	return err
 }
 
 // Point the thread to the top of the frame stack and tell it to run from there.
@@ -94,6 +143,9 @@ func runThread(t *thread.ExecThread) error {
 	for t.Stack.Len() > 0 {
 		err := runFrame(t.Stack)
 		if err != nil {
+			if jt, ok := err.(*javaThrowable); ok {
+				reportUncaughtException(jt, threadName(t))
+			}
 			return err
 		}
 
@@ -108,17 +160,25 @@ func runThread(t *thread.ExecThread) error {
 // golang function in the present frame. If it is a golang function, it's sent to
 // a different function for execution. Otherwise, bytecode interpretation takes
 // place through a giant switch statement.
-func runFrame(fs *list.List) error {
+func runFrame(fs *list.List) (err error) {
 	// the current frame is always the head of the linked list of frames.
 	// the next statement converts the address of that frame to the more readable 'f'
 	f := fs.Front().Value.(*frames.Frame)
+	if err := checkFrameDepth(fs); err != nil {
+		return err
+	}
+	defer recoverOperandStackError(fs, f, &err)
+	if f.Monitor != nil { // a synchronized method holds the monitor while it runs
+		enterMonitor(fs, f.Monitor)
+		defer exitMethodMonitor(fs, f, &err)
+	}
 
 	// if the frame contains a golang method, execute it using runGframe(),
 	// which returns a value (possibly nil) and an exceptions code. Presuming no exceptions,
 	// if the return value (here, retval) is not nil, it is placed on the stack
 	// of the calling frame.
 	if f.Ftype == 'G' {
-		retval, slotCount, err := runGframe(f)
+		retval, slotCount, err := runGframe(f, fs)
 
 		if retval != nil {
 			f = fs.Front().Next().Value.(*frames.Frame)
@@ -134,10 +194,13 @@ func runFrame(fs *list.List) error {
 	// the frame's method is not a golang method, so it's Java bytecode, which
 	// is interpreted in the rest of this function.
 	for f.PC < len(f.Meth) {
-		if MainThread.Trace {
+		if f.Trace {
 			traceInfo := emitTraceData(f)
 			_ = log.Log(traceInfo, log.TRACE_INST)
 		}
+		if atomic.LoadInt32(&stackRequests) > 0 { // a thread dump wants the frames
+			publishStack(fs)
+		}
 
 		switch f.Meth[f.PC] { // cases listed in numerical value of opcode
 		case NOP:
@@ -355,17 +418,21 @@ func runFrame(fs *list.List) error {
 			index := pop(f).(int64)
 			iAref := pop(f).(*object.Object) // ptr to array object
 			if iAref == object.Null {
-				exceptions.Throw(exceptions.NullPointerException,
-					"IALOAD: Invalid (null) reference to an array")
-				return errors.New("IALOAD error")
+				errMsg := "Cannot load from " + arrayKinds[f.Meth[f.PC]] + " array"
+				if err := throwVMException(fs, exceptions.NullPointerException, errMsg); err != nil {
+					return err
+				}
+				continue // the exception was caught, so resume at the handler
 			}
 
 			array := *(iAref.Fields[0].Fvalue).(*[]int64)
 
-			if index >= int64(len(array)) {
-				exceptions.Throw(exceptions.ArrayIndexOutOfBoundsException,
-					"IALOAD: Invalid array subscript")
-				return errors.New("IALOAD error")
+			if index < 0 || index >= int64(len(array)) {
+				errMsg := fmt.Sprintf("Index %d out of bounds for length %d", index, len(array))
+				if err := throwVMException(fs, exceptions.ArrayIndexOutOfBoundsException, errMsg); err != nil {
+					return err
+				}
+				continue // the exception was caught, so resume at the handler
 			}
 			var value = array[index]
 			push(f, value)
@@ -373,16 +440,20 @@ func runFrame(fs *list.List) error {
 			index := pop(f).(int64)
 			iAref := pop(f).(*object.Object) // ptr to array object
 			if iAref == nil {
-				exceptions.Throw(exceptions.NullPointerException,
-					"LALOAD: Invalid (null) reference to an array")
-				return errors.New("LALOAD error")
+				errMsg := "Cannot load from long array"
+				if err := throwVMException(fs, exceptions.NullPointerException, errMsg); err != nil {
+					return err
+				}
+				continue // the exception was caught, so resume at the handler
 			}
 
 			array := *(iAref.Fields[0].Fvalue).(*[]int64)
-			if index >= int64(len(array)) {
-				exceptions.Throw(exceptions.ArrayIndexOutOfBoundsException,
-					"LALOAD: Invalid array subscript")
-				return errors.New("LALOAD error")
+			if index < 0 || index >= int64(len(array)) {
+				errMsg := fmt.Sprintf("Index %d out of bounds for length %d", index, len(array))
+				if err := throwVMException(fs, exceptions.ArrayIndexOutOfBoundsException, errMsg); err != nil {
+					return err
+				}
+				continue // the exception was caught, so resume at the handler
 			}
 			var value = array[index]
 			push(f, value)
@@ -393,17 +464,21 @@ func runFrame(fs *list.List) error {
 			ref := pop(f) // ptr to array object
 			// fAref := (*object.JacobinFloatArray)(ref)
 			if ref == nil || ref == object.Null {
-				exceptions.Throw(exceptions.NullPointerException,
-					"FALOAD: Invalid (null) reference to an array")
-				return errors.New("FALOAD error")
+				errMsg := "Cannot load from float array"
+				if err := throwVMException(fs, exceptions.NullPointerException, errMsg); err != nil {
+					return err
+				}
+				continue // the exception was caught, so resume at the handler
 			}
 
 			fAref := ref.(*object.Object)
 			array := *(fAref.Fields[0].Fvalue).(*[]float64)
-			if index >= int64(len(array)) {
-				exceptions.Throw(exceptions.ArrayIndexOutOfBoundsException,
-					"FALOAD: Invalid array subscript")
-				return errors.New("FALOAD error")
+			if index < 0 || index >= int64(len(array)) {
+				errMsg := fmt.Sprintf("Index %d out of bounds for length %d", index, len(array))
+				if err := throwVMException(fs, exceptions.ArrayIndexOutOfBoundsException, errMsg); err != nil {
+					return err
+				}
+				continue // the exception was caught, so resume at the handler
 			}
 			var value = array[index]
 			push(f, value)
@@ -412,16 +487,20 @@ func runFrame(fs *list.List) error {
 			index := pop(f).(int64)
 			fAref := pop(f).(*object.Object) // ptr to array object
 			if fAref == nil {
-				exceptions.Throw(exceptions.NullPointerException,
-					"DALOAD: Invalid (null) reference to an array")
-				return errors.New("DALOAD error")
+				errMsg := "Cannot load from double array"
+				if err := throwVMException(fs, exceptions.NullPointerException, errMsg); err != nil {
+					return err
+				}
+				continue // the exception was caught, so resume at the handler
 			}
 			array := *(fAref.Fields[0].Fvalue).(*[]float64)
 
-			if index >= int64(len(array)) {
-				exceptions.Throw(exceptions.ArrayIndexOutOfBoundsException,
-					"DALOAD: Invalid array subscript")
-				return errors.New("DALOAD error")
+			if index < 0 || index >= int64(len(array)) {
+				errMsg := fmt.Sprintf("Index %d out of bounds for length %d", index, len(array))
+				if err := throwVMException(fs, exceptions.ArrayIndexOutOfBoundsException, errMsg); err != nil {
+					return err
+				}
+				continue // the exception was caught, so resume at the handler
 			}
 			var value = array[index]
 			push(f, value)
@@ -429,18 +508,22 @@ func runFrame(fs *list.List) error {
 		case AALOAD: // 0x32    (push contents of a reference array element)
 			index := pop(f).(int64)
 			rAref := pop(f) // the array object. Can't be cast to *Object b/c might be nil
-			if rAref == nil {
-				errMsg := "AALOAD: Invalid (null) reference to an array"
-				exceptions.Throw(exceptions.NullPointerException, errMsg)
-				return errors.New(errMsg)
+			if rAref == nil || rAref == object.Null {
+				errMsg := "Cannot load from object array"
+				if err := throwVMException(fs, exceptions.NullPointerException, errMsg); err != nil {
+					return err
+				}
+				continue // the exception was caught, so resume at the handler
 			}
 
 			arrayPtr := (rAref.(*object.Object)).Fields[0].Fvalue.(*[]*object.Object)
 			size := int64(len(*arrayPtr))
-			if index >= size {
-				errMsg := "AALOAD: Invalid array subscript"
-				exceptions.Throw(exceptions.ArrayIndexOutOfBoundsException, errMsg)
-				return errors.New(errMsg)
+			if index < 0 || index >= size {
+				errMsg := fmt.Sprintf("Index %d out of bounds for length %d", index, size)
+				if err := throwVMException(fs, exceptions.ArrayIndexOutOfBoundsException, errMsg); err != nil {
+					return err
+				}
+				continue // the exception was caught, so resume at the handler
 			}
 			array := *(arrayPtr)
 			var value = array[index]
@@ -450,19 +533,23 @@ func runFrame(fs *list.List) error {
 			index := pop(f).(int64)
 			ref := pop(f) // the array object
 			if ref == nil || ref == object.Null {
-				exceptions.Throw(exceptions.NullPointerException,
-					"BALOAD: Invalid (null) reference to an array")
-				return errors.New("BALOAD error")
+				errMsg := "Cannot load from byte/boolean array"
+				if err := throwVMException(fs, exceptions.NullPointerException, errMsg); err != nil {
+					return err
+				}
+				continue // the exception was caught, so resume at the handler
 			}
 
 			bAref := ref.(*object.Object)
 			arrayPtr := bAref.Fields[0].Fvalue.(*[]byte)
 			size := int64(len(*arrayPtr))
 
-			if index >= size {
-				exceptions.Throw(exceptions.ArrayIndexOutOfBoundsException,
-					"BALOAD: Invalid array subscript")
-				return errors.New("BALOAD error")
+			if index < 0 || index >= size {
+				errMsg := fmt.Sprintf("Index %d out of bounds for length %d", index, size)
+				if err := throwVMException(fs, exceptions.ArrayIndexOutOfBoundsException, errMsg); err != nil {
+					return err
+				}
+				continue // the exception was caught, so resume at the handler
 			}
 			array := *(arrayPtr)
 			var value = array[index]
@@ -555,27 +642,31 @@ func runFrame(fs *list.List) error {
 			index := pop(f).(int64)
 			arrObj := pop(f).(*object.Object) // the array object
 			if arrObj == nil {
-				exceptions.Throw(exceptions.NullPointerException,
-					"IA/CA/SASTORE: Invalid (null) reference to an array")
-				return errors.New("IA/CA/SASTORE: Invalid array address")
+				errMsg := "Cannot store to " + arrayKinds[f.Meth[f.PC]] + " array"
+				if err := throwVMException(fs, exceptions.NullPointerException, errMsg); err != nil {
+					return err
+				}
+				continue // the exception was caught, so resume at the handler
 			}
 
 			if arrObj.Fields[0].Ftype != "[I" {
 				msg := fmt.Sprintf("IA/CA/SASTORE: field type expected=[I, observed=%s", arrObj.Fields[0].Ftype)
 				_ = log.Log(msg, log.SEVERE)
-				exceptions.Throw(exceptions.ArrayStoreException,
-					"IA/CA/SASTORE: Attempt to access array of incorrect type")
-				return errors.New("IA/CA/SASTORE: Invalid array type")
+				errMsg := "IA/CA/SASTORE: Attempt to access array of incorrect type"
+				if err := throwVMException(fs, exceptions.ArrayStoreException, errMsg); err != nil {
+					return err
+				}
+				continue // the exception was caught, so resume at the handler
 			}
 
 			array := *(arrObj.Fields[0].Fvalue).(*[]int64)
 			size := int64(len(array))
-			if index >= size {
-				msg := fmt.Sprintf("IA/CA/SASTORE: array size=%d but index=%d (too large)", size, index)
-				_ = log.Log(msg, log.SEVERE)
-				exceptions.Throw(exceptions.ArrayIndexOutOfBoundsException,
-					"IA/CA/SATORE: Invalid array subscript")
-				return errors.New("IA/CA/SASTORE: Invalid array index")
+			if index < 0 || index >= size {
+				errMsg := fmt.Sprintf("Index %d out of bounds for length %d", index, size)
+				if err := throwVMException(fs, exceptions.ArrayIndexOutOfBoundsException, errMsg); err != nil {
+					return err
+				}
+				continue // the exception was caught, so resume at the handler
 			}
 			array[index] = value
 
@@ -585,9 +676,11 @@ func runFrame(fs *list.List) error {
 			index := pop(f).(int64)
 			lAref := pop(f).(*object.Object) // ptr to array object
 			if lAref == nil {
-				exceptions.Throw(exceptions.NullPointerException,
-					"LASTORE: Invalid (null) reference to an array")
-				return errors.New("LASTORE: Invalid array reference")
+				errMsg := "Cannot store to long array"
+				if err := throwVMException(fs, exceptions.NullPointerException, errMsg); err != nil {
+					return err
+				}
+				continue // the exception was caught, so resume at the handler
 			}
 
 			arrType := lAref.Fields[0].Ftype
@@ -595,19 +688,21 @@ func runFrame(fs *list.List) error {
 			if arrType != "[I" {
 				msg := fmt.Sprintf("LASTORE: field type expected=[I, observed=%s", arrType)
 				_ = log.Log(msg, log.SEVERE)
-				exceptions.Throw(exceptions.ArrayStoreException,
-					"LASTORE: Attempt to access array of incorrect type")
-				return errors.New("LASTORE: Invalid array type")
+				errMsg := "LASTORE: Attempt to access array of incorrect type"
+				if err := throwVMException(fs, exceptions.ArrayStoreException, errMsg); err != nil {
+					return err
+				}
+				continue // the exception was caught, so resume at the handler
 			}
 
 			array := *(lAref.Fields[0].Fvalue).(*[]int64)
 			size := int64(len(array))
-			if index >= size {
-				msg := fmt.Sprintf("LASTORE: array size=%d but index=%d (too large)", size, index)
-				_ = log.Log(msg, log.SEVERE)
-				exceptions.Throw(exceptions.ArrayIndexOutOfBoundsException,
-					"LASTORE: Invalid array subscript")
-				return errors.New("LASTORE: Invalid array index")
+			if index < 0 || index >= size {
+				errMsg := fmt.Sprintf("Index %d out of bounds for length %d", index, size)
+				if err := throwVMException(fs, exceptions.ArrayIndexOutOfBoundsException, errMsg); err != nil {
+					return err
+				}
+				continue // the exception was caught, so resume at the handler
 			}
 			array[index] = value
 
@@ -616,27 +711,31 @@ func runFrame(fs *list.List) error {
 			index := pop(f).(int64)
 			fAref := pop(f).(*object.Object) // ptr to array object
 			if fAref == nil {
-				exceptions.Throw(exceptions.NullPointerException,
-					"FASTORE: Invalid (null) reference to an array")
-				return errors.New("FASTORE: Invalid array address")
+				errMsg := "Cannot store to float array"
+				if err := throwVMException(fs, exceptions.NullPointerException, errMsg); err != nil {
+					return err
+				}
+				continue // the exception was caught, so resume at the handler
 			}
 
 			if fAref.Fields[0].Ftype != "[F" {
 				msg := fmt.Sprintf("FASTORE: field type expected=[F, observed=%s", fAref.Fields[0].Ftype)
 				_ = log.Log(msg, log.SEVERE)
-				exceptions.Throw(exceptions.ArrayStoreException,
-					"FASTORE: Attempt to access array of incorrect type")
-				return errors.New("FASTORE: Invalid array type")
+				errMsg := "FASTORE: Attempt to access array of incorrect type"
+				if err := throwVMException(fs, exceptions.ArrayStoreException, errMsg); err != nil {
+					return err
+				}
+				continue // the exception was caught, so resume at the handler
 			}
 
 			array := *(fAref.Fields[0].Fvalue).(*[]float64)
 			size := int64(len(array))
-			if index >= size {
-				msg := fmt.Sprintf("FASTORE: array size=%d but index=%d (too large)", size, index)
-				_ = log.Log(msg, log.SEVERE)
-				exceptions.Throw(exceptions.ArrayIndexOutOfBoundsException,
-					"FASTORE: Invalid array subscript")
-				return errors.New("FASTORE: Invalid array index")
+			if index < 0 || index >= size {
+				errMsg := fmt.Sprintf("Index %d out of bounds for length %d", index, size)
+				if err := throwVMException(fs, exceptions.ArrayIndexOutOfBoundsException, errMsg); err != nil {
+					return err
+				}
+				continue // the exception was caught, so resume at the handler
 			}
 			array[index] = value
 
@@ -646,27 +745,31 @@ func runFrame(fs *list.List) error {
 			index := pop(f).(int64)
 			dAref := pop(f).(*object.Object)
 			if dAref == nil {
-				exceptions.Throw(exceptions.NullPointerException,
-					"DASTORE: Invalid (null) reference to an array")
-				return errors.New("DASTORE: Invalid array reference")
+				errMsg := "Cannot store to double array"
+				if err := throwVMException(fs, exceptions.NullPointerException, errMsg); err != nil {
+					return err
+				}
+				continue // the exception was caught, so resume at the handler
 			}
 
 			if dAref.Fields[0].Ftype != "[F" {
 				msg := fmt.Sprintf("DASTORE: field type expected=[F, observed=%s", dAref.Fields[0].Ftype)
 				_ = log.Log(msg, log.SEVERE)
-				exceptions.Throw(exceptions.ArrayStoreException,
-					"DASTORE: Attempt to access array of incorrect type")
-				return errors.New("DASTORE: Invalid array type")
+				errMsg := "DASTORE: Attempt to access array of incorrect type"
+				if err := throwVMException(fs, exceptions.ArrayStoreException, errMsg); err != nil {
+					return err
+				}
+				continue // the exception was caught, so resume at the handler
 			}
 
 			array := *(dAref.Fields[0].Fvalue).(*[]float64)
 			size := int64(len(array))
-			if index >= size {
-				msg := fmt.Sprintf("DASTORE: array size=%d but index=%d (too large)", size, index)
-				_ = log.Log(msg, log.SEVERE)
-				exceptions.Throw(exceptions.ArrayIndexOutOfBoundsException,
-					"DASTORE: Invalid array subscript")
-				return errors.New("DASTORE: Invalid array index")
+			if index < 0 || index >= size {
+				errMsg := fmt.Sprintf("Index %d out of bounds for length %d", index, size)
+				if err := throwVMException(fs, exceptions.ArrayIndexOutOfBoundsException, errMsg); err != nil {
+					return err
+				}
+				continue // the exception was caught, so resume at the handler
 			}
 
 			array[index] = value
@@ -677,28 +780,32 @@ func runFrame(fs *list.List) error {
 			ptrObj := pop(f).(*object.Object) // ptr to the array object
 
 			if ptrObj == nil {
-				exceptions.Throw(exceptions.NullPointerException,
-					"AASTORE: Invalid (null) reference to an array")
-				return errors.New("AASTORE: Invalid array address")
+				errMsg := "Cannot store to object array"
+				if err := throwVMException(fs, exceptions.NullPointerException, errMsg); err != nil {
+					return err
+				}
+				continue // the exception was caught, so resume at the handler
 			}
 
 			if ptrObj.Fields[0].Ftype != "[L" {
 				msg := fmt.Sprintf("AASTORE: field type expected=[L, observed=%s", ptrObj.Fields[0].Ftype)
 				_ = log.Log(msg, log.SEVERE)
-				exceptions.Throw(exceptions.ArrayStoreException,
-					"AASTORE: Attempt to access array of incorrect type")
-				return errors.New("AASTORE: Invalid array type")
+				errMsg := "AASTORE: Attempt to access array of incorrect type"
+				if err := throwVMException(fs, exceptions.ArrayStoreException, errMsg); err != nil {
+					return err
+				}
+				continue // the exception was caught, so resume at the handler
 			}
 
 			// get pointer to the actual array
 			arrayPtr := ptrObj.Fields[0].Fvalue.(*[]*object.Object)
 			size := int64(len(*arrayPtr))
-			if index >= size {
-				msg := fmt.Sprintf("AASTORE: array size=%d but index=%d (too large)", size, index)
-				_ = log.Log(msg, log.SEVERE)
-				exceptions.Throw(exceptions.ArrayIndexOutOfBoundsException,
-					"AASTORE: Invalid array subscript")
-				return errors.New("AASTORE: Invalid array index")
+			if index < 0 || index >= size {
+				errMsg := fmt.Sprintf("Index %d out of bounds for length %d", index, size)
+				if err := throwVMException(fs, exceptions.ArrayIndexOutOfBoundsException, errMsg); err != nil {
+					return err
+				}
+				continue // the exception was caught, so resume at the handler
 			}
 
 			array := *arrayPtr
@@ -711,53 +818,47 @@ func runFrame(fs *list.List) error {
 			index := pop(f).(int64)
 			ptrObj := pop(f).(*object.Object) // ptr to array object
 			if ptrObj == nil {
-				exceptions.Throw(exceptions.NullPointerException,
-					"BASTORE: Invalid (null) reference to an array")
-				return errors.New("BASTORE: Invalid array address")
+				errMsg := "Cannot store to byte/boolean array"
+				if err := throwVMException(fs, exceptions.NullPointerException, errMsg); err != nil {
+					return err
+				}
+				continue // the exception was caught, so resume at the handler
 			}
 
 			if ptrObj.Fields[0].Ftype != "[B" {
 				msg := fmt.Sprintf("BASTORE: field type expected=[B, observed=%s", ptrObj.Fields[0].Ftype)
 				_ = log.Log(msg, log.SEVERE)
-				exceptions.Throw(exceptions.ArrayStoreException,
-					"BASTORE: Attempt to access array of incorrect type")
-				return errors.New("BASTORE: Invalid array type")
+				errMsg := "BASTORE: Attempt to access array of incorrect type"
+				if err := throwVMException(fs, exceptions.ArrayStoreException, errMsg); err != nil {
+					return err
+				}
+				continue // the exception was caught, so resume at the handler
 			}
 
 			// array := *(ptrObj.Fields[0].Fvalue.(*[]types.JavaByte)) // changed w/ JACOBIN-282
 			array := *(ptrObj.Fields[0].Fvalue.(*[]byte))
 			size := int64(len(array))
-			if index >= size {
-				msg := fmt.Sprintf("BASTORE: array size=%d but index=%d (too large)", size, index)
-				_ = log.Log(msg, log.SEVERE)
-				exceptions.Throw(exceptions.ArrayIndexOutOfBoundsException,
-					"BASTORE: Invalid array subscript")
-				return errors.New("BASTORE: Invalid array index")
+			if index < 0 || index >= size {
+				errMsg := fmt.Sprintf("Index %d out of bounds for length %d", index, size)
+				if err := throwVMException(fs, exceptions.ArrayIndexOutOfBoundsException, errMsg); err != nil {
+					return err
+				}
+				continue // the exception was caught, so resume at the handler
 			}
 
 			array[index] = value
 
 		case POP: // 0x57 	(pop an item off the stack and discard it)
-			if MainThread.Trace { // if tracing, don't show the pop in the trace b/c
-				// it's already present from this instruction being traced.
-				// Without this step, POP would appear twice in the trace listing,
-				// while only one actual pop action took place.
-				MainThread.Trace = false
-				pop(f)
-				MainThread.Trace = true
-			} else {
-				pop(f)
-			}
+			// pop() is not used, so the pop isn't shown in the trace: it's already
+			// present from this instruction being traced. Otherwise, POP would appear
+			// twice in the trace listing, while only one actual pop action took place.
+			checkPop(f)
+			f.TOS -= 1
 		case POP2: // 0x58	(pop 2 itmes from stack and discard them)
-			if MainThread.Trace { // see POP for why we turn of tracing
-				MainThread.Trace = false
-				pop(f)
-				pop(f)
-				MainThread.Trace = true
-			} else {
-				pop(f)
-				pop(f)
-			}
+			checkPop(f) // see POP for why pop() is not used
+			f.TOS -= 1
+			checkPop(f)
+			f.TOS -= 1
 		case DUP: // 0x59 			(push an item equal to the current top of the stack
 			tosItem := peek(f)
 			push(f, tosItem)
@@ -887,9 +988,10 @@ func runFrame(fs *list.List) error {
 		case IDIV: //  0x6C (integer divide tos-1 by tos)
 			val1 := pop(f).(int64)
 			if val1 == 0 {
-				exceptions.Throw(exceptions.ArithmeticException, ""+
-					"IDIV: Arithmetic Exception: divide by zero")
-				return errors.New("IDIV: Arithmetic Exception: divide by zero")
+				if err := throwVMException(fs, exceptions.ArithmeticException, "/ by zero"); err != nil {
+					return err
+				}
+				continue // the exception was caught, so resume at the handler
 			} else {
 				val2 := pop(f).(int64)
 				push(f, val2/val1)
@@ -898,9 +1000,10 @@ func runFrame(fs *list.List) error {
 			val2 := pop(f).(int64)
 			pop(f) //    longs occupy two slots, hence double pushes and pops
 			if val2 == 0 {
-				exceptions.Throw(exceptions.ArithmeticException, ""+
-					"LDIV: Arithmetic Exception: divide by zero")
-				return errors.New("LDIV: Divide by zero")
+				if err := throwVMException(fs, exceptions.ArithmeticException, "/ by zero"); err != nil {
+					return err
+				}
+				continue // the exception was caught, so resume at the handler
 			} else {
 				val1 := pop(f).(int64)
 				pop(f)
@@ -945,9 +1048,10 @@ func runFrame(fs *list.List) error {
 		case IREM: // 	0x70	(remainder after int division, modulo)
 			val2 := pop(f).(int64)
 			if val2 == 0 {
-				exceptions.Throw(exceptions.ArithmeticException,
-					"IREM: Arithmetic Exception: divide by zero")
-				return errors.New("IREM: Arithmetic Exception: divide by zero")
+				if err := throwVMException(fs, exceptions.ArithmeticException, "/ by zero"); err != nil {
+					return err
+				}
+				continue // the exception was caught, so resume at the handler
 			} else {
 				val1 := pop(f).(int64)
 				res := val1 % val2
@@ -957,9 +1061,10 @@ func runFrame(fs *list.List) error {
 			val2 := pop(f).(int64)
 			pop(f) //    longs occupy two slots, hence double pushes and pops
 			if val2 == 0 {
-				exceptions.Throw(exceptions.ArithmeticException,
-					"LREM: Arithmetic Exception: divide by zero")
-				return errors.New("LREM: Arithmetic Exception: divide by zero")
+				if err := throwVMException(fs, exceptions.ArithmeticException, "/ by zero"); err != nil {
+					return err
+				}
+				continue // the exception was caught, so resume at the handler
 			} else {
 				val1 := pop(f).(int64)
 				pop(f)
@@ -1334,6 +1439,39 @@ func runFrame(fs *list.List) error {
 		case GOTO: // 0xA7     (goto an instruction)
 			jumpTo := (int16(f.Meth[f.PC+1]) * 256) + int16(f.Meth[f.PC+2])
 			f.PC = f.PC + int(jumpTo) - 1 // -1 because this loop will increment f.PC by 1
+		case TABLESWITCH: // 0xAA (jump to the offset in a table indexed by the int on the stack)
+			// https://docs.oracle.com/javase/specs/jvms/se17/html/jvms-6.html#jvms-6.5.tableswitch
+			basePC := f.PC
+			pos := switchOperandsPos(basePC)
+			defaultOffset := fourBytesToInt32(f.Meth, pos)
+			low := fourBytesToInt32(f.Meth, pos+4)
+			high := fourBytesToInt32(f.Meth, pos+8)
+			index := int32(pop(f).(int64))
+
+			jumpOffset := defaultOffset
+			if index >= low && index <= high {
+				jumpOffset = fourBytesToInt32(f.Meth, pos+12+int(index-low)*4)
+			}
+			f.PC = basePC + int(jumpOffset) - 1 // -1 because this loop will increment f.PC by 1
+		case LOOKUPSWITCH: // 0xAB (jump to the offset paired with the int on the stack)
+			// https://docs.oracle.com/javase/specs/jvms/se17/html/jvms-6.html#jvms-6.5.lookupswitch
+			basePC := f.PC
+			pos := switchOperandsPos(basePC)
+			defaultOffset := fourBytesToInt32(f.Meth, pos)
+			npairs := int(fourBytesToInt32(f.Meth, pos+4))
+			key := int32(pop(f).(int64))
+			pairs := pos + 8
+
+			// the match-offset pairs are sorted by match, so do a binary search
+			i := sort.Search(npairs, func(n int) bool {
+				return fourBytesToInt32(f.Meth, pairs+n*8) >= key
+			})
+
+			jumpOffset := defaultOffset
+			if i < npairs && fourBytesToInt32(f.Meth, pairs+i*8) == key {
+				jumpOffset = fourBytesToInt32(f.Meth, pairs+i*8+4)
+			}
+			f.PC = basePC + int(jumpOffset) - 1 // -1 because this loop will increment f.PC by 1
 		case IRETURN: // 0xAC (return an int and exit current frame)
 			valToReturn := pop(f)
 			f = fs.Front().Next().Value.(*frames.Frame)
@@ -1392,14 +1530,22 @@ func runFrame(fs *list.List) error {
 			fieldName := classloader.FetchUTF8stringFromCPEntryNumber(f.CP, fieldNameIndex)
 			fieldName = className + "." + fieldName
 
+			// the class is initialized on the first use of its static fields
+			if err := initializeClass(fs, className); err != nil {
+				if jt, ok := err.(*javaThrowable); ok && catchException(f, jt) {
+					continue // the exception was caught, so resume at the handler
+				}
+				return err
+			}
+
 			// was this static field previously loaded? Is so, get its location and move on.
-			prevLoaded, ok := classloader.Statics[fieldName]
+			prevLoaded, ok := classloader.FetchStatic(fieldName)
 			if !ok { // if field is not already loaded, then
 				// the class has not been instantiated, so
 				// instantiate the class
 				_, err := instantiateClass(className)
 				if err == nil {
-					prevLoaded, ok = classloader.Statics[fieldName]
+					prevLoaded, ok = classloader.FetchStatic(fieldName)
 				} else {
 					errMsg := fmt.Sprintf("GETSTATIC: could not load class %s", className)
 					_ = log.Log(errMsg, log.SEVERE)
@@ -1472,14 +1618,22 @@ func runFrame(fs *list.List) error {
 			fieldName := classloader.FetchUTF8stringFromCPEntryNumber(f.CP, fieldNameIndex)
 			fieldName = className + "." + fieldName
 
+			// the class is initialized on the first use of its static fields
+			if err := initializeClass(fs, className); err != nil {
+				if jt, ok := err.(*javaThrowable); ok && catchException(f, jt) {
+					continue // the exception was caught, so resume at the handler
+				}
+				return err
+			}
+
 			// was this static field previously loaded? Is so, get its location and move on.
-			prevLoaded, ok := classloader.Statics[fieldName]
+			prevLoaded, ok := classloader.FetchStatic(fieldName)
 			if !ok { // if field is not already loaded, then
 				// the class has not been instantiated, so
 				// instantiate the class
 				_, err := instantiateClass(className)
 				if err == nil {
-					prevLoaded, ok = classloader.Statics[fieldName]
+					prevLoaded, ok = classloader.FetchStatic(fieldName)
 				} else {
 					errMsg := fmt.Sprintf("PUTSTATIC: could not load class %s", className)
 					_ = log.Log(errMsg, log.SEVERE)
@@ -1503,34 +1657,28 @@ func runFrame(fs *list.List) error {
 				// be stored as a boolean, a byte (in an array), or int64
 				// We want all forms normalized to int64
 				value = pop(f).(int64) & 0x01
-				classloader.Statics[fieldName] = classloader.Static{
+				_ = classloader.AddStatic(fieldName, classloader.Static{
 					Type:  prevLoaded.Type,
 					Value: value,
-				}
-			case types.Char, types.Short, types.Int, types.Long:
+				})
+			case types.Byte, types.Char, types.Short, types.Int, types.Long:
 				value = pop(f).(int64)
-				classloader.Statics[fieldName] = classloader.Static{
+				_ = classloader.AddStatic(fieldName, classloader.Static{
 					Type:  prevLoaded.Type,
 					Value: value,
-				}
-			case types.Byte:
-				value = pop(f).(byte)
-				classloader.Statics[fieldName] = classloader.Static{
-					Type:  prevLoaded.Type,
-					Value: value,
-				}
+				})
 			case types.Float, types.Double:
 				value = pop(f).(float64)
-				classloader.Statics[fieldName] = classloader.Static{
+				_ = classloader.AddStatic(fieldName, classloader.Static{
 					Type:  prevLoaded.Type,
 					Value: value,
-				}
-			default:
-				value = pop(f).(float64)
-				classloader.Statics[fieldName] = classloader.Static{
+				})
+			default: // references
+				value = pop(f)
+				_ = classloader.AddStatic(fieldName, classloader.Static{
 					Type:  prevLoaded.Type,
 					Value: value,
-				}
+				})
 			}
 
 			// doubles and longs consume two slots on the op stack
@@ -1549,16 +1697,27 @@ func runFrame(fs *list.List) error {
 					fieldEntry.Type, f.PC, f.MethName, f.ClName)
 			}
 
-			ref := pop(f).(*object.Object)
+			ref, ok := pop(f).(*object.Object)
+			if !ok || ref == object.Null {
+				errMsg := fmt.Sprintf("Cannot read field \"%s\"", getFieldNameFromCPfieldref(f.CP, CPslot))
+				if err := throwVMException(fs, exceptions.NullPointerException, errMsg); err != nil {
+					return err
+				}
+				continue // the exception was caught, so resume at the handler
+			}
 			obj := *ref
 
 			// var fieldName string
 			var fieldType string
 			var fieldValue interface{}
 
+			// the field is read with the field lock held, so that what another thread
+			// wrote to it is seen, and seen whole (see fieldLock.go)
 			if obj.Fields != nil {
 				fieldType = obj.Fields[fieldEntry.Slot].Ftype
+				ref.FieldLock().Lock()
 				fieldValue = obj.Fields[fieldEntry.Slot].Fvalue
+				ref.FieldLock().Unlock()
 			} else { // retrieve by name
 				fullFieldEntry := f.CP.FieldRefs[fieldEntry.Slot]
 				nameAndTypeCPIndex := fullFieldEntry.NameAndType
@@ -1568,7 +1727,9 @@ func runFrame(fs *list.List) error {
 				nameCPentry := f.CP.CpIndex[nameCPIndex]
 				fieldName := f.CP.Utf8Refs[nameCPentry.Slot]
 
+				ref.FieldLock().Lock()
 				objField := obj.FieldTable[fieldName]
+				ref.FieldLock().Unlock()
 				fieldValue = objField.Fvalue
 			}
 			push(f, fieldValue)
@@ -1606,6 +1767,13 @@ func runFrame(fs *list.List) error {
 				ref = pop(f).(*object.Object)
 			}
 
+			if ref == nil || ref.(*object.Object) == object.Null {
+				errMsg := fmt.Sprintf("Cannot assign field \"%s\"", getFieldNameFromCPfieldref(f.CP, CPslot))
+				if err := throwVMException(fs, exceptions.NullPointerException, errMsg); err != nil {
+					return err
+				}
+				continue // the exception was caught, so resume at the handler
+			}
 			obj := *(ref.(*object.Object))
 
 			// if the value we're inserting is a reference to an
@@ -1624,6 +1792,8 @@ func runFrame(fs *list.List) error {
 				}
 			}
 
+			// the field is written with the field lock held (see fieldLock.go)
+			fieldLock := ref.(*object.Object).FieldLock()
 			if obj.Fields != nil {
 				// If it's a simple object w/out superclasses other than Object,
 				// the fields in the object are numbered in the same
@@ -1637,7 +1807,9 @@ func runFrame(fs *list.List) error {
 					_ = log.Log(errMsg, log.SEVERE)
 					return fmt.Errorf(errMsg)
 				} else {
+					fieldLock.Lock()
 					obj.Fields[fieldEntry.Slot].Fvalue = value
+					fieldLock.Unlock()
 				}
 			} else {
 				// otherwise, it's an object that contains superclass fields and
@@ -1650,9 +1822,13 @@ func runFrame(fs *list.List) error {
 				nameCPentry := f.CP.CpIndex[nameCPIndex]
 				fieldName := f.CP.Utf8Refs[nameCPentry.Slot]
 
-				objField := obj.FieldTable[fieldName]
-				objField.Fvalue = value
-				obj.FieldTable[fieldName] = objField
+				func() { // an object with no field table panics, so unlock in any case
+					fieldLock.Lock()
+					defer fieldLock.Unlock()
+					objField := obj.FieldTable[fieldName]
+					objField.Fvalue = value
+					obj.FieldTable[fieldName] = objField
+				}()
 			}
 
 		case INVOKEVIRTUAL: // 	0xB6 invokevirtual (create new frame, invoke function)
@@ -1669,40 +1845,61 @@ func runFrame(fs *list.List) error {
 				return fmt.Errorf(errMsg)
 			}
 
-			// get the methodRef entry
-			method := f.CP.MethodRefs[CPentry.Slot]
-
-			// get the class entry from this method
-			classRef := method.ClassIndex
-			classNameIndex := f.CP.ClassRefs[f.CP.CpIndex[classRef].Slot]
-			classNameEntry := f.CP.CpIndex[classNameIndex]
-			className := f.CP.Utf8Refs[classNameEntry.Slot]
-
-			// get the method name for this method
-			nAndTindex := method.NameAndType
-			nAndTentry := f.CP.CpIndex[nAndTindex]
-			nAndTslot := nAndTentry.Slot
-			nAndT := f.CP.NameAndTypes[nAndTslot]
-			methodNameIndex := nAndT.NameIndex
-			methodName := classloader.FetchUTF8stringFromCPEntryNumber(f.CP, methodNameIndex)
-			// methodName = className + "." + methodName
+			// the class and method named in the methodRef, and the method's vtable slot
+			site := resolveVirtualCallSite(f.CP, CPslot)
+			className, methodName, methodType := site.className, site.methodName, site.methodType
+
+			// the method to run is selected by the class of the object it's invoked on,
+			// whose ref is below the arguments on the operand stack
+			var mtEntry classloader.MTentry
+			var receiver interface{}
+			if f.TOS-site.argSlots >= 0 {
+				receiver = f.OpStack[f.TOS-site.argSlots]
+			}
+			receiverClass := receiverClassName(receiver)
+			selected := false
+			if receiverClass != "" && !strings.HasPrefix(receiverClass, "[") { // arrays have no vtable
+				var selectErr error
+				mtEntry, className, selected, selectErr = selectVirtualMethod(site, receiverClass)
+				if selectErr != nil { // the selected method is abstract
+					if err := throwVMException(fs, exceptions.AbstractMethodError, selectErr.Error()); err != nil {
+						return err
+					}
+					continue // the exception was caught, so resume at the handler
+				}
+			}
 
-			// get the signature for this method
-			methodSigIndex := nAndT.DescIndex
-			methodType := classloader.FetchUTF8stringFromCPEntryNumber(f.CP, methodSigIndex)
+			if !selected { // look up the method in the class named in the methodRef
+				mtEntry = classloader.MTableFetch(className + "." + methodName + methodType)
+				if mtEntry.Meth == nil { // if the method is not in the method table, find it
+					mtEntry, err = classloader.FetchMethodAndCP(className, methodName, methodType)
+					if err != nil || mtEntry.Meth == nil {
+						// TODO: search the classpath and retry
+						return errors.New("INVOKEVIRTUAL: Class not found: " + className + "." + methodName)
+					}
+				}
 
-			mtEntry := classloader.MTable[className+"."+methodName+methodType]
-			if mtEntry.Meth == nil { // if the method is not in the method table, find it
-				mtEntry, err = classloader.FetchMethodAndCP(className, methodName, methodType)
-				if err != nil || mtEntry.Meth == nil {
-					// TODO: search the classpath and retry
-					return errors.New("INVOKEVIRTUAL: Class not found: " + className + "." + methodName)
+				// bytecode can't be run on a null object. (Some Go methods, however, accept
+				// objects, such as System.out, that Jacobin does not instantiate.)
+				if mtEntry.MType == 'J' && receiver == object.Null {
+					errMsg := fmt.Sprintf("Cannot invoke \"%s.%s()\"",
+						strings.ReplaceAll(className, "/", "."), methodName)
+					if err := throwVMException(fs, exceptions.NullPointerException, errMsg); err != nil {
+						return err
+					}
+					continue // the exception was caught, so resume at the handler
 				}
 			}
 
 			if mtEntry.MType == 'G' { // so we have a golang function
 				_, err := runGmethod(mtEntry, fs, className, methodName, methodType)
 				if err != nil {
+					if _, thrown := err.(*javaThrowable); thrown { // the Go method threw an exception
+						if f, err = catchInCaller(fs, err); err != nil {
+							return err
+						}
+						continue // the exception was caught, so resume at the handler
+					}
 					// any exception message will already have been displayed to the user
 					return errors.New("INVOKEVIRTUAL: Error encountered in: " +
 						className + "." + methodName)
@@ -1723,7 +1920,11 @@ func runFrame(fs *list.List) error {
 				f = fs.Front().Value.(*frames.Frame) // point f to the new head
 				err = runFrame(fs)                   // 2nd on stack from new crash site
 				if err != nil {
-					return err
+					// if a Java exception was thrown, see whether this frame catches it
+					if f, err = catchInCaller(fs, err); err != nil {
+						return err
+					}
+					continue // the exception was caught, so resume at the handler
 				}
 
 				// if the method is main(), then when we get here the
@@ -1763,6 +1964,12 @@ func runFrame(fs *list.List) error {
 			if mtEntry.MType == 'G' { // it's a golang method
 				f, err = runGmethod(mtEntry, fs, className, className+"."+methName, methSig)
 				if err != nil {
+					if _, thrown := err.(*javaThrowable); thrown { // the Go method threw an exception
+						if f, err = catchInCaller(fs, err); err != nil {
+							return err
+						}
+						continue // the exception was caught, so resume at the handler
+					}
 					// any exceptions message will already have been displayed to the user
 					return errors.New("INVOKESPECIAL: Error encountered in: " +
 						className + "." + methName)
@@ -1781,7 +1988,11 @@ func runFrame(fs *list.List) error {
 				f = fs.Front().Value.(*frames.Frame) // point f to the new head
 				err = runFrame(fs)                   // 2nd on stack from new crash site
 				if err != nil {
-					return err
+					// if a Java exception was thrown, see whether this frame catches it
+					if f, err = catchInCaller(fs, err); err != nil {
+						return err
+					}
+					continue // the exception was caught, so resume at the handler
 				}
 
 				fs.Remove(fs.Front()) // pop the frame off
@@ -1826,10 +2037,24 @@ func runFrame(fs *list.List) error {
 				return errors.New("INVOKESTATIC: Class not found: " + className + methodName)
 			}
 
+			// the class is initialized on the first call to one of its static methods
+			if err := initializeClass(fs, className); err != nil {
+				if jt, ok := err.(*javaThrowable); ok && catchException(f, jt) {
+					continue // the exception was caught, so resume at the handler
+				}
+				return err
+			}
+
 			if mtEntry.MType == 'G' {
 				f, err = runGmethod(mtEntry, fs, className, methodName, methodType)
 
 				if err != nil {
+					if _, thrown := err.(*javaThrowable); thrown { // the Go method threw an exception
+						if f, err = catchInCaller(fs, err); err != nil {
+							return err
+						}
+						continue // the exception was caught, so resume at the handler
+					}
 					// any exceptions message will already have been displayed to the user
 					return errors.New("INVOKESTATIC: Error encountered in: " +
 						className + "." + methodName)
@@ -1847,7 +2072,11 @@ func runFrame(fs *list.List) error {
 				f = fs.Front().Value.(*frames.Frame) // point f to the new head
 				err = runFrame(fs)                   // 2nd on stack from new crash site
 				if err != nil {
-					return err
+					// if a Java exception was thrown, see whether this frame catches it
+					if f, err = catchInCaller(fs, err); err != nil {
+						return err
+					}
+					continue // the exception was caught, so resume at the handler
 				}
 
 				// if the static method is main(), when we get here the
@@ -1867,6 +2096,109 @@ func runFrame(fs *list.List) error {
 					return nil
 				}
 			}
+		case INVOKEINTERFACE: // 0xB9 invokeinterface (invoke the receiver's implementation of an interface method)
+			CPslot := (int(f.Meth[f.PC+1]) * 256) + int(f.Meth[f.PC+2]) // next 2 bytes point to CP entry
+			count := int(f.Meth[f.PC+3])                                // the slots taken by the arguments, including the object ref
+			f.PC += 4                                                   // the fourth byte is always zero
+
+			interfaceName, methodName, methodType := getMethInfoFromCPinterfaceRef(f.CP, CPslot)
+			if interfaceName == "" {
+				errMsg := fmt.Sprintf("INVOKEINTERFACE: Expected an interface method ref at CP entry %d "+
+					"in method %s of class %s", CPslot, f.MethName, f.ClName)
+				_ = log.Log(errMsg, log.SEVERE)
+				return errors.New(errMsg)
+			}
+
+			// the object ref is below the arguments on the operand stack
+			receiver, ok := f.OpStack[f.TOS-count+1].(*object.Object)
+			if !ok || receiver == nil || receiver.Klass == nil {
+				errMsg := fmt.Sprintf("Cannot invoke \"%s.%s()\"",
+					strings.ReplaceAll(interfaceName, "/", "."), methodName)
+				if err := throwVMException(fs, exceptions.NullPointerException, errMsg); err != nil {
+					return err
+				}
+				continue // the exception was caught, so resume at the handler
+			}
+
+			// the method to run is selected based on the class of the object, not the interface
+			mtEntry, className, err := classloader.FetchInterfaceMethod(*receiver.Klass, methodName, methodType)
+			if err != nil {
+				unresolved, ok := err.(*classloader.UnresolvedMethodError)
+				if !ok {
+					return errors.New("INVOKEINTERFACE: Class not found: " + *receiver.Klass + "." + methodName)
+				}
+
+				excType := exceptions.AbstractMethodError
+				errMsg := unresolved.Error()
+				if !unresolved.IsDeclared {
+					excType = exceptions.IncompatibleClassChangeError
+					errMsg = fmt.Sprintf("Class %s does not implement the requested interface %s",
+						strings.ReplaceAll(*receiver.Klass, "/", "."), strings.ReplaceAll(interfaceName, "/", "."))
+				}
+				if err := throwVMException(fs, excType, errMsg); err != nil {
+					return err
+				}
+				continue // the exception was caught, so resume at the handler
+			}
+
+			if mtEntry.MType == 'G' { // so we have a golang function
+				f, err = runGmethod(mtEntry, fs, className, methodName, methodType)
+				if err != nil {
+					if _, thrown := err.(*javaThrowable); thrown { // the Go method threw an exception
+						if f, err = catchInCaller(fs, err); err != nil {
+							return err
+						}
+						continue // the exception was caught, so resume at the handler
+					}
+					// any exception message will already have been displayed to the user
+					return errors.New("INVOKEINTERFACE: Error encountered in: " +
+						className + "." + methodName)
+				}
+			} else if mtEntry.MType == 'J' { // it's a Java function (that is, non-native)
+				m := mtEntry.Meth.(classloader.JmEntry)
+				fram, err := createAndInitNewFrame(
+					className, methodName, methodType, &m, true, f)
+				if err != nil {
+					return errors.New("INVOKEINTERFACE: Error creating frame in: " +
+						className + "." + methodName)
+				}
+
+				fs.PushFront(fram)                   // push the new frame
+				f = fs.Front().Value.(*frames.Frame) // point f to the new head
+				err = runFrame(fs)
+				if err != nil {
+					// if a Java exception was thrown, see whether this frame catches it
+					if f, err = catchInCaller(fs, err); err != nil {
+						return err
+					}
+					continue // the exception was caught, so resume at the handler
+				}
+
+				fs.Remove(fs.Front()) // pop the frame off
+				if fs.Len() != 0 {
+					f = fs.Front().Value.(*frames.Frame)
+				} else {
+					return nil
+				}
+			}
+		case INVOKEDYNAMIC: // 0xBA invokedynamic (link the call site on first use, then run it)
+			CPslot := (int(f.Meth[f.PC+1]) * 256) + int(f.Meth[f.PC+2]) // next 2 bytes point to CP entry
+			f.PC += 4                                                   // the last two bytes are always zero
+
+			site, err := linkCallSite(f, CPslot)
+			if err != nil {
+				if err := throwVMException(fs, exceptions.BootstrapMethodError, err.Error()); err != nil {
+					return err
+				}
+				continue // the exception was caught, so resume at the handler
+			}
+
+			if err = site.run(fs, f); err != nil {
+				if jt, ok := err.(*javaThrowable); ok && catchException(f, jt) {
+					continue // the exception was caught, so resume at the handler
+				}
+				return err
+			}
 		case NEW: // 0xBB 	new: create and instantiate a new object
 			CPslot := (int(f.Meth[f.PC+1]) * 256) + int(f.Meth[f.PC+2]) // next 2 bytes point to CP entry
 			f.PC += 2
@@ -1884,6 +2216,14 @@ func runFrame(fs *list.List) error {
 				className = classloader.FetchUTF8stringFromCPEntryNumber(f.CP, utf8Index)
 			}
 
+			// the class is initialized before its first instance is created
+			if err := initializeClass(fs, className); err != nil {
+				if jt, ok := err.(*javaThrowable); ok && catchException(f, jt) {
+					continue // the exception was caught, so resume at the handler
+				}
+				return err
+			}
+
 			ref, err := instantiateClass(className)
 			if err != nil {
 				errMsg := fmt.Sprintf("NEW: could not load class %s", className)
@@ -1895,9 +2235,11 @@ func runFrame(fs *list.List) error {
 		case NEWARRAY: // 0xBC create a new array of primitives
 			size := pop(f).(int64)
 			if size < 0 {
-				errMsg := "NEWARRAY: Invalid size for array"
-				exceptions.Throw(exceptions.NegativeArraySizeException, errMsg)
-				return errors.New(errMsg)
+				errMsg := strconv.FormatInt(size, 10)
+				if err := throwVMException(fs, exceptions.NegativeArraySizeException, errMsg); err != nil {
+					return err
+				}
+				continue // the exception was caught, so resume at the handler
 			}
 
 			arrayType := int(f.Meth[f.PC+1])
@@ -1918,9 +2260,11 @@ func runFrame(fs *list.List) error {
 		case ANEWARRAY: // 0xBD create array of references
 			size := pop(f).(int64)
 			if size < 0 {
-				errMsg := "ANEWARRAY: Invalid size for array"
-				exceptions.Throw(exceptions.NegativeArraySizeException, errMsg)
-				return errors.New(errMsg)
+				errMsg := strconv.FormatInt(size, 10)
+				if err := throwVMException(fs, exceptions.NegativeArraySizeException, errMsg); err != nil {
+					return err
+				}
+				continue // the exception was caught, so resume at the handler
 			}
 
 			arrayPtr := object.Make1DimArray(object.REF, size)
@@ -1938,9 +2282,11 @@ func runFrame(fs *list.List) error {
 			// expects a pointer to an array
 			ref := pop(f)
 			if ref == nil {
-				exceptions.Throw(exceptions.NullPointerException,
-					"ARRAYLENGTH: Invalid (null) reference to an array")
-				return errors.New("ARRAYLENGTHY: invalid (null) reference to an array")
+				errMsg := "Cannot read the array length"
+				if err := throwVMException(fs, exceptions.NullPointerException, errMsg); err != nil {
+					return err
+				}
+				continue // the exception was caught, so resume at the handler
 			}
 
 			var size int64
@@ -1977,6 +2323,27 @@ func runFrame(fs *list.List) error {
 			}
 			push(f, size)
 
+		case ATHROW: // 0xBF throw an exception
+			ref := pop(f)
+			thrown, ok := ref.(*object.Object)
+			if !ok || thrown == nil {
+				errMsg := "Cannot throw exception"
+				if err := throwVMException(fs, exceptions.NullPointerException, errMsg); err != nil {
+					return err
+				}
+				continue // the exception was caught, so resume at the handler
+			}
+
+			jt := newThrowableFromObject(thrown)
+			if jt.trace == nil { // fillInStackTrace() was not run, so record the trace now
+				jt.trace = captureStackTrace(fs.Front())
+				setObjectFieldByName(thrown, "backtrace", jt.trace)
+			}
+			if catchException(f, jt) {
+				continue // the PC now points to the handler
+			}
+			return jt // no handler here, so let the calling frame look for one
+
 		case CHECKCAST: // 0xC0 same as INSTANCEOF but throws exception on null
 			// because this uses the same logic as INSTANCEOF, any change here should
 			// be made to INSTANCEOF
@@ -1999,8 +2366,10 @@ func runFrame(fs *list.List) error {
 				}
 			default:
 				errMsg := "CHECKCAST: Invalid class reference"
-				exceptions.Throw(exceptions.ClassCastException, errMsg)
-				return errors.New(errMsg)
+				if err := throwVMException(fs, exceptions.ClassCastException, errMsg); err != nil {
+					return err
+				}
+				continue // the exception was caught, so resume at the handler
 			}
 
 			// at this point, we know we have a valid non-nil, non-null pointer to an object
@@ -2017,7 +2386,7 @@ func runFrame(fs *list.List) error {
 				}
 
 				className = *(classNamePtr.stringVal)
-				if MainThread.Trace {
+				if f.Trace {
 					var msg string
 					if strings.HasPrefix(className, "[") {
 						msg = fmt.Sprintf("CHECKCAST: class is an array = %s", className)
@@ -2038,13 +2407,17 @@ func runFrame(fs *list.List) error {
 						} else {
 							errMsg := fmt.Sprintf("CHECKCAST: %s is not castable with respect to %s",
 								className, *sptr)
-							exceptions.Throw(exceptions.ClassCastException, errMsg)
-							return errors.New(errMsg)
+							if err := throwVMException(fs, exceptions.ClassCastException, errMsg); err != nil {
+								return err
+							}
+							continue // the exception was caught, so resume at the handler
 						}
 					} else {
 						errMsg := fmt.Sprintf("CHECKCAST: Klass field for object is nil")
-						exceptions.Throw(exceptions.ClassCastException, errMsg)
-						return errors.New(errMsg)
+						if err := throwVMException(fs, exceptions.ClassCastException, errMsg); err != nil {
+							return err
+						}
+						continue // the exception was caught, so resume at the handler
 					}
 				} else { // the object being checked is a class
 					classPtr := classloader.MethAreaFetch(className)
@@ -2058,8 +2431,10 @@ func runFrame(fs *list.List) error {
 					if classPtr != classloader.MethAreaFetch(*obj.Klass) {
 						errMsg := fmt.Sprintf("CHECKCAST: %s is not castable with respect to %s",
 							className, classPtr.Data.Name)
-						exceptions.Throw(exceptions.ClassCastException, errMsg)
-						return errors.New(errMsg)
+						if err := throwVMException(fs, exceptions.ClassCastException, errMsg); err != nil {
+							return err
+						}
+						continue // the exception was caught, so resume at the handler
 					}
 					// note that if the classPtr == obj.Klass, which is the desired outcome,
 					// do nothing. That is, the incoming stack should remain the same.
@@ -2098,7 +2473,7 @@ func runFrame(fs *list.List) error {
 							return errors.New(" INSTANCEOF: Invalid classRef found")
 						} else {
 							className = *(classNamePtr.stringVal)
-							if MainThread.Trace {
+							if f.Trace {
 								msg := fmt.Sprintf("INSTANCEOF: className = %s", className)
 								_ = log.Log(msg, log.TRACE_INST)
 							}
@@ -2120,8 +2495,31 @@ func runFrame(fs *list.List) error {
 				}
 			}
 
-		case MONITORENTER, MONITOREXIT: // OxC2 and OxC3. These  are not implemented in the JDK JVM
-			_ = pop(f) // so just pop off the reference on the stack
+		case MONITORENTER: // 0xC2 (lock the monitor of the object, waiting if another thread holds it)
+			obj, ok := pop(f).(*object.Object)
+			if !ok || obj == object.Null {
+				if err := throwVMException(fs, exceptions.NullPointerException, "Cannot enter synchronized block"); err != nil {
+					return err
+				}
+				continue // the exception was caught, so resume at the handler
+			}
+			enterMonitor(fs, obj)
+			f.Locks = append(f.Locks, obj)
+		case MONITOREXIT: // 0xC3 (unlock the monitor of the object, which the thread must hold)
+			obj, ok := pop(f).(*object.Object)
+			if !ok || obj == object.Null {
+				if err := throwVMException(fs, exceptions.NullPointerException, "Cannot exit synchronized block"); err != nil {
+					return err
+				}
+				continue // the exception was caught, so resume at the handler
+			}
+			if !obj.MonitorExit(f.Thread) {
+				if err := throwVMException(fs, exceptions.IllegalMonitorStateException, notOwnerMsg); err != nil {
+					return err
+				}
+				continue // the exception was caught, so resume at the handler
+			}
+			removeLock(f, obj)
 
 		case MULTIANEWARRAY: // 0xC5 create multi-dimensional array
 			var arrayDesc string
@@ -2308,6 +2706,23 @@ func emitTraceData(f *frames.Frame) string {
 		}
 	}
 
+	// for the switch instructions, show the cases, which otherwise don't appear in the trace
+	switch f.Meth[f.PC] {
+	case TABLESWITCH:
+		pos := switchOperandsPos(f.PC)
+		if pos+12 <= len(f.Meth) {
+			stackTop += fmt.Sprintf("low: %d high: %d default: %d ",
+				fourBytesToInt32(f.Meth, pos+4), fourBytesToInt32(f.Meth, pos+8),
+				f.PC+int(fourBytesToInt32(f.Meth, pos)))
+		}
+	case LOOKUPSWITCH:
+		pos := switchOperandsPos(f.PC)
+		if pos+8 <= len(f.Meth) {
+			stackTop += fmt.Sprintf("pairs: %d default: %d ",
+				fourBytesToInt32(f.Meth, pos+4), f.PC+int(fourBytesToInt32(f.Meth, pos)))
+		}
+	}
+
 	traceInfo :=
 		"class: " + fmt.Sprintf("%-22s", f.ClName) +
 			" meth: " + fmt.Sprintf("%-10s", f.MethName) +
@@ -2319,13 +2734,14 @@ func emitTraceData(f *frames.Frame) string {
 	return traceInfo
 }
 
-// pop from the operand stack. TODO: need to put in checks for invalid pops
+// pop from the operand stack. An empty stack is an operandStackError (see checkPop()).
 func pop(f *frames.Frame) interface{} {
+	checkPop(f)
 	value := f.OpStack[f.TOS]
 
 	// we show trace info of the TOS *before* we change its value--
 	// all traces show TOS before the instruction is executed.
-	if MainThread.Trace {
+	if f.Trace {
 		var traceInfo string
 		if f.TOS == -1 {
 			traceInfo = fmt.Sprintf("%74s", "POP           TOS:  -")
@@ -2385,7 +2801,8 @@ func pop(f *frames.Frame) interface{} {
 
 // returns the value at the top of the stack without popping it off.
 func peek(f *frames.Frame) interface{} {
-	if MainThread.Trace {
+	checkPop(f)
+	if f.Trace {
 		var traceInfo string
 		value := f.OpStack[f.TOS]
 		if f.TOS == -1 {
@@ -2424,12 +2841,13 @@ func peek(f *frames.Frame) interface{} {
 	return f.OpStack[f.TOS]
 }
 
-// push onto the operand stack
+// push onto the operand stack. A full stack is an operandStackError (see checkPush()).
 func push(f *frames.Frame, x interface{}) {
+	checkPush(f)
 
 	// we show trace info of the TOS *before* we change its value--
 	// all traces show TOS before the instruction is executed.
-	if MainThread.Trace {
+	if f.Trace {
 		var traceInfo string
 
 		if f.TOS == -1 {
@@ -2542,7 +2960,7 @@ func createAndInitNewFrame(
 	includeObjectRef bool,
 	currFrame *frames.Frame) (*frames.Frame, error) {
 
-	if MainThread.Trace {
+	if currFrame.Trace {
 		traceInfo := fmt.Sprintf("\tcreateAndInitNewFrame: class=%s, method=%s, methodType=%s, includeObjectRef=%v, m.MaxStack=%d, m.MaxLocals=%d",
 			className, methodName, methodType, includeObjectRef, m.MaxStack, m.MaxLocals)
 		_ = log.Log(traceInfo, log.TRACE_INST)
@@ -2557,7 +2975,11 @@ func createAndInitNewFrame(
 	fram := frames.CreateFrame(stackSize)
 	fram.ClName = className
 	fram.MethName = methodName
-	fram.CP = m.Cp                     // add its pointer to the class CP
+	fram.CP = m.Cp               // add its pointer to the class CP
+	fram.ExcTable = m.Exceptions // and its exception table
+	fram.LineTable = m.LineTable // and its source line numbers
+	fram.Thread = f.Thread       // it runs on the caller's thread
+	fram.Trace = f.Trace
 	for i := 0; i < len(m.Code); i++ { // copy the method's bytecodes over
 		fram.Meth = append(fram.Meth, m.Code[i])
 	}
@@ -2675,7 +3097,7 @@ func createAndInitNewFrame(
 		lenLocals++                                 // There is 1 more local needed
 	}
 
-	if MainThread.Trace {
+	if f.Trace {
 		traceInfo := fmt.Sprintf("\tcreateAndInitNewFrame: lenArgList=%d, lenLocals=%d, stackSize=%d",
 			lenArgList, lenLocals, stackSize)
 		_ = log.Log(traceInfo, log.TRACE_INST)
@@ -2686,11 +3108,27 @@ func createAndInitNewFrame(
 		destLocal += 1
 	}
 
+	if m.IsSynchronized() {
+		fram.Monitor = methodMonitor(m, includeObjectRef, fram.Locals)
+	}
 	fram.TOS = -1
 
 	return fram, nil
 }
 
+// The operands of TABLESWITCH and LOOKUPSWITCH begin after 0-3 bytes of padding,
+// so that they start at an address that is a multiple of 4 bytes from the start of
+// the method's bytecode. Returns the position of the first operand for the switch
+// instruction at pc.
+func switchOperandsPos(pc int) int {
+	return (pc + 4) &^ 3
+}
+
+// Convert four bytes in big-endian order, starting at pos, to a signed int32
+func fourBytesToInt32(code []byte, pos int) int32 {
+	return int32(binary.BigEndian.Uint32(code[pos : pos+4]))
+}
+
 // Convert a byte to an int64 by extending the sign-bit
 func byteToInt64(bite byte) int64 {
 	if (bite & 0x80) == 0x80 { // Negative bite value (left-most bit on)?
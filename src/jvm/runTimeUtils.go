@@ -9,6 +9,7 @@ package jvm
 
 import (
 	"jacobin/classloader"
+	"jacobin/object"
 	"unsafe"
 )
 
@@ -174,3 +175,112 @@ func getMethInfoFromCPmethref(CP *classloader.CPool, cpIndex int) (string, strin
 
 	return className, methName, methSig
 }
+
+// same as getMethInfoFromCPmethref(), except for an interface method reference,
+// as used by INVOKEINTERFACE. Returns the interface name, method name, and signature.
+func getMethInfoFromCPinterfaceRef(CP *classloader.CPool, cpIndex int) (string, string, string) {
+	if cpIndex < 1 || cpIndex >= len(CP.CpIndex) {
+		return "", "", ""
+	}
+
+	if CP.CpIndex[cpIndex].Type != classloader.Interface {
+		return "", "", ""
+	}
+	interfaceRef := CP.InterfaceRefs[CP.CpIndex[cpIndex].Slot]
+
+	classRefIdx := CP.CpIndex[interfaceRef.ClassIndex].Slot
+	classIdx := CP.ClassRefs[classRefIdx]
+	className := CP.Utf8Refs[CP.CpIndex[classIdx].Slot]
+
+	nameAndTypeEntry := CP.NameAndTypes[CP.CpIndex[interfaceRef.NameAndType].Slot]
+	methName := CP.Utf8Refs[CP.CpIndex[nameAndTypeEntry.NameIndex].Slot]
+	methSig := CP.Utf8Refs[CP.CpIndex[nameAndTypeEntry.DescIndex].Slot]
+
+	return className, methName, methSig
+}
+
+// getObjectFieldByName returns the value of the named instance field of an object
+// and true, or nil and false if no such field is found. Objects whose class has
+// superclasses other than Object hold their fields in FieldTable, indexed by name.
+// Other objects hold their fields in the Fields slice, which GETFIELD and PUTFIELD
+// index by the slot of the field's FieldRef in the class's CP, so the same lookup
+// is done here.
+func getObjectFieldByName(obj *object.Object, fieldName string) (interface{}, bool) {
+	if obj == nil {
+		return nil, false
+	}
+
+	if obj.FieldTable != nil {
+		field, ok := obj.FieldTable[fieldName]
+		return field.Fvalue, ok
+	}
+
+	slot := fieldRefSlotForName(obj, fieldName)
+	if slot < 0 {
+		return nil, false
+	}
+	return obj.Fields[slot].Fvalue, true
+}
+
+// setObjectFieldByName sets the value of the named instance field of an object,
+// using the same lookup as getObjectFieldByName(). Returns false if no such
+// field is found.
+func setObjectFieldByName(obj *object.Object, fieldName string, value interface{}) bool {
+	if obj == nil {
+		return false
+	}
+
+	if obj.FieldTable != nil {
+		field, ok := obj.FieldTable[fieldName]
+		if ok {
+			field.Fvalue = value
+			obj.FieldTable[fieldName] = field
+		}
+		return ok
+	}
+
+	slot := fieldRefSlotForName(obj, fieldName)
+	if slot < 0 {
+		return false
+	}
+	obj.Fields[slot].Fvalue = value
+	return true
+}
+
+// returns the index into obj.Fields for the named field, or -1 if it can't be found
+func fieldRefSlotForName(obj *object.Object, fieldName string) int {
+	if obj.Klass == nil || classloader.MethArea == nil {
+		return -1
+	}
+	k := classloader.MethAreaFetch(*obj.Klass)
+	if k == nil || k.Data == nil {
+		return -1
+	}
+
+	cp := &k.Data.CP
+	for i, fieldRef := range cp.FieldRefs {
+		nAndT := cp.NameAndTypes[cp.CpIndex[fieldRef.NameAndType].Slot]
+		if classloader.FetchUTF8stringFromCPEntryNumber(cp, nAndT.NameIndex) == fieldName {
+			if i < len(obj.Fields) {
+				return i
+			}
+			return -1
+		}
+	}
+	return -1
+}
+
+// accepts the index of a CP entry, which should point to a fieldref, and returns
+// the name of the field. Returns an empty string if an error occurred.
+func getFieldNameFromCPfieldref(CP *classloader.CPool, cpIndex int) string {
+	if cpIndex < 1 || cpIndex >= len(CP.CpIndex) {
+		return ""
+	}
+
+	if CP.CpIndex[cpIndex].Type != classloader.FieldRef {
+		return ""
+	}
+	fieldRef := CP.FieldRefs[CP.CpIndex[cpIndex].Slot]
+	nameAndType := CP.NameAndTypes[CP.CpIndex[fieldRef.NameAndType].Slot]
+	return classloader.FetchUTF8stringFromCPEntryNumber(CP, nameAndType.NameIndex)
+}
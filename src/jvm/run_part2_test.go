@@ -267,6 +267,330 @@ func TestInstanceofString(t *testing.T) {
 	}
 }
 
+// a method of a class created for the INVOKEINTERFACE and INVOKEVIRTUAL tests
+type testMethod struct {
+	name  string
+	desc  string
+	flags int
+	code  []byte
+}
+
+// a field of a class created for the tests
+type testField struct {
+	name   string
+	desc   string
+	static bool
+}
+
+// adds a class (or interface) with the given methods to the method area
+func addTestClass(name, superclass string, interfaces []string, methods ...testMethod) {
+	addClass(name, superclass, interfaces, newCPBuilder(), nil, methods...)
+}
+
+// adds a class (or interface) with the given fields and methods to the method area,
+// and returns it. Its CP is the one b builds, to which the names of its interfaces
+// and the names and types of its fields and methods are added.
+func addClass(name, superclass string, interfaces []string, b *cpBuilder,
+	fields []testField, methods ...testMethod) *classloader.Klass {
+	data := classloader.ClData{Name: name, Superclass: superclass}
+	for _, intf := range interfaces {
+		data.Interfaces = append(data.Interfaces, uint16(len(b.cp.Utf8Refs)))
+		b.cp.Utf8Refs = append(b.cp.Utf8Refs, intf)
+	}
+	for _, fld := range fields {
+		field := classloader.Field{IsStatic: fld.static}
+		field.Name = uint16(len(b.cp.Utf8Refs))
+		field.Desc = uint16(len(b.cp.Utf8Refs) + 1)
+		b.cp.Utf8Refs = append(b.cp.Utf8Refs, fld.name, fld.desc)
+		data.Fields = append(data.Fields, field)
+	}
+	for _, m := range methods {
+		meth := classloader.Method{AccessFlags: m.flags}
+		meth.Name = uint16(len(b.cp.Utf8Refs))
+		meth.Desc = uint16(len(b.cp.Utf8Refs) + 1)
+		b.cp.Utf8Refs = append(b.cp.Utf8Refs, m.name, m.desc)
+		meth.CodeAttr = classloader.CodeAttrib{MaxStack: 2, MaxLocals: 1, Code: m.code}
+		data.Methods = append(data.Methods, meth)
+	}
+	data.CP = b.cp
+	k := &classloader.Klass{Status: 'X', Loader: "bootstrap", Data: &data}
+	classloader.MethAreaInsert(name, k)
+	initializedClasses.Delete(name) // the new class is not initialized yet
+	return k
+}
+
+// runs the code in test/Main.main(), using the given CP, and returns its frame
+func runMainTestCode(CP *classloader.CPool, code ...byte) (*frames.Frame, error) {
+	f := newFrame(code[0])
+	f.Meth = append(f.Meth, code[1:]...)
+	f.ClName = "test/Main"
+	f.MethName = "main"
+	f.CP = CP
+	fs := frames.CreateFrameStack()
+	fs.PushFront(&f)
+	err := runFrame(fs)
+	return &f, err
+}
+
+// sets up these classes and interfaces, whose int methods return the values shown:
+//
+//	interface Greeter { int greet(); default int hello() { return 2; } }
+//	interface LoudGreeter extends Greeter { default int hello() { return 3; } }
+//	class English implements Greeter { int greet() { return 1; } }
+//	class British extends English { }
+//	class Shouter implements Greeter, LoudGreeter { int greet() { return 4; } }
+//	class Mute implements Greeter { }   // does not implement greet()
+func setupInterfaceClasses() {
+	globals.InitGlobals("test")
+	log.Init()
+	classloader.InitMethodArea()
+	classloader.MTable = make(map[string]classloader.MTentry)
+
+	const public, abstract = 0x0001, 0x0400
+	addTestClass("java/lang/Object", "", nil)
+	addTestClass("test/Greeter", "java/lang/Object", nil,
+		testMethod{"greet", "()I", public | abstract, nil},
+		testMethod{"hello", "()I", public, []byte{ICONST_2, IRETURN}})
+	addTestClass("test/LoudGreeter", "java/lang/Object", []string{"test/Greeter"},
+		testMethod{"hello", "()I", public, []byte{ICONST_3, IRETURN}})
+	addTestClass("test/English", "java/lang/Object", []string{"test/Greeter"},
+		testMethod{"greet", "()I", public, []byte{ICONST_1, IRETURN}})
+	addTestClass("test/British", "test/English", nil)
+	addTestClass("test/Shouter", "java/lang/Object", []string{"test/Greeter", "test/LoudGreeter"},
+		testMethod{"greet", "()I", public, []byte{ICONST_4, IRETURN}})
+	addTestClass("test/Mute", "java/lang/Object", []string{"test/Greeter"})
+}
+
+// runs INVOKEINTERFACE (or INVOKEVIRTUAL) for the method of the interface (or class)
+// named refName on an object of the class, and returns the frame of the calling method
+func runInvoke(opcode byte, className, refName, methName string) (*frames.Frame, error) {
+	f := newFrame(opcode)
+	refType := uint16(classloader.MethodRef)
+	if opcode == INVOKEINTERFACE {
+		f.Meth = append(f.Meth, 0x00, 0x01, 0x01, 0x00) // CP slot 1, 1 slot of arguments
+		refType = classloader.Interface
+	} else {
+		f.Meth = append(f.Meth, 0x00, 0x01) // CP slot 1
+	}
+
+	CP := classloader.CPool{}
+	CP.CpIndex = make([]classloader.CpEntry, 7, 7)
+	CP.CpIndex[0] = classloader.CpEntry{Type: 0, Slot: 0}
+	CP.CpIndex[1] = classloader.CpEntry{Type: refType, Slot: 0}
+	CP.CpIndex[2] = classloader.CpEntry{Type: classloader.ClassRef, Slot: 0}
+	CP.CpIndex[3] = classloader.CpEntry{Type: classloader.UTF8, Slot: 0}
+	CP.CpIndex[4] = classloader.CpEntry{Type: classloader.NameAndType, Slot: 0}
+	CP.CpIndex[5] = classloader.CpEntry{Type: classloader.UTF8, Slot: 1}
+	CP.CpIndex[6] = classloader.CpEntry{Type: classloader.UTF8, Slot: 2}
+	CP.InterfaceRefs = []classloader.InterfaceRefEntry{{ClassIndex: 2, NameAndType: 4}}
+	CP.MethodRefs = []classloader.MethodRefEntry{{ClassIndex: 2, NameAndType: 4}}
+	CP.ClassRefs = []uint16{3}
+	CP.NameAndTypes = []classloader.NameAndTypeEntry{{NameIndex: 5, DescIndex: 6}}
+	CP.Utf8Refs = []string{refName, methName, "()I"}
+	f.CP = &CP
+
+	if className == "" {
+		push(&f, object.Null)
+	} else {
+		receiver := object.MakeEmptyObject()
+		receiver.Klass = &className
+		push(&f, receiver)
+	}
+
+	fs := frames.CreateFrameStack()
+	fs.PushFront(&f) // push the new frame
+	err := runFrame(fs)
+	return &f, err
+}
+
+// INVOKEINTERFACE: the methods run are selected by the class of the object, its
+// superclasses, and the default methods of its interfaces, in that order
+func TestInvokeinterface(t *testing.T) {
+	tests := []struct {
+		className string
+		methName  string
+		expected  int64
+	}{
+		{"test/English", "greet", 1}, // declared in the class
+		{"test/British", "greet", 1}, // declared in the superclass
+		{"test/English", "hello", 2}, // default method in the interface
+		{"test/British", "hello", 2}, // default method in the superclass's interface
+		{"test/Shouter", "hello", 3}, // default method in the most specific interface
+		{"test/Shouter", "greet", 4}} // declared in the class
+
+	for _, test := range tests {
+		setupInterfaceClasses()
+		f, err := runInvoke(INVOKEINTERFACE, test.className, "test/Greeter", test.methName)
+		if err != nil {
+			t.Errorf("INVOKEINTERFACE: Unexpected error calling %s.%s(): %s",
+				test.className, test.methName, err.Error())
+			continue
+		}
+
+		if f.TOS != 0 {
+			t.Errorf("INVOKEINTERFACE: Expected one value on the stack, got TOS: %d", f.TOS)
+			continue
+		}
+		if ret := pop(f).(int64); ret != test.expected {
+			t.Errorf("INVOKEINTERFACE: %s.%s() expected to return %d, got: %d",
+				test.className, test.methName, test.expected, ret)
+		}
+	}
+}
+
+// INVOKEINTERFACE: the selected method can be a Go method
+func TestInvokeinterfaceGoMethod(t *testing.T) {
+	setupInterfaceClasses()
+	addTestClass("test/NativeGreeter", "java/lang/Object", []string{"test/Greeter"})
+	classloader.MTable["test/NativeGreeter.greet()I"] = classloader.MTentry{
+		MType: 'G',
+		Meth: classloader.GmEntry{
+			ParamSlots: 1,
+			Fu:         func([]interface{}) interface{} { return int64(9) },
+		},
+	}
+
+	f, err := runInvoke(INVOKEINTERFACE, "test/NativeGreeter", "test/Greeter", "greet")
+	if err != nil {
+		t.Errorf("INVOKEINTERFACE: Unexpected error calling Go method: %s", err.Error())
+		return
+	}
+	if ret := pop(f).(int64); ret != 9 {
+		t.Errorf("INVOKEINTERFACE: Go method expected to return 9, got: %d", ret)
+	}
+}
+
+// INVOKEINTERFACE: errors detected by the JVM are thrown as Java exceptions
+func TestInvokeinterfaceExceptions(t *testing.T) {
+	tests := []struct {
+		className string
+		expected  string
+	}{
+		{"", "java.lang.NullPointerException: Cannot invoke \"test.Greeter.greet()\""},
+		{"test/Mute", "java.lang.AbstractMethodError: Receiver class test.Mute"},
+		{"test/British", ""}, // the control case: no error
+		{"test/Unrelated", "java.lang.IncompatibleClassChangeError: Class test.Unrelated " +
+			"does not implement the requested interface test.Greeter"}}
+
+	for _, test := range tests {
+		setupInterfaceClasses()
+		addTestClass("test/Unrelated", "java/lang/Object", nil)
+
+		normalStderr := os.Stderr
+		_, w, _ := os.Pipe()
+		os.Stderr = w
+
+		_, err := runInvoke(INVOKEINTERFACE, test.className, "test/Greeter", "greet")
+
+		_ = w.Close()
+		os.Stderr = normalStderr
+
+		if test.expected == "" {
+			if err != nil {
+				t.Errorf("INVOKEINTERFACE: Unexpected error: %s", err.Error())
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("INVOKEINTERFACE: Expected error %s, but got none", test.expected)
+		} else if !strings.HasPrefix(err.Error(), test.expected) {
+			t.Errorf("INVOKEINTERFACE: Expected error %s, got: %s", test.expected, err.Error())
+		}
+	}
+}
+
+// sets up the classes for the INVOKEVIRTUAL tests (in addition to those for the
+// INVOKEINTERFACE tests), whose int methods return the values shown:
+//
+//	class Animal { int sound() { return 1; } int legs() { return 4; } }
+//	class Bird extends Animal { int sound() { return 2; } int legs() { return 2; } }
+//	class Parrot extends Bird { int sound() { return 3; } }
+//	abstract class Shape { abstract int sound(); }
+//	class Blob extends Shape { }   // does not implement sound()
+func setupVirtualClasses() {
+	setupInterfaceClasses()
+
+	const public, abstract = 0x0001, 0x0400
+	addTestClass("test/Animal", "java/lang/Object", nil,
+		testMethod{"sound", "()I", public, []byte{ICONST_1, IRETURN}},
+		testMethod{"legs", "()I", public, []byte{ICONST_4, IRETURN}})
+	addTestClass("test/Bird", "test/Animal", nil,
+		testMethod{"legs", "()I", public, []byte{ICONST_2, IRETURN}},
+		testMethod{"sound", "()I", public, []byte{ICONST_2, IRETURN}})
+	addTestClass("test/Parrot", "test/Bird", nil,
+		testMethod{"sound", "()I", public, []byte{ICONST_3, IRETURN}})
+	addTestClass("test/Shape", "java/lang/Object", nil,
+		testMethod{"sound", "()I", public | abstract, nil})
+	addTestClass("test/Blob", "test/Shape", nil)
+}
+
+// INVOKEVIRTUAL: the method run is the one of the object's class, not the one
+// named in the methodRef, if the object's class overrides it
+func TestInvokevirtualOverriddenMethods(t *testing.T) {
+	tests := []struct {
+		className string
+		refName   string
+		methName  string
+		expected  int64
+	}{
+		{"test/Animal", "test/Animal", "sound", 1},
+		{"test/Bird", "test/Animal", "sound", 2},     // overridden in the subclass
+		{"test/Parrot", "test/Animal", "sound", 3},   // overridden twice
+		{"test/Parrot", "test/Bird", "sound", 3},     // overridden in a subclass of the named class
+		{"test/Parrot", "test/Animal", "legs", 2},    // overridden in the superclass
+		{"test/English", "test/English", "hello", 2}, // default method inherited from an interface
+	}
+
+	for _, test := range tests {
+		setupVirtualClasses()
+		f, err := runInvoke(INVOKEVIRTUAL, test.className, test.refName, test.methName)
+		if err != nil {
+			t.Errorf("INVOKEVIRTUAL: Unexpected error calling %s.%s() on %s: %s",
+				test.refName, test.methName, test.className, err.Error())
+			continue
+		}
+
+		if f.TOS != 0 {
+			t.Errorf("INVOKEVIRTUAL: Expected one value on the stack, got TOS: %d", f.TOS)
+			continue
+		}
+		if ret := pop(f).(int64); ret != test.expected {
+			t.Errorf("INVOKEVIRTUAL: %s.%s() on %s expected to return %d, got: %d",
+				test.refName, test.methName, test.className, test.expected, ret)
+		}
+	}
+}
+
+// INVOKEVIRTUAL: a null object and a missing implementation are thrown as exceptions
+func TestInvokevirtualExceptions(t *testing.T) {
+	tests := []struct {
+		className string
+		refName   string
+		expected  string
+	}{
+		{"", "test/Animal", "java.lang.NullPointerException: Cannot invoke \"test.Animal.sound()\""},
+		{"test/Blob", "test/Shape", "java.lang.AbstractMethodError: Receiver class test.Blob"}}
+
+	for _, test := range tests {
+		setupVirtualClasses()
+
+		normalStderr := os.Stderr
+		_, w, _ := os.Pipe()
+		os.Stderr = w
+
+		_, err := runInvoke(INVOKEVIRTUAL, test.className, test.refName, "sound")
+
+		_ = w.Close()
+		os.Stderr = normalStderr
+
+		if err == nil {
+			t.Errorf("INVOKEVIRTUAL: Expected error %s, but got none", test.expected)
+		} else if !strings.HasPrefix(err.Error(), test.expected) {
+			t.Errorf("INVOKEVIRTUAL: Expected error %s, got: %s", test.expected, err.Error())
+		}
+	}
+}
+
 // INVOKEVIRTUAL : invoke method -- here testing for error
 func TestInvokevirtualInvalid(t *testing.T) {
 	f := newFrame(INVOKEVIRTUAL)
@@ -346,7 +670,7 @@ func TestIremDivideByZero(t *testing.T) {
 	fs.PushFront(&f) // push the new frame
 	err := runFrame(fs)
 	errMsg := err.Error()
-	if !strings.Contains(errMsg, "divide by zero") {
+	if !strings.Contains(errMsg, "java.lang.ArithmeticException: / by zero") {
 		t.Errorf("IREM: Expected divide by zero error msg, got: %s", errMsg)
 	}
 }
@@ -978,7 +1302,7 @@ func TestLdivDivideByZero(t *testing.T) {
 	fs.PushFront(&f) // push the new frame
 	res := runFrame(fs)
 
-	if !strings.Contains(res.Error(), "Divide by zero") {
+	if !strings.Contains(res.Error(), "java.lang.ArithmeticException: / by zero") {
 		t.Errorf("LDIV: Expected err msg re divide by zero, got %s", res.Error())
 	}
 }
@@ -1157,6 +1481,61 @@ func TestLneg(t *testing.T) {
 	}
 }
 
+// appends an int32 to the bytecode in big-endian order, as in switch operands
+func appendInt32(code []byte, val int32) []byte {
+	return append(code, byte(val>>24), byte(val>>16), byte(val>>8), byte(val))
+}
+
+// creates a method consisting of nopCount NOPs, then a LOOKUPSWITCH with the
+// keys -10, 3, and 1000, then four RETURNs: the first is the default target,
+// the others are the targets of the keys, in order.
+func makeLookupswitch(nopCount int) (code []byte, targets []int) {
+	for i := 0; i < nopCount; i++ {
+		code = append(code, NOP)
+	}
+	basePC := len(code)
+	code = append(code, LOOKUPSWITCH)
+	for len(code)%4 != 0 { // padding
+		code = append(code, 0)
+	}
+
+	keys := []int32{-10, 3, 1000}
+	targetsStart := len(code) + 8 + len(keys)*8
+	targets = []int{targetsStart, targetsStart + 1, targetsStart + 2, targetsStart + 3}
+	code = appendInt32(code, int32(targets[0]-basePC)) // default
+	code = appendInt32(code, int32(len(keys)))         // npairs
+	for i, key := range keys {
+		code = appendInt32(code, key)
+		code = appendInt32(code, int32(targets[i+1]-basePC))
+	}
+	return append(code, RETURN, RETURN, RETURN, RETURN), targets
+}
+
+// LOOKUPSWITCH: jump to the target paired with the key, or to the default, at all four alignments
+func TestLookupswitch(t *testing.T) {
+	tests := []struct {
+		key    int64
+		target int // index into the targets: 0 is the default
+	}{{-10, 1}, {3, 2}, {1000, 3}, {0, 0}, {-100, 0}, {2000, 0}}
+
+	for nopCount := 0; nopCount < 4; nopCount++ {
+		code, targets := makeLookupswitch(nopCount)
+		for _, test := range tests {
+			f := newFrame(NOP)
+			f.Meth = code
+			push(&f, test.key)
+			fs := frames.CreateFrameStack()
+			fs.PushFront(&f) // push the new frame
+			_ = runFrame(fs)
+
+			if f.PC != targets[test.target] {
+				t.Errorf("LOOKUPSWITCH at PC %d: key %d, expected jump to %d, got: %d",
+					nopCount, test.key, targets[test.target], f.PC)
+			}
+		}
+	}
+}
+
 // LOR: Logical OR of two longs
 func TestLor(t *testing.T) {
 	f := newFrame(LOR)
@@ -1217,7 +1596,7 @@ func TestLremDivideByZero(t *testing.T) {
 	fs.PushFront(&f) // push the new frame
 	err := runFrame(fs)
 	errMsg := err.Error()
-	if !strings.Contains(errMsg, "divide by zero") {
+	if !strings.Contains(errMsg, "java.lang.ArithmeticException: / by zero") {
 		t.Errorf("LREM: Expected divide by zero error msg, got: %s", errMsg)
 	}
 }
@@ -1499,10 +1878,11 @@ func TestLxor(t *testing.T) {
 	}
 }
 
-// MONITORENTER: The JDK JVM does not implement this, nor do we. So just pop the ref off stack
+// MONITORENTER: lock the monitor of the object popped off the stack
 func TestMonitorEnter(t *testing.T) {
 	f := newFrame(MONITORENTER)
-	push(&f, &f) // push any value and make sure it gets popped off
+	obj := object.MakeEmptyObject()
+	push(&f, obj)
 
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
@@ -1511,20 +1891,31 @@ func TestMonitorEnter(t *testing.T) {
 	if f.TOS != -1 {
 		t.Errorf("MONITORENTER: Expected an empty stack, but got a tos of: %d", f.TOS)
 	}
+	if !obj.HoldsMonitor(f.Thread) {
+		t.Errorf("MONITORENTER: Expected the thread to hold the object's monitor")
+	}
 }
 
-// MONITOREXIT: The JDK JVM does not implement this, nor do we. So just pop the ref off stack
+// MONITOREXIT: unlock the monitor of the object popped off the stack
 func TestMonitorExit(t *testing.T) {
 	f := newFrame(MONITOREXIT)
-	push(&f, &f) // push any value and make sure it gets popped off
+	obj := object.MakeEmptyObject()
+	obj.MonitorEnter(f.Thread)
+	push(&f, obj)
 
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
-	_ = runFrame(fs)
+	err := runFrame(fs)
 
+	if err != nil {
+		t.Errorf("MONITOREXIT: Unexpected error: %v", err)
+	}
 	if f.TOS != -1 {
 		t.Errorf("MONITOREXIT: Expected an empty stack, but got a tos of: %d", f.TOS)
 	}
+	if obj.HoldsMonitor(f.Thread) {
+		t.Errorf("MONITOREXIT: Expected the object's monitor to be released")
+	}
 }
 
 // NEW: Instantiate object -- here with an error
@@ -1589,7 +1980,7 @@ func TestPopWithTracing(t *testing.T) {
 	MainThread.Stack = frames.CreateFrameStack()
 	// fs := frames.CreateFrameStack()
 	MainThread.Stack.PushFront(&f) // push the new frame
-	MainThread.Trace = true        // turn on tracing
+	f.Trace = true                 // turn on tracing
 	_ = runFrame(MainThread.Stack)
 
 	if f.TOS != 1 {
@@ -1602,9 +1993,6 @@ func TestPopWithTracing(t *testing.T) {
 		t.Errorf("POP: expected top's value to be 21, but got: %d", top)
 	}
 
-	if MainThread.Trace != true {
-		t.Errorf("POP: MainThread.Trace was not re-enabled after the POP execution")
-	}
 }
 
 // POP2: pop two items
@@ -1639,7 +2027,7 @@ func TestPop2WithTrace(t *testing.T) {
 	MainThread = thread.CreateThread()
 	MainThread.Stack = frames.CreateFrameStack()
 	MainThread.Stack.PushFront(&f) // push the new frame
-	MainThread.Trace = true        // turn on tracing
+	f.Trace = true                 // turn on tracing
 	_ = runFrame(MainThread.Stack)
 
 	if f.TOS != 0 {
@@ -1652,9 +2040,6 @@ func TestPop2WithTrace(t *testing.T) {
 		t.Errorf("POP2: expected top's value to be 34, but got: %d", top)
 	}
 
-	if MainThread.Trace != true {
-		t.Errorf("POP2: MainThread.Trace was not re-enabled after the POP2 execution")
-	}
 }
 
 // PUTFIELD: Update a non-static field
@@ -1909,6 +2294,76 @@ func TestSwap(t *testing.T) {
 	}
 }
 
+// creates a method consisting of nopCount NOPs, then a TABLESWITCH for cases
+// 1 to 3, then four RETURNs: the first is the default target, the others are
+// the targets of cases 1 to 3. Varying nopCount tests the padding.
+func makeTableswitch(nopCount int) (code []byte, targets []int) {
+	for i := 0; i < nopCount; i++ {
+		code = append(code, NOP)
+	}
+	basePC := len(code)
+	code = append(code, TABLESWITCH)
+	for len(code)%4 != 0 { // padding
+		code = append(code, 0)
+	}
+
+	targetsStart := len(code) + 12 + 3*4
+	targets = []int{targetsStart, targetsStart + 1, targetsStart + 2, targetsStart + 3}
+	code = appendInt32(code, int32(targets[0]-basePC)) // default
+	code = appendInt32(code, 1)                        // low
+	code = appendInt32(code, 3)                        // high
+	for _, target := range targets[1:] {
+		code = appendInt32(code, int32(target-basePC))
+	}
+	return append(code, RETURN, RETURN, RETURN, RETURN), targets
+}
+
+// TABLESWITCH: jump to the case for the index, or to the default, at all four alignments
+func TestTableswitch(t *testing.T) {
+	tests := []struct {
+		index  int64
+		target int // index into the targets: 0 is the default
+	}{{1, 1}, {2, 2}, {3, 3}, {0, 0}, {4, 0}, {-5, 0}}
+
+	for nopCount := 0; nopCount < 4; nopCount++ {
+		code, targets := makeTableswitch(nopCount)
+		for _, test := range tests {
+			f := newFrame(NOP)
+			f.Meth = code
+			push(&f, test.index)
+			fs := frames.CreateFrameStack()
+			fs.PushFront(&f) // push the new frame
+			_ = runFrame(fs)
+
+			if f.PC != targets[test.target] {
+				t.Errorf("TABLESWITCH at PC %d: index %d, expected jump to %d, got: %d",
+					nopCount, test.index, targets[test.target], f.PC)
+			}
+		}
+	}
+}
+
+// the trace of a switch instruction should show its cases and default target
+func TestSwitchTraceData(t *testing.T) {
+	f := newFrame(NOP)
+	f.Meth, _ = makeTableswitch(1)
+	f.PC = 1
+	push(&f, int64(2))
+	trace := emitTraceData(&f)
+	if !strings.Contains(trace, "TABLESWITCH") || !strings.Contains(trace, "low: 1 high: 3 default: 28") {
+		t.Errorf("TABLESWITCH: unexpected trace data: %s", trace)
+	}
+
+	f = newFrame(NOP)
+	f.Meth, _ = makeLookupswitch(2)
+	f.PC = 2
+	push(&f, int64(3))
+	trace = emitTraceData(&f)
+	if !strings.Contains(trace, "LOOKUPSWITCH") || !strings.Contains(trace, "pairs: 3 default: 36") {
+		t.Errorf("LOOKUPSWITCH: unexpected trace data: %s", trace)
+	}
+}
+
 func TestInvalidInstruction(t *testing.T) {
 	// set the logger to low granularity, so that logging messages are not also captured in this test
 	Global := globals.InitGlobals("test")
@@ -1930,7 +2385,7 @@ func TestInvalidInstruction(t *testing.T) {
 	MainThread = thread.CreateThread()
 	MainThread.Stack = frames.CreateFrameStack()
 	MainThread.Stack.PushFront(&f) // push the new frame
-	MainThread.Trace = false       // turn off tracing
+	f.Trace = false                // turn off tracing
 	ret := runFrame(MainThread.Stack)
 
 	if ret == nil {
@@ -19,6 +19,7 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 	"unsafe"
 )
 
@@ -283,6 +284,210 @@ func TestAstore3(t *testing.T) {
 	}
 }
 
+// set up for the ATHROW tests: loads the method area with an exception class
+// (test/MyException) whose superclass is test/MyBaseException, and returns a CP
+// whose entry [2] is a ClassRef to the superclass.
+func setupExceptionClasses() *classloader.CPool {
+	classloader.InitMethodArea()
+	classloader.MethAreaInsert("test/MyBaseException",
+		&(classloader.Klass{
+			Status: 'X',
+			Loader: "bootstrap",
+			Data:   &classloader.ClData{Superclass: "java/lang/Object"},
+		}))
+	classloader.MethAreaInsert("test/MyException",
+		&(classloader.Klass{
+			Status: 'X',
+			Loader: "bootstrap",
+			Data:   &classloader.ClData{Superclass: "test/MyBaseException"},
+		}))
+
+	CP := classloader.CPool{}
+	CP.CpIndex = make([]classloader.CpEntry, 10, 10)
+	CP.CpIndex[0] = classloader.CpEntry{Type: 0, Slot: 0}
+	CP.CpIndex[1] = classloader.CpEntry{Type: classloader.UTF8, Slot: 0}
+	CP.CpIndex[2] = classloader.CpEntry{Type: classloader.ClassRef, Slot: 0}
+	CP.ClassRefs = append(CP.ClassRefs, 1) // point to record 1 in CP: Utf8 for class name
+	CP.Utf8Refs = append(CP.Utf8Refs, "test/MyBaseException")
+	return &CP
+}
+
+// An exception whose class is still being loaded (by the goroutine that preloads
+// the classes a class refers to) is matched with a handler once the load is done
+func TestCatchTypeOfClassBeingLoaded(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	setupExceptionClasses()
+
+	classloader.MethAreaReserve("test/LateException")
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		classloader.MethAreaInsert("test/LateException",
+			&(classloader.Klass{
+				Status: 'X',
+				Loader: "bootstrap",
+				Data:   &classloader.ClData{Superclass: "test/MyBaseException"},
+			}))
+		classloader.MethAreaLoadDone("test/LateException", nil)
+	}()
+	if !isClassOrSubclassOf("test/LateException", "test/MyBaseException") {
+		t.Error("Expected the exception to match its superclass once its class was loaded")
+	}
+}
+
+// ATHROW: exception caught by a handler for its superclass in the same method
+func TestAthrowCaughtInSameFrame(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	f := newFrame(ATHROW)
+	f.Meth = append(f.Meth, NOP) // the exception handler
+	f.CP = setupExceptionClasses()
+	f.ExcTable = []classloader.CodeException{
+		{StartPc: 0, EndPc: 1, HandlerPc: 1, CatchType: 2}}
+
+	className := "test/MyException"
+	exc := object.MakeEmptyObject()
+	exc.Klass = &className
+	push(&f, int64(42)) // this should be cleared from the stack by the throw
+	push(&f, exc)
+
+	fs := frames.CreateFrameStack()
+	fs.PushFront(&f) // push the new frame
+	err := runFrame(fs)
+
+	if err != nil {
+		t.Errorf("ATHROW: Expected exception to be caught, but got error: %s", err.Error())
+	}
+
+	if f.TOS != 0 {
+		t.Errorf("ATHROW: Expected TOS to be 0, got %d", f.TOS)
+	}
+
+	if pop(&f).(*object.Object) != exc {
+		t.Errorf("ATHROW: Expected the exception to be on the stack at the handler")
+	}
+}
+
+// ATHROW: exception not caught in the method, so returned as an error
+func TestAthrowUncaught(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	f := newFrame(ATHROW)
+	f.Meth = append(f.Meth, NOP)
+	f.CP = setupExceptionClasses()
+	f.ExcTable = []classloader.CodeException{ // handler does not cover the ATHROW
+		{StartPc: 1, EndPc: 2, HandlerPc: 1, CatchType: 2}}
+
+	className := "test/MyException"
+	msg := "bad input"
+	exc := object.MakeEmptyObject()
+	exc.Klass = &className
+	exc.FieldTable = make(map[string]object.Field)
+	exc.FieldTable["detailMessage"] = object.Field{
+		Ftype: "Ljava/lang/String;", Fvalue: object.CreateCompactStringFromGoString(&msg)}
+	push(&f, exc)
+
+	fs := frames.CreateFrameStack()
+	fs.PushFront(&f) // push the new frame
+	err := runFrame(fs)
+
+	if err == nil {
+		t.Errorf("ATHROW: Expected an uncaught exception, but got no error")
+		return
+	}
+
+	jt, ok := err.(*javaThrowable)
+	if !ok {
+		t.Errorf("ATHROW: Expected a thrown exception, but got: %s", err.Error())
+		return
+	}
+
+	if jt.obj != exc {
+		t.Errorf("ATHROW: Thrown exception does not point to the exception object")
+	}
+
+	if err.Error() != "test.MyException: bad input" {
+		t.Errorf("ATHROW: Expected 'test.MyException: bad input', got: %s", err.Error())
+	}
+}
+
+// ATHROW: exception thrown in one method and caught by the handler in its caller
+func TestAthrowCaughtInCaller(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	caller := newFrame(INVOKESTATIC)
+	caller.Meth = append(caller.Meth, 0x00, 0x01, NOP) // NOP at 3 is the handler
+	caller.CP = setupExceptionClasses()
+	caller.ExcTable = []classloader.CodeException{
+		{StartPc: 0, EndPc: 3, HandlerPc: 3, CatchType: 2}}
+	caller.PC = 2 // where INVOKESTATIC leaves the PC
+
+	callee := newFrame(ATHROW)
+	callee.CP = caller.CP
+
+	fs := frames.CreateFrameStack()
+	fs.PushFront(&caller)
+	fs.PushFront(&callee)
+
+	className := "test/MyException"
+	exc := object.MakeEmptyObject()
+	exc.Klass = &className
+	push(&callee, exc)
+
+	err := runFrame(fs)
+	if err == nil {
+		t.Errorf("ATHROW: Expected an uncaught exception in the callee, but got no error")
+		return
+	}
+
+	f, err := catchInCaller(fs, err)
+	if err != nil {
+		t.Errorf("ATHROW: Expected exception to be caught by the caller, got: %s", err.Error())
+		return
+	}
+
+	if fs.Len() != 1 || f != &caller {
+		t.Errorf("ATHROW: Expected the callee's frame to be popped off the frame stack")
+	}
+
+	if f.PC != 3 {
+		t.Errorf("ATHROW: Expected PC to be at the handler (3), got %d", f.PC)
+	}
+
+	if pop(f).(*object.Object) != exc {
+		t.Errorf("ATHROW: Expected the exception to be on the caller's stack")
+	}
+}
+
+// ATHROW: throwing a null reference is an error
+func TestAthrowNull(t *testing.T) {
+	g := globals.GetGlobalRef()
+	globals.InitGlobals("test")
+	g.JacobinName = "test"
+	log.Init()
+
+	// redirect stderr to avoid printing error message to console
+	normalStderr := os.Stderr
+	_, w, _ := os.Pipe()
+	os.Stderr = w
+
+	f := newFrame(ATHROW)
+	push(&f, object.Null)
+
+	fs := frames.CreateFrameStack()
+	fs.PushFront(&f) // push the new frame
+	err := runFrame(fs)
+
+	os.Stderr = normalStderr // restore stderr
+
+	if err == nil {
+		t.Errorf("ATHROW: Expected an error on throwing null, but did not get one")
+	}
+}
+
 // BIPUSH
 func TestBipush(t *testing.T) {
 	f := newFrame(BIPUSH)
@@ -453,6 +658,50 @@ func TestCheckcastOfInvalidReference(t *testing.T) {
 	}
 }
 
+// CHECKCAST: a ClassCastException inside a try block is caught by the handler
+// in the method's exception table
+func TestCheckcastOfInvalidReferenceCaught(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	classloader.InitMethodArea()
+	classloader.MethAreaInsert("java/lang/ClassCastException",
+		&(classloader.Klass{
+			Status: 'X',
+			Loader: "bootstrap",
+			Data:   &classloader.ClData{Superclass: "java/lang/Object"},
+		}))
+
+	// [2] is a ClassRef that points to the UTF8 string in [1]
+	CP := classloader.CPool{}
+	CP.CpIndex = make([]classloader.CpEntry, 10, 10)
+	CP.CpIndex[0] = classloader.CpEntry{Type: 0, Slot: 0}
+	CP.CpIndex[1] = classloader.CpEntry{Type: classloader.UTF8, Slot: 0}
+	CP.CpIndex[2] = classloader.CpEntry{Type: classloader.ClassRef, Slot: 0}
+	CP.ClassRefs = append(CP.ClassRefs, 1)
+	CP.Utf8Refs = append(CP.Utf8Refs, "java/lang/ClassCastException")
+
+	f := newFrame(CHECKCAST)
+	f.Meth = append(f.Meth, 0x00, 0x02, NOP) // the NOP is the exception handler
+	f.CP = &CP
+	f.ExcTable = []classloader.CodeException{
+		{StartPc: 0, EndPc: 3, HandlerPc: 3, CatchType: 2}}
+	push(&f, float64(42.0)) // not a reference, so the cast fails
+	fs := frames.CreateFrameStack()
+	fs.PushFront(&f) // push the new frame
+	err := runFrame(fs)
+
+	if err != nil {
+		t.Errorf("CHECKCAST: Expected the exception to be caught, but got: %s", err.Error())
+		return
+	}
+
+	exc := pop(&f).(*object.Object)
+	if exc == nil || *exc.Klass != "java/lang/ClassCastException" {
+		t.Errorf("CHECKCAST: Expected a ClassCastException object on the stack at the handler")
+	}
+}
+
 // D2F: test convert double to float
 func TestD2f(t *testing.T) {
 	f := newFrame(D2F)
@@ -1968,6 +2217,39 @@ func TestGetFieldInvalidFieldEntry(t *testing.T) {
 	}
 }
 
+// GETFIELD: Get a field from a null reference, which throws a NullPointerException
+func TestGetFieldNullReference(t *testing.T) {
+	f := newFrame(GETFIELD)
+	f.Meth = append(f.Meth, 0x00)
+	f.Meth = append(f.Meth, 0x01) // Go to slot 0x0001 in the CP
+
+	// [1] is a FieldRef pointing to the NameAndType in [2], whose name is in [3]
+	CP := classloader.CPool{}
+	CP.CpIndex = make([]classloader.CpEntry, 10, 10)
+	CP.CpIndex[0] = classloader.CpEntry{Type: 0, Slot: 0}
+	CP.CpIndex[1] = classloader.CpEntry{Type: classloader.FieldRef, Slot: 0}
+	CP.CpIndex[2] = classloader.CpEntry{Type: classloader.NameAndType, Slot: 0}
+	CP.CpIndex[3] = classloader.CpEntry{Type: classloader.UTF8, Slot: 0}
+	CP.FieldRefs = append(CP.FieldRefs, classloader.FieldRefEntry{ClassIndex: 0, NameAndType: 2})
+	CP.NameAndTypes = append(CP.NameAndTypes, classloader.NameAndTypeEntry{NameIndex: 3, DescIndex: 0})
+	CP.Utf8Refs = append(CP.Utf8Refs, "count")
+	f.CP = &CP
+
+	push(&f, object.Null)
+	fs := frames.CreateFrameStack()
+	fs.PushFront(&f) // push the new frame
+	err := runFrame(fs)
+
+	if err == nil {
+		t.Errorf("GETFIELD: Expected a NullPointerException, but got no error")
+		return
+	}
+
+	if err.Error() != "java.lang.NullPointerException: Cannot read field \"count\"" {
+		t.Errorf("GETFIELD: Expected a different error, got: %s", err.Error())
+	}
+}
+
 // GETSTATIC: Get a static field's value (here, with error that it's not a fieldref)
 func TestGetStaticInvalidFieldEntry(t *testing.T) {
 	f := newFrame(GETSTATIC)
@@ -1995,7 +2277,9 @@ func TestGetStaticBoolean(t *testing.T) {
 	f.Meth = append(f.Meth, 0x00)
 	f.Meth = append(f.Meth, 0x01) // Go to slot 0x0001 in the CP
 
-	classloader.StaticsPreload() // load the statics table with the String class
+	classloader.InitMethodArea()
+	addTestClass("java/lang/String", "", nil) // GETSTATIC initializes the class
+	classloader.StaticsPreload()              // load the statics table with the String class
 
 	CP := classloader.CPool{}
 	CP.CpIndex = make([]classloader.CpEntry, 10, 10)
@@ -2274,23 +2558,73 @@ func TestIdivDivideByZero(t *testing.T) {
 	hread := thread.CreateThread()
 	hread.Stack = fs
 	hread.ID = thread.AddThreadToTable(&hread, &g.Threads)
-	_ = runFrame(fs)
+	err := runFrame(fs)
 
 	// restore stderr and stdout to what they were before
 	_ = w.Close()
-	out, _ := io.ReadAll(r)
+	_, _ = io.ReadAll(r)
 	os.Stderr = normalStderr
 
-	errMsg := string(out[:])
+	errMsg := err.Error()
 
 	_ = wout.Close()
 	os.Stdout = normalStdout
 
-	if !strings.Contains(errMsg, "Arithmetic Exception") {
+	if !strings.Contains(errMsg, "java.lang.ArithmeticException: / by zero") {
 		t.Errorf("IDIV: Did not get expected error msg, got: %s", errMsg)
 	}
 }
 
+// IDIV: divide by zero inside a try block, so the ArithmeticException is caught
+// by the handler in the method's exception table
+func TestIdivDivideByZeroCaught(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	classloader.InitMethodArea()
+	classloader.MethAreaInsert("java/lang/ArithmeticException",
+		&(classloader.Klass{
+			Status: 'X',
+			Loader: "bootstrap",
+			Data:   &classloader.ClData{Superclass: "java/lang/Object"},
+		}))
+
+	// [2] is a ClassRef that points to the UTF8 string in [1]
+	CP := classloader.CPool{}
+	CP.CpIndex = make([]classloader.CpEntry, 10, 10)
+	CP.CpIndex[0] = classloader.CpEntry{Type: 0, Slot: 0}
+	CP.CpIndex[1] = classloader.CpEntry{Type: classloader.UTF8, Slot: 0}
+	CP.CpIndex[2] = classloader.CpEntry{Type: classloader.ClassRef, Slot: 0}
+	CP.ClassRefs = append(CP.ClassRefs, 1)
+	CP.Utf8Refs = append(CP.Utf8Refs, "java/lang/ArithmeticException")
+
+	f := newFrame(IDIV)
+	f.Meth = append(f.Meth, NOP) // the exception handler
+	f.CP = &CP
+	f.ExcTable = []classloader.CodeException{
+		{StartPc: 0, EndPc: 1, HandlerPc: 1, CatchType: 2}}
+	push(&f, int64(220))
+	push(&f, int64(0))
+	fs := frames.CreateFrameStack()
+	fs.PushFront(&f) // push the new frame
+	err := runFrame(fs)
+
+	if err != nil {
+		t.Errorf("IDIV: Expected the exception to be caught, but got: %s", err.Error())
+		return
+	}
+
+	if f.TOS != 0 {
+		t.Errorf("IDIV: Expected TOS to be 0, got %d", f.TOS)
+		return
+	}
+
+	exc := pop(&f).(*object.Object)
+	if exc == nil || *exc.Klass != "java/lang/ArithmeticException" {
+		t.Errorf("IDIV: Expected an ArithmeticException object on the stack at the handler")
+	}
+}
+
 // ICONST_M1:
 func TestIconstN1(t *testing.T) {
 	f := newFrame(ICONST_M1)
@@ -0,0 +1,248 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"errors"
+	"fmt"
+	"jacobin/globals"
+	"jacobin/log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"unicode"
+)
+
+// Source-file mode (JEP 330) runs a program directly from a .java file, e.g.,
+// jacobin Hello.java. The file is compiled with the javac in JAVA_HOME into a
+// temporary directory, which is put at the front of the classpath, and the first
+// top-level class in the file (or the file's unnamed class) is run as the main class.
+
+// compileSourceFile compiles the source file given on the command line. It returns
+// the path of the main class's class file and the temporary directory holding the
+// compiled classes, which the caller removes when the program ends.
+func compileSourceFile(gl *globals.Globals) (string, string, error) {
+	source, err := os.ReadFile(gl.StartingSourceFile)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "error: file not found: %s\n", gl.StartingSourceFile)
+		return "", "", err
+	}
+	mainClass, err := sourceMainClass(gl.StartingSourceFile, string(source))
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "error: %s: %s\n", err.Error(), gl.StartingSourceFile)
+		return "", "", err
+	}
+
+	outDir, err := os.MkdirTemp("", "jacobin-source-")
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "error: unable to create a directory for the compiled classes: %s\n",
+			err.Error())
+		return "", "", err
+	}
+
+	javac := filepath.Join(gl.JavaHome, "bin", "javac")
+	if runtime.GOOS == "windows" {
+		javac += ".exe"
+	}
+	args := []string{"-d", outDir, "-proc:none", "-Xdiags:verbose", "-Xlint:deprecation"}
+	if gl.SourceVersion != "" {
+		args = append(args, "--source", gl.SourceVersion)
+	}
+	if len(gl.Classpath) > 0 {
+		args = append(args, "-cp", strings.Join(gl.Classpath, string(os.PathListSeparator)))
+	}
+	args = append(args, gl.StartingSourceFile)
+	_ = log.Log("Compiling source file: "+javac+" "+strings.Join(args, " "), log.FINE)
+
+	cmd := exec.Command(javac, args...)
+	cmd.Stdout = os.Stderr // javac's diagnostics go to the user, as in the JDK
+	cmd.Stderr = os.Stderr
+	if err = cmd.Run(); err != nil {
+		_ = os.RemoveAll(outDir)
+		if errors.Is(err, os.ErrNotExist) {
+			_, _ = fmt.Fprintf(os.Stderr, "error: source-file mode requires javac, which was not found at %s\n",
+				javac)
+		}
+		_, _ = fmt.Fprintln(os.Stderr, "error: compilation failed")
+		return "", "", err
+	}
+
+	// the other classes in the source file are loaded from the output directory
+	gl.Classpath = append([]string{outDir}, gl.Classpath...)
+	return filepath.Join(outDir, filepath.FromSlash(mainClass)+".class"), outDir, nil
+}
+
+// sourceMainClass returns the name, in java/lang/String format, of the first
+// top-level class, interface, enum, or record declared in the Java source. If the
+// source declares methods or fields outside of any class, it's an unnamed class
+// (JEP 445), which is named after the file.
+func sourceMainClass(fileName, source string) (string, error) {
+	tokens := javaTokens(source)
+	pkg := ""
+	mainClass := ""
+	member := false // the tokens since the last declaration might begin a method or field
+	for i := 0; i < len(tokens); i++ {
+		switch tokens[i] {
+		case "package":
+			for i++; i < len(tokens) && tokens[i] != ";"; i++ {
+				pkg += tokens[i]
+			}
+		case "import":
+			for i++; i < len(tokens) && tokens[i] != ";"; i++ {
+			}
+		case "@":
+			if i+1 < len(tokens) && tokens[i+1] != "interface" {
+				i = skipAnnotation(tokens, i+1)
+			}
+		case "class", "interface", "enum", "record":
+			if i+1 >= len(tokens) || !isJavaIdentifier(tokens[i+1]) || (i > 0 && tokens[i-1] == ".") {
+				member = true // not a declaration (e.g., String.class)
+				continue
+			}
+			if mainClass == "" {
+				mainClass = tokens[i+1]
+			}
+			member = false
+			i = skipBody(tokens, i)
+		case "(", "=", "{": // a method, field, or initializer outside a class
+			return unnamedClassName(fileName)
+		case ";":
+			if member {
+				return unnamedClassName(fileName)
+			}
+		default:
+			member = true
+		}
+	}
+
+	if mainClass == "" {
+		return "", errors.New("no class declared in source file")
+	}
+	if pkg != "" {
+		return strings.ReplaceAll(pkg, ".", "/") + "/" + mainClass, nil
+	}
+	return mainClass, nil
+}
+
+// unnamedClassName returns the name of the unnamed class in the source file,
+// which is the file's name without the .java extension
+func unnamedClassName(fileName string) (string, error) {
+	name := strings.TrimSuffix(filepath.Base(fileName), ".java")
+	if !isJavaIdentifier(name) {
+		return "", errors.New("the name of a source file with an unnamed class must be a valid identifier")
+	}
+	return name, nil
+}
+
+// skipAnnotation returns the index of the last token of the annotation whose name
+// begins at tokens[from], including its parenthesized elements, if any
+func skipAnnotation(tokens []string, from int) int {
+	i := from
+	for i+2 < len(tokens) && tokens[i+1] == "." { // a qualified name
+		i += 2
+	}
+	if i+1 < len(tokens) && tokens[i+1] == "(" {
+		return skipNested(tokens, i+1, "(", ")")
+	}
+	return i
+}
+
+// skipBody returns the index of the closing brace of the body of the class
+// declared at tokens[from]
+func skipBody(tokens []string, from int) int {
+	for i := from; i < len(tokens); i++ {
+		if tokens[i] == "{" {
+			return skipNested(tokens, i, "{", "}")
+		}
+	}
+	return len(tokens) - 1
+}
+
+// skipNested returns the index of the close token that matches the open token
+// at tokens[from], or the index of the last token if there's no match
+func skipNested(tokens []string, from int, open, close string) int {
+	depth := 0
+	for i := from; i < len(tokens); i++ {
+		switch tokens[i] {
+		case open:
+			depth++
+		case close:
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(tokens) - 1
+}
+
+// javaTokens splits Java source into identifiers and punctuation, leaving out
+// whitespace, comments, and literals, which can't affect sourceMainClass()
+func javaTokens(source string) []string {
+	var tokens []string
+	runes := []rune(source)
+	for i := 0; i < len(runes); i++ {
+		ch := runes[i]
+		switch {
+		case unicode.IsSpace(ch):
+		case ch == '/' && i+1 < len(runes) && runes[i+1] == '/': // a line comment
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+		case ch == '/' && i+1 < len(runes) && runes[i+1] == '*': // a block comment
+			i = skipPast(runes, i+2, "*/")
+		case ch == '"' && strings.HasPrefix(string(runes[i:]), `"""`): // a text block
+			i = skipPast(runes, i+3, `"""`)
+		case ch == '"' || ch == '\'': // a string or char literal
+			for i++; i < len(runes) && runes[i] != ch && runes[i] != '\n'; i++ {
+				if runes[i] == '\\' {
+					i++
+				}
+			}
+		case isJavaIdentifierStart(ch):
+			start := i
+			for i+1 < len(runes) && isJavaIdentifierPart(runes[i+1]) {
+				i++
+			}
+			tokens = append(tokens, string(runes[start:i+1]))
+		default:
+			tokens = append(tokens, string(ch))
+		}
+	}
+	return tokens
+}
+
+// skipPast returns the index of the last rune of the first occurrence of end at or
+// after runes[from], or the index of the last rune if end doesn't occur
+func skipPast(runes []rune, from int, end string) int {
+	endRunes := []rune(end)
+	for i := from; i+len(endRunes) <= len(runes); i++ {
+		if string(runes[i:i+len(endRunes)]) == end {
+			return i + len(endRunes) - 1
+		}
+	}
+	return len(runes) - 1
+}
+
+func isJavaIdentifierStart(ch rune) bool {
+	return unicode.IsLetter(ch) || ch == '_' || ch == '$'
+}
+
+func isJavaIdentifierPart(ch rune) bool {
+	return isJavaIdentifierStart(ch) || unicode.IsDigit(ch)
+}
+
+func isJavaIdentifier(s string) bool {
+	for i, ch := range s {
+		if !isJavaIdentifierPart(ch) || (i == 0 && !isJavaIdentifierStart(ch)) {
+			return false
+		}
+	}
+	return s != ""
+}
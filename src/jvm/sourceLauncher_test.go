@@ -0,0 +1,115 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"io"
+	"jacobin/globals"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestSourceMainClass(t *testing.T) {
+	tests := []struct {
+		source, expected string
+	}{
+		{"public class Hello { public static void main(String[] args) {} }", "Hello"},
+		{"/* class NotThis */ // class NorThis\n" +
+			"package com.example.tools;\n" +
+			"import java.util.List;\n" +
+			"@Deprecated(since = \"class Nope {\")\n" +
+			"final class Tool { class Inner {} }\n" +
+			"class Helper {}", "com/example/tools/Tool"},
+		{"@interface Marker {} class Main {}", "Marker"},
+		{"record Point(int x, int y) { static Class<?> c = Point.class; }", "Point"},
+		{"enum Color { RED; String s = \"\"\"\n    class Hidden {}\n    \"\"\"; }", "Color"},
+		{"import java.util.*;\n@SuppressWarnings(\"unused\") class Helper {}\nvoid main() {}", "Script"},
+		{"// class Commented {}\nint x = 1;", "Script"},
+		{"static String greeting;", "Script"},
+	}
+	for _, test := range tests {
+		mainClass, err := sourceMainClass("scripts/Script.java", test.source)
+		if err != nil {
+			t.Errorf("Unexpected error for %q: %s", test.source, err.Error())
+		} else if mainClass != test.expected {
+			t.Errorf("Expected main class %s for %q, got: %s", test.expected, test.source, mainClass)
+		}
+	}
+
+	if _, err := sourceMainClass("Empty.java", "// class Commented {}\n"); err == nil {
+		t.Errorf("Expected an error for source without a class")
+	}
+	if _, err := sourceMainClass("my-script.java", "void main() {}"); err == nil {
+		t.Errorf("Expected an error for an unnamed class in a file whose name is not an identifier")
+	}
+}
+
+// javac is run with the expected args, and its output directory goes at the front of the classpath
+func TestCompileSourceFile(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the stand-in for javac is a shell script")
+	}
+	global := globals.InitGlobals("test")
+	dir := t.TempDir()
+	global.JavaHome = dir
+	global.Classpath = []string{"lib"}
+	global.SourceVersion = "17"
+	global.StartingSourceFile = filepath.Join(dir, "Hello.java")
+	_ = os.WriteFile(global.StartingSourceFile, []byte("package demo; class Hello {}"), 0644)
+
+	// the stand-in javac records its args in javac.args
+	_ = os.Mkdir(filepath.Join(dir, "bin"), 0755)
+	script := "#!/bin/sh\necho \"$@\" > " + filepath.Join(dir, "javac.args") + "\n"
+	_ = os.WriteFile(filepath.Join(dir, "bin", "javac"), []byte(script), 0755)
+
+	classFile, outDir, err := compileSourceFile(&global)
+	if err != nil {
+		t.Fatalf("Unexpected error compiling source file: %s", err.Error())
+	}
+	defer os.RemoveAll(outDir)
+
+	if classFile != filepath.Join(outDir, "demo", "Hello.class") {
+		t.Errorf("Expected the main class file in %s, got: %s", outDir, classFile)
+	}
+	if len(global.Classpath) != 2 || global.Classpath[0] != outDir || global.Classpath[1] != "lib" {
+		t.Errorf("Expected the classpath to be [%s lib], got: %v", outDir, global.Classpath)
+	}
+	args, _ := os.ReadFile(filepath.Join(dir, "javac.args"))
+	expected := "-d " + outDir + " -proc:none -Xdiags:verbose -Xlint:deprecation --source 17 -cp lib " +
+		global.StartingSourceFile
+	if strings.TrimSpace(string(args)) != expected {
+		t.Errorf("Expected javac args: %s, got: %s", expected, string(args))
+	}
+}
+
+func TestCompileSourceFileWithoutJavac(t *testing.T) {
+	global := globals.InitGlobals("test")
+	dir := t.TempDir()
+	global.JavaHome = dir
+	global.StartingSourceFile = filepath.Join(dir, "Hello.java")
+	_ = os.WriteFile(global.StartingSourceFile, []byte("class Hello {}"), 0644)
+
+	normalStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+
+	_, _, err := compileSourceFile(&global)
+
+	_ = w.Close()
+	os.Stderr = normalStderr
+	out, _ := io.ReadAll(r)
+
+	if err == nil {
+		t.Errorf("Expected an error when javac is missing")
+	}
+	if !strings.Contains(string(out), "error: compilation failed") {
+		t.Errorf("Unexpected error message: %s", string(out))
+	}
+}
@@ -0,0 +1,95 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"container/list"
+	"jacobin/exceptions"
+	"jacobin/frames"
+	"jacobin/globals"
+	"strconv"
+	"strings"
+)
+
+// Two limits keep a runaway program from crashing Jacobin. First, each frame's
+// operand stack holds no more than the method's max_stack entries: push() and pop()
+// check every access and, should an instruction overflow or underflow the stack
+// (which the verifier rules out for verified classes), the method fails with a
+// VerifyError that shows where. Second, a thread holds no more than
+// globals.MaxFrameDepth frames (set by -Xss): a call nested more deeply throws a
+// StackOverflowError, which the program can catch, rather than exhausting the stack
+// of the goroutine that runs the thread, which would end the process.
+
+// maxFrameDepth is the most frames -Xss can allow. Each frame of bytecode takes up
+// a few KB of the goroutine's stack, which Go limits to 1 GB.
+const maxFrameDepth = 100_000
+
+// operandStackError is the panic raised by push() and pop() when the frame's
+// operand stack overflows or underflows. runFrame() recovers it and returns it as
+// a VerifyError. (Returning an error from push() and pop() would burden their many
+// callers, for an error that can only occur in unverified code.)
+type operandStackError struct {
+	f      *frames.Frame
+	reason string
+}
+
+// checkPush panics if there is no room on the operand stack for another value
+func checkPush(f *frames.Frame) {
+	if f.TOS+1 >= len(f.OpStack) {
+		panic(&operandStackError{f: f, reason: "Exceeded max stack size."})
+	}
+}
+
+// checkPop panics if the operand stack is empty
+func checkPop(f *frames.Frame) {
+	if f.TOS < 0 || f.TOS >= len(f.OpStack) {
+		panic(&operandStackError{f: f, reason: "Attempt to pop empty stack."})
+	}
+}
+
+// recoverOperandStackError is deferred by runFrame(). If the panic is an
+// operandStackError, it sets *err to the VerifyError for it, after popping any frames
+// that were pushed onto the frame stack above the frame that runFrame() is running
+// (which is left at the head of the frame stack, as for any other exception).
+// Other panics are passed on.
+func recoverOperandStackError(fs *list.List, running *frames.Frame, err *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	ose, ok := r.(*operandStackError)
+	if !ok {
+		panic(r)
+	}
+
+	for fs.Len() > 1 && fs.Front().Value.(*frames.Frame) != running {
+		fs.Remove(fs.Front())
+	}
+
+	location := ose.f.ClName + "." + ose.f.MethName + " @" + strconv.Itoa(ose.f.PC)
+	if ose.f.PC >= 0 && ose.f.PC < len(ose.f.Meth) {
+		location += ": " + strings.ToLower(BytecodeNames[ose.f.Meth[ose.f.PC]])
+	}
+	gist := "Operand stack overflow"
+	if strings.HasPrefix(ose.reason, "Attempt to pop") {
+		gist = "Operand stack underflow"
+	}
+	*err = newVMThrowable(fs, exceptions.VerifyError, verifyErrorMessage(gist, location, ose.reason))
+}
+
+// checkFrameDepth returns a StackOverflowError if the frame stack holds more frames
+// than a thread is allowed. If the limit hasn't been set, the default applies.
+func checkFrameDepth(fs *list.List) error {
+	limit := globals.GetGlobalRef().MaxFrameDepth
+	if limit <= 0 {
+		limit = globals.DefaultThreadStackSize / globals.FrameStackSize
+	}
+	if fs.Len() > limit {
+		return newVMThrowable(fs, exceptions.StackOverflowError, "")
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"jacobin/classloader"
+	"jacobin/frames"
+	"jacobin/globals"
+	"jacobin/log"
+	"jacobin/object"
+	"strings"
+	"testing"
+)
+
+// runs the code in a frame whose operand stack holds maxStack values
+func runOperandStackTestCode(maxStack int, code ...byte) error {
+	f := frames.CreateFrame(maxStack)
+	f.ClName = "test/Stack"
+	f.MethName = "run"
+	f.Meth = code
+	fs := frames.CreateFrameStack()
+	fs.PushFront(f)
+	return runFrame(fs)
+}
+
+func TestOperandStackOverflow(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	err := runOperandStackTestCode(2, ICONST_1, ICONST_2, ICONST_3, RETURN)
+	jt, ok := err.(*javaThrowable)
+	if !ok || jt.className != "java/lang/VerifyError" {
+		t.Fatalf("Expected a VerifyError, got: %v", err)
+	}
+	for _, detail := range []string{"Operand stack overflow", "test/Stack.run @2: iconst_3",
+		"Exceeded max stack size."} {
+		if !strings.Contains(jt.msg, detail) {
+			t.Errorf("Expected %q in the message, got: %s", detail, jt.msg)
+		}
+	}
+}
+
+func TestOperandStackUnderflow(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	err := runOperandStackTestCode(2, ICONST_1, POP, POP, RETURN)
+	jt, ok := err.(*javaThrowable)
+	if !ok || jt.className != "java/lang/VerifyError" {
+		t.Fatalf("Expected a VerifyError, got: %v", err)
+	}
+	for _, detail := range []string{"Operand stack underflow", "test/Stack.run @2: pop",
+		"Attempt to pop empty stack."} {
+		if !strings.Contains(jt.msg, detail) {
+			t.Errorf("Expected %q in the message, got: %s", detail, jt.msg)
+		}
+	}
+}
+
+// sets up this class, and returns the CP of the test code and the CP indexes
+// of its two methods:
+//
+//	class Deep {
+//	    static Throwable caught;
+//	    static void recurse() { recurse(); }
+//	    static int tryRecurse() {
+//	        try { recurse(); return 0; } catch (StackOverflowError e) { caught = e; return 1; }
+//	    }
+//	}
+func setupDeepClass() (*classloader.CPool, uint16, uint16) {
+	setupInterfaceClasses()
+	addInitExceptionClasses()
+	addTestClass("java/lang/VirtualMachineError", "java/lang/Error", nil)
+	addTestClass("java/lang/StackOverflowError", "java/lang/VirtualMachineError", nil)
+	classloader.Statics = make(map[string]classloader.Static)
+
+	b := newCPBuilder()
+	recurse := b.methodRef("test/Deep", "recurse", "()V")
+	soe := b.class("java/lang/StackOverflowError")
+	caught := b.fieldRef("test/Deep", "caught", "Ljava/lang/Throwable;")
+	addClass("test/Deep", "java/lang/Object", nil, b, []testField{{"caught", "Ljava/lang/Throwable;", true}},
+		testMethod{"recurse", "()V", accStatic, []byte{INVOKESTATIC, 0x00, byte(recurse), RETURN}},
+		testMethod{"tryRecurse", "()I", accStatic, []byte{
+			INVOKESTATIC, 0x00, byte(recurse), ICONST_0, IRETURN,
+			PUTSTATIC, 0x00, byte(caught), ICONST_1, IRETURN}})
+	k := classloader.MethAreaFetch("test/Deep")
+	k.Data.Methods[1].CodeAttr.Exceptions = []classloader.CodeException{
+		{StartPc: 0, EndPc: 3, HandlerPc: 5, CatchType: soe}}
+
+	callers := newCPBuilder()
+	return &callers.cp, callers.methodRef("test/Deep", "recurse", "()V"),
+		callers.methodRef("test/Deep", "tryRecurse", "()I")
+}
+
+func TestStackOverflowError(t *testing.T) {
+	CP, recurse, _ := setupDeepClass()
+	globals.GetGlobalRef().MaxFrameDepth = 50
+
+	_, err := runMainTestCode(CP, INVOKESTATIC, 0x00, byte(recurse), RETURN)
+	jt, ok := err.(*javaThrowable)
+	if !ok || jt.className != "java/lang/StackOverflowError" {
+		t.Fatalf("Expected a StackOverflowError, got: %v", err)
+	}
+	if len(jt.trace) != 51 || jt.trace[0].methodName != "recurse" || jt.trace[50].methodName != "main" {
+		t.Errorf("Expected a stack trace of 50 calls of recurse() from main(), got: %v", jt.trace)
+	}
+}
+
+// with the default stack size, the recursion goes thousands of frames deep before
+// the StackOverflowError, which the program catches. The stack trace is truncated.
+func TestStackOverflowErrorIsCaught(t *testing.T) {
+	CP, _, tryRecurse := setupDeepClass()
+
+	f, err := runMainTestCode(CP, INVOKESTATIC, 0x00, byte(tryRecurse), RETURN)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if f.OpStack[0] != int64(1) {
+		t.Fatalf("Expected the StackOverflowError to be caught, got: %v", f.OpStack[0])
+	}
+
+	exc, ok := classloader.Statics["test/Deep.caught"].Value.(*object.Object)
+	if !ok {
+		t.Fatalf("Expected the StackOverflowError to be stored in Deep.caught")
+	}
+	jt := newThrowableFromObject(exc)
+	if jt.className != "java/lang/StackOverflowError" || len(jt.trace) != maxStackTraceDepth {
+		t.Errorf("Expected a StackOverflowError with a stack trace of %d elements, got: %s with %d",
+			maxStackTraceDepth, jt.className, len(jt.trace))
+	}
+}
@@ -0,0 +1,359 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"encoding/binary"
+	"errors"
+	"jacobin/classloader"
+	"strconv"
+	"strings"
+)
+
+// The verifier tracks the type of every local variable and operand stack slot. The
+// types are the verification types of the JVM spec, see:
+// https://docs.oracle.com/javase/specs/jvms/se17/html/jvms-4.html#jvms-4.10.1.2
+// As in the JVM, a long or double takes two slots: the second one is top.
+
+// the kinds of verification types
+const (
+	vtTop = iota
+	vtInt
+	vtFloat
+	vtLong
+	vtDouble
+	vtNull
+	vtUninitThis // this in a constructor, before the superclass's constructor is called
+	vtUninit     // an object created by NEW whose constructor has not been called
+	vtRef
+)
+
+// vType is a verification type
+type vType struct {
+	tag    int
+	class  string // for vtRef, the class in java/lang/String format, or the array type, e.g., [I
+	offset int    // for vtUninit, the offset of the NEW instruction that created the object
+}
+
+var (
+	topType    = vType{tag: vtTop}
+	intType    = vType{tag: vtInt}
+	floatType  = vType{tag: vtFloat}
+	longType   = vType{tag: vtLong}
+	doubleType = vType{tag: vtDouble}
+	nullType   = vType{tag: vtNull}
+)
+
+func refType(class string) vType {
+	return vType{tag: vtRef, class: class}
+}
+
+// isCategory2 reports whether the type takes two slots
+func (t vType) isCategory2() bool {
+	return t.tag == vtLong || t.tag == vtDouble
+}
+
+// isReference reports whether the type is a reference, including null and
+// objects that are not yet initialized
+func (t vType) isReference() bool {
+	return t.tag == vtRef || t.tag == vtNull || t.tag == vtUninitThis || t.tag == vtUninit
+}
+
+// String returns the type as the JDK shows it in a VerifyError
+func (t vType) String() string {
+	switch t.tag {
+	case vtInt:
+		return "integer"
+	case vtFloat:
+		return "float"
+	case vtLong:
+		return "long"
+	case vtDouble:
+		return "double"
+	case vtNull:
+		return "null"
+	case vtUninitThis:
+		return "uninitializedThis"
+	case vtUninit:
+		return "uninitialized(" + strconv.Itoa(t.offset) + ")"
+	case vtRef:
+		if t.class == "" {
+			return "reference type"
+		}
+		return "'" + t.class + "'"
+	default:
+		return "top"
+	}
+}
+
+// descriptorType returns the verification type of a field descriptor, e.g., I or
+// Ljava/lang/String;. Booleans, bytes, chars, and shorts are ints. The second
+// return is false if the descriptor is invalid.
+func descriptorType(desc string) (vType, bool) {
+	if desc == "" {
+		return topType, false
+	}
+	switch desc[0] {
+	case 'B', 'C', 'I', 'S', 'Z':
+		return intType, len(desc) == 1
+	case 'F':
+		return floatType, len(desc) == 1
+	case 'J':
+		return longType, len(desc) == 1
+	case 'D':
+		return doubleType, len(desc) == 1
+	case 'L':
+		if len(desc) < 3 || !strings.HasSuffix(desc, ";") {
+			return topType, false
+		}
+		return refType(desc[1 : len(desc)-1]), true
+	case '[':
+		if _, ok := descriptorType(strings.TrimLeft(desc, "[")); !ok {
+			return topType, false
+		}
+		return refType(desc), true
+	}
+	return topType, false
+}
+
+// methodDescriptorTypes returns the types of the parameters and the return type of
+// a method descriptor, e.g., (I[JLjava/lang/String;)V. The return type of void
+// methods is top. The last return is false if the descriptor is invalid.
+func methodDescriptorTypes(desc string) ([]vType, vType, bool) {
+	var params []vType
+	if !strings.HasPrefix(desc, "(") {
+		return nil, topType, false
+	}
+	i := 1
+	for i < len(desc) && desc[i] != ')' {
+		end := i
+		for end < len(desc) && desc[end] == '[' {
+			end++
+		}
+		if end < len(desc) && desc[end] == 'L' {
+			semicolon := strings.IndexByte(desc[end:], ';')
+			if semicolon < 0 {
+				return nil, topType, false
+			}
+			end += semicolon
+		}
+		if end >= len(desc) {
+			return nil, topType, false
+		}
+		param, ok := descriptorType(desc[i : end+1])
+		if !ok {
+			return nil, topType, false
+		}
+		params = append(params, param)
+		i = end + 1
+	}
+	if i >= len(desc) {
+		return nil, topType, false
+	}
+
+	returnDesc := desc[i+1:]
+	if returnDesc == "V" {
+		return params, topType, true
+	}
+	ret, ok := descriptorType(returnDesc)
+	return params, ret, ok
+}
+
+// expandTypes converts a list of types, such as those of a method's parameters, into
+// slots, in which each long and double is followed by top
+func expandTypes(types []vType) []vType {
+	slots := make([]vType, 0, len(types))
+	for _, t := range types {
+		slots = append(slots, t)
+		if t.isCategory2() {
+			slots = append(slots, topType)
+		}
+	}
+	return slots
+}
+
+// typeFrame holds the types of the local variables and of the operand stack at
+// an instruction. thisUninit is set in constructors until this is initialized.
+type typeFrame struct {
+	locals     []vType
+	stack      []vType
+	thisUninit bool
+}
+
+func (fr *typeFrame) copy() *typeFrame {
+	return &typeFrame{
+		locals:     append([]vType(nil), fr.locals...),
+		stack:      append([]vType(nil), fr.stack...),
+		thisUninit: fr.thisUninit,
+	}
+}
+
+// stackMapFrame is a frame of the StackMapTable attribute. Its locals and stack
+// list one entry for each long and double, as in the attribute.
+type stackMapFrame struct {
+	offset int
+	locals []vType
+	stack  []vType
+}
+
+// parseStackMapTable parses the StackMapTable attribute of a method's Code attribute,
+// whose layout is described here:
+// https://docs.oracle.com/javase/specs/jvms/se17/html/jvms-4.html#jvms-4.7.4
+// The frames are deltas from the previous frame, the first of which is the method's
+// initial frame, whose locals are given.
+func parseStackMapTable(content []byte, cp *classloader.CPool, initialLocals []vType) ([]stackMapFrame, error) {
+	r := byteReader{data: content}
+	count := r.u2()
+	frames := make([]stackMapFrame, 0, count)
+	locals := initialLocals
+	offset := -1
+	for i := 0; i < count && r.err == nil; i++ {
+		frameType := r.u1()
+		var delta int
+		var stack []vType
+		switch {
+		case frameType <= 63: // same_frame
+			delta = frameType
+		case frameType <= 127: // same_locals_1_stack_item_frame
+			delta = frameType - 64
+			stack = []vType{r.typeInfo(cp)}
+		case frameType < 247:
+			return nil, errors.New("reserved frame type " + strconv.Itoa(frameType) + " in StackMapTable")
+		case frameType == 247: // same_locals_1_stack_item_frame_extended
+			delta = r.u2()
+			stack = []vType{r.typeInfo(cp)}
+		case frameType <= 250: // chop_frame
+			delta = r.u2()
+			chop := 251 - frameType
+			if chop > len(locals) {
+				return nil, errors.New("chop_frame removes more locals than there are in StackMapTable")
+			}
+			locals = locals[:len(locals)-chop]
+		case frameType == 251: // same_frame_extended
+			delta = r.u2()
+		case frameType <= 254: // append_frame
+			delta = r.u2()
+			appended := append([]vType(nil), locals...)
+			for j := 0; j < frameType-251; j++ {
+				appended = append(appended, r.typeInfo(cp))
+			}
+			locals = appended
+		default: // full_frame
+			delta = r.u2()
+			locals = make([]vType, r.u2())
+			for j := range locals {
+				locals[j] = r.typeInfo(cp)
+			}
+			stack = make([]vType, r.u2())
+			for j := range stack {
+				stack[j] = r.typeInfo(cp)
+			}
+		}
+
+		offset += delta + 1
+		frames = append(frames, stackMapFrame{offset: offset, locals: locals, stack: stack})
+	}
+
+	if r.err != nil {
+		return nil, r.err
+	}
+	if r.pos != len(content) {
+		return nil, errors.New("extra bytes at the end of StackMapTable")
+	}
+	return frames, nil
+}
+
+// byteReader reads the big-endian values of an attribute. After an error, such
+// as reading past the end of the data, it returns zeros.
+type byteReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *byteReader) u1() int {
+	if r.err != nil || r.pos+1 > len(r.data) {
+		r.fail()
+		return 0
+	}
+	r.pos++
+	return int(r.data[r.pos-1])
+}
+
+func (r *byteReader) u2() int {
+	if r.err != nil || r.pos+2 > len(r.data) {
+		r.fail()
+		return 0
+	}
+	r.pos += 2
+	return int(binary.BigEndian.Uint16(r.data[r.pos-2:]))
+}
+
+func (r *byteReader) fail() {
+	if r.err == nil {
+		r.err = errors.New("truncated StackMapTable")
+	}
+}
+
+// typeInfo reads a verification_type_info entry
+func (r *byteReader) typeInfo(cp *classloader.CPool) vType {
+	switch tag := r.u1(); tag {
+	case 0:
+		return topType
+	case 1:
+		return intType
+	case 2:
+		return floatType
+	case 3:
+		return doubleType
+	case 4:
+		return longType
+	case 5:
+		return nullType
+	case 6:
+		return vType{tag: vtUninitThis}
+	case 7:
+		class, ok := cpClassName(cp, r.u2())
+		if !ok && r.err == nil {
+			r.err = errors.New("invalid class in StackMapTable")
+		}
+		return refType(class)
+	case 8:
+		return vType{tag: vtUninit, offset: r.u2()}
+	default:
+		if r.err == nil {
+			r.err = errors.New("invalid verification type " + strconv.Itoa(tag) + " in StackMapTable")
+		}
+		return topType
+	}
+}
+
+// cpClassName returns the name of the class in the ClassRef CP entry at index. The
+// second return is false if the entry is not a valid ClassRef.
+func cpClassName(cp *classloader.CPool, index int) (string, bool) {
+	if index < 1 || index >= len(cp.CpIndex) || cp.CpIndex[index].Type != classloader.ClassRef {
+		return "", false
+	}
+	slot := int(cp.CpIndex[index].Slot)
+	if slot >= len(cp.ClassRefs) {
+		return "", false
+	}
+	return cpUTF8(cp, int(cp.ClassRefs[slot]))
+}
+
+// cpUTF8 returns the string in the UTF8 CP entry at index. Unlike
+// classloader.FetchUTF8stringFromCPEntryNumber(), it doesn't log invalid
+// entries: the verifier reports them. The second return is false if the entry
+// is not a UTF8 entry.
+func cpUTF8(cp *classloader.CPool, index int) (string, bool) {
+	if index < 1 || index >= len(cp.CpIndex) || cp.CpIndex[index].Type != classloader.UTF8 ||
+		int(cp.CpIndex[index].Slot) >= len(cp.Utf8Refs) {
+		return "", false
+	}
+	s := cp.Utf8Refs[cp.CpIndex[index].Slot]
+	return s, s != ""
+}
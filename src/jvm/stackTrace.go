@@ -0,0 +1,145 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"container/list"
+	"fmt"
+	"jacobin/classloader"
+	"jacobin/frames"
+	"jacobin/object"
+	"strings"
+)
+
+// the values used for the line number of a stack trace element when no line
+// number is available. These are the same values the JDK uses.
+const (
+	lineUnknown = -1 // the method's class has no LineNumberTable
+	lineNative  = -2 // a native (in Jacobin, a golang) method
+)
+
+// maxStackTraceDepth is the most elements a stack trace holds, as in the JDK (whose
+// -XX:MaxJavaStackTraceDepth defaults to this). It keeps the stack traces of
+// StackOverflowErrors to a reasonable size.
+const maxStackTraceDepth = 1024
+
+// stackTraceElement is one frame in the stack trace of an exception. It holds the
+// same data as java.lang.StackTraceElement.
+type stackTraceElement struct {
+	className  string // in java/lang/Object format
+	methodName string
+	sourceFile string // "" if the class has no SourceFile attribute
+	line       int    // lineUnknown or lineNative if no line number is available
+}
+
+// String returns the element in the format used by the JDK in stack traces, e.g.:
+// com.example.Main.run(Main.java:27)
+func (ste stackTraceElement) String() string {
+	var location string
+	switch {
+	case ste.line == lineNative:
+		location = "Native Method"
+	case ste.sourceFile == "":
+		location = "Unknown Source"
+	case ste.line >= 0:
+		location = fmt.Sprintf("%s:%d", ste.sourceFile, ste.line)
+	default:
+		location = ste.sourceFile
+	}
+	return strings.ReplaceAll(ste.className, "/", ".") + "." + ste.methodName + "(" + location + ")"
+}
+
+// captureStackTrace returns the stack trace made up of the frame in elem and all the
+// frames below it on the frame stack (up to maxStackTraceDepth of them), starting
+// with the most recently called method.
+func captureStackTrace(elem *list.Element) []stackTraceElement {
+	trace := []stackTraceElement{}
+	for e := elem; e != nil && len(trace) < maxStackTraceDepth; e = e.Next() {
+		f := e.Value.(*frames.Frame)
+		if isLambdaProxy(f.ClName) {
+			continue
+		}
+		trace = append(trace, newStackTraceElement(f))
+	}
+	return trace
+}
+
+// newStackTraceElement creates the stack trace element for a frame. For frames
+// executing bytecode, the line number is that of the instruction at the frame's PC.
+func newStackTraceElement(f *frames.Frame) stackTraceElement {
+	ste := stackTraceElement{className: f.ClName, methodName: f.MethName}
+	if f.Ftype == 'G' {
+		// the name of a golang method includes its signature, which the JDK omits
+		ste.methodName, _, _ = strings.Cut(f.MethName, "(")
+		ste.line = lineNative
+	} else {
+		ste.line = sourceLineForPC(f.LineTable, f.PC)
+	}
+
+	if classloader.MethArea != nil {
+		k := classloader.MethAreaFetch(f.ClName)
+		if k != nil && k.Data != nil {
+			ste.sourceFile = k.Data.SourceFile
+		}
+	}
+	return ste
+}
+
+// sourceLineForPC returns the source line of the bytecode at pc. Per the JVM spec,
+// that's the entry with the highest starting PC that is not past pc. (Entries
+// may be in any order.) Returns lineUnknown if there's no such entry.
+func sourceLineForPC(lineTable []classloader.BytecodeToSourceLine, pc int) int {
+	line := lineUnknown
+	bestPos := -1
+	for _, entry := range lineTable {
+		if entry.BytecodePos <= pc && entry.BytecodePos > bestPos {
+			bestPos = entry.BytecodePos
+			line = entry.SourceLine
+		}
+	}
+	return line
+}
+
+// stackTraceString returns the exception and its stack trace, followed by the
+// same for each of its causes, in the format of Throwable.printStackTrace().
+// The frames a cause has in common with the exception it caused are elided,
+// as in the JDK. The string ends with a newline.
+func stackTraceString(jt *javaThrowable) string {
+	var sb strings.Builder
+	sb.WriteString(jt.Error() + "\n")
+	for _, ste := range jt.trace {
+		sb.WriteString("\tat " + ste.String() + "\n")
+	}
+
+	enclosingTrace := jt.trace
+	seen := map[*object.Object]bool{jt.obj: true}
+	for cause := jt.cause(); cause != nil; cause = cause.cause() {
+		if seen[cause.obj] { // the chain of causes loops back on itself
+			break
+		}
+		seen[cause.obj] = true
+
+		// find the frames at the bottom of the stack that the two traces share
+		m := len(cause.trace) - 1
+		n := len(enclosingTrace) - 1
+		for m >= 0 && n >= 0 && cause.trace[m] == enclosingTrace[n] {
+			m--
+			n--
+		}
+		framesInCommon := len(cause.trace) - 1 - m
+
+		sb.WriteString("Caused by: " + cause.Error() + "\n")
+		for i := 0; i <= m; i++ {
+			sb.WriteString("\tat " + cause.trace[i].String() + "\n")
+		}
+		if framesInCommon != 0 {
+			sb.WriteString(fmt.Sprintf("\t... %d more\n", framesInCommon))
+		}
+		enclosingTrace = cause.trace
+	}
+	return sb.String()
+}
@@ -0,0 +1,169 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"jacobin/classloader"
+	"jacobin/frames"
+	"jacobin/globals"
+	"jacobin/log"
+	"jacobin/object"
+	"testing"
+)
+
+// creates an exception object whose fields are held in a FieldTable
+func makeTestException(className string, trace []stackTraceElement) *object.Object {
+	exc := object.MakeEmptyObject()
+	exc.Klass = &className
+	exc.FieldTable = make(map[string]object.Field)
+	exc.FieldTable["detailMessage"] = object.Field{Ftype: "Ljava/lang/String;", Fvalue: object.Null}
+	exc.FieldTable["cause"] = object.Field{Ftype: "Ljava/lang/Throwable;", Fvalue: exc}
+	exc.FieldTable["backtrace"] = object.Field{Ftype: "Ljava/lang/Object;", Fvalue: trace}
+	return exc
+}
+
+func TestSourceLineForPC(t *testing.T) {
+	lineTable := []classloader.BytecodeToSourceLine{
+		{BytecodePos: 0, SourceLine: 10},
+		{BytecodePos: 8, SourceLine: 12}, // entries need not be in order
+		{BytecodePos: 4, SourceLine: 11},
+	}
+
+	tests := []struct{ pc, line int }{{0, 10}, {3, 10}, {4, 11}, {7, 11}, {8, 12}, {20, 12}}
+	for _, test := range tests {
+		if line := sourceLineForPC(lineTable, test.pc); line != test.line {
+			t.Errorf("sourceLineForPC: expected line %d for PC %d, got: %d", test.line, test.pc, line)
+		}
+	}
+
+	if line := sourceLineForPC(nil, 5); line != lineUnknown {
+		t.Errorf("sourceLineForPC: expected %d for a missing line table, got: %d", lineUnknown, line)
+	}
+}
+
+func TestStackTraceElementString(t *testing.T) {
+	tests := []struct {
+		ste      stackTraceElement
+		expected string
+	}{
+		{stackTraceElement{"test/Main", "run", "Main.java", 27}, "test.Main.run(Main.java:27)"},
+		{stackTraceElement{"test/Main", "run", "Main.java", lineUnknown}, "test.Main.run(Main.java)"},
+		{stackTraceElement{"test/Main", "run", "", 27}, "test.Main.run(Unknown Source)"},
+		{stackTraceElement{"java/lang/Throwable", "fillInStackTrace", "Throwable.java", lineNative},
+			"java.lang.Throwable.fillInStackTrace(Native Method)"},
+	}
+
+	for _, test := range tests {
+		if test.ste.String() != test.expected {
+			t.Errorf("stackTraceElement: expected %s, got: %s", test.expected, test.ste.String())
+		}
+	}
+}
+
+// a division by zero two frames down should produce a trace with the source
+// lines of the instruction in each frame
+func TestVMExceptionStackTrace(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	classloader.InitMethodArea()
+	classloader.MethAreaInsert("test/Main",
+		&(classloader.Klass{
+			Status: 'X',
+			Loader: "bootstrap",
+			Data:   &classloader.ClData{Superclass: "java/lang/Object", SourceFile: "Main.java"},
+		}))
+
+	caller := newFrame(NOP)
+	caller.Meth = append(caller.Meth, INVOKESTATIC, 0x00, 0x01)
+	caller.ClName = "test/Main"
+	caller.MethName = "main"
+	caller.PC = 1 // at the INVOKESTATIC
+	caller.LineTable = []classloader.BytecodeToSourceLine{
+		{BytecodePos: 0, SourceLine: 5}, {BytecodePos: 1, SourceLine: 7}}
+
+	callee := newFrame(IDIV)
+	callee.ClName = "test/Main"
+	callee.MethName = "divide"
+	callee.LineTable = []classloader.BytecodeToSourceLine{{BytecodePos: 0, SourceLine: 11}}
+	push(&callee, int64(3))
+	push(&callee, int64(0))
+
+	fs := frames.CreateFrameStack()
+	fs.PushFront(&caller)
+	fs.PushFront(&callee)
+	err := runFrame(fs)
+
+	jt, ok := err.(*javaThrowable)
+	if !ok {
+		t.Errorf("IDIV: expected a thrown exception, got: %v", err)
+		return
+	}
+
+	expected := "java.lang.ArithmeticException: / by zero\n" +
+		"\tat test.Main.divide(Main.java:11)\n" +
+		"\tat test.Main.main(Main.java:7)\n"
+	if stackTraceString(jt) != expected {
+		t.Errorf("IDIV: expected stack trace:\n%s\ngot:\n%s", expected, stackTraceString(jt))
+	}
+}
+
+// fillInStackTrace() should omit its own frame and the exception's constructors
+func TestFillInStackTraceSkipsConstructors(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	setupExceptionClasses()
+
+	frameFor := func(className, methName string, ftype byte) *frames.Frame {
+		f := frames.CreateFrame(1)
+		f.ClName = className
+		f.MethName = methName
+		f.Ftype = ftype
+		return f
+	}
+
+	fs := frames.CreateFrameStack()
+	fs.PushFront(frameFor("test/Main", "main", 'J'))
+	fs.PushFront(frameFor("test/MyException", "<init>", 'J'))
+	fs.PushFront(frameFor("test/MyBaseException", "<init>", 'J'))
+	fs.PushFront(frameFor("java/lang/Throwable", "fillInStackTrace()Ljava/lang/Throwable;", 'G'))
+
+	exc := makeTestException("test/MyException", nil)
+	ret := fillInStackTrace([]interface{}{exc, fs})
+	if ret != exc {
+		t.Errorf("fillInStackTrace: expected the exception to be returned")
+	}
+
+	trace := newThrowableFromObject(exc).trace
+	if len(trace) != 1 || trace[0].methodName != "main" {
+		t.Errorf("fillInStackTrace: expected a trace of only main(), got: %v", trace)
+	}
+}
+
+// the frames a cause shares with the exception it caused are shown as "... n more"
+func TestStackTraceStringWithCause(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	run := stackTraceElement{"test/Main", "run", "Main.java", 20}
+	main := stackTraceElement{"test/Main", "main", "Main.java", 5}
+	parse := stackTraceElement{"test/Parser", "parse", "Parser.java", 42}
+
+	cause := makeTestException("java/lang/NumberFormatException", []stackTraceElement{parse, run, main})
+	exc := makeTestException("java/lang/IllegalStateException", []stackTraceElement{run, main})
+	exc.FieldTable["cause"] = object.Field{Ftype: "Ljava/lang/Throwable;", Fvalue: cause}
+
+	expected := "java.lang.IllegalStateException\n" +
+		"\tat test.Main.run(Main.java:20)\n" +
+		"\tat test.Main.main(Main.java:5)\n" +
+		"Caused by: java.lang.NumberFormatException\n" +
+		"\tat test.Parser.parse(Parser.java:42)\n" +
+		"\t... 2 more\n"
+	actual := stackTraceString(newThrowableFromObject(exc))
+	if actual != expected {
+		t.Errorf("stackTraceString: expected:\n%s\ngot:\n%s", expected, actual)
+	}
+}
@@ -0,0 +1,257 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"container/list"
+	"fmt"
+	"jacobin/classloader"
+	"jacobin/frames"
+	"jacobin/object"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Since Java 9, javac compiles string concatenation, such as "a" + x + "b", into
+// an INVOKEDYNAMIC whose bootstrap method is StringConcatFactory.makeConcatWithConstants().
+// Its first static argument is a recipe: the concatenated string in which \u0001
+// marks where the next argument goes and \u0002 where the next of the remaining
+// static arguments (the constants) goes. Jacobin performs the concatenation in Go.
+
+const (
+	recipeArg      = '\u0001'
+	recipeConstant = '\u0002'
+)
+
+// stringConcatCallSite is a call site linked by StringConcatFactory
+type stringConcatCallSite struct {
+	parts    []concatPart // the recipe, with the constants already inserted
+	argTypes []string     // the types of the arguments, as field descriptors
+}
+
+// concatPart is either literal text or an argument of a concatenation
+type concatPart struct {
+	literal string
+	arg     int // the index of the argument, or -1 if the part is literal text
+}
+
+// linkStringConcat links a call site whose bootstrap method is
+// StringConcatFactory.makeConcatWithConstants() or makeConcat(), which takes
+// no recipe and concatenates all the arguments.
+func linkStringConcat(f *frames.Frame, bsi *bootstrapInfo) (*stringConcatCallSite, error) {
+	argTypes, _ := methodTypeParts(bsi.desc)
+	site := stringConcatCallSite{argTypes: argTypes}
+
+	var recipe string
+	var constants []uint16
+	if bsi.methodName == "makeConcat" {
+		recipe = strings.Repeat(string(recipeArg), len(argTypes))
+	} else {
+		if len(bsi.args) < 1 {
+			return nil, fmt.Errorf("Missing recipe for %s%s", bsi.name, bsi.desc)
+		}
+		cpe := FetchCPentry(f.CP, int(bsi.args[0]))
+		if cpe.entryType != classloader.UTF8 {
+			return nil, fmt.Errorf("Expected a recipe string at CP entry %d", bsi.args[0])
+		}
+		recipe = *cpe.stringVal
+		constants = bsi.args[1:]
+	}
+
+	var literal strings.Builder
+	argCount := 0
+	for _, ch := range recipe {
+		switch ch {
+		case recipeArg:
+			if literal.Len() > 0 {
+				site.parts = append(site.parts, concatPart{literal: literal.String(), arg: -1})
+				literal.Reset()
+			}
+			site.parts = append(site.parts, concatPart{arg: argCount})
+			argCount++
+		case recipeConstant:
+			if len(constants) == 0 {
+				return nil, fmt.Errorf("Missing constant for recipe of %s%s", bsi.name, bsi.desc)
+			}
+			constant, err := concatConstant(f.CP, constants[0])
+			if err != nil {
+				return nil, err
+			}
+			literal.WriteString(constant)
+			constants = constants[1:]
+		default:
+			literal.WriteRune(ch)
+		}
+	}
+	if literal.Len() > 0 {
+		site.parts = append(site.parts, concatPart{literal: literal.String(), arg: -1})
+	}
+
+	if argCount != len(argTypes) {
+		return nil, fmt.Errorf("Recipe of %s%s has %d arguments, expected: %d",
+			bsi.name, bsi.desc, argCount, len(argTypes))
+	}
+	return &site, nil
+}
+
+// concatConstant returns the text of a constant inserted by a recipe
+func concatConstant(CP *classloader.CPool, cpIndex uint16) (string, error) {
+	cpe := FetchCPentry(CP, int(cpIndex))
+	switch cpe.entryType {
+	case classloader.UTF8:
+		return *cpe.stringVal, nil
+	case classloader.IntConst, classloader.LongConst:
+		return strconv.FormatInt(cpe.intVal, 10), nil
+	case classloader.FloatConst:
+		return javaFloatString(cpe.floatVal, 32), nil
+	case classloader.DoubleConst:
+		return javaFloatString(cpe.floatVal, 64), nil
+	}
+	return "", fmt.Errorf("Invalid constant for string concatenation at CP entry %d", cpIndex)
+}
+
+// run pops the arguments and pushes the concatenated string
+func (site *stringConcatCallSite) run(fs *list.List, f *frames.Frame) error {
+	args := make([]interface{}, len(site.argTypes))
+	for i := len(args) - 1; i >= 0; i-- {
+		if isWide(site.argTypes[i]) {
+			pop(f) // longs and doubles take two slots
+		}
+		args[i] = pop(f)
+	}
+
+	var sb strings.Builder
+	for _, part := range site.parts {
+		if part.arg < 0 {
+			sb.WriteString(part.literal)
+			continue
+		}
+		s, err := concatArgString(fs, args[part.arg], site.argTypes[part.arg])
+		if err != nil {
+			return err
+		}
+		sb.WriteString(s)
+	}
+
+	result := sb.String()
+	push(f, object.CreateCompactStringFromGoString(&result))
+	return nil
+}
+
+// concatArgString formats an argument of the given type as Java does when it's
+// concatenated to a string, that is, as String.valueOf() does
+func concatArgString(fs *list.List, value interface{}, desc string) (string, error) {
+	switch desc {
+	case "Z":
+		if intValue(value) != 0 {
+			return "true", nil
+		}
+		return "false", nil
+	case "C":
+		return string(rune(intValue(value))), nil
+	case "B", "I", "J", "S":
+		return strconv.FormatInt(intValue(value), 10), nil
+	case "F":
+		return javaFloatString(value.(float64), 32), nil
+	case "D":
+		return javaFloatString(value.(float64), 64), nil
+	}
+	return objectString(fs, value)
+}
+
+// intValue returns the value of an int-like operand stack value, which should
+// be an int64, but is occasionally an int
+func intValue(value interface{}) int64 {
+	switch v := value.(type) {
+	case int64:
+		return v
+	case int:
+		return int64(v)
+	}
+	return 0
+}
+
+// objectString returns the string for an object: "null" if it's null, the
+// string itself if it's a String, and otherwise the result of its toString()
+func objectString(fs *list.List, value interface{}) (string, error) {
+	obj, ok := value.(*object.Object)
+	if !ok {
+		if value == nil {
+			return "null", nil
+		}
+		return fmt.Sprintf("%v", value), nil
+	}
+	if obj == nil || obj.Klass == nil {
+		return "null", nil
+	}
+	if *obj.Klass == object.StringClassName {
+		return object.GetGoStringFromJavaString(obj), nil
+	}
+
+	mte, className, err := classloader.FetchInterfaceMethod(*obj.Klass, "toString", "()Ljava/lang/String;")
+	if err != nil || className == "java/lang/Object" {
+		return identityString(obj), nil
+	}
+	ret, err := runJavaMethod(fs, mte, className, "toString", "()Ljava/lang/String;", true,
+		[]interface{}{obj})
+	if err != nil {
+		return "", err
+	}
+	str, ok := ret.(*object.Object)
+	if !ok || str == nil {
+		return "null", nil
+	}
+	return object.GetGoStringFromJavaString(str), nil
+}
+
+// identityString returns the string produced by Object.toString(): the class
+// name and the hash code in hex, e.g., java.lang.Object@1b6d3586
+func identityString(obj *object.Object) string {
+	return fmt.Sprintf("%s@%x", strings.ReplaceAll(*obj.Klass, "/", "."), obj.Mark.Hash)
+}
+
+// javaFloatString formats a float (if bitSize is 32) or a double (if it's 64) as
+// Float.toString() and Double.toString() do: the shortest decimal that uniquely
+// identifies the value, with at least one digit after the decimal point, and in
+// computerized scientific notation (e.g., 1.0E10) if the magnitude is less than
+// 10^-3 or at least 10^7.
+func javaFloatString(value float64, bitSize int) string {
+	switch {
+	case math.IsNaN(value):
+		return "NaN"
+	case math.IsInf(value, 1):
+		return "Infinity"
+	case math.IsInf(value, -1):
+		return "-Infinity"
+	case value == 0:
+		if math.Signbit(value) {
+			return "-0.0"
+		}
+		return "0.0"
+	}
+
+	magnitude := math.Abs(value)
+	if bitSize == 32 {
+		magnitude = float64(float32(magnitude))
+	}
+	if magnitude >= 1e-3 && magnitude < 1e7 {
+		s := strconv.FormatFloat(value, 'f', -1, bitSize)
+		if !strings.Contains(s, ".") {
+			s += ".0"
+		}
+		return s
+	}
+
+	s := strconv.FormatFloat(value, 'E', -1, bitSize) // e.g., 1.5E+10 or 1E-05
+	mantissa, exponent, _ := strings.Cut(s, "E")
+	if !strings.Contains(mantissa, ".") {
+		mantissa += ".0"
+	}
+	exp, _ := strconv.Atoi(exponent)
+	return mantissa + "E" + strconv.Itoa(exp)
+}
@@ -0,0 +1,164 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"fmt"
+	"jacobin/classloader"
+	"jacobin/frames"
+	"jacobin/object"
+	"math"
+	"testing"
+)
+
+// sets up test/Main with a string concatenation call site of the given type, whose
+// bootstrap method has the given name and static arguments (built by addArgs).
+// Returns the CP and the index of the call site.
+func setupStringConcat(bootstrapName, desc string,
+	addArgs func(b *cpBuilder) []uint16) (*classloader.CPool, uint16) {
+
+	setupInterfaceClasses()
+	addTestClass("test/Main", "java/lang/Object", nil)
+	addTestClass("test/Counter", "java/lang/Object", nil)
+
+	b := newCPBuilder()
+	bootstrap := b.methodHandle(refInvokeStatic, b.methodRef("java/lang/invoke/StringConcatFactory",
+		bootstrapName, "(Ljava/lang/invoke/MethodHandles$Lookup;Ljava/lang/String;"+
+			"Ljava/lang/invoke/MethodType;Ljava/lang/String;[Ljava/lang/Object;)Ljava/lang/invoke/CallSite;"))
+	args := addArgs(b)
+	index := b.invokeDynamic(0, bootstrapName, desc)
+
+	k := classloader.MethAreaFetch("test/Main")
+	k.Data.Bootstraps = []classloader.BootstrapMethod{{MethodRef: bootstrap, Args: args}}
+	k.Data.CP = b.cp
+	return &k.Data.CP, index
+}
+
+// INVOKEDYNAMIC: a string concatenation with arguments of each kind and constants
+func TestInvokedynamicStringConcat(t *testing.T) {
+	CP, index := setupStringConcat("makeConcatWithConstants",
+		"(IJZCLjava/lang/String;Ljava/lang/Object;Ljava/lang/Object;F)Ljava/lang/String;",
+		func(b *cpBuilder) []uint16 {
+			return []uint16{
+				b.utf8("i=\u0001 j=\u0001 \u0001\u0001 \u0001 \u0001 \u0002 \u0002 \u0001 \u0001"),
+				b.utf8("const"),
+				b.intConst(42),
+			}
+		})
+
+	counter := object.MakeEmptyObject()
+	className := "test/Counter"
+	counter.Klass = &className
+
+	f := newFrame(INVOKEDYNAMIC)
+	f.Meth = append(f.Meth, 0x00, byte(index), 0x00, 0x00)
+	f.ClName = "test/Main"
+	f.CP = CP
+	f.OpStack = make([]interface{}, 10) // room for the arguments
+	push(&f, int64(-7))
+	push(&f, int64(1<<40))
+	push(&f, int64(1<<40))
+	push(&f, int64(1))
+	push(&f, int64('x'))
+	str := "str"
+	push(&f, object.CreateCompactStringFromGoString(&str))
+	push(&f, object.Null)
+	push(&f, counter)
+	push(&f, float64(float32(0.1)))
+
+	fs := frames.CreateFrameStack()
+	fs.PushFront(&f)
+	if err := runFrame(fs); err != nil {
+		t.Fatalf("INVOKEDYNAMIC: Unexpected error: %s", err.Error())
+	}
+	if f.TOS != 0 {
+		t.Fatalf("INVOKEDYNAMIC: Expected only the string on the operand stack, got TOS: %d", f.TOS)
+	}
+
+	expected := fmt.Sprintf("i=-7 j=1099511627776 truex str null const 42 test.Counter@%x 0.1",
+		counter.Mark.Hash)
+	actual := object.GetGoStringFromJavaString(f.OpStack[0].(*object.Object))
+	if actual != expected {
+		t.Errorf("INVOKEDYNAMIC: Expected \"%s\", got: \"%s\"", expected, actual)
+	}
+}
+
+// INVOKEDYNAMIC: makeConcat() takes no recipe and concatenates its arguments
+func TestInvokedynamicMakeConcat(t *testing.T) {
+	CP, index := setupStringConcat("makeConcat", "(DZ)Ljava/lang/String;",
+		func(b *cpBuilder) []uint16 { return nil })
+
+	f := newFrame(INVOKEDYNAMIC)
+	f.Meth = append(f.Meth, 0x00, byte(index), 0x00, 0x00)
+	f.ClName = "test/Main"
+	f.CP = CP
+	push(&f, 1e10)
+	push(&f, 1e10)
+	push(&f, int64(0))
+
+	fs := frames.CreateFrameStack()
+	fs.PushFront(&f)
+	if err := runFrame(fs); err != nil {
+		t.Fatalf("INVOKEDYNAMIC: Unexpected error: %s", err.Error())
+	}
+	actual := object.GetGoStringFromJavaString(f.OpStack[0].(*object.Object))
+	if actual != "1.0E10false" {
+		t.Errorf("INVOKEDYNAMIC: Expected \"1.0E10false\", got: \"%s\"", actual)
+	}
+}
+
+// a recipe must have one \u0001 for each argument
+func TestInvokedynamicStringConcatInvalidRecipe(t *testing.T) {
+	CP, index := setupStringConcat("makeConcatWithConstants", "(II)Ljava/lang/String;",
+		func(b *cpBuilder) []uint16 { return []uint16{b.utf8("only \u0001")} })
+
+	f := newFrame(INVOKEDYNAMIC)
+	f.Meth = append(f.Meth, 0x00, byte(index), 0x00, 0x00)
+	f.ClName = "test/Main"
+	f.CP = CP
+	push(&f, int64(1))
+	push(&f, int64(2))
+
+	fs := frames.CreateFrameStack()
+	fs.PushFront(&f)
+	err := runFrame(fs)
+	if jt, ok := err.(*javaThrowable); !ok || jt.className != "java/lang/BootstrapMethodError" {
+		t.Errorf("INVOKEDYNAMIC: Expected a BootstrapMethodError, got: %v", err)
+	}
+}
+
+func TestJavaFloatString(t *testing.T) {
+	tests := []struct {
+		value    float64
+		bitSize  int
+		expected string
+	}{
+		{100, 64, "100.0"},
+		{0.001, 64, "0.001"},
+		{0.0001, 64, "1.0E-4"},
+		{1234567.0, 64, "1234567.0"},
+		{12345678.0, 64, "1.2345678E7"},
+		{1e10, 64, "1.0E10"},
+		{-1.5e-7, 64, "-1.5E-7"},
+		{0.1, 64, "0.1"},
+		{1.0 / 3, 64, "0.3333333333333333"},
+		{float64(float32(0.1)), 32, "0.1"},
+		{float64(float32(1.0 / 3)), 32, "0.33333334"},
+		{math.MaxFloat32, 32, "3.4028235E38"},
+		{math.Copysign(0, -1), 64, "-0.0"},
+		{0, 32, "0.0"},
+		{math.NaN(), 64, "NaN"},
+		{math.Inf(1), 64, "Infinity"},
+		{math.Inf(-1), 32, "-Infinity"},
+	}
+
+	for _, test := range tests {
+		if actual := javaFloatString(test.value, test.bitSize); actual != test.expected {
+			t.Errorf("javaFloatString: expected %s for %v, got: %s", test.expected, test.value, actual)
+		}
+	}
+}
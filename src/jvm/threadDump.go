@@ -0,0 +1,222 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"container/list"
+	"fmt"
+	"jacobin/frames"
+	"jacobin/globals"
+	"jacobin/object"
+	"jacobin/thread"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// A thread dump lists each thread with its state and the frames on its stack, along
+// with the monitors each frame holds and the monitor the thread is waiting for, in
+// the format of the JDK's. As in the JDK, SIGQUIT (kill -3) prints one to stderr,
+// and the program keeps running. It's the first thing to look at when a program hangs.
+//
+// Only a thread itself can read its frames safely, so the dump asks each running
+// thread to publish copies of them (see thread.ExecThread.RequestStack()), which it
+// does between instructions. Threads publish them as well before they block or wait
+// (see setWaitState()), so the dump shows where blocked and waiting threads are. A
+// thread that's running Go code, such as a read from stdin, publishes its frames only
+// once it returns to bytecode, so if it doesn't do so in time, the dump omits them.
+
+// stackTimeout is how long the dump waits for the running threads to publish their frames
+const stackTimeout = 500 * time.Millisecond
+
+// stackRequests is the number of dumps that are waiting for threads to publish their
+// frames; accessed atomically. runFrame() checks it between instructions.
+var stackRequests int32
+
+// threadDumpOnce ensures that there's only one handler for SIGQUIT
+var threadDumpOnce sync.Once
+
+// handleThreadDumpSignal prints a thread dump to stderr each time the JVM gets a SIGQUIT
+func handleThreadDumpSignal() {
+	threadDumpOnce.Do(func() {
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, syscall.SIGQUIT)
+		go func() {
+			for range sigs {
+				_, _ = fmt.Fprint(os.Stderr, ThreadDump())
+			}
+		}()
+	})
+}
+
+// ThreadDump returns a dump of the threads in the thread table that have not terminated
+func ThreadDump() string {
+	threads := dumpableThreads()
+	collectStacks(threads)
+
+	var sb strings.Builder
+	sb.WriteString("Full thread dump Jacobin VM (" + globals.GetGlobalRef().Version + "):\n")
+	for _, t := range threads {
+		sb.WriteString("\n")
+		writeThreadDump(&sb, t)
+	}
+	return sb.String()
+}
+
+// dumpableThreads returns the threads in the thread table that have not terminated
+func dumpableThreads() []*thread.ExecThread {
+	tbl := &globals.GetGlobalRef().Threads
+	tbl.ThreadsMutex.Lock()
+	defer tbl.ThreadsMutex.Unlock()
+
+	threads := []*thread.ExecThread{}
+	if tbl.ThreadsList == nil {
+		return threads
+	}
+	for e := tbl.ThreadsList.Front(); e != nil; e = e.Next() {
+		if t := e.Value.(*thread.ExecThread); t.State() != thread.Terminated {
+			threads = append(threads, t)
+		}
+	}
+	return threads
+}
+
+// collectStacks asks the running threads to publish their frames and waits until they
+// have, or stackTimeout elapses. Blocked and waiting threads published theirs already.
+func collectStacks(threads []*thread.ExecThread) {
+	atomic.AddInt32(&stackRequests, 1)
+	defer atomic.AddInt32(&stackRequests, -1)
+
+	for _, t := range threads {
+		if t.State() == thread.Runnable {
+			t.RequestStack()
+		}
+	}
+	deadline := time.Now().Add(stackTimeout)
+	for _, t := range threads {
+		for t.StackWanted() && t.State() == thread.Runnable && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+	}
+}
+
+// publishStack publishes copies of the frames of the thread of the frame stack, if a
+// thread dump has asked for them. runFrame() calls it between instructions while a
+// dump is waiting for them.
+func publishStack(fs *list.List) {
+	if t := currentExecThread(fs); t.StackWanted() {
+		t.PublishStack(copyFrames(fs))
+	}
+}
+
+// setWaitState records that the thread of the frame stack is about to block or wait
+// (see thread.ExecThread.SetWaitState()), and publishes its frames for thread dumps
+func setWaitState(fs *list.List, t *thread.ExecThread, state int32, blocker *object.Object) {
+	t.PublishStack(copyFrames(fs))
+	t.SetWaitState(state, blocker)
+}
+
+// copyFrames returns copies of the frames on the stack, from the top
+func copyFrames(fs *list.List) []frames.Frame {
+	stack := make([]frames.Frame, 0, fs.Len())
+	for e := fs.Front(); e != nil; e = e.Next() {
+		f := *e.Value.(*frames.Frame)
+		f.Locks = append([]*object.Object(nil), f.Locks...)
+		stack = append(stack, f)
+	}
+	return stack
+}
+
+// writeThreadDump writes the dump of one thread, e.g.:
+//
+//	"Thread-0" #2 daemon
+//	   java.lang.Thread.State: BLOCKED (on object monitor)
+//		at Counter.add(Counter.java:12) [pc 4]
+//		- waiting to lock <0xc000010040> (a Counter)
+//		at Worker.run(Worker.java:7) [pc 9]
+//		- locked <0xc000010080> (a java.lang.Object)
+func writeThreadDump(sb *strings.Builder, t *thread.ExecThread) {
+	sb.WriteString(fmt.Sprintf("\"%s\" #%d", threadName(t), t.ID))
+	if t.Daemon {
+		sb.WriteString(" daemon")
+	}
+	state := t.State()
+	_, blocker := t.WaitState()
+	sb.WriteString("\n   java.lang.Thread.State: " + threadStateName(state, blocker) + "\n")
+	if state == thread.Runnable && t.StackWanted() {
+		sb.WriteString("\t(frames not available: the thread did not publish them in time)\n")
+		return
+	}
+
+	top := true
+	stack := t.PublishedStack()
+	for i := range stack {
+		f := &stack[i]
+		if isLambdaProxy(f.ClName) {
+			continue
+		}
+		sb.WriteString("\tat " + newStackTraceElement(f).String())
+		if f.Ftype != 'G' {
+			sb.WriteString(fmt.Sprintf(" [pc %d]", f.PC))
+		}
+		sb.WriteString("\n")
+
+		if top && blocker != nil {
+			switch state {
+			case thread.Blocked:
+				sb.WriteString("\t- waiting to lock " + monitorDescription(blocker) + "\n")
+			case thread.Waiting, thread.TimedWaiting:
+				sb.WriteString("\t- waiting on " + monitorDescription(blocker) + "\n")
+			}
+		}
+		locks := f.Locks
+		for i := len(locks) - 1; i >= 0; i-- {
+			sb.WriteString("\t- locked " + monitorDescription(locks[i]) + "\n")
+		}
+		// a synchronized method that's blocked on its own monitor doesn't hold it yet
+		if f.Monitor != nil && !(top && state == thread.Blocked && blocker == f.Monitor) {
+			sb.WriteString("\t- locked " + monitorDescription(f.Monitor) + "\n")
+		}
+		top = false
+	}
+}
+
+// threadStateName returns the thread state as the JDK shows it in thread dumps.
+// blocker is the object whose monitor the thread is blocked or waiting on, if any.
+func threadStateName(state int32, blocker *object.Object) string {
+	switch state {
+	case thread.New:
+		return "NEW"
+	case thread.Blocked:
+		return "BLOCKED (on object monitor)"
+	case thread.Waiting:
+		return "WAITING (on object monitor)"
+	case thread.TimedWaiting:
+		if blocker == nil {
+			return "TIMED_WAITING (sleeping)"
+		}
+		return "TIMED_WAITING (on object monitor)"
+	case thread.Terminated:
+		return "TERMINATED"
+	default:
+		return "RUNNABLE"
+	}
+}
+
+// monitorDescription identifies the object whose monitor a thread holds or waits
+// for, as in the JDK, e.g.: <0xc000010040> (a java.lang.Object)
+func monitorDescription(obj *object.Object) string {
+	className := "java/lang/Object"
+	if obj.Klass != nil && *obj.Klass != "" {
+		className = *obj.Klass
+	}
+	return fmt.Sprintf("<%p> (a %s)", obj, strings.ReplaceAll(className, "/", "."))
+}
@@ -0,0 +1,142 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"container/list"
+	"fmt"
+	"jacobin/classloader"
+	"jacobin/frames"
+	"jacobin/object"
+	"jacobin/thread"
+	"jacobin/types"
+	"strings"
+	"testing"
+	"time"
+)
+
+// starts a new thread with the given name, whose frame is test/Worker.run() at PC 4,
+// and waits until it's in the given state
+func startDumpTestThread(t *testing.T, name string, state int32,
+	body func(fs *list.List)) *thread.ExecThread {
+	exec, fs := newWaitTestThread()
+	exec.Name = name
+	f := fs.Front().Value.(*frames.Frame)
+	f.ClName, f.MethName, f.Ftype, f.PC = "test/Worker", "run", 'J', 4
+	exec.Start(func() { body(fs) })
+
+	deadline := time.Now().Add(5 * time.Second)
+	for exec.State() != state {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for thread %s to be in state %d", name, state)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return exec
+}
+
+// The dump shows each thread's state and frames, with the monitors the thread holds
+// and the one it's blocked or waiting on
+func TestThreadDump(t *testing.T) {
+	main, mainFs := setupWaitTest()
+	contended, waitedOn, held := object.MakeEmptyObject(), object.MakeEmptyObject(), object.MakeEmptyObject()
+	contended.MonitorEnter(main.ID)
+
+	blocked := startDumpTestThread(t, "blocked", thread.Blocked, func(fs *list.List) {
+		f := fs.Front().Value.(*frames.Frame)
+		held.MonitorEnter(f.Thread)
+		f.Locks = append(f.Locks, held)
+		enterMonitor(fs, contended)
+		contended.MonitorExit(f.Thread)
+	})
+	waiting := startDumpTestThread(t, "waiting", thread.Waiting, func(fs *list.List) {
+		threadID := fs.Front().Value.(*frames.Frame).Thread
+		waitedOn.MonitorEnter(threadID)
+		objectWait([]interface{}{waitedOn, fs})
+		waitedOn.MonitorExit(threadID)
+	})
+	sleeping := startDumpTestThread(t, "sleeping", thread.TimedWaiting, func(fs *list.List) {
+		threadSleep([]interface{}{int64(5000), int64(5000), fs})
+	})
+
+	dump := ThreadDump()
+	for _, expected := range []string{
+		"Full thread dump Jacobin VM",
+		fmt.Sprintf("\"blocked\" #%d\n   java.lang.Thread.State: BLOCKED (on object monitor)\n"+
+			"\tat test.Worker.run(Unknown Source) [pc 4]\n"+
+			"\t- waiting to lock <%p> (a java.lang.Object)\n"+
+			"\t- locked <%p> (a java.lang.Object)\n", blocked.ID, contended, held),
+		fmt.Sprintf("\"waiting\" #%d\n   java.lang.Thread.State: WAITING (on object monitor)\n"+
+			"\tat test.Worker.run(Unknown Source) [pc 4]\n"+
+			"\t- waiting on <%p> (a java.lang.Object)\n", waiting.ID, waitedOn),
+		fmt.Sprintf("\"sleeping\" #%d\n   java.lang.Thread.State: TIMED_WAITING (sleeping)\n", sleeping.ID),
+	} {
+		if !strings.Contains(dump, expected) {
+			t.Errorf("Expected the thread dump to contain:\n%s\ngot:\n%s", expected, dump)
+		}
+	}
+
+	contended.MonitorExit(main.ID)
+	waitedOn.MonitorEnter(main.ID)
+	objectNotify([]interface{}{waitedOn, mainFs})
+	waitedOn.MonitorExit(main.ID)
+	sleeping.Interrupt()
+	for _, exec := range []*thread.ExecThread{blocked, waiting, sleeping} {
+		if !exec.Join(5 * time.Second) {
+			t.Fatalf("Timed out waiting for thread %s to finish", exec.Name)
+		}
+	}
+	if dump = ThreadDump(); strings.Contains(dump, "\"blocked\"") {
+		t.Errorf("Expected the thread dump not to show terminated threads, got:\n%s", dump)
+	}
+}
+
+// The dump shows the frames of a running thread, which it publishes between
+// instructions, while the thread pushes and pops frames and locks and releases a
+// monitor in this loop:
+//
+//	do { synchronized (this) { more = Spin.keepGoing(); } } while (more);
+func TestThreadDumpOfRunningThread(t *testing.T) {
+	setupInterfaceClasses()
+	stop := make(chan struct{})
+	addTestClass("test/Spin", "java/lang/Object", nil)
+	classloader.MTable["test/Spin.keepGoing()Z"] =
+		classloader.MTentry{MType: 'G', Meth: classloader.GmEntry{
+			ParamSlots: 0,
+			Fu: func(params []interface{}) interface{} {
+				select {
+				case <-stop:
+					return types.JavaBoolFalse
+				default:
+					return types.JavaBoolTrue
+				}
+			}}}
+	b := newCPBuilder()
+	keepGoing := b.methodRef("test/Spin", "keepGoing", "()Z")
+
+	exec, fs := newWaitTestThread()
+	exec.Name = "spinning"
+	f := fs.Front().Value.(*frames.Frame)
+	f.ClName, f.MethName, f.CP = "test/Worker", "run", &b.cp
+	f.Locals = []interface{}{object.MakeEmptyObject()}
+	f.Meth = []byte{ALOAD_0, MONITORENTER, INVOKESTATIC, byte(keepGoing >> 8), byte(keepGoing),
+		ALOAD_0, MONITOREXIT, IFNE, 0xFF, 0xF9, RETURN}
+	exec.Start(func() { _ = runFrame(fs) })
+
+	expected := fmt.Sprintf("\"spinning\" #%d\n   java.lang.Thread.State: RUNNABLE\n"+
+		"\tat test.Worker.run(Unknown Source) [pc ", exec.ID)
+	for i := 0; i < 20; i++ {
+		if dump := ThreadDump(); !strings.Contains(dump, expected) {
+			t.Fatalf("Expected the thread dump to contain:\n%s\ngot:\n%s", expected, dump)
+		}
+	}
+
+	close(stop)
+	if !exec.Join(5 * time.Second) {
+		t.Fatal("Timed out waiting for the spinning thread to finish")
+	}
+}
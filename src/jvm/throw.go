@@ -0,0 +1,211 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"container/list"
+	"jacobin/classloader"
+	"jacobin/exceptions"
+	"jacobin/frames"
+	"jacobin/log"
+	"jacobin/object"
+	"strings"
+)
+
+// the element types named in the messages for null references to int, char, and
+// short arrays, whose load and store instructions share the same implementation
+var arrayKinds = map[byte]string{
+	IALOAD: "int", CALOAD: "char", SALOAD: "short",
+	IASTORE: "int", CASTORE: "char", SASTORE: "short",
+}
+
+// javaThrowable is the error returned by runFrame() when a Java exception has
+// been thrown and no handler for it was found in the frame. It travels back up
+// through the calling frames, each of which checks its exception table for a
+// matching handler, until one catches it or the thread runs out of frames.
+type javaThrowable struct {
+	obj       *object.Object // the exception object
+	className string         // the exception class, in java/lang/Exception format
+	msg       string         // the detail message, "" if there is none
+	trace     []stackTraceElement
+}
+
+// Error returns the exception in the format used by Throwable.toString(),
+// e.g.: java.lang.IllegalStateException: no data
+func (jt *javaThrowable) Error() string {
+	name := strings.ReplaceAll(jt.className, "/", ".")
+	if jt.msg == "" {
+		return name
+	}
+	return name + ": " + jt.msg
+}
+
+// newThrowableFromObject creates the javaThrowable for an exception object,
+// such as the one thrown by ATHROW. The detail message is fetched from the
+// detailMessage field, which is declared in java/lang/Throwable. The stack trace
+// is the one recorded by fillInStackTrace() in the backtrace field, if any.
+func newThrowableFromObject(obj *object.Object) *javaThrowable {
+	jt := javaThrowable{obj: obj, className: *obj.Klass}
+	msg, ok := getObjectFieldByName(obj, "detailMessage")
+	if ok {
+		switch msg.(type) {
+		case *object.Object:
+			jt.msg = object.GetGoStringFromJavaString(msg.(*object.Object))
+		}
+	}
+
+	trace, ok := getObjectFieldByName(obj, "backtrace")
+	if ok {
+		switch trace.(type) {
+		case []stackTraceElement:
+			jt.trace = trace.([]stackTraceElement)
+		}
+	}
+	return &jt
+}
+
+// cause returns the exception that caused this one, or nil if there is none. Per
+// java/lang/Throwable, a cause field that points to the exception itself means
+// the cause has not been set.
+func (jt *javaThrowable) cause() *javaThrowable {
+	if jt.obj == nil {
+		return nil
+	}
+	cause, ok := getObjectFieldByName(jt.obj, "cause")
+	if !ok {
+		return nil
+	}
+	causeObj, ok := cause.(*object.Object)
+	if !ok || causeObj == nil || causeObj == jt.obj {
+		return nil
+	}
+	return newThrowableFromObject(causeObj)
+}
+
+// throwVMException throws an exception detected by the JVM itself while executing
+// the current frame's current instruction, such as an integer division by zero. If a
+// handler in the frame catches it, nil is returned and the PC points to the handler.
+// Otherwise, the exception is returned so the calling frames can look for a handler.
+func throwVMException(fs *list.List, excType int, msg string) error {
+	jt := &javaThrowable{className: exceptions.ExceptionClassNames[excType], msg: msg}
+	jt.trace = captureStackTrace(fs.Front())
+	if catchException(fs.Front().Value.(*frames.Frame), jt) {
+		return nil
+	}
+	return jt
+}
+
+// newExceptionObject creates an instance of an exception class with the given
+// detail message. The constructor is not run; instead, the two fields it sets
+// (the detail message and the cause, which points to the exception itself
+// until a cause is set) are filled in here.
+func newExceptionObject(className, msg string) (*object.Object, error) {
+	obj, err := instantiateClass(className)
+	if err != nil {
+		return nil, err
+	}
+
+	if msg != "" {
+		setObjectFieldByName(obj, "detailMessage", object.CreateCompactStringFromGoString(&msg))
+	}
+	setObjectFieldByName(obj, "cause", obj)
+	return obj, nil
+}
+
+// catchException searches the exception table of the frame for a handler
+// whose range covers the current PC and whose catch type is the thrown
+// exception's class or one of its superclasses. A catch type of 0 (used for
+// finally blocks) catches everything. Per the JVM spec, the first matching
+// entry wins. If a handler is found, the operand stack is cleared, the
+// exception object is pushed, the PC is set to the handler, and true is returned.
+func catchException(f *frames.Frame, jt *javaThrowable) bool {
+	for _, ex := range f.ExcTable {
+		if f.PC < ex.StartPc || f.PC >= ex.EndPc { // EndPc is exclusive
+			continue
+		}
+
+		if ex.CatchType != 0 {
+			catchClass := FetchCPentry(f.CP, int(ex.CatchType))
+			if catchClass.retType != IS_STRING_ADDR ||
+				!isClassOrSubclassOf(jt.className, *catchClass.stringVal) {
+				continue
+			}
+		}
+
+		if jt.obj == nil { // exceptions thrown by the JVM are instantiated only when caught
+			obj, err := newExceptionObject(jt.className, jt.msg)
+			if err != nil {
+				return false
+			}
+			setObjectFieldByName(obj, "backtrace", jt.trace)
+			jt.obj = obj
+		}
+
+		if f.Trace {
+			_ = log.Log("catchException: "+jt.className+" caught in "+
+				f.ClName+"."+f.MethName, log.TRACE_INST)
+		}
+
+		f.TOS = -1
+		push(f, jt.obj)
+		f.PC = ex.HandlerPc
+		return true
+	}
+	return false
+}
+
+// catchInCaller is called when runFrame() returns an error for a method invoked
+// by the frame now at the second position on the frame stack. If the error is a
+// thrown Java exception, the callee's frame is popped and the caller's exception
+// table is checked for a handler. It returns the caller's frame and a nil error
+// if the exception was caught (in which case the caller's PC points to the
+// handler). Otherwise, the error is returned so it can continue up the stack.
+func catchInCaller(fs *list.List, err error) (*frames.Frame, error) {
+	jt, ok := err.(*javaThrowable)
+	if !ok || fs.Len() < 2 {
+		return nil, err
+	}
+
+	fs.Remove(fs.Front()) // pop the callee's frame
+	f := fs.Front().Value.(*frames.Frame)
+	if catchException(f, jt) {
+		return f, nil
+	}
+	return f, err
+}
+
+// isClassOrSubclassOf returns true if className is superName or has superName
+// among its superclasses. Both names are in java/lang/Object format.
+func isClassOrSubclassOf(className, superName string) bool {
+	for className != "" {
+		if className == superName {
+			return true
+		}
+		if className == "java/lang/Object" {
+			return false
+		}
+
+		// loads the class, or waits for it if it's loaded or being loaded
+		if classloader.LoadClassFromNameOnly(className) != nil {
+			return false
+		}
+		k := classloader.MethAreaFetch(className)
+		if k == nil || k.Data == nil {
+			return false
+		}
+		className = k.Data.Superclass
+	}
+	return false
+}
+
+// reportUncaughtException shows the user an exception that propagated out of
+// the last frame of the named thread, along with its stack trace, in the same
+// format as the JDK
+func reportUncaughtException(jt *javaThrowable, threadName string) {
+	msg := "Exception in thread \"" + threadName + "\" " + stackTraceString(jt)
+	_ = log.Log(strings.TrimSuffix(msg, "\n"), log.SEVERE)
+}
@@ -0,0 +1,1353 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"container/list"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"jacobin/classloader"
+	"jacobin/exceptions"
+	"jacobin/globals"
+	"jacobin/log"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// A class is verified when it's linked, which Jacobin does just before the class is
+// initialized (see initializeClass()). Verification checks that the bytecode of every
+// method is type safe--that each instruction gets operands of the types it expects,
+// that the operand stack neither overflows nor underflows, that branches stay within
+// the method, etc.--so that malformed code is rejected with a VerifyError rather than
+// failing while it runs. The checks are those of the type checker in the JVM spec:
+// https://docs.oracle.com/javase/specs/jvms/se17/html/jvms-4.html#jvms-4.10.1
+// which uses the StackMapTable attribute to know the types at branch targets and
+// exception handlers. Class files older than Java 6 (version 50) have no StackMapTable
+// and would require verification by type inference, which Jacobin does not do.
+//
+// Which classes are verified depends on the -Xverify option: none, all but the JDK's
+// classes (remote, the default), or all. As the README says, Jacobin's verification is
+// somewhat less stringent than the JDK's: a class that can't be loaded to check the
+// assignability of one reference type to another is assumed to be assignable, and
+// access to protected members is not checked.
+
+// classVerification is the outcome of verifying a class
+type classVerification struct {
+	once sync.Once
+	msg  string // the message of the VerifyError, or "" if the class is valid
+}
+
+var classVerifications sync.Map // *classloader.Klass -> *classVerification
+
+// linkClass verifies the class, if the -Xverify level calls for it, the first time
+// it's linked. If the class is not valid, the error is a VerifyError to be thrown
+// by the current instruction (now and on every later attempt to link the class).
+func linkClass(fs *list.List, k *classloader.Klass, className string) error {
+	switch globals.GetGlobalRef().VerifyLevel {
+	case globals.VerifyNone:
+		return nil
+	case globals.VerifyRemote:
+		if isJDKClass(className) {
+			return nil
+		}
+	}
+
+	v, ok := classVerifications.Load(k)
+	if !ok {
+		v, _ = classVerifications.LoadOrStore(k, &classVerification{})
+	}
+	cv := v.(*classVerification)
+	cv.once.Do(func() {
+		if err := verifyClass(k); err != nil {
+			cv.msg = err.Error()
+			_ = log.Log("VerifyError in class "+className+": "+cv.msg, log.CLASS)
+		}
+	})
+	if cv.msg != "" {
+		return newVMThrowable(fs, exceptions.VerifyError, cv.msg)
+	}
+	return nil
+}
+
+// verifyClass type checks all the methods of the class. The error's message is
+// that of the VerifyError.
+func verifyClass(k *classloader.Klass) error {
+	if k.Data.Version < 50 { // no StackMapTable, so nothing to check against
+		return nil
+	}
+	_ = log.Log("Verifying class "+k.Data.Name, log.FINE)
+	for i := range k.Data.Methods {
+		m := &k.Data.Methods[i]
+		if m.AccessFlags&(0x0100|0x0400) != 0 { // ACC_NATIVE and ACC_ABSTRACT methods have no code
+			continue
+		}
+		if err := verifyMethod(k, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verifyError is a failure of verification at an instruction (or, if pc is -1,
+// in the method as a whole). gist is the first line of the message of the
+// VerifyError, and reason explains it, as in the JDK.
+type verifyError struct {
+	pc     int
+	gist   string
+	reason string
+}
+
+// methodVerifier holds the data needed to type check a method
+type methodVerifier struct {
+	k          *classloader.Klass
+	cp         *classloader.CPool
+	className  string
+	methName   string
+	methType   string
+	code       []byte
+	maxStack   int
+	maxLocals  int
+	returnType vType // top for void methods
+	isInit     bool  // is the method a constructor?
+	starts     []bool
+	maps       map[int]*typeFrame // the stack map frames, by the offset of their instruction
+	handlers   []classloader.CodeException
+	attributes []classloader.Attr // those of the Code attribute
+	pc         int                // the instruction being checked
+}
+
+// verifyMethod type checks the method. The error's message is that of the VerifyError.
+func verifyMethod(k *classloader.Klass, m *classloader.Method) error {
+	v := methodVerifier{
+		k:          k,
+		cp:         &k.Data.CP,
+		className:  k.Data.Name,
+		methName:   k.Data.CP.Utf8Refs[m.Name],
+		methType:   k.Data.CP.Utf8Refs[m.Desc],
+		code:       m.CodeAttr.Code,
+		maxStack:   m.CodeAttr.MaxStack,
+		maxLocals:  m.CodeAttr.MaxLocals,
+		handlers:   m.CodeAttr.Exceptions,
+		attributes: m.CodeAttr.Attributes,
+		pc:         -1,
+	}
+	v.isInit = v.methName == "<init>"
+
+	verr := v.verify(m)
+	if verr == nil {
+		return nil
+	}
+
+	location := v.className + "." + v.methName + v.methType
+	if verr.pc >= 0 && verr.pc < len(v.code) {
+		location += " @" + strconv.Itoa(verr.pc)
+		if int(v.code[verr.pc]) < len(BytecodeNames) {
+			location += ": " + strings.ToLower(BytecodeNames[v.code[verr.pc]])
+		}
+	}
+	return errors.New(verifyErrorMessage(verr.gist, location, verr.reason))
+}
+
+// verifyErrorMessage returns the message of a VerifyError in the JDK's format
+func verifyErrorMessage(gist, location, reason string) string {
+	return gist + "\nException Details:\n  Location:\n    " + location + "\n  Reason:\n    " + reason
+}
+
+// fail returns the verifyError for the current instruction
+func (v *methodVerifier) fail(gist, reason string, args ...interface{}) *verifyError {
+	return &verifyError{pc: v.pc, gist: gist, reason: fmt.Sprintf(reason, args...)}
+}
+
+func (v *methodVerifier) verify(m *classloader.Method) *verifyError {
+	if len(v.code) == 0 {
+		return v.fail("Method has no code", "Methods that are neither native nor abstract must have code.")
+	}
+
+	// find where the instructions start, which is where branches can go
+	v.starts = make([]bool, len(v.code)+1)
+	for pc := 0; pc < len(v.code); {
+		v.pc = pc
+		length := instructionLength(v.code, pc)
+		if length == 0 {
+			return v.fail("Bad instruction", "Error exists in the bytecode.")
+		}
+		v.starts[pc] = true
+		pc += length
+	}
+	v.starts[len(v.code)] = true // the end of the code ends exception ranges
+	v.pc = -1
+
+	initial, initialLocals, verr := v.initialFrame(m)
+	if verr != nil {
+		return verr
+	}
+	if verr = v.loadStackMap(initialLocals); verr != nil {
+		return verr
+	}
+	if verr = v.checkHandlers(); verr != nil {
+		return verr
+	}
+
+	// the instructions are checked in order. The types after an instruction are
+	// those before the next one, unless there's a stack map frame at the next one.
+	fr := initial
+	for pc := 0; pc < len(v.code); pc += instructionLength(v.code, pc) {
+		v.pc = pc
+		if mapFrame, ok := v.maps[pc]; ok {
+			if fr != nil {
+				if reason := v.frameMismatch(fr, mapFrame); reason != "" {
+					return v.fail("Instruction type does not match stack map", "%s", reason)
+				}
+			}
+			fr = mapFrame.copy()
+		} else if fr == nil { // the previous instruction doesn't continue here
+			return v.fail("Expecting a stack map frame", "Expected stack map frame at this location.")
+		}
+
+		if verr = v.checkExceptionHandlers(fr); verr != nil {
+			return verr
+		}
+		continues, verr := v.checkInstruction(fr)
+		if verr != nil {
+			return verr
+		}
+		if !continues {
+			fr = nil
+		}
+	}
+
+	if fr != nil {
+		v.pc = -1
+		return v.fail("Falling off the end of the code", "Control flow falls through the code limit.")
+	}
+	return nil
+}
+
+// initialFrame returns the frame at the start of the method, whose locals hold this
+// (for instance methods) and the arguments. It also returns the types of the locals,
+// which the first frame in the StackMapTable is relative to.
+func (v *methodVerifier) initialFrame(m *classloader.Method) (*typeFrame, []vType, *verifyError) {
+	params, ret, ok := methodDescriptorTypes(v.methType)
+	if !ok {
+		return nil, nil, v.fail("Invalid method signature", "Method signature %s is invalid.", v.methType)
+	}
+	v.returnType = ret
+
+	var locals []vType
+	fr := typeFrame{}
+	if m.AccessFlags&0x0008 == 0 { // not ACC_STATIC, so this is in local 0
+		if v.isInit && v.className != "java/lang/Object" {
+			locals = append(locals, vType{tag: vtUninitThis})
+			fr.thisUninit = true
+		} else {
+			locals = append(locals, refType(v.className))
+		}
+	}
+	locals = append(locals, params...)
+
+	fr.locals = expandTypes(locals)
+	if len(fr.locals) > v.maxLocals {
+		return nil, nil, v.fail("Arguments can't fit into locals", "Method requires %d locals, but max_locals is %d.",
+			len(fr.locals), v.maxLocals)
+	}
+	for len(fr.locals) < v.maxLocals {
+		fr.locals = append(fr.locals, topType)
+	}
+	return &fr, locals, nil
+}
+
+// loadStackMap parses the StackMapTable attribute, if the method has one, into the
+// frames at the instructions
+func (v *methodVerifier) loadStackMap(initialLocals []vType) *verifyError {
+	v.maps = make(map[int]*typeFrame)
+	var content []byte
+	found := false
+	for _, attr := range v.attributes {
+		if int(attr.AttrName) < len(v.cp.Utf8Refs) && v.cp.Utf8Refs[attr.AttrName] == "StackMapTable" {
+			if found {
+				return v.fail("StackMapTable error: multiple StackMapTable attributes",
+					"A Code attribute can have only one StackMapTable attribute.")
+			}
+			content = attr.AttrContent
+			found = true
+		}
+	}
+	if !found {
+		return nil
+	}
+
+	frames, err := parseStackMapTable(content, v.cp, initialLocals)
+	if err != nil {
+		return v.fail("StackMapTable error: bad table", "%s.", err.Error())
+	}
+	for _, mapFrame := range frames {
+		if mapFrame.offset >= len(v.code) || !v.starts[mapFrame.offset] {
+			return v.fail("StackMapTable error: bad offset",
+				"Stack map frame at offset %d is not at an instruction.", mapFrame.offset)
+		}
+		fr := typeFrame{locals: expandTypes(mapFrame.locals), stack: expandTypes(mapFrame.stack)}
+		if len(fr.locals) > v.maxLocals {
+			return v.fail("StackMapTable error: local size exceeds max locals",
+				"Stack map frame at offset %d has %d locals, but max_locals is %d.",
+				mapFrame.offset, len(fr.locals), v.maxLocals)
+		}
+		if len(fr.stack) > v.maxStack {
+			return v.fail("StackMapTable error: stack size exceeds max stack",
+				"Stack map frame at offset %d has a stack of size %d, but max_stack is %d.",
+				mapFrame.offset, len(fr.stack), v.maxStack)
+		}
+		for len(fr.locals) < v.maxLocals {
+			fr.locals = append(fr.locals, topType)
+		}
+		for _, t := range append(append([]vType(nil), fr.locals...), fr.stack...) {
+			if t.tag == vtUninitThis {
+				fr.thisUninit = true
+			} else if t.tag == vtUninit && (t.offset >= len(v.code) || !v.starts[t.offset] || v.code[t.offset] != NEW) {
+				return v.fail("StackMapTable error: bad uninitialized type",
+					"Type %s in the stack map frame at offset %d does not refer to a NEW instruction.",
+					t, mapFrame.offset)
+			}
+		}
+		v.maps[mapFrame.offset] = &fr
+	}
+	return nil
+}
+
+// checkHandlers checks the entries of the method's exception table
+func (v *methodVerifier) checkHandlers() *verifyError {
+	for i, h := range v.handlers {
+		if h.StartPc < 0 || h.StartPc >= len(v.code) || !v.starts[h.StartPc] ||
+			h.EndPc <= h.StartPc || h.EndPc > len(v.code) || !v.starts[h.EndPc] {
+			return v.fail("Illegal exception table range",
+				"Exception table entry %d covers [%d, %d), which is not a range of instructions.",
+				i, h.StartPc, h.EndPc)
+		}
+		if h.HandlerPc < 0 || h.HandlerPc >= len(v.code) || !v.starts[h.HandlerPc] {
+			return v.fail("Illegal exception table handler",
+				"Exception handler %d is not at an instruction.", h.HandlerPc)
+		}
+		if h.CatchType != 0 {
+			catchClass, ok := cpClassName(v.cp, int(h.CatchType))
+			if !ok {
+				return v.fail("Illegal exception table catch type",
+					"Constant pool index %d is not a class.", h.CatchType)
+			}
+			if !isRefAssignable(catchClass, "java/lang/Throwable") {
+				return v.fail(fmt.Sprintf("Catch type is not a subclass of Throwable in exception handler %d",
+					h.HandlerPc), "Type '%s' is not assignable to 'java/lang/Throwable'.", catchClass)
+			}
+		}
+	}
+	return nil
+}
+
+// checkExceptionHandlers checks that the locals at the current instruction suit the
+// handlers of the exceptions it might throw
+func (v *methodVerifier) checkExceptionHandlers(fr *typeFrame) *verifyError {
+	for _, h := range v.handlers {
+		if v.pc < h.StartPc || v.pc >= h.EndPc {
+			continue
+		}
+		catchType := refType("java/lang/Throwable")
+		if h.CatchType != 0 {
+			catchClass, _ := cpClassName(v.cp, int(h.CatchType))
+			catchType = refType(catchClass)
+		}
+		handlerFrame := &typeFrame{locals: fr.locals, stack: []vType{catchType}, thisUninit: fr.thisUninit}
+
+		mapFrame, ok := v.maps[h.HandlerPc]
+		if !ok {
+			return v.fail(fmt.Sprintf("Expecting a stackmap frame at exception handler %d", h.HandlerPc),
+				"Expected stackmap frame at this location.")
+		}
+		if reason := v.frameMismatch(handlerFrame, mapFrame); reason != "" {
+			return v.fail(fmt.Sprintf("Stack map does not match the one at exception handler %d", h.HandlerPc),
+				"%s", reason)
+		}
+	}
+	return nil
+}
+
+// checkBranch checks that the current types suit those of the stack map frame at
+// the target of a branch
+func (v *methodVerifier) checkBranch(fr *typeFrame, target int) *verifyError {
+	if target < 0 || target >= len(v.code) || !v.starts[target] {
+		return v.fail("Illegal target of jump or branch", "Target %d is not the start of an instruction.", target)
+	}
+	mapFrame, ok := v.maps[target]
+	if !ok {
+		return v.fail(fmt.Sprintf("Expecting a stackmap frame at branch target %d", target),
+			"Expected stackmap frame at this location.")
+	}
+	if reason := v.frameMismatch(fr, mapFrame); reason != "" {
+		return v.fail(fmt.Sprintf("Inconsistent stackmap frames at branch target %d", target), "%s", reason)
+	}
+	return nil
+}
+
+// frameMismatch returns why the types of the current frame are not assignable to
+// those of a stack map frame, or "" if they are
+func (v *methodVerifier) frameMismatch(fr, mapFrame *typeFrame) string {
+	for i := range mapFrame.locals {
+		if !v.isAssignable(fr.locals[i], mapFrame.locals[i]) {
+			return fmt.Sprintf("Type %s (current frame, locals[%d]) is not assignable to %s (stack map, locals[%d])",
+				fr.locals[i], i, mapFrame.locals[i], i)
+		}
+	}
+	if len(fr.stack) != len(mapFrame.stack) {
+		return "Current frame's stack size doesn't match stackmap."
+	}
+	for i := range mapFrame.stack {
+		if !v.isAssignable(fr.stack[i], mapFrame.stack[i]) {
+			return fmt.Sprintf("Type %s (current frame, stack[%d]) is not assignable to %s (stack map, stack[%d])",
+				fr.stack[i], i, mapFrame.stack[i], i)
+		}
+	}
+	if fr.thisUninit && !mapFrame.thisUninit {
+		return "Current frame's flags are not assignable to stack map frame's."
+	}
+	return ""
+}
+
+// isAssignable reports whether a value of type from can be used where one of type
+// to is expected. A reference type whose class is "" stands for any reference,
+// which, as in the JVM spec, includes uninitialized objects.
+func (v *methodVerifier) isAssignable(from, to vType) bool {
+	switch {
+	case to.tag == vtTop || from == to:
+		return true
+	case to.tag != vtRef:
+		return false
+	case to.class == "" || from.tag == vtNull:
+		return from.isReference()
+	case from.tag != vtRef:
+		return false
+	}
+	return isRefAssignable(from.class, to.class)
+}
+
+// isRefAssignable reports whether a reference to an instance of class from can be
+// used where one to class to is expected, as defined here:
+// https://docs.oracle.com/javase/specs/jvms/se17/html/jvms-4.html#jvms-4.10.1.2
+// Every class is assignable to an interface, as the verifier leaves checking them
+// to the invoke instructions. A class that can't be loaded is assumed to be assignable.
+func isRefAssignable(from, to string) bool {
+	if from == to || to == "java/lang/Object" {
+		return true
+	}
+
+	if strings.HasPrefix(to, "[") {
+		if !strings.HasPrefix(from, "[") {
+			return false
+		}
+		fromComponent, toComponent := from[1:], to[1:]
+		if !isRefComponent(fromComponent) || !isRefComponent(toComponent) {
+			return false // arrays of primitives are assignable only to the same type
+		}
+		return isRefAssignable(componentClass(fromComponent), componentClass(toComponent))
+	}
+	if strings.HasPrefix(from, "[") {
+		return to == "java/lang/Cloneable" || to == "java/io/Serializable"
+	}
+
+	toKlass := loadForVerification(to)
+	if toKlass == nil || toKlass.Data.Access.ClassIsInterface {
+		return true
+	}
+	for class := from; class != ""; {
+		if class == to {
+			return true
+		}
+		k := loadForVerification(class)
+		if k == nil {
+			return true
+		}
+		class = k.Data.Superclass
+	}
+	return false
+}
+
+// isRefComponent reports whether an array's component type, e.g., Ljava/lang/String; or
+// [I, is a reference type
+func isRefComponent(component string) bool {
+	return strings.HasPrefix(component, "L") || strings.HasPrefix(component, "[")
+}
+
+// componentClass converts a reference component type to a class name: Ljava/lang/String;
+// becomes java/lang/String, while array types are unchanged
+func componentClass(component string) string {
+	if strings.HasPrefix(component, "L") {
+		return strings.TrimSuffix(component[1:], ";")
+	}
+	return component
+}
+
+// loadForVerification returns the class, loading it if needed, or nil if it can't be loaded
+func loadForVerification(className string) *classloader.Klass {
+	if classloader.MethAreaFetch(className) == nil {
+		if classloader.LoadClassFromNameOnly(className) != nil {
+			return nil
+		}
+	}
+	if classloader.WaitForClassStatus(className) != nil {
+		return nil
+	}
+	k := classloader.MethAreaFetch(className)
+	if k == nil || k.Data == nil {
+		return nil
+	}
+	return k
+}
+
+// === operations on the types of the operand stack and local variables ===
+
+var anyRefType = refType("") // any reference, including null
+
+func (v *methodVerifier) push(fr *typeFrame, t vType) *verifyError {
+	if t.isCategory2() {
+		return v.pushSlots(fr, t, topType)
+	}
+	return v.pushSlots(fr, t)
+}
+
+// pushSlots pushes the slots, which are already expanded (so, a long is followed by top)
+func (v *methodVerifier) pushSlots(fr *typeFrame, slots ...vType) *verifyError {
+	if len(fr.stack)+len(slots) > v.maxStack {
+		return v.fail("Operand stack overflow", "Exceeded max stack size.")
+	}
+	fr.stack = append(fr.stack, slots...)
+	return nil
+}
+
+// pop pops a value that must be assignable to want, which is int, float, long,
+// double, or a reference type. It returns the type of the value.
+func (v *methodVerifier) pop(fr *typeFrame, want vType) (vType, *verifyError) {
+	size := 1
+	if want.isCategory2() {
+		size = 2
+	}
+	if len(fr.stack) < size {
+		return topType, v.fail("Unable to pop operand off an empty stack", "Attempt to pop empty stack.")
+	}
+
+	index := len(fr.stack) - size
+	t := fr.stack[index]
+	ok := v.isAssignable(t, want)
+	if size == 2 {
+		ok = t.tag == want.tag && fr.stack[index+1].tag == vtTop
+	}
+	if !ok {
+		return topType, v.fail("Bad type on operand stack",
+			"Type %s (current frame, stack[%d]) is not assignable to %s", t, index, want)
+	}
+	fr.stack = fr.stack[:index]
+	return t, nil
+}
+
+// popArray pops a reference to an array (or null). If kind is not 0, it's the first
+// character of the array's component type: B (which includes arrays of booleans), C,
+// D, F, I, J, S, or L for any array of references.
+func (v *methodVerifier) popArray(fr *typeFrame, kind byte) (vType, *verifyError) {
+	t, verr := v.pop(fr, anyRefType)
+	if verr != nil || t.tag == vtNull {
+		return t, verr
+	}
+
+	ok := strings.HasPrefix(t.class, "[")
+	if ok && kind != 0 {
+		component := t.class[1]
+		switch kind {
+		case 'B':
+			ok = component == 'B' || component == 'Z'
+		case 'L':
+			ok = isRefComponent(t.class[1:])
+		default:
+			ok = component == kind
+		}
+	}
+	if !ok {
+		return topType, v.fail("Bad type on operand stack",
+			"Type %s (current frame, stack[%d]) is not an array of the type the instruction expects",
+			t, len(fr.stack))
+	}
+	return t, nil
+}
+
+// popSlots pops n slots of any type, but not half of a long or a double
+func (v *methodVerifier) popSlots(fr *typeFrame, n int) ([]vType, *verifyError) {
+	if len(fr.stack) < n {
+		return nil, v.fail("Unable to pop operand off an empty stack", "Attempt to pop empty stack.")
+	}
+	index := len(fr.stack) - n
+	if fr.stack[index].tag == vtTop {
+		return nil, v.fail("Bad type on operand stack",
+			"Type top (current frame, stack[%d]) is half of a long or double", index)
+	}
+	slots := append([]vType(nil), fr.stack[index:]...)
+	fr.stack = fr.stack[:index]
+	return slots, nil
+}
+
+// op pops the operands of an instruction, which are listed from the deepest in the
+// stack to the top, and pushes the result, unless it's top
+func (v *methodVerifier) op(fr *typeFrame, result vType, operands ...vType) *verifyError {
+	for i := len(operands) - 1; i >= 0; i-- {
+		if _, verr := v.pop(fr, operands[i]); verr != nil {
+			return verr
+		}
+	}
+	if result.tag == vtTop {
+		return nil
+	}
+	return v.push(fr, result)
+}
+
+// load pushes the value of a local variable, which must be of the type of want,
+// or, if want is anyRefType, any reference
+func (v *methodVerifier) load(fr *typeFrame, index int, want vType) *verifyError {
+	size := 1
+	if want.isCategory2() {
+		size = 2
+	}
+	if index < 0 || index+size > len(fr.locals) {
+		return v.fail("Illegal local variable number", "Local index %d is invalid", index)
+	}
+
+	t := fr.locals[index]
+	var ok bool
+	switch {
+	case size == 2:
+		ok = t.tag == want.tag && fr.locals[index+1].tag == vtTop
+	case want == anyRefType:
+		ok = t.isReference()
+	default:
+		ok = t.tag == want.tag
+	}
+	if !ok {
+		return v.fail("Bad local variable type",
+			"Type %s (current frame, locals[%d]) is not assignable to %s", t, index, want)
+	}
+	return v.push(fr, t)
+}
+
+// store pops a value of the type of want into a local variable
+func (v *methodVerifier) store(fr *typeFrame, index int, want vType) *verifyError {
+	t, verr := v.pop(fr, want)
+	if verr != nil {
+		return verr
+	}
+	return v.setLocal(fr, index, t)
+}
+
+func (v *methodVerifier) setLocal(fr *typeFrame, index int, t vType) *verifyError {
+	size := 1
+	if t.isCategory2() {
+		size = 2
+	}
+	if index < 0 || index+size > len(fr.locals) {
+		return v.fail("Illegal local variable number", "Local index %d is invalid", index)
+	}
+	if index > 0 && fr.locals[index-1].isCategory2() {
+		fr.locals[index-1] = topType // the value of the long or double is overwritten
+	}
+	fr.locals[index] = t
+	if size == 2 {
+		fr.locals[index+1] = topType
+	}
+	return nil
+}
+
+// replaceType replaces a type throughout the frame, as when an object is initialized
+func (fr *typeFrame) replaceType(old, new vType) {
+	for i := range fr.locals {
+		if fr.locals[i] == old {
+			fr.locals[i] = new
+		}
+	}
+	for i := range fr.stack {
+		if fr.stack[i] == old {
+			fr.stack[i] = new
+		}
+	}
+}
+
+// === the constant pool ===
+
+// badConstant is the error for an instruction whose CP operand is not of the right type
+func (v *methodVerifier) badConstant(index int) *verifyError {
+	return v.fail(fmt.Sprintf("Illegal type at constant pool entry %d in class %s", index, v.className),
+		"Constant pool index %d is invalid", index)
+}
+
+// cpMember returns the class, name, and descriptor of the field or method at index
+// in the CP, whose entry must be of one of the given types. The last return is false
+// if it's not.
+func (v *methodVerifier) cpMember(index int, types ...uint16) (string, string, string, bool) {
+	if index < 1 || index >= len(v.cp.CpIndex) {
+		return "", "", "", false
+	}
+	entry := v.cp.CpIndex[index]
+	found := false
+	for _, t := range types {
+		found = found || entry.Type == t
+	}
+	if !found {
+		return "", "", "", false
+	}
+
+	var classIndex, natIndex uint16
+	switch slot := int(entry.Slot); entry.Type {
+	case classloader.FieldRef:
+		if slot >= len(v.cp.FieldRefs) {
+			return "", "", "", false
+		}
+		classIndex, natIndex = v.cp.FieldRefs[slot].ClassIndex, v.cp.FieldRefs[slot].NameAndType
+	case classloader.MethodRef:
+		if slot >= len(v.cp.MethodRefs) {
+			return "", "", "", false
+		}
+		classIndex, natIndex = v.cp.MethodRefs[slot].ClassIndex, v.cp.MethodRefs[slot].NameAndType
+	case classloader.Interface:
+		if slot >= len(v.cp.InterfaceRefs) {
+			return "", "", "", false
+		}
+		classIndex, natIndex = v.cp.InterfaceRefs[slot].ClassIndex, v.cp.InterfaceRefs[slot].NameAndType
+	}
+
+	class, ok := cpClassName(v.cp, int(classIndex))
+	if !ok {
+		return "", "", "", false
+	}
+	name, desc, ok := v.cpNameAndType(int(natIndex))
+	return class, name, desc, ok
+}
+
+// cpNameAndType returns the name and descriptor of the NameAndType entry at index
+func (v *methodVerifier) cpNameAndType(index int) (string, string, bool) {
+	if index < 1 || index >= len(v.cp.CpIndex) || v.cp.CpIndex[index].Type != classloader.NameAndType ||
+		int(v.cp.CpIndex[index].Slot) >= len(v.cp.NameAndTypes) {
+		return "", "", false
+	}
+	nat := v.cp.NameAndTypes[v.cp.CpIndex[index].Slot]
+	name, ok1 := cpUTF8(v.cp, int(nat.NameIndex))
+	desc, ok2 := cpUTF8(v.cp, int(nat.DescIndex))
+	return name, desc, ok1 && ok2
+}
+
+// constantType returns the type of the constant that LDC and LDC_W (or, if wide is
+// set, LDC2_W) push. The second return is false if the constant can't be pushed.
+func (v *methodVerifier) constantType(index int, wide bool) (vType, bool) {
+	if index < 1 || index >= len(v.cp.CpIndex) {
+		return topType, false
+	}
+	var t vType
+	switch entry := v.cp.CpIndex[index]; entry.Type {
+	case classloader.IntConst:
+		t = intType
+	case classloader.FloatConst:
+		t = floatType
+	case classloader.LongConst:
+		t = longType
+	case classloader.DoubleConst:
+		t = doubleType
+	case classloader.UTF8: // String constants point to UTF8 entries once the class is loaded
+		t = refType("java/lang/String")
+	case classloader.ClassRef:
+		t = refType("java/lang/Class")
+	case classloader.MethodType:
+		t = refType("java/lang/invoke/MethodType")
+	case classloader.MethodHandle:
+		t = refType("java/lang/invoke/MethodHandle")
+	case classloader.Dynamic:
+		if int(entry.Slot) >= len(v.cp.Dynamics) {
+			return topType, false
+		}
+		_, desc, ok := v.cpNameAndType(int(v.cp.Dynamics[entry.Slot].NameAndType))
+		if t, ok = descriptorType(desc); !ok {
+			return topType, false
+		}
+	default:
+		return topType, false
+	}
+	return t, t.isCategory2() == wide
+}
+
+// === the instructions ===
+
+func (v *methodVerifier) u1(offset int) int {
+	return int(v.code[v.pc+offset])
+}
+
+func (v *methodVerifier) u2(offset int) int {
+	return int(binary.BigEndian.Uint16(v.code[v.pc+offset:]))
+}
+
+func (v *methodVerifier) s2(offset int) int {
+	return int(int16(binary.BigEndian.Uint16(v.code[v.pc+offset:])))
+}
+
+func (v *methodVerifier) s4(offset int) int {
+	return int(int32(binary.BigEndian.Uint32(v.code[v.pc+offset:])))
+}
+
+// the types of the loads and stores of locals, in the order of their opcodes
+var localTypes = []vType{intType, longType, floatType, doubleType, anyRefType}
+
+// checkInstruction checks the types of the operands of the current instruction and
+// updates the frame with its results. The first return is false if the next
+// instruction can't be reached from this one, as after a goto or a return.
+func (v *methodVerifier) checkInstruction(fr *typeFrame) (bool, *verifyError) {
+	var verr *verifyError
+	switch opcode := v.code[v.pc]; {
+	case opcode == NOP:
+	case opcode == ACONST_NULL:
+		verr = v.push(fr, nullType)
+	case opcode >= ICONST_M1 && opcode <= ICONST_5, opcode == BIPUSH, opcode == SIPUSH:
+		verr = v.push(fr, intType)
+	case opcode == LCONST_0 || opcode == LCONST_1:
+		verr = v.push(fr, longType)
+	case opcode >= FCONST_0 && opcode <= FCONST_2:
+		verr = v.push(fr, floatType)
+	case opcode == DCONST_0 || opcode == DCONST_1:
+		verr = v.push(fr, doubleType)
+	case opcode == LDC || opcode == LDC_W || opcode == LDC2_W:
+		index := v.u1(1)
+		if opcode != LDC {
+			index = v.u2(1)
+		}
+		t, ok := v.constantType(index, opcode == LDC2_W)
+		if !ok {
+			return false, v.badConstant(index)
+		}
+		verr = v.push(fr, t)
+
+	case opcode >= ILOAD && opcode <= ALOAD:
+		verr = v.load(fr, v.u1(1), localTypes[opcode-ILOAD])
+	case opcode >= ILOAD_0 && opcode <= ALOAD_3:
+		verr = v.load(fr, int(opcode-ILOAD_0)%4, localTypes[(opcode-ILOAD_0)/4])
+	case opcode >= ISTORE && opcode <= ASTORE:
+		verr = v.store(fr, v.u1(1), localTypes[opcode-ISTORE])
+	case opcode >= ISTORE_0 && opcode <= ASTORE_3:
+		verr = v.store(fr, int(opcode-ISTORE_0)%4, localTypes[(opcode-ISTORE_0)/4])
+	case opcode == IINC:
+		verr = v.iinc(fr, v.u1(1))
+	case opcode == WIDE:
+		verr = v.checkWide(fr)
+
+	case opcode == IALOAD:
+		verr = v.arrayLoad(fr, 'I', intType)
+	case opcode == BALOAD:
+		verr = v.arrayLoad(fr, 'B', intType)
+	case opcode == CALOAD:
+		verr = v.arrayLoad(fr, 'C', intType)
+	case opcode == SALOAD:
+		verr = v.arrayLoad(fr, 'S', intType)
+	case opcode == LALOAD:
+		verr = v.arrayLoad(fr, 'J', longType)
+	case opcode == FALOAD:
+		verr = v.arrayLoad(fr, 'F', floatType)
+	case opcode == DALOAD:
+		verr = v.arrayLoad(fr, 'D', doubleType)
+	case opcode == AALOAD:
+		verr = v.arrayLoad(fr, 'L', anyRefType)
+	case opcode == IASTORE:
+		verr = v.arrayStore(fr, 'I', intType)
+	case opcode == BASTORE:
+		verr = v.arrayStore(fr, 'B', intType)
+	case opcode == CASTORE:
+		verr = v.arrayStore(fr, 'C', intType)
+	case opcode == SASTORE:
+		verr = v.arrayStore(fr, 'S', intType)
+	case opcode == LASTORE:
+		verr = v.arrayStore(fr, 'J', longType)
+	case opcode == FASTORE:
+		verr = v.arrayStore(fr, 'F', floatType)
+	case opcode == DASTORE:
+		verr = v.arrayStore(fr, 'D', doubleType)
+	case opcode == AASTORE:
+		verr = v.arrayStore(fr, 'L', anyRefType)
+
+	case opcode == POP:
+		_, verr = v.popSlots(fr, 1)
+	case opcode == POP2:
+		_, verr = v.popSlots(fr, 2)
+	case opcode == DUP:
+		verr = v.dup(fr, 1, 0)
+	case opcode == DUP_X1:
+		verr = v.dup(fr, 1, 1)
+	case opcode == DUP_X2:
+		verr = v.dup(fr, 1, 2)
+	case opcode == DUP2:
+		verr = v.dup(fr, 2, 0)
+	case opcode == DUP2_X1:
+		verr = v.dup(fr, 2, 1)
+	case opcode == DUP2_X2:
+		verr = v.dup(fr, 2, 2)
+	case opcode == SWAP:
+		var value1, value2 []vType
+		if value1, verr = v.popSlots(fr, 1); verr == nil {
+			if value2, verr = v.popSlots(fr, 1); verr == nil {
+				verr = v.pushSlots(fr, append(value1, value2...)...)
+			}
+		}
+
+	case opcode == IADD || opcode == ISUB || opcode == IMUL || opcode == IDIV || opcode == IREM ||
+		opcode == ISHL || opcode == ISHR || opcode == IUSHR || opcode == IAND || opcode == IOR || opcode == IXOR:
+		verr = v.op(fr, intType, intType, intType)
+	case opcode == LADD || opcode == LSUB || opcode == LMUL || opcode == LDIV || opcode == LREM ||
+		opcode == LAND || opcode == LOR || opcode == LXOR:
+		verr = v.op(fr, longType, longType, longType)
+	case opcode == LSHL || opcode == LSHR || opcode == LUSHR:
+		verr = v.op(fr, longType, longType, intType)
+	case opcode == FADD || opcode == FSUB || opcode == FMUL || opcode == FDIV || opcode == FREM:
+		verr = v.op(fr, floatType, floatType, floatType)
+	case opcode == DADD || opcode == DSUB || opcode == DMUL || opcode == DDIV || opcode == DREM:
+		verr = v.op(fr, doubleType, doubleType, doubleType)
+	case opcode == INEG || opcode == I2B || opcode == I2C || opcode == I2S:
+		verr = v.op(fr, intType, intType)
+	case opcode == LNEG:
+		verr = v.op(fr, longType, longType)
+	case opcode == FNEG:
+		verr = v.op(fr, floatType, floatType)
+	case opcode == DNEG:
+		verr = v.op(fr, doubleType, doubleType)
+	case opcode == I2L:
+		verr = v.op(fr, longType, intType)
+	case opcode == I2F:
+		verr = v.op(fr, floatType, intType)
+	case opcode == I2D:
+		verr = v.op(fr, doubleType, intType)
+	case opcode == L2I:
+		verr = v.op(fr, intType, longType)
+	case opcode == L2F:
+		verr = v.op(fr, floatType, longType)
+	case opcode == L2D:
+		verr = v.op(fr, doubleType, longType)
+	case opcode == F2I:
+		verr = v.op(fr, intType, floatType)
+	case opcode == F2L:
+		verr = v.op(fr, longType, floatType)
+	case opcode == F2D:
+		verr = v.op(fr, doubleType, floatType)
+	case opcode == D2I:
+		verr = v.op(fr, intType, doubleType)
+	case opcode == D2L:
+		verr = v.op(fr, longType, doubleType)
+	case opcode == D2F:
+		verr = v.op(fr, floatType, doubleType)
+	case opcode == LCMP:
+		verr = v.op(fr, intType, longType, longType)
+	case opcode == FCMPL || opcode == FCMPG:
+		verr = v.op(fr, intType, floatType, floatType)
+	case opcode == DCMPL || opcode == DCMPG:
+		verr = v.op(fr, intType, doubleType, doubleType)
+
+	case opcode >= IFEQ && opcode <= IFLE:
+		if verr = v.op(fr, topType, intType); verr == nil {
+			verr = v.checkBranch(fr, v.pc+v.s2(1))
+		}
+	case opcode >= IF_ICMPEQ && opcode <= IF_ICMPLE:
+		if verr = v.op(fr, topType, intType, intType); verr == nil {
+			verr = v.checkBranch(fr, v.pc+v.s2(1))
+		}
+	case opcode == IF_ACMPEQ || opcode == IF_ACMPNE:
+		if verr = v.op(fr, topType, anyRefType, anyRefType); verr == nil {
+			verr = v.checkBranch(fr, v.pc+v.s2(1))
+		}
+	case opcode == IFNULL || opcode == IFNONNULL:
+		if verr = v.op(fr, topType, anyRefType); verr == nil {
+			verr = v.checkBranch(fr, v.pc+v.s2(1))
+		}
+	case opcode == GOTO:
+		return false, v.checkBranch(fr, v.pc+v.s2(1))
+	case opcode == GOTO_W:
+		return false, v.checkBranch(fr, v.pc+v.s4(1))
+	case opcode == TABLESWITCH || opcode == LOOKUPSWITCH:
+		return false, v.checkSwitch(fr)
+	case opcode == JSR || opcode == JSR_W || opcode == RET:
+		return false, v.fail("Bad instruction", "JSR and RET are not allowed in class files of version 51 or later.")
+
+	case opcode >= IRETURN && opcode <= RETURN:
+		return false, v.checkReturn(fr)
+	case opcode == ATHROW:
+		_, verr = v.pop(fr, refType("java/lang/Throwable"))
+		return false, verr
+
+	case opcode >= GETSTATIC && opcode <= PUTFIELD:
+		verr = v.checkField(fr)
+	case opcode >= INVOKEVIRTUAL && opcode <= INVOKEINTERFACE:
+		verr = v.checkInvoke(fr)
+	case opcode == INVOKEDYNAMIC:
+		verr = v.checkInvokeDynamic(fr)
+
+	case opcode == NEW:
+		verr = v.checkNew(fr)
+	case opcode == NEWARRAY:
+		atype := v.u1(1)
+		if atype < 4 || atype > 11 { // T_BOOLEAN through T_LONG
+			return false, v.fail("Illegal newarray instruction", "Array type %d is invalid.", atype)
+		}
+		verr = v.op(fr, refType("["+"ZCFDBSIJ"[atype-4:atype-3]), intType)
+	case opcode == ANEWARRAY:
+		class, ok := cpClassName(v.cp, v.u2(1))
+		if !ok {
+			return false, v.badConstant(v.u2(1))
+		}
+		arrayType := "[L" + class + ";"
+		if strings.HasPrefix(class, "[") {
+			arrayType = "[" + class
+		}
+		verr = v.op(fr, refType(arrayType), intType)
+	case opcode == MULTIANEWARRAY:
+		class, ok := cpClassName(v.cp, v.u2(1))
+		if !ok {
+			return false, v.badConstant(v.u2(1))
+		}
+		dimensions := v.u1(3)
+		if dimensions < 1 || len(class)-len(strings.TrimLeft(class, "[")) < dimensions {
+			return false, v.fail("Illegal dimension in multianewarray instruction",
+				"Class %s does not have %d dimensions.", class, dimensions)
+		}
+		counts := make([]vType, dimensions)
+		for i := range counts {
+			counts[i] = intType
+		}
+		verr = v.op(fr, refType(class), counts...)
+	case opcode == ARRAYLENGTH:
+		if _, verr = v.popArray(fr, 0); verr == nil {
+			verr = v.push(fr, intType)
+		}
+	case opcode == CHECKCAST || opcode == INSTANCEOF:
+		class, ok := cpClassName(v.cp, v.u2(1))
+		if !ok {
+			return false, v.badConstant(v.u2(1))
+		}
+		result := intType
+		if opcode == CHECKCAST {
+			result = refType(class)
+		}
+		verr = v.op(fr, result, anyRefType)
+	case opcode == MONITORENTER || opcode == MONITOREXIT:
+		verr = v.op(fr, topType, anyRefType)
+
+	default:
+		return false, v.fail("Bad instruction", "Error exists in the bytecode.")
+	}
+	return verr == nil, verr
+}
+
+// iinc checks the increment of an int local variable
+func (v *methodVerifier) iinc(fr *typeFrame, index int) *verifyError {
+	if index >= len(fr.locals) {
+		return v.fail("Illegal local variable number", "Local index %d is invalid", index)
+	}
+	if fr.locals[index].tag != vtInt {
+		return v.fail("Bad local variable type",
+			"Type %s (current frame, locals[%d]) is not assignable to integer", fr.locals[index], index)
+	}
+	return nil
+}
+
+// checkWide checks a load, store, or IINC with a two-byte index
+func (v *methodVerifier) checkWide(fr *typeFrame) *verifyError {
+	index := v.u2(2)
+	switch opcode := v.code[v.pc+1]; {
+	case opcode >= ILOAD && opcode <= ALOAD:
+		return v.load(fr, index, localTypes[opcode-ILOAD])
+	case opcode >= ISTORE && opcode <= ASTORE:
+		return v.store(fr, index, localTypes[opcode-ISTORE])
+	case opcode == IINC:
+		return v.iinc(fr, index)
+	}
+	return v.fail("Bad instruction", "Instruction %d can't be modified by wide.", v.code[v.pc+1])
+}
+
+// arrayLoad checks the load of an element of an array, whose kind is as in popArray()
+func (v *methodVerifier) arrayLoad(fr *typeFrame, kind byte, element vType) *verifyError {
+	if _, verr := v.pop(fr, intType); verr != nil {
+		return verr
+	}
+	array, verr := v.popArray(fr, kind)
+	if verr != nil {
+		return verr
+	}
+	if kind == 'L' { // the type of the element comes from that of the array
+		element = nullType
+		if array.tag == vtRef {
+			element = refType(componentClass(array.class[1:]))
+		}
+	}
+	return v.push(fr, element)
+}
+
+// arrayStore checks the store of an element in an array. As in the JVM spec, it
+// doesn't check that a reference is assignable to the array's component type,
+// which AASTORE checks when it's run.
+func (v *methodVerifier) arrayStore(fr *typeFrame, kind byte, element vType) *verifyError {
+	if _, verr := v.pop(fr, element); verr != nil {
+		return verr
+	}
+	if _, verr := v.pop(fr, intType); verr != nil {
+		return verr
+	}
+	_, verr := v.popArray(fr, kind)
+	return verr
+}
+
+// dup checks the DUP instructions, which copy the top size slots of the stack
+// and insert the copy below the depth slots under them
+func (v *methodVerifier) dup(fr *typeFrame, size, depth int) *verifyError {
+	top, verr := v.popSlots(fr, size)
+	if verr != nil {
+		return verr
+	}
+	var under []vType
+	if depth > 0 {
+		if under, verr = v.popSlots(fr, depth); verr != nil {
+			return verr
+		}
+	}
+	slots := append(append(append([]vType(nil), top...), under...), top...)
+	return v.pushSlots(fr, slots...)
+}
+
+// checkSwitch checks TABLESWITCH and LOOKUPSWITCH, whose default offset and
+// branch offsets follow padding to a 4-byte boundary
+func (v *methodVerifier) checkSwitch(fr *typeFrame) *verifyError {
+	if _, verr := v.pop(fr, intType); verr != nil {
+		return verr
+	}
+	base := 1 + (4-(v.pc+1)%4)%4
+	targets := []int{v.s4(base)}
+	if v.code[v.pc] == TABLESWITCH {
+		low, high := v.s4(base+4), v.s4(base+8)
+		for i := 0; i <= high-low; i++ {
+			targets = append(targets, v.s4(base+12+4*i))
+		}
+	} else {
+		pairs := v.s4(base + 4)
+		for i := 0; i < pairs; i++ {
+			if i > 0 && v.s4(base+8+8*i) <= v.s4(base+8+8*(i-1)) {
+				return v.fail("Bad lookupswitch instruction", "The keys of lookupswitch are not sorted.")
+			}
+			targets = append(targets, v.s4(base+12+8*i))
+		}
+	}
+
+	for _, offset := range targets {
+		if verr := v.checkBranch(fr, v.pc+offset); verr != nil {
+			return verr
+		}
+	}
+	return nil
+}
+
+// checkReturn checks the return instructions against the method's return type
+func (v *methodVerifier) checkReturn(fr *typeFrame) *verifyError {
+	opcode := v.code[v.pc]
+	if opcode == RETURN {
+		if v.returnType.tag != vtTop {
+			return v.fail("Method expects a return value", "Expected a return value of type %s.", v.returnType)
+		}
+		if v.isInit && fr.thisUninit {
+			return v.fail("Constructor must call super() or this() before return",
+				"The constructor returns before this is initialized.")
+		}
+		return nil
+	}
+
+	if v.returnType.tag == vtTop {
+		return v.fail("Method does not expect a return value", "The method is void.")
+	}
+	if v.returnType.tag != localTypes[opcode-IRETURN].tag {
+		return v.fail("Bad return type", "The method's return type is %s.", v.returnType)
+	}
+	_, verr := v.pop(fr, v.returnType)
+	return verr
+}
+
+// checkField checks GETSTATIC, PUTSTATIC, GETFIELD, and PUTFIELD
+func (v *methodVerifier) checkField(fr *typeFrame) *verifyError {
+	index := v.u2(1)
+	class, name, desc, ok := v.cpMember(index, classloader.FieldRef)
+	if !ok {
+		return v.badConstant(index)
+	}
+	fieldType, ok := descriptorType(desc)
+	if !ok {
+		return v.badConstant(index)
+	}
+
+	switch v.code[v.pc] {
+	case GETSTATIC:
+		return v.push(fr, fieldType)
+	case PUTSTATIC:
+		_, verr := v.pop(fr, fieldType)
+		return verr
+	case GETFIELD:
+		if _, verr := v.pop(fr, refType(class)); verr != nil {
+			return verr
+		}
+		return v.push(fr, fieldType)
+	default: // PUTFIELD
+		if _, verr := v.pop(fr, fieldType); verr != nil {
+			return verr
+		}
+		// a constructor can set the fields its class declares before calling super()
+		if len(fr.stack) > 0 && fr.stack[len(fr.stack)-1].tag == vtUninitThis && class == v.className &&
+			findDeclaredField(v.k, name, desc) {
+			fr.stack = fr.stack[:len(fr.stack)-1]
+			return nil
+		}
+		_, verr := v.pop(fr, refType(class))
+		return verr
+	}
+}
+
+// findDeclaredField reports whether the class declares the field
+func findDeclaredField(k *classloader.Klass, fieldName, fieldType string) bool {
+	for _, f := range k.Data.Fields {
+		if k.Data.CP.Utf8Refs[f.Name] == fieldName && k.Data.CP.Utf8Refs[f.Desc] == fieldType {
+			return true
+		}
+	}
+	return false
+}
+
+// checkInvoke checks INVOKEVIRTUAL, INVOKESPECIAL, INVOKESTATIC, and INVOKEINTERFACE
+func (v *methodVerifier) checkInvoke(fr *typeFrame) *verifyError {
+	opcode := v.code[v.pc]
+	index := v.u2(1)
+	refTypes := []uint16{classloader.MethodRef, classloader.Interface}
+	if opcode == INVOKEVIRTUAL {
+		refTypes = refTypes[:1]
+	} else if opcode == INVOKEINTERFACE {
+		refTypes = refTypes[1:]
+	}
+	class, name, desc, ok := v.cpMember(index, refTypes...)
+	if !ok {
+		return v.badConstant(index)
+	}
+	if strings.HasPrefix(name, "<") && (opcode != INVOKESPECIAL || name != "<init>") {
+		return v.fail("Illegal call to internal method", "Method %s can't be invoked by this instruction.", name)
+	}
+	params, ret, ok := methodDescriptorTypes(desc)
+	if !ok {
+		return v.badConstant(index)
+	}
+
+	if opcode == INVOKEINTERFACE {
+		argSlots := len(expandTypes(params)) + 1
+		if v.u1(3) != argSlots {
+			return v.fail("Inconsistent args count operand in invokeinterface",
+				"The count operand is %d, but the arguments take %d slots.", v.u1(3), argSlots)
+		}
+		if v.u1(4) != 0 {
+			return v.fail("Fourth operand byte of invokeinterface must be zero", "The operand is %d.", v.u1(4))
+		}
+	}
+
+	for i := len(params) - 1; i >= 0; i-- {
+		if _, verr := v.pop(fr, params[i]); verr != nil {
+			return verr
+		}
+	}
+
+	switch {
+	case opcode == INVOKESTATIC:
+	case name == "<init>":
+		if verr := v.checkInit(fr, class); verr != nil {
+			return verr
+		}
+	case opcode == INVOKESPECIAL: // a private or superclass method, called on this (or a subclass)
+		if _, verr := v.pop(fr, refType(v.className)); verr != nil {
+			return verr
+		}
+	default:
+		if _, verr := v.pop(fr, refType(class)); verr != nil {
+			return verr
+		}
+	}
+
+	if ret.tag == vtTop {
+		return nil
+	}
+	return v.push(fr, ret)
+}
+
+// checkInit checks the call of a constructor, after which the object it initializes
+// is no longer uninitialized. A constructor can call one of its own class's other
+// constructors or one of its superclass's.
+func (v *methodVerifier) checkInit(fr *typeFrame, class string) *verifyError {
+	object, verr := v.pop(fr, anyRefType)
+	if verr != nil {
+		return verr
+	}
+
+	switch object.tag {
+	case vtUninitThis:
+		if class != v.className && class != v.k.Data.Superclass {
+			return v.fail("Bad <init> method call",
+				"Constructor of %s can't initialize this, which is of class %s.", class, v.className)
+		}
+		fr.thisUninit = false
+		fr.replaceType(object, refType(v.className))
+	case vtUninit:
+		newClass, _ := cpClassName(v.cp, int(binary.BigEndian.Uint16(v.code[object.offset+1:])))
+		if newClass != class {
+			return v.fail("Call to wrong <init> method",
+				"Constructor of %s can't initialize the %s created at %d.", class, newClass, object.offset)
+		}
+		fr.replaceType(object, refType(class))
+	default:
+		return v.fail("Bad operand type when invoking <init>",
+			"Type %s (current frame, stack[%d]) is not an uninitialized object", object, len(fr.stack))
+	}
+	return nil
+}
+
+// checkInvokeDynamic checks INVOKEDYNAMIC, whose call site's descriptor gives the
+// types of its arguments and result
+func (v *methodVerifier) checkInvokeDynamic(fr *typeFrame) *verifyError {
+	index := v.u2(1)
+	if index < 1 || index >= len(v.cp.CpIndex) || v.cp.CpIndex[index].Type != classloader.InvokeDynamic ||
+		int(v.cp.CpIndex[index].Slot) >= len(v.cp.InvokeDynamics) {
+		return v.badConstant(index)
+	}
+	if v.u2(3) != 0 {
+		return v.fail("Third and fourth operand bytes of invokedynamic must be zero", "The operands are %d.", v.u2(3))
+	}
+	_, desc, ok := v.cpNameAndType(int(v.cp.InvokeDynamics[v.cp.CpIndex[index].Slot].NameAndType))
+	if !ok {
+		return v.badConstant(index)
+	}
+	params, ret, ok := methodDescriptorTypes(desc)
+	if !ok {
+		return v.badConstant(index)
+	}
+	return v.op(fr, ret, params...)
+}
+
+// checkNew checks NEW, which pushes an uninitialized object identified by the offset
+// of the instruction
+func (v *methodVerifier) checkNew(fr *typeFrame) *verifyError {
+	class, ok := cpClassName(v.cp, v.u2(1))
+	if !ok || strings.HasPrefix(class, "[") {
+		return v.badConstant(v.u2(1))
+	}
+	uninit := vType{tag: vtUninit, offset: v.pc}
+	for i, t := range fr.stack {
+		if t == uninit {
+			return v.fail("Uninitialized object exists on backward branch",
+				"Type %s (current frame, stack[%d]) was created by this instruction.", t, i)
+		}
+	}
+	fr.replaceType(uninit, topType) // an earlier object from this NEW is lost
+	return v.push(fr, uninit)
+}
@@ -0,0 +1,183 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"jacobin/classloader"
+	"jacobin/frames"
+	"jacobin/globals"
+	"strings"
+	"testing"
+)
+
+// a method of a class to be verified, with the content of its StackMapTable
+// attribute, if it has one
+type verifierTestMethod struct {
+	name      string
+	desc      string
+	flags     int
+	maxStack  int
+	maxLocals int
+	code      []byte
+	stackMap  []byte
+}
+
+// adds a class whose class file is that of Java 17, so that it's verified
+func addVerifierTestClass(name string, b *cpBuilder, methods ...verifierTestMethod) *classloader.Klass {
+	attrName := uint16(len(b.cp.Utf8Refs))
+	b.cp.Utf8Refs = append(b.cp.Utf8Refs, "StackMapTable")
+	meths := make([]testMethod, len(methods))
+	for i, m := range methods {
+		meths[i] = testMethod{m.name, m.desc, m.flags, m.code}
+	}
+
+	k := addClass(name, "java/lang/Object", nil, b, nil, meths...)
+	k.Data.Version = 61
+	for i, m := range methods {
+		code := &k.Data.Methods[i].CodeAttr
+		code.MaxStack, code.MaxLocals = m.maxStack, m.maxLocals
+		if m.stackMap != nil {
+			code.Attributes = []classloader.Attr{
+				{AttrName: attrName, AttrSize: len(m.stackMap), AttrContent: m.stackMap}}
+		}
+	}
+	return k
+}
+
+// static int max(int a, int b) { return a > b ? a : b; }
+var maxMethod = verifierTestMethod{"max", "(II)I", accStatic, 2, 2,
+	[]byte{
+		ILOAD_0, ILOAD_1, IF_ICMPLE, 0x00, 0x05,
+		ILOAD_0, IRETURN,
+		ILOAD_1, IRETURN}, // 7
+	[]byte{0x00, 0x01, 7}} // same_frame at 7
+
+func TestVerifyValidClass(t *testing.T) {
+	setupInterfaceClasses()
+	b := newCPBuilder()
+	objectInit := b.methodRef("java/lang/Object", "<init>", "()V")
+	k := addVerifierTestClass("test/Valid", b,
+		verifierTestMethod{"<init>", "()V", 0, 1, 1,
+			[]byte{ALOAD_0, INVOKESPECIAL, 0x00, byte(objectInit), RETURN}, nil},
+		maxMethod,
+		// static long sum(int n) { long total = 0; for (; n > 0; n--) total += n; return total; }
+		verifierTestMethod{"sum", "(I)J", accStatic, 4, 3,
+			[]byte{
+				LCONST_0, LSTORE_1,
+				ILOAD_0, IFLE, 0x00, 0x0E, // 2
+				LLOAD_1, ILOAD_0, I2L, LADD, LSTORE_1,
+				IINC, 0x00, 0xFF,
+				GOTO, 0xFF, 0xF4,
+				LLOAD_1, LRETURN}, // 17
+			[]byte{0x00, 0x02,
+				252, 0x00, 0x02, 4, // append_frame at 2 with a long
+				14}}) // same_frame at 17
+
+	if err := verifyClass(k); err != nil {
+		t.Errorf("Unexpected VerifyError: %s", err.Error())
+	}
+}
+
+func TestVerifyInvalidMethods(t *testing.T) {
+	noStackMap := maxMethod
+	noStackMap.stackMap = nil
+
+	tests := []struct {
+		method verifierTestMethod
+		gist   string
+	}{
+		{verifierTestMethod{"add", "(Ljava/lang/String;)I", accStatic, 2, 1,
+			[]byte{ALOAD_0, ICONST_1, IADD, IRETURN}, nil},
+			"Bad type on operand stack"},
+		{noStackMap, "Expecting a stackmap frame at branch target 7"},
+		{verifierTestMethod{"push", "()V", accStatic, 2, 0,
+			[]byte{ICONST_1, ICONST_1, ICONST_1, RETURN}, nil},
+			"Operand stack overflow"},
+		{verifierTestMethod{"pop", "()V", accStatic, 2, 0,
+			[]byte{POP, RETURN}, nil},
+			"Unable to pop operand off an empty stack"},
+		{verifierTestMethod{"split", "()V", accStatic, 2, 0,
+			[]byte{LCONST_0, POP, RETURN}, nil},
+			"Bad type on operand stack"},
+		{verifierTestMethod{"fall", "()V", accStatic, 1, 0,
+			[]byte{ICONST_0, POP}, nil},
+			"Falling off the end of the code"},
+		{verifierTestMethod{"noReturn", "()I", accStatic, 1, 0,
+			[]byte{RETURN}, nil},
+			"Method expects a return value"},
+		{verifierTestMethod{"<init>", "()V", 0, 1, 1,
+			[]byte{RETURN}, nil},
+			"Constructor must call super() or this() before return"},
+		{verifierTestMethod{"local", "()I", accStatic, 1, 1,
+			[]byte{ILOAD_1, IRETURN}, nil},
+			"Illegal local variable number"},
+	}
+	for _, test := range tests {
+		setupInterfaceClasses()
+		k := addVerifierTestClass("test/Invalid", newCPBuilder(), test.method)
+
+		err := verifyClass(k)
+		if err == nil || !strings.HasPrefix(err.Error(), test.gist+"\n") {
+			t.Errorf("%s: Expected a VerifyError of %s, got: %v", test.method.name, test.gist, err)
+		}
+	}
+}
+
+func TestVerifyErrorDetails(t *testing.T) {
+	setupInterfaceClasses()
+	k := addVerifierTestClass("test/Invalid", newCPBuilder(),
+		verifierTestMethod{"add", "(Ljava/lang/String;)I", accStatic, 2, 1,
+			[]byte{ALOAD_0, ICONST_1, IADD, IRETURN}, nil})
+
+	expected := "Bad type on operand stack\n" +
+		"Exception Details:\n" +
+		"  Location:\n" +
+		"    test/Invalid.add(Ljava/lang/String;)I @2: iadd\n" +
+		"  Reason:\n" +
+		"    Type 'java/lang/String' (current frame, stack[0]) is not assignable to integer"
+	if err := verifyClass(k); err == nil || err.Error() != expected {
+		t.Errorf("Unexpected VerifyError: %v", err)
+	}
+}
+
+// an invalid class can't be initialized, unless verification is off
+func TestVerifyOnInitialization(t *testing.T) {
+	invalid := verifierTestMethod{"run", "()V", accStatic, 1, 0, []byte{POP, RETURN}, nil}
+
+	f := newFrame(RETURN)
+	f.ClName = "test/Main"
+	f.MethName = "main"
+	fs := frames.CreateFrameStack()
+	fs.PushFront(&f)
+
+	setupInterfaceClasses()
+	addVerifierTestClass("test/Invalid", newCPBuilder(), invalid)
+	for i := 0; i < 2; i++ { // the class remains invalid
+		err := initializeClass(fs, "test/Invalid")
+		if jt, ok := err.(*javaThrowable); !ok || jt.className != "java/lang/VerifyError" {
+			t.Errorf("Expected a VerifyError, got: %v", err)
+		}
+	}
+
+	// JDK classes are verified only with -Xverify:all
+	addVerifierTestClass("java/lang/Invalid", newCPBuilder(), invalid)
+	if err := initializeClass(fs, "java/lang/Invalid"); err != nil {
+		t.Errorf("Expected the JDK class not to be verified, got: %v", err)
+	}
+	globals.GetGlobalRef().VerifyLevel = globals.VerifyAll
+	addVerifierTestClass("java/lang/Invalid", newCPBuilder(), invalid) // not yet initialized
+	if _, ok := initializeClass(fs, "java/lang/Invalid").(*javaThrowable); !ok {
+		t.Errorf("Expected a VerifyError for the JDK class with -Xverify:all")
+	}
+
+	setupInterfaceClasses()
+	globals.GetGlobalRef().VerifyLevel = globals.VerifyNone
+	addVerifierTestClass("test/Invalid", newCPBuilder(), invalid)
+	if err := initializeClass(fs, "test/Invalid"); err != nil {
+		t.Errorf("Expected the class not to be verified with -Xverify:none, got: %v", err)
+	}
+}
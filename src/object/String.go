@@ -96,3 +96,20 @@ func CreateCompactStringFromGoString(in *string) *Object {
 	s.Fields[1].Fvalue = int64(0)
 	return s
 }
+
+// GetGoStringFromJavaString returns the go string held in a Java string,
+// whether its chars are stored as bytes (compact strings) or as a go string.
+// If the object is null or is not a string, "" is returned.
+func GetGoStringFromJavaString(s *Object) string {
+	if s == nil || len(s.Fields) == 0 {
+		return ""
+	}
+
+	switch s.Fields[0].Fvalue.(type) {
+	case *[]byte:
+		return string(*(s.Fields[0].Fvalue.(*[]byte)))
+	case string:
+		return s.Fields[0].Fvalue.(string)
+	}
+	return ""
+}
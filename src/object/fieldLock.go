@@ -0,0 +1,28 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package object
+
+import (
+	"sync"
+	"unsafe"
+)
+
+// The fields of an object, and the elements of an array, are guarded by a field
+// lock whenever they're accessed in ways that threads must see atomically and in
+// order: by GETFIELD and PUTFIELD, which makes volatile fields behave as the JLS
+// requires, and by the methods of jdk.internal.misc.Unsafe, such as compare-and-set.
+// Rather than a mutex in every object, the objects share a fixed set of locks, and
+// the lock of an object is chosen by its address. (Go's garbage collector does not
+// move objects, so the address is stable.)
+const fieldLockCount = 64
+
+var fieldLocks [fieldLockCount]sync.Mutex
+
+// FieldLock returns the lock that guards the object's fields or, for an array, its elements
+func (o *Object) FieldLock() *sync.Mutex {
+	return &fieldLocks[(uintptr(unsafe.Pointer(o))>>4)%fieldLockCount]
+}
@@ -0,0 +1,286 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package object
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Every object has a monitor, which a thread locks by entering a synchronized block
+// or method. The monitor is reentrant: the thread that holds it can enter it again,
+// and it's released when the thread has exited it as many times as it entered it.
+//
+// Most monitors are only ever locked by one thread at a time, so the lock starts out
+// thin: the misc field of the object's mark word holds the ID of the thread that
+// holds it and the number of times the thread has entered it, which are changed by
+// compare-and-swap. Once a second thread must wait for the monitor (or the thread's
+// entries exceed what the mark word can count), the lock is inflated to a Monitor,
+// which has a mutex and a condition on which threads wait, and the mark word holds
+// the Monitor's index in the table of inflated monitors. A monitor is not deflated.
+// Since threads that wait on the monitor (by Object.wait()) are kept in its wait set,
+// a thread inflates the lock before it waits.
+//
+// The layout of MarkWord.Misc is:
+//
+//	0                            the monitor is not locked
+//	0 | (threadID + 1) << 8 | n  thin lock held by the thread, which entered it n+1 times
+//	1 << 31 | index              inflated: the Monitor is monitors.table[index]
+const (
+	inflatedBit   = uint32(1) << 31
+	thinCountMask = uint32(0xFF)
+	thinOwnerMax  = int(inflatedBit>>8) - 2 // the highest thread ID that fits in a thin lock
+)
+
+// Monitor is an inflated monitor
+type Monitor struct {
+	mutex sync.Mutex
+	freed *sync.Cond // signalled when the monitor is released
+	owner int        // the ID of the thread that holds the monitor; -1 if none
+	count int        // the number of times the owner has entered the monitor
+
+	waitSet []chan struct{} // the threads waiting to be notified, in the order they began waiting
+}
+
+// the inflated monitors. An object's mark word holds the index of its monitor.
+var monitors struct {
+	sync.RWMutex
+	table []*Monitor
+}
+
+func newMonitor(owner, count int) *Monitor {
+	m := &Monitor{owner: owner, count: count}
+	m.freed = sync.NewCond(&m.mutex)
+	return m
+}
+
+// MonitorEnter locks the object's monitor for the thread, first waiting until no
+// other thread holds it
+func (o *Object) MonitorEnter(threadID int) {
+	for {
+		misc := atomic.LoadUint32(&o.Mark.Misc)
+		switch {
+		case misc&inflatedBit != 0:
+			monitorAt(misc).enter(threadID)
+			return
+		case misc == 0 && threadID >= 0 && threadID <= thinOwnerMax:
+			if atomic.CompareAndSwapUint32(&o.Mark.Misc, 0, thinLock(threadID)) {
+				return
+			}
+		case misc != 0 && thinOwner(misc) == threadID && misc&thinCountMask < thinCountMask:
+			if atomic.CompareAndSwapUint32(&o.Mark.Misc, misc, misc+1) {
+				return
+			}
+		default: // another thread holds the thin lock, or it can't be thin
+			o.inflate(misc)
+		}
+	}
+}
+
+// MonitorTryEnter locks the object's monitor for the thread if it can do so without
+// waiting. It returns false if another thread holds the monitor.
+func (o *Object) MonitorTryEnter(threadID int) bool {
+	for {
+		misc := atomic.LoadUint32(&o.Mark.Misc)
+		switch {
+		case misc&inflatedBit != 0:
+			return monitorAt(misc).tryEnter(threadID)
+		case misc != 0 && thinOwner(misc) != threadID:
+			return false
+		case misc == 0 && threadID >= 0 && threadID <= thinOwnerMax:
+			if atomic.CompareAndSwapUint32(&o.Mark.Misc, 0, thinLock(threadID)) {
+				return true
+			}
+		case misc != 0 && misc&thinCountMask < thinCountMask:
+			if atomic.CompareAndSwapUint32(&o.Mark.Misc, misc, misc+1) {
+				return true
+			}
+		default: // the lock can't be thin
+			o.inflate(misc)
+		}
+	}
+}
+
+// MonitorExit unlocks the object's monitor, once for each time the thread entered
+// it. It returns false if the thread does not hold the monitor.
+func (o *Object) MonitorExit(threadID int) bool {
+	for {
+		misc := atomic.LoadUint32(&o.Mark.Misc)
+		switch {
+		case misc&inflatedBit != 0:
+			return monitorAt(misc).exit(threadID)
+		case misc == 0 || thinOwner(misc) != threadID:
+			return false
+		case misc&thinCountMask == 0: // the last exit
+			if atomic.CompareAndSwapUint32(&o.Mark.Misc, misc, 0) {
+				return true
+			}
+		default:
+			if atomic.CompareAndSwapUint32(&o.Mark.Misc, misc, misc-1) {
+				return true
+			}
+		}
+	}
+}
+
+// HoldsMonitor returns true if the thread holds the object's monitor
+func (o *Object) HoldsMonitor(threadID int) bool {
+	misc := atomic.LoadUint32(&o.Mark.Misc)
+	if misc&inflatedBit != 0 {
+		m := monitorAt(misc)
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
+		return m.owner == threadID
+	}
+	return misc != 0 && thinOwner(misc) == threadID
+}
+
+// MonitorWait releases the object's monitor, which the thread must hold, and waits
+// until another thread notifies it, the timeout elapses (unless timeout is 0), or
+// wake is signalled. (Per JLS 17.2.1, the wait can end for no reason, so callers
+// wait in a loop.) It then locks the monitor again, as many times as the thread had
+// entered it. It returns whether the thread was notified, and false for held if the
+// thread does not hold the monitor, in which case it doesn't wait.
+func (o *Object) MonitorWait(threadID int, timeout time.Duration, wake <-chan struct{}) (notified, held bool) {
+	if !o.HoldsMonitor(threadID) {
+		return false, false
+	}
+	misc := atomic.LoadUint32(&o.Mark.Misc)
+	for misc&inflatedBit == 0 { // the wait set is in the inflated monitor
+		o.inflate(misc)
+		misc = atomic.LoadUint32(&o.Mark.Misc)
+	}
+	m := monitorAt(misc)
+
+	m.mutex.Lock()
+	count := m.count
+	notice := make(chan struct{}, 1)
+	m.waitSet = append(m.waitSet, notice)
+	m.owner, m.count = -1, 0
+	m.freed.Signal()
+	m.mutex.Unlock()
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	select {
+	case <-notice:
+	case <-expired:
+	case <-wake:
+	}
+
+	m.mutex.Lock()
+	notified = true
+	for i, waiter := range m.waitSet {
+		if waiter == notice { // still in the wait set, so not notified
+			m.waitSet = append(m.waitSet[:i], m.waitSet[i+1:]...)
+			notified = false
+			break
+		}
+	}
+	for m.owner != -1 {
+		m.freed.Wait()
+	}
+	m.owner, m.count = threadID, count
+	m.mutex.Unlock()
+	return notified, true
+}
+
+// MonitorNotify wakes one of the threads waiting on the object's monitor, or all
+// of them if all is true. The thread must hold the monitor, else it returns false.
+func (o *Object) MonitorNotify(threadID int, all bool) bool {
+	misc := atomic.LoadUint32(&o.Mark.Misc)
+	if misc&inflatedBit == 0 { // no thread can be waiting on a thin lock
+		return misc != 0 && thinOwner(misc) == threadID
+	}
+
+	m := monitorAt(misc)
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.owner != threadID {
+		return false
+	}
+	for len(m.waitSet) > 0 {
+		m.waitSet[0] <- struct{}{}
+		m.waitSet = m.waitSet[1:]
+		if !all {
+			break
+		}
+	}
+	return true
+}
+
+// inflate replaces the lock in the mark word, which was misc when examined, with a
+// Monitor that has the same state. If the mark word has changed since, it's left
+// as is, and the caller should examine it again.
+func (o *Object) inflate(misc uint32) {
+	owner, count := -1, 0
+	if misc != 0 {
+		owner, count = thinOwner(misc), int(misc&thinCountMask)+1
+	}
+	m := newMonitor(owner, count)
+
+	monitors.Lock()
+	defer monitors.Unlock()
+	if atomic.CompareAndSwapUint32(&o.Mark.Misc, misc, inflatedBit|uint32(len(monitors.table))) {
+		monitors.table = append(monitors.table, m)
+	}
+}
+
+// monitorAt returns the inflated monitor whose index is in the mark word's misc field
+func monitorAt(misc uint32) *Monitor {
+	monitors.RLock()
+	defer monitors.RUnlock()
+	return monitors.table[misc&^inflatedBit]
+}
+
+func thinLock(threadID int) uint32 {
+	return uint32(threadID+1) << 8
+}
+
+func thinOwner(misc uint32) int {
+	return int(misc>>8) - 1
+}
+
+func (m *Monitor) enter(threadID int) {
+	m.mutex.Lock()
+	for m.owner != -1 && m.owner != threadID {
+		m.freed.Wait()
+	}
+	m.owner = threadID
+	m.count++
+	m.mutex.Unlock()
+}
+
+func (m *Monitor) tryEnter(threadID int) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.owner != -1 && m.owner != threadID {
+		return false
+	}
+	m.owner = threadID
+	m.count++
+	return true
+}
+
+func (m *Monitor) exit(threadID int) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.owner != threadID {
+		return false
+	}
+	m.count--
+	if m.count == 0 {
+		m.owner = -1
+		m.freed.Signal()
+	}
+	return true
+}
@@ -23,11 +23,11 @@ type Object struct {
 
 // These mark word contains values for different purposes. Here,
 // we use the first four bytes for a hash value, which is taken
-// from the address of the object. The 'misc' field will eventually
-// contain other values, such as locking and monitoring items.
+// from the address of the object. The 'misc' field holds the state
+// of the object's monitor (see monitor.go).
 type MarkWord struct {
 	Hash uint32 // contains hash code which is the lower 32 bits of the address
-	Misc uint32 // at present unused
+	Misc uint32 // the lock state of the object's monitor, accessed atomically
 }
 
 // We need to know the type of the field only to tell whether
@@ -11,6 +11,7 @@ import (
 	"jacobin/globals"
 	"jacobin/log"
 	"os"
+	"sync"
 )
 
 // The various flags that can be passed to the exit() function, reflecting
@@ -26,10 +27,33 @@ const (
 	UNKNOWN_ERROR
 )
 
-// Shutdown is the exit function. Later on, this will check a list of JVM Shutdown hooks
-// before closing down in order to have an orderly exit
+// the functions that Exit() runs before closing down, which AddHook() adds
+var (
+	hooks      []func()
+	hooksMutex sync.Mutex
+)
+
+// AddHook adds a function for Exit() to run before closing down, such as one that
+// removes temporary files. Each function is run once.
+func AddHook(hook func()) {
+	hooksMutex.Lock()
+	hooks = append(hooks, hook)
+	hooksMutex.Unlock()
+}
+
+// Shutdown is the exit function. It runs the functions added by AddHook(). Later on,
+// this will check a list of JVM Shutdown hooks before closing down in order to have
+// an orderly exit
 func Exit(errorCondition ExitStatus) int {
 	globals.LoaderWg.Wait()
+	hooksMutex.Lock()
+	toRun := hooks
+	hooks = nil
+	hooksMutex.Unlock()
+	for _, hook := range toRun {
+		hook()
+	}
+
 	g := globals.GetGlobalRef()
 	if g.JacobinName == "test" {
 		if errorCondition == OK {
@@ -77,3 +77,17 @@ func TestShutdownReturn(t *testing.T) {
 		t.Errorf("Expecting exit() return value of 0, but got %d", ret)
 	}
 }
+
+// Exit() runs the functions added by AddHook(), each of them once
+func TestShutdownRunsHooks(t *testing.T) {
+	globals.InitGlobals("test")
+	globals.GetGlobalRef().JacobinName = "test"
+
+	runs := 0
+	AddHook(func() { runs++ })
+	Exit(OK)
+	Exit(OK)
+	if runs != 1 {
+		t.Errorf("Expected the hook to run once, but it ran %d times", runs)
+	}
+}
@@ -8,36 +8,308 @@ package thread
 
 import (
 	"container/list"
+	"jacobin/frames"
 	"jacobin/globals"
+	"jacobin/object"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // Creates a JVM program execution thread. These threads are extremely limited.
 // They basically hold a Stack of frames. They push and popFrame frames as required.
 // They begin execution; they exit when execution ends; and they emit diagnostic
 // and performance data.
+//
+// Each thread other than the main thread runs on its own goroutine, which Start()
+// launches. The JVM exits once the main thread and all the started threads that
+// are not daemon threads have finished (see WaitForNonDaemonThreads()).
 
 type ExecThread struct {
-	ID    int        // the thread ID
-	Stack *list.List // the JVM Stack (frame stack, that is) for this thread
-	PC    int        // the program counter (the index to the instruction being executed)
-	Trace bool       // do we Trace instructions?
+	ID     int            // the thread ID
+	Name   string         // the thread's name, as returned by Thread.getName()
+	Stack  *list.List     // the JVM Stack (frame stack, that is) for this thread
+	PC     int            // the program counter (the index to the instruction being executed)
+	Trace  bool           // do we Trace instructions?
+	Daemon bool           // daemon threads don't keep the JVM running
+	Object *object.Object // the java.lang.Thread object for this thread, if there is one
+	state  int32          // New, Runnable, or Terminated; accessed atomically
+	done   chan struct{}  // closed when the thread terminates
+
+	table *globals.ThreadList // the thread table the thread was added to, if any
+
+	waiting atomic.Value // the thread's waitState, which SetWaitState() sets
+
+	published   atomic.Value // the []frames.Frame that PublishStack() last published
+	stackWanted int32        // 1 if RequestStack() has asked for the stack; accessed atomically
+
+	interrupted int32         // the interrupt status: 1 if interrupted; accessed atomically
+	interrupts  chan struct{} // signalled when the thread is interrupted, to wake it if it's waiting
 }
 
+// waitState is what a thread that's blocked or waiting records about itself
+type waitState struct {
+	state   int32          // Blocked, Waiting, or TimedWaiting; or Runnable once the thread resumes
+	blocker *object.Object // the object whose monitor the thread waits to lock or waits on, if any
+}
+
+// the states of a thread, as in java.lang.Thread.State. A started thread that has
+// not terminated is Runnable unless it's Blocked, Waiting, or TimedWaiting.
+const (
+	New          = iota // not yet started
+	Runnable            // started and not yet terminated
+	Terminated          // finished execution
+	Blocked             // waiting to lock a monitor
+	Waiting             // waiting with no timeout: in Object.wait() or Thread.join()
+	TimedWaiting        // waiting with a timeout, or sleeping
+)
+
+// nonDaemons counts the started threads that are not daemon threads and are still running
+var nonDaemons sync.WaitGroup
+
 func CreateThread() ExecThread {
 	t := ExecThread{}
 	t.ID = 0
 	t.PC = 0
 	t.Stack = nil
 	t.Trace = false
+	t.done = make(chan struct{})
+	t.interrupts = make(chan struct{}, 1)
 	return t
 }
 
+// Start runs body, which executes the thread's frames, on a new goroutine. It
+// returns false if the thread has already been started.
+func (t *ExecThread) Start(body func()) bool {
+	if !atomic.CompareAndSwapInt32(&t.state, New, Runnable) {
+		return false
+	}
+	daemon := t.Daemon
+	if !daemon {
+		nonDaemons.Add(1)
+	}
+	go func() {
+		defer func() {
+			t.terminate()
+			if !daemon {
+				nonDaemons.Done()
+			}
+		}()
+		body()
+	}()
+	return true
+}
+
+// Run runs body on the current goroutine. It's used for the main thread, which
+// the JVM runs itself, rather than starting it. It returns false if the thread
+// has already been started.
+func (t *ExecThread) Run(body func()) bool {
+	if !atomic.CompareAndSwapInt32(&t.state, New, Runnable) {
+		return false
+	}
+	defer t.terminate()
+	body()
+	return true
+}
+
+// terminate marks the thread as terminated, removes it from the thread table, and
+// releases the threads that join it
+func (t *ExecThread) terminate() {
+	atomic.StoreInt32(&t.state, Terminated)
+	if tbl := t.table; tbl != nil {
+		tbl.ThreadsMutex.Lock()
+		if e, ok := tbl.ThreadsByID[t.ID]; ok && e.Value == t {
+			tbl.ThreadsList.Remove(e)
+			delete(tbl.ThreadsByID, t.ID)
+		}
+		tbl.ThreadsMutex.Unlock()
+	}
+	close(t.done)
+}
+
+// State returns the thread's state: New, Terminated, or, if the thread is running,
+// Runnable, Blocked, Waiting, or TimedWaiting
+func (t *ExecThread) State() int32 {
+	state := atomic.LoadInt32(&t.state)
+	if state == Runnable {
+		state, _ = t.WaitState()
+	}
+	return state
+}
+
+// IsAlive returns true if the thread has been started and has not yet terminated
+func (t *ExecThread) IsAlive() bool {
+	return atomic.LoadInt32(&t.state) == Runnable
+}
+
+// SetWaitState records that the thread is about to block or wait: state is Blocked,
+// Waiting, or TimedWaiting, and obj is the object whose monitor the thread waits to
+// lock or waits on (or nil, as when it sleeps). Once it resumes, the thread calls
+// SetWaitState(Runnable, nil). The thread dump reports these.
+func (t *ExecThread) SetWaitState(state int32, obj *object.Object) {
+	t.waiting.Store(waitState{state, obj})
+}
+
+// WaitState returns what the thread last recorded with SetWaitState(): Runnable (and
+// nil) if it's not blocked or waiting
+func (t *ExecThread) WaitState() (int32, *object.Object) {
+	w, ok := t.waiting.Load().(waitState)
+	if !ok {
+		return Runnable, nil
+	}
+	return w.state, w.blocker
+}
+
+// Only the thread itself can read its frames safely, as it changes them while it runs.
+// So another thread that wants them, to make a thread dump, calls RequestStack() and
+// waits for the thread to publish copies of them with PublishStack(), which it does
+// between instructions, and before it blocks or waits. PublishedStack() returns them.
+
+// RequestStack asks the thread to publish its frames
+func (t *ExecThread) RequestStack() {
+	atomic.StoreInt32(&t.stackWanted, 1)
+}
+
+// StackWanted returns true if the thread has been asked to publish its frames and
+// has not yet done so
+func (t *ExecThread) StackWanted() bool {
+	return atomic.LoadInt32(&t.stackWanted) == 1
+}
+
+// PublishStack publishes copies of the thread's frames, from the top of its stack
+func (t *ExecThread) PublishStack(stack []frames.Frame) {
+	t.published.Store(stack)
+	atomic.StoreInt32(&t.stackWanted, 0)
+}
+
+// PublishedStack returns the frames that the thread last published, or nil if it hasn't
+func (t *ExecThread) PublishedStack() []frames.Frame {
+	stack, _ := t.published.Load().([]frames.Frame)
+	return stack
+}
+
+// Join waits for the thread to terminate, for no longer than timeout, unless timeout
+// is 0, in which case it waits as long as necessary. It returns immediately if the
+// thread has not been started. It returns true if the thread has terminated.
+func (t *ExecThread) Join(timeout time.Duration) bool {
+	switch atomic.LoadInt32(&t.state) {
+	case New:
+		return false
+	case Terminated:
+		return true
+	}
+	if timeout <= 0 {
+		<-t.done
+		return true
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-t.done:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// Done returns a channel that's closed when the thread terminates
+func (t *ExecThread) Done() <-chan struct{} {
+	return t.done
+}
+
+// Interrupt sets the thread's interrupt status and wakes the thread if it's sleeping
+// or waiting
+func (t *ExecThread) Interrupt() {
+	atomic.StoreInt32(&t.interrupted, 1)
+	select {
+	case t.interrupts <- struct{}{}:
+	default: // a wake-up is already pending
+	}
+}
+
+// IsInterrupted returns the thread's interrupt status
+func (t *ExecThread) IsInterrupted() bool {
+	return atomic.LoadInt32(&t.interrupted) == 1
+}
+
+// ClearInterrupt clears the thread's interrupt status and returns what it was
+func (t *ExecThread) ClearInterrupt() bool {
+	return atomic.SwapInt32(&t.interrupted, 0) == 1
+}
+
+// Interrupts returns the channel that's signalled when the thread is interrupted.
+// A signal can be stale (if the interrupt status was cleared since), so a thread
+// woken by it must check its interrupt status.
+func (t *ExecThread) Interrupts() <-chan struct{} {
+	return t.interrupts
+}
+
+// Sleep suspends the thread for the given time. It returns false if the thread is
+// interrupted before or while it sleeps, in which case the interrupt status is cleared.
+func (t *ExecThread) Sleep(d time.Duration) bool {
+	if d <= 0 {
+		return !t.ClearInterrupt()
+	}
+	return t.Await(nil, d)
+}
+
+// Await suspends the thread until done is closed or the timeout elapses, unless
+// timeout is 0, in which case it waits for done as long as necessary. It returns
+// false if the thread is interrupted before or while it waits, in which case the
+// interrupt status is cleared.
+func (t *ExecThread) Await(done <-chan struct{}, timeout time.Duration) bool {
+	if t.ClearInterrupt() {
+		return false
+	}
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	for {
+		select {
+		case <-done:
+			return true
+		case <-expired:
+			return true
+		case <-t.interrupts:
+			if t.ClearInterrupt() {
+				return false
+			} // else a stale signal, so keep waiting
+		}
+	}
+}
+
+// WaitForNonDaemonThreads waits until every started thread that is not a daemon
+// thread has terminated
+func WaitForNonDaemonThreads() {
+	nonDaemons.Wait()
+}
+
+// AddThreadToTable adds the thread to the thread table, giving it the next thread
+// ID, which it returns. IDs are not reused, since the monitors a thread has locked
+// record its ID.
 func AddThreadToTable(t *ExecThread, tbl *globals.ThreadList) int {
 	tbl.ThreadsMutex.Lock()
+	defer tbl.ThreadsMutex.Unlock()
 
-	tbl.ThreadsList.PushBack(t)
-	t.ID = tbl.ThreadsList.Len() - 1
-	tbl.ThreadsMutex.Unlock()
-
+	if tbl.ThreadsByID == nil {
+		tbl.ThreadsByID = make(map[int]*list.Element)
+	}
+	t.ID = tbl.NextID
+	tbl.NextID++
+	tbl.ThreadsByID[t.ID] = tbl.ThreadsList.PushBack(t)
+	t.table = tbl
 	return t.ID
 }
+
+// FindThread returns the thread in the thread table with the given ID, or nil if there's none
+func FindThread(id int, tbl *globals.ThreadList) *ExecThread {
+	tbl.ThreadsMutex.Lock()
+	defer tbl.ThreadsMutex.Unlock()
+	if e, ok := tbl.ThreadsByID[id]; ok {
+		return e.Value.(*ExecThread)
+	}
+	return nil
+}
@@ -11,6 +11,7 @@ import (
 	"jacobin/globals"
 	"sync"
 	"testing"
+	"time"
 )
 
 func TestCreateThread(t *testing.T) {
@@ -88,3 +89,145 @@ func add100threads(tbl *globals.ThreadList, wgrp *sync.WaitGroup) {
 	}
 	wgrp.Done() // decrements the wait group by 1.
 }
+
+// A started thread is alive until its body returns. It can't be started twice.
+func TestStartAndJoin(t *testing.T) {
+	th := CreateThread()
+	if th.IsAlive() || th.Join(0) {
+		t.Error("Expected a thread that hasn't been started to be neither alive nor joinable")
+	}
+
+	release := make(chan struct{})
+	if !th.Start(func() { <-release }) {
+		t.Fatal("Expected Start() to start the thread")
+	}
+	if !th.IsAlive() {
+		t.Error("Expected the started thread to be alive")
+	}
+	if th.Start(func() {}) {
+		t.Error("Expected the second Start() to fail")
+	}
+	if th.Join(10 * time.Millisecond) {
+		t.Error("Expected Join() to time out while the thread is blocked")
+	}
+
+	close(release)
+	if !th.Join(0) || th.IsAlive() || th.State() != Terminated {
+		t.Error("Expected the thread to have terminated after Join()")
+	}
+}
+
+// WaitForNonDaemonThreads() waits for the non-daemon threads, but not for daemon threads
+func TestWaitForNonDaemonThreads(t *testing.T) {
+	daemon := CreateThread()
+	daemon.Daemon = true
+	release := make(chan struct{})
+	daemon.Start(func() { <-release })
+	defer close(release)
+
+	worker := CreateThread()
+	finished := false
+	worker.Start(func() {
+		time.Sleep(10 * time.Millisecond)
+		finished = true
+	})
+
+	WaitForNonDaemonThreads()
+	if !finished {
+		t.Error("Expected WaitForNonDaemonThreads() to wait for the non-daemon thread")
+	}
+	if !daemon.IsAlive() {
+		t.Error("Expected the daemon thread to be still running")
+	}
+}
+
+// FindThread() finds threads in the thread table by ID
+func TestFindThread(t *testing.T) {
+	tbl := globals.ThreadList{ThreadsList: list.New()}
+	first, second := CreateThread(), CreateThread()
+	AddThreadToTable(&first, &tbl)
+	AddThreadToTable(&second, &tbl)
+
+	if FindThread(1, &tbl) != &second {
+		t.Error("Expected FindThread(1) to find the second thread")
+	}
+	if FindThread(2, &tbl) != nil {
+		t.Error("Expected FindThread(2) to find no thread")
+	}
+}
+
+// A thread is removed from the thread table when it terminates, and its ID is not reused
+func TestTerminatedThreadLeavesTable(t *testing.T) {
+	tbl := globals.ThreadList{ThreadsList: list.New()}
+	th := CreateThread()
+	AddThreadToTable(&th, &tbl)
+	th.Run(func() {
+		if FindThread(0, &tbl) != &th {
+			t.Error("Expected the running thread to be in the thread table")
+		}
+	})
+
+	if FindThread(0, &tbl) != nil || tbl.ThreadsList.Len() != 0 {
+		t.Error("Expected the terminated thread to be removed from the thread table")
+	}
+	next := CreateThread()
+	if id := AddThreadToTable(&next, &tbl); id != 1 {
+		t.Errorf("Expected the next thread to get ID 1, got %d", id)
+	}
+}
+
+// Await() returns true when the channel is closed or the timeout elapses, and false,
+// clearing the interrupt status, when the thread is interrupted
+func TestAwaitAndInterrupt(t *testing.T) {
+	th := CreateThread()
+	done := make(chan struct{})
+	close(done)
+	if !th.Await(done, 0) || !th.Await(nil, time.Millisecond) {
+		t.Error("Expected Await() to return true when not interrupted")
+	}
+
+	th.Interrupt()
+	if !th.IsInterrupted() || th.Sleep(time.Hour) || th.IsInterrupted() {
+		t.Error("Expected Sleep() to return false at once and clear a pending interrupt")
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		th.Interrupt()
+	}()
+	if th.Await(nil, 0) {
+		t.Error("Expected Await() to return false when the thread is interrupted")
+	}
+
+	// a signal left over from an interrupt that was already cleared doesn't wake the thread
+	th.Interrupt()
+	th.ClearInterrupt()
+	if !th.Sleep(5 * time.Millisecond) {
+		t.Error("Expected Sleep() to ignore a stale interrupt signal")
+	}
+}
+
+// A running thread's state is the wait state it records, and IsAlive() is still true
+func TestSetWaitState(t *testing.T) {
+	th := CreateThread()
+	release := make(chan struct{})
+	th.Start(func() { <-release })
+
+	if th.State() != Runnable {
+		t.Errorf("Expected a started thread to be Runnable, got: %d", th.State())
+	}
+	th.SetWaitState(Waiting, nil)
+	if th.State() != Waiting || !th.IsAlive() {
+		t.Errorf("Expected a waiting thread to be Waiting and alive, got: %d", th.State())
+	}
+	th.SetWaitState(Runnable, nil)
+	if th.State() != Runnable {
+		t.Errorf("Expected the thread to be Runnable once it resumes, got: %d", th.State())
+	}
+
+	close(release)
+	th.Join(0)
+	if th.State() != Terminated {
+		t.Errorf("Expected the thread to be Terminated, got: %d", th.State())
+	}
+}
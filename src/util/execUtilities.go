@@ -50,6 +50,11 @@ func ParseIncomingParamsFromMethTypeString(s string) []string {
             // i is now pointing to the primitive in the array
             elements = append(elements, paramChars[i])
             params = append(params, string(elements))
+            if paramChars[i] == 'L' { // an array of objects: skip over the class name
+                for paramChars[i] != ';' {
+                    i += 1
+                }
+            }
         }
     }
     return params
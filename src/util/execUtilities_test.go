@@ -53,3 +53,18 @@ func TestParseIncomingReferenceParamsFromMethType(t *testing.T) {
 		t.Errorf("Expected param string of 'LLJJ', got: %s", params)
 	}
 }
+
+// test that the class name in an array of references is skipped, so that
+// letters in the name (such as the S in String) are not parsed as parameters
+func TestParseIncomingReferenceArrayParamsFromMethType(t *testing.T) {
+	res := ParseIncomingParamsFromMethTypeString("([Ljava/lang/String;I[[LSample;)V")
+	if len(res) != 3 {
+		t.Errorf("Expected 3 parsed parameters, got %d", len(res))
+		return
+	}
+
+	var params string = res[0] + res[1] + res[2]
+	if params != "[LI[[L" {
+		t.Errorf("Expected param string of '[LI[[L', got: %s", params)
+	}
+}